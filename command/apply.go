@@ -3,9 +3,13 @@ package command
 import (
 	"bytes"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"runtime/debug"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-getter"
 	"github.com/hashicorp/go-multierror"
@@ -27,31 +31,62 @@ type ApplyCommand struct {
 	ShutdownCh <-chan struct{}
 }
 
-func (c *ApplyCommand) Run(args []string) int {
-	var destroyForce, refresh bool
+func (c *ApplyCommand) Run(args []string) (code int) {
+	var destroyForce, refresh, cont, allowEmpty, overridePrevent bool
+	var planId, planOut, versionMismatch, policyCommand string
+	var refreshSkip, replace []string
+	var lockTimeout time.Duration
 	args = c.Meta.process(args, true)
 
+	// Call the hook once everything else, such as unlocking, is done
+	defer func() { c.postOperation(code) }()
+
+	// Output any warnings collected during the operation once it is done
+	defer c.showWarnings()
+
+	// Release the state lock acquired by Context once we're done
+	defer c.unlockState()
+
 	cmdName := "apply"
 	if c.Destroy {
 		cmdName = "destroy"
 	}
 
+	// Capture the logs of the operation if requested
+	defer c.captureLogs(cmdName)()
+
 	cmdFlags := c.Meta.flagSet(cmdName)
+	cmdFlags.BoolVar(&destroyForce, "force", false, "force")
+	cmdFlags.BoolVar(&allowEmpty, "allow-empty", false, "allow-empty")
+	cmdFlags.BoolVar(&refresh, "refresh", true, "refresh")
+	cmdFlags.BoolVar(&c.Meta.quiet, "quiet", false, "quiet")
+	cmdFlags.Var((*FlagStringSlice)(&refreshSkip), "refresh-skip", "resource to skip refreshing")
+	cmdFlags.StringVar(&policyCommand, "policy-command", "", "policy-command")
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
+	c.addCompactWarningsFlag(cmdFlags)
 	if c.Destroy {
-		cmdFlags.BoolVar(&destroyForce, "force", false, "force")
+		cmdFlags.BoolVar(&overridePrevent, "override-prevent-destroy", false, "override-prevent-destroy")
+	} else {
+		cmdFlags.BoolVar(&cont, "continue", false, "continue")
+		cmdFlags.Var((*FlagStringSlice)(&replace), "replace", "resource to replace")
+		cmdFlags.StringVar(&planId, "plan-id", "", "plan-id")
+		cmdFlags.StringVar(&planOut, "plan-out", "", "path")
+		cmdFlags.StringVar(&versionMismatch, "version-mismatch", "warn", "version-mismatch")
 	}
-	cmdFlags.BoolVar(&refresh, "refresh", true, "refresh")
 	cmdFlags.IntVar(
 		&c.Meta.parallelism, "parallelism", DefaultParallelism, "parallelism")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.StringVar(&c.Meta.stateOutPath, "state-out", "", "path")
 	cmdFlags.StringVar(&c.Meta.backupPath, "backup", "", "path")
+	cmdFlags.StringVar(&c.Meta.backupDir, "backup-dir", "", "path")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
 
-	pwd, err := os.Getwd()
+	// The working directory given with -chdir, which the configuration is
+	// installed into if it's fetched before applying it.
+	pwd, err := c.pwd()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		return 1
@@ -74,7 +109,8 @@ func (c *ApplyCommand) Run(args []string) int {
 	// Prepare the extra hooks to count resources
 	countHook := new(CountHook)
 	stateHook := new(StateHook)
-	c.Meta.extraHooks = []terraform.Hook{countHook, stateHook}
+	progressHook := new(ApplyProgressHook)
+	c.Meta.extraHooks = []terraform.Hook{countHook, stateHook, progressHook}
 
 	if !c.Destroy && maybeInit {
 		// Do a detect to determine if we need to do an init + apply.
@@ -91,12 +127,12 @@ func (c *ApplyCommand) Run(args []string) int {
 				return code
 			}
 
-			// Change the config path to be the cwd
+			// Change the config path to be the working directory
 			configPath = pwd
 		}
 	}
 
-	terraform.SetDebugInfo(DefaultDataDir)
+	terraform.SetDebugInfo(c.DataDir())
 
 	// Check for the legacy graph
 	if experiment.Enabled(experiment.X_legacyGraph) {
@@ -111,32 +147,140 @@ func (c *ApplyCommand) Run(args []string) int {
 	// This is going to keep track of shadow errors
 	var shadowErr error
 
+	// Load the progress of the previous apply if we're continuing it
+	var progress *ApplyProgress
+	progressPath := filepath.Join(c.stateDataDir(), DefaultApplyProgressFilename)
+	if cont {
+		progress, err = ReadApplyProgress(progressPath)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+	}
+
+	switch versionMismatch {
+	case "", "warn", "error":
+	default:
+		c.Ui.Error(fmt.Sprintf(
+			"Invalid -version-mismatch value %q. Valid values are \"warn\" and \"error\".",
+			versionMismatch))
+		return 1
+	}
+
 	// Build the context based on the arguments given
 	ctx, planned, err := c.Context(contextOpts{
-		Destroy:     c.Destroy,
-		Path:        configPath,
-		StatePath:   c.Meta.statePath,
-		Parallelism: c.Meta.parallelism,
+		Destroy:         c.Destroy,
+		Path:            configPath,
+		PathEmptyOk:     allowEmpty,
+		StatePath:       c.Meta.statePath,
+		Parallelism:     c.Meta.parallelism,
+		RefreshSkip:     refreshSkip,
+		ForceReplace:    replace,
+		PlanId:          planId,
+		VersionMismatch: versionMismatch,
+		Progress:        progress,
+		Lock:            true,
+		LockTimeout:     lockTimeout,
+		Operation:       cmdName,
+		WritesState:     true,
+
+		OverridePreventDestroy: overridePrevent,
+		SkipProviderConfig:     !refresh,
 	})
 	if err != nil {
 		c.Ui.Error(err.Error())
 		return 1
 	}
+	if planId != "" && !planned {
+		c.Ui.Error("The -plan-id flag can only be used when applying a plan file.")
+		return 1
+	}
+	if planOut != "" && planned {
+		c.Ui.Error(
+			"The -plan-out flag can't be used when applying a plan file, since\n" +
+				"the plan being applied is already saved in it.")
+		return 1
+	}
+	if len(replace) > 0 && planned {
+		c.Ui.Error(
+			"The -replace flag can't be used when applying a plan file, since\n" +
+				"the plan already records what it replaces. Use it with plan instead.")
+		return 1
+	}
+	if cont && !planned {
+		c.Ui.Error(
+			"The -continue flag can only be used when applying a plan file. Without\n" +
+				"a plan file, apply creates a new plan from the current state, which\n" +
+				"already includes any resources that were applied previously.")
+		return 1
+	}
+	if cont && progress == nil {
+		c.Ui.Error(fmt.Sprintf(
+			"There is no apply to continue: no progress was recorded in %s.\n"+
+				"Either no apply of a plan failed partway through, or the progress\n"+
+				"was removed. Apply the plan without -continue to apply all of it.",
+			progressPath))
+		return 1
+	}
+
+	c.outputOperationHeader()
+
+	// Record the progress of applying a plan file so that the apply can
+	// be continued if it fails partway through.
+	if planned && !c.Destroy {
+		if progress == nil {
+			id, err := c.plan.Id()
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+
+			progress = &ApplyProgress{PlanId: id}
+		} else if len(progress.Applied) > 0 {
+			c.Ui.Output(c.Colorize().Color(fmt.Sprintf(
+				"[reset][bold]Continuing the apply of plan %s. Resources that were\n"+
+					"already applied with an unchanged plan are skipped.\n",
+				progress.PlanId)))
+		}
+
+		if err := progressHook.SetDiff(c.plan.Diff); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error preparing apply progress: %s", err))
+			return 1
+		}
+		progressHook.Path = progressPath
+		progressHook.Progress = progress
+	}
 	if c.Destroy && planned {
 		c.Ui.Error(fmt.Sprintf(
 			"Destroy can't be called with a plan file."))
 		return 1
 	}
-	if !destroyForce && c.Destroy {
+
+	// A destroy plan is applied just like running destroy, so it is
+	// confirmed and reported the same way.
+	destroy := c.Destroy
+	targets := c.Meta.targets
+	if planned && c.plan.Destroy {
+		destroy = true
+		targets = c.plan.Targets
+	}
+
+	if !destroyForce && destroy {
 		// Default destroy message
 		desc := "Terraform will delete all your managed infrastructure.\n" +
 			"There is no undo. Only 'yes' will be accepted to confirm."
+		if planned {
+			desc = fmt.Sprintf(
+				"The plan %s destroys all your managed infrastructure.\n"+
+					"There is no undo. Only 'yes' will be accepted to confirm.",
+				configPath)
+		}
 
 		// If targets are specified, list those to user
-		if c.Meta.targets != nil {
+		if targets != nil {
 			var descBuffer bytes.Buffer
 			descBuffer.WriteString("Terraform will delete the following infrastructure:\n")
-			for _, target := range c.Meta.targets {
+			for _, target := range targets {
 				descBuffer.WriteString("\t")
 				descBuffer.WriteString(target)
 				descBuffer.WriteString("\n")
@@ -171,11 +315,12 @@ func (c *ApplyCommand) Run(args []string) int {
 				err, "input operation:"))
 		}
 	}
-	if !validateContext(ctx, c.Ui) {
+	if err := c.validateContext(ctx); err != nil {
 		return 1
 	}
 
 	// Plan if we haven't already
+	plan := c.plan
 	if !planned {
 		if refresh {
 			if _, err := ctx.Refresh(); err != nil {
@@ -184,7 +329,8 @@ func (c *ApplyCommand) Run(args []string) int {
 			}
 		}
 
-		if _, err := ctx.Plan(); err != nil {
+		plan, err = ctx.Plan()
+		if err != nil {
 			c.Ui.Error(fmt.Sprintf(
 				"Error creating plan: %s", err))
 			return 1
@@ -195,6 +341,70 @@ func (c *ApplyCommand) Run(args []string) int {
 			shadowErr = multierror.Append(shadowErr, multierror.Prefix(
 				err, "plan operation:"))
 		}
+
+		if err := c.postPlan(plan); err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+
+		// Save the plan before anything is applied, so that it can still
+		// be inspected if the apply fails.
+		if planOut != "" {
+			id, err := plan.Id()
+			if err != nil {
+				c.Ui.Error(err.Error())
+				return 1
+			}
+
+			log.Printf("[INFO] Writing plan %s output to: %s", id, planOut)
+			if err := writePlanFile(plan, planOut, c.planEncryptionKey()); err != nil {
+				c.Ui.Error(fmt.Sprintf("Error writing plan file: %s", err))
+				return 1
+			}
+			c.Ui.Output(fmt.Sprintf(
+				"The plan was saved to: %s\nPlan ID: %s\n", planOut, id))
+
+			// Record the progress like for an applied plan file, so that
+			// the saved plan can be continued instead of applied again.
+			if !c.Destroy {
+				if err := progressHook.SetDiff(plan.Diff); err != nil {
+					c.Ui.Error(fmt.Sprintf("Error preparing apply progress: %s", err))
+					return 1
+				}
+				progressHook.Path = progressPath
+				progressHook.Progress = &ApplyProgress{PlanId: id}
+			}
+		}
+	}
+
+	// Destroying resources that have lifecycle.prevent_destroy set is
+	// always confirmed, even with -force.
+	if overridePrevent {
+		if protected := preventDestroyResources(plan); len(protected) > 0 {
+			v, err := c.UIInput().Input(&terraform.InputOpts{
+				Id:    "destroy-protected",
+				Query: "Do you really want to destroy these protected resources?",
+				Description: fmt.Sprintf(
+					"These resources have lifecycle.prevent_destroy set to true, but\n"+
+						"-override-prevent-destroy was given, so they will be destroyed:\n\n"+
+						"  %s\n\n"+
+						"There is no undo. Only 'yes' will be accepted to confirm.",
+					strings.Join(protected, "\n  ")),
+			})
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error asking for confirmation: %s", err))
+				return 1
+			}
+			if v != "yes" {
+				c.Ui.Output("Destroy cancelled.")
+				return 1
+			}
+		}
+	}
+
+	// Check the plan against the policies before changing anything
+	if !c.checkPlanPolicy(plan, policyCommand) {
+		return 1
 	}
 
 	// Setup the state hook for continuous state updates
@@ -215,6 +425,17 @@ func (c *ApplyCommand) Run(args []string) int {
 	doneCh := make(chan struct{})
 	go func() {
 		defer close(doneCh)
+
+		// A panic here would crash Terraform without persisting the state
+		// or releasing the lock, so turn it into an error of the apply.
+		defer func() {
+			if r := recover(); r != nil {
+				stack := debug.Stack()
+				log.Printf("[ERROR] Apply panicked: %s\n\n%s", r, stack)
+				applyErr = fmt.Errorf("apply panicked: %s\n\n%s", r, stack)
+			}
+		}()
+
 		state, applyErr = ctx.Apply()
 
 		// Record any shadow errors for later
@@ -250,6 +471,11 @@ func (c *ApplyCommand) Run(args []string) int {
 	if state != nil {
 		if err := c.Meta.PersistState(state); err != nil {
 			c.Ui.Error(fmt.Sprintf("Failed to save state: %s", err))
+			if applyErr != nil {
+				c.Ui.Error(fmt.Sprintf(
+					"\nThe apply also failed:\n\n%s", multierror.Flatten(applyErr)))
+			}
+			c.outputPlanOutNote(planOut)
 			return 1
 		}
 	}
@@ -263,10 +489,24 @@ func (c *ApplyCommand) Run(args []string) int {
 				"any resources that successfully completed. Please address the error\n"+
 				"above and apply again to incrementally change your infrastructure.",
 			multierror.Flatten(applyErr)))
+		if planned {
+			c.Ui.Error(
+				"\nTo apply the rest of this plan file without applying the resources\n" +
+					"that completed again, run apply with the -continue flag.")
+		}
+		c.outputPlanOutNote(planOut)
 		return 1
 	}
 
-	if c.Destroy {
+	// The plan was fully applied so there is nothing left to continue
+	if (planned || planOut != "") && !c.Destroy {
+		if err := os.Remove(progressPath); err != nil && !os.IsNotExist(err) {
+			c.Ui.Error(fmt.Sprintf("Error removing apply progress: %s", err))
+			return 1
+		}
+	}
+
+	if destroy {
 		c.Ui.Output(c.Colorize().Color(fmt.Sprintf(
 			"[reset][bold][green]\n"+
 				"Destroy complete! Resources: %d destroyed.",
@@ -291,7 +531,7 @@ func (c *ApplyCommand) Run(args []string) int {
 			c.Meta.StateOutPath())))
 	}
 
-	if !c.Destroy {
+	if !destroy {
 		if outputs := outputsAsString(state, terraform.RootModulePath, ctx.Module().Config().Outputs, true); outputs != "" {
 			c.Ui.Output(c.Colorize().Color(outputs))
 		}
@@ -303,6 +543,92 @@ func (c *ApplyCommand) Run(args []string) int {
 	return 0
 }
 
+// checkPlanPolicy checks the plan against the policy set on Meta and the
+// policy command given with the -policy-command flag, outputting the error
+// if it is rejected. It returns whether the plan can be applied.
+func (c *ApplyCommand) checkPlanPolicy(plan *terraform.Plan, command string) bool {
+	var policies []PlanPolicy
+	if c.PlanPolicy != nil {
+		policies = append(policies, c.PlanPolicy)
+	}
+	if command != "" {
+		policies = append(policies, PlanPolicyCommand(command))
+	}
+
+	for _, policy := range policies {
+		if err := policy(plan); err != nil {
+			c.Ui.Error(err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+// preventDestroyResources returns the addresses of the resources that the
+// plan destroys even though they have lifecycle.prevent_destroy set,
+// which is only possible if the check was overridden.
+func preventDestroyResources(plan *terraform.Plan) []string {
+	if plan == nil || plan.Diff == nil || plan.Module == nil {
+		return nil
+	}
+
+	var result []string
+	for _, m := range plan.Diff.Modules {
+		tree := plan.Module.Child(m.Path[1:])
+		if tree == nil {
+			continue
+		}
+
+		prefix := ""
+		for _, name := range m.Path[1:] {
+			prefix += "module." + name + "."
+		}
+
+		for name, rdiff := range m.Resources {
+			if !rdiff.GetDestroy() {
+				continue
+			}
+
+			for _, r := range tree.Config().Resources {
+				id := r.Id()
+				if r.Lifecycle.PreventDestroy &&
+					(name == id || strings.HasPrefix(name, id+".")) {
+					result = append(result, prefix+name)
+					break
+				}
+			}
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// outputPlanOutNote outputs where the plan that failed to apply was saved
+// with -plan-out, if it was, and how to apply the rest of it.
+func (c *ApplyCommand) outputPlanOutNote(planOut string) {
+	if planOut == "" {
+		return
+	}
+
+	if c.Destroy {
+		c.Ui.Error(fmt.Sprintf(
+			"\nThe plan that was being applied is saved in %s. It can be\n"+
+				"inspected with \"terraform show %s\". It must not be applied,\n"+
+				"since some of it was; run destroy again to destroy the rest.",
+			planOut, planOut))
+		return
+	}
+
+	c.Ui.Error(fmt.Sprintf(
+		"\nThe plan that was being applied is saved in %s. It can be\n"+
+			"inspected with \"terraform show %s\". To apply the rest of it\n"+
+			"without applying the resources that completed again, run:\n\n"+
+			"    terraform apply -continue %s",
+		planOut, planOut, planOut))
+}
+
 func (c *ApplyCommand) Help() string {
 	if c.Destroy {
 		return c.helpDestroy()
@@ -339,23 +665,86 @@ Usage: terraform apply [options] [DIR-OR-PLAN]
 
 Options:
 
+  -allow-empty           Allow the configuration directory to have no
+                         Terraform configuration files, which destroys
+                         everything in the state.
+
   -backup=path           Path to backup the existing state file before
                          modifying. Defaults to the "-state-out" path with
                          ".backup" extension. Set to "-" to disable backup.
 
+  -backup-dir=path       Directory to write the backup to, keeping its name.
+                         By default, it is written next to the state, or to
+                         within .terraform with a warning if that fails.
+
+  -compact-warnings=true
+                         Output warnings that differ only in what they're
+                         for, such as the same deprecated argument in many
+                         resources, as one warning with how many more there are.
+
+  -continue              Continue a previous apply of the given plan file that
+                         failed partway through. Resources that were already
+                         applied are not applied again. Fails if no progress
+                         was recorded.
+
+  -force                 Don't ask for confirmation when applying a plan
+                         file created with "plan -destroy".
+
   -input=true            Ask for input for variables if not directly set.
 
+  -input-cache=true      Reuse the answers given for provider configuration
+                         by earlier runs, saved in .terraform/input.json.
+                         Credentials are never saved.
+
+  -lock-takeover=0s      Take over a state lock older than this that was
+                         acquired by a process on this host that is no longer
+                         running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s       Duration to retry a state lock held by another
+                         operation before giving up.
+
   -no-color              If specified, output won't contain any color.
 
   -parallelism=n         Limit the number of concurrent operations.
                          Defaults to 10.
 
+  -quiet                 Don't output which state file or remote state the
+                         operation uses.
+
+  -policy-command=cmd    Run cmd with the plan as JSON on stdin before
+                         applying it. If cmd exits with a non-zero status,
+                         nothing is applied.
+
+  -plan-id=id            The ID of the plan shown by "terraform plan -out".
+                         If given, the plan file being applied must have this
+                         ID or apply will fail without making any changes.
+
+  -plan-out=path         Save the plan that apply creates to this path before
+                         applying it, so that it can be inspected if the apply
+                         fails, and the rest of it applied with -continue.
+                         Can't be used when applying a plan file.
+
   -refresh=true          Update state prior to checking for differences. This
                          has no effect if a plan file is given to apply.
 
+  -refresh-skip=res      Resource address to leave out of the refresh. The
+                         resource is still planned using its current state.
+                         This flag can be used multiple times.
+
+  -replace=res           Resource address to replace: it is destroyed and
+                         created again, even if its configuration hasn't
+                         changed. This flag can be used multiple times,
+                         but not when applying a plan file.
+
   -state=path            Path to read and save state (unless state-out
                          is specified). Defaults to "terraform.tfstate".
 
+  -state-lock-file=path
+                         Path of the lock file of the local state. Defaults to
+                         a file next to the state, such as
+                         ".terraform.tfstate.lock.info". Every operation on
+                         the state must use the same lock file.
+
   -state-out=path        Path to write state to that is different than
                          "-state". This can be used to preserve the old
                          state.
@@ -364,12 +753,23 @@ Options:
                          resource and its dependencies. This flag can be used
                          multiple times.
 
+  -target-file=path      File with a resource to target on each line, in
+                         addition to those given with -target. Blank lines and
+                         comments starting with # are ignored. This flag can be
+                         used multiple times.
+
   -var 'foo=bar'         Set a variable in the Terraform configuration. This
                          flag can be set multiple times.
 
   -var-file=foo          Set variables in the Terraform configuration from
-                         a file. If "terraform.tfvars" is present, it will be
-                         automatically loaded if this flag is not specified.
+                         a file. If "terraform.tfvars" or any ".auto.tfvars"
+                         files are present, they will be automatically loaded.
+                         A directory or glob pattern loads all the matching
+                         files, in lexical order.
+
+  -version-mismatch=warn What to do when the plan file being applied was
+                         created by a different version of Terraform. Either
+                         "warn" to continue with a warning, or "error".
 
 
 `
@@ -384,23 +784,65 @@ Usage: terraform destroy [options] [DIR]
 
 Options:
 
+  -allow-empty           Allow the configuration directory to have no
+                         Terraform configuration files, which destroys
+                         everything in the state.
+
   -backup=path           Path to backup the existing state file before
                          modifying. Defaults to the "-state-out" path with
                          ".backup" extension. Set to "-" to disable backup.
 
+  -backup-dir=path       Directory to write the backup to, keeping its name.
+                         By default, it is written next to the state, or to
+                         within .terraform with a warning if that fails.
+
+  -compact-warnings=true
+                         Output warnings that differ only in what they're
+                         for, such as the same deprecated argument in many
+                         resources, as one warning with how many more there are.
+
   -force                 Don't ask for input for destroy confirmation.
 
+  -lock-takeover=0s      Take over a state lock older than this that was
+                         acquired by a process on this host that is no longer
+                         running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s       Duration to retry a state lock held by another
+                         operation before giving up.
+
   -no-color              If specified, output won't contain any color.
 
+  -override-prevent-destroy
+                         Destroy resources even if they have
+                         lifecycle.prevent_destroy set. They are listed
+                         and must be confirmed, even with -force.
+
   -parallelism=n         Limit the number of concurrent operations.
                          Defaults to 10.
 
+  -quiet                 Don't output which state file or remote state the
+                         operation uses.
+
+  -policy-command=cmd    Run cmd with the plan as JSON on stdin before
+                         applying it. If cmd exits with a non-zero status,
+                         nothing is applied.
+
   -refresh=true          Update state prior to checking for differences. This
                          has no effect if a plan file is given to apply.
 
+  -refresh-skip=res      Resource address to leave out of the refresh. The
+                         resource is still planned using its current state.
+                         This flag can be used multiple times.
+
   -state=path            Path to read and save state (unless state-out
                          is specified). Defaults to "terraform.tfstate".
 
+  -state-lock-file=path
+                         Path of the lock file of the local state. Defaults to
+                         a file next to the state, such as
+                         ".terraform.tfstate.lock.info". Every operation on
+                         the state must use the same lock file.
+
   -state-out=path        Path to write state to that is different than
                          "-state". This can be used to preserve the old
                          state.
@@ -409,12 +851,19 @@ Options:
                          resource and its dependencies. This flag can be used
                          multiple times.
 
+  -target-file=path      File with a resource to target on each line, in
+                         addition to those given with -target. Blank lines and
+                         comments starting with # are ignored. This flag can be
+                         used multiple times.
+
   -var 'foo=bar'         Set a variable in the Terraform configuration. This
                          flag can be set multiple times.
 
   -var-file=foo          Set variables in the Terraform configuration from
-                         a file. If "terraform.tfvars" is present, it will be
-                         automatically loaded if this flag is not specified.
+                         a file. If "terraform.tfvars" or any ".auto.tfvars"
+                         files are present, they will be automatically loaded.
+                         A directory or glob pattern loads all the matching
+                         files, in lexical order.
 
 
 `
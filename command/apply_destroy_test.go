@@ -1,7 +1,9 @@
 package command
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -92,6 +94,57 @@ func TestApply_destroy(t *testing.T) {
 	}
 }
 
+func TestApply_destroyAllowEmpty(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Destroy: true,
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// Without the flag, the empty directory is an error
+	args := []string{
+		"-force",
+		"-state", statePath,
+		dir,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+
+	ui = new(cli.MockUi)
+	c = &ApplyCommand{
+		Destroy: true,
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = append([]string{"-allow-empty"}, args...)
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	state := testStateRead(t, statePath)
+	if len(state.RootModule().Resources) != 0 {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
 func TestApply_destroyPlan(t *testing.T) {
 	planPath := testPlanFile(t, &terraform.Plan{
 		Module: testModule(t, "apply"),
@@ -209,3 +262,104 @@ func TestApply_destroyTargeted(t *testing.T) {
 const testApplyDestroyStr = `
 <no state>
 `
+
+func testPreventDestroyState() *terraform.State {
+	resources := make(map[string]*terraform.ResourceState)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		resources["test_instance."+name] = &terraform.ResourceState{
+			Type:    "test_instance",
+			Primary: &terraform.InstanceState{ID: "i-" + name},
+		}
+	}
+
+	return &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path:      []string{"root"},
+				Resources: resources,
+			},
+		},
+	}
+}
+
+func TestApply_destroyPreventDestroy(t *testing.T) {
+	statePath := testStateFile(t, testPreventDestroyState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Destroy: true,
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-state", statePath,
+		testFixturePath("destroy-prevent-destroy"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+
+	// Both protected resources are listed in the one error
+	path := filepath.Join(testFixturePath("destroy-prevent-destroy"), "main.tf")
+	expected := fmt.Sprintf(
+		"\n  test_instance.bar (%s:7)\n  test_instance.foo (%s:1)\n", path, path)
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestApply_destroyOverridePreventDestroy(t *testing.T) {
+	cases := []struct {
+		Answer string
+		Code   int
+	}{
+		{"no", 1},
+		{"yes", 0},
+	}
+
+	for _, tc := range cases {
+		statePath := testStateFile(t, testPreventDestroyState())
+
+		p := testProvider()
+		input := &terraform.MockUIInput{
+			InputReturnMap: map[string]string{"destroy-protected": tc.Answer},
+		}
+		ctxOpts := testCtxConfig(p)
+		ctxOpts.UIInput = input
+		ui := new(cli.MockUi)
+		c := &ApplyCommand{
+			Destroy: true,
+			Meta: Meta{
+				ContextOpts: ctxOpts,
+				Ui:          ui,
+			},
+		}
+
+		// The protected resources are confirmed even with -force
+		args := []string{
+			"-force",
+			"-override-prevent-destroy",
+			"-state", statePath,
+			testFixturePath("destroy-prevent-destroy"),
+		}
+		if code := c.Run(args); code != tc.Code {
+			t.Fatalf("%s: bad: %d\n\n%s", tc.Answer, code, ui.ErrorWriter.String())
+		}
+		if !strings.Contains(input.InputOpts.Description, "\n  test_instance.bar\n  test_instance.foo\n") {
+			t.Fatalf("%s: bad: %s", tc.Answer, input.InputOpts.Description)
+		}
+
+		state := testStateRead(t, statePath)
+		if empty := len(state.RootModule().Resources) == 0; empty != (tc.Code == 0) {
+			t.Fatalf("%s: bad: %s", tc.Answer, state)
+		}
+	}
+}
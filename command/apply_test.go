@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/terraform/state/remote"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
@@ -236,6 +237,116 @@ func TestApply_defaultState(t *testing.T) {
 	}
 }
 
+func TestApply_planOut(t *testing.T) {
+	statePath := testTempFile(t)
+	planOut := filepath.Join(testTempDir(t), "plan")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-plan-out", planOut,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	plan := testReadPlan(t, planOut)
+	if plan.Diff.Empty() {
+		t.Fatalf("bad: %s", plan)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "The plan was saved to: "+planOut) {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestApply_planOutApplyError(t *testing.T) {
+	statePath := testTempFile(t)
+	planOut := filepath.Join(testTempDir(t), "plan")
+
+	// The plan is saved before anything is applied
+	p := testProvider()
+	p.ApplyFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.InstanceDiff) (*terraform.InstanceState, error) {
+		if _, err := os.Stat(planOut); err != nil {
+			return nil, fmt.Errorf("plan not saved: %s", err)
+		}
+
+		return nil, fmt.Errorf("error")
+	}
+
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-plan-out", planOut,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	// The plan is left for inspecting what failed, and the error says
+	// where it is.
+	plan := testReadPlan(t, planOut)
+	if plan.Diff.Empty() {
+		t.Fatalf("bad: %s", plan)
+	}
+	output := ui.ErrorWriter.String()
+	if strings.Contains(output, "plan not saved") {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "The plan that was being applied is saved in "+planOut) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestApply_planOutPlanFile(t *testing.T) {
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module: testModule(t, "apply"),
+	})
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state-out", testTempFile(t),
+		"-plan-out", filepath.Join(testTempDir(t), "plan"),
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "-plan-out") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
 func TestApply_error(t *testing.T) {
 	statePath := testTempFile(t)
 
@@ -374,6 +485,46 @@ func TestApply_init(t *testing.T) {
 	}
 }
 
+func TestApply_initChdir(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	statePath := testTempFile(t)
+	ln := testHttpServer(t)
+	defer ln.Close()
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	var u url.URL
+	u.Scheme = "http"
+	u.Host = ln.Addr().String()
+	u.Path = "/header"
+
+	args := []string{
+		"-chdir=work",
+		"-state", statePath,
+		u.String(),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The configuration is installed into the working directory
+	if _, err := os.Stat(filepath.Join(tmp, "work", "hello.tf")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "hello.tf")); !os.IsNotExist(err) {
+		t.Fatalf("config should not be in the current directory: %v", err)
+	}
+}
+
 func TestApply_input(t *testing.T) {
 	// Disable test mode so input would be asked
 	test = false
@@ -431,33 +582,657 @@ func TestApply_inputPartial(t *testing.T) {
 
 	args := []string{
 		"-state", statePath,
-		"-var", "foo=foovalue",
-		testFixturePath("apply-input-partial"),
+		"-var", "foo=foovalue",
+		testFixturePath("apply-input-partial"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := strings.TrimSpace(`
+<no state>
+Outputs:
+
+bar = one
+foo = foovalue
+	`)
+	testStateOutput(t, statePath, expected)
+}
+
+func TestApply_noArgs(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(testFixturePath("plan")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	state, err := terraform.ReadState(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if state == nil {
+		t.Fatal("state should not be nil")
+	}
+}
+
+func TestApply_plan(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	// Set some default reader/writers for the inputs
+	defaultInputReader = new(bytes.Buffer)
+	defaultInputWriter = new(bytes.Buffer)
+
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module: testModule(t, "apply"),
+	})
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if p.InputCalled {
+		t.Fatalf("input should not be called for plans")
+	}
+
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	f, err := os.Open(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	state, err := terraform.ReadState(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if state == nil {
+		t.Fatal("state should not be nil")
+	}
+}
+
+func TestApply_continue(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	statePath := filepath.Join(tmp, DefaultStateFilename)
+	planPath := filepath.Join(tmp, "plan")
+	progressPath := filepath.Join(tmp, DefaultDataDir, DefaultApplyProgressFilename)
+
+	var lock sync.Mutex
+	var applied []string
+	fail := true
+
+	p := testProvider()
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		if s != nil && s.ID != "" {
+			return nil, nil
+		}
+
+		return &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": &terraform.ResourceAttrDiff{New: "bar"},
+			},
+		}, nil
+	}
+	p.ApplyFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		d *terraform.InstanceDiff) (*terraform.InstanceState, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if fail && info.Id == "test_instance.c" {
+			return nil, fmt.Errorf("failing %s", info.Id)
+		}
+
+		applied = append(applied, info.Id)
+		return &terraform.InstanceState{
+			ID:         info.Id,
+			Attributes: map[string]string{"ami": "bar"},
+		}, nil
+	}
+
+	planCmd := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          new(cli.MockUi),
+		},
+	}
+	args := []string{
+		"-state", statePath,
+		"-out", planPath,
+		testFixturePath("apply-continue"),
+	}
+	if code := planCmd.Run(args); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// The first apply fails applying the third resource
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state", statePath,
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "-continue") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if !reflect.DeepEqual(applied, []string{"test_instance.a", "test_instance.b"}) {
+		t.Fatalf("bad: %#v", applied)
+	}
+
+	progress, err := ReadApplyProgress(progressPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if progress == nil {
+		t.Fatal("progress should be recorded")
+	}
+	planId, err := testReadPlan(t, planPath).Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if progress.PlanId != planId {
+		t.Fatalf("bad: %#v", progress)
+	}
+	if len(progress.Applied) != 2 {
+		t.Fatalf("bad: %#v", progress)
+	}
+
+	// Continuing the apply only applies the remaining resources
+	lock.Lock()
+	fail = false
+	applied = nil
+	lock.Unlock()
+
+	ui = new(cli.MockUi)
+	c = &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state", statePath,
+		"-continue",
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !reflect.DeepEqual(applied, []string{"test_instance.c", "test_instance.d"}) {
+		t.Fatalf("bad: %#v", applied)
+	}
+
+	state := testStateRead(t, statePath)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		if _, ok := state.RootModule().Resources["test_instance."+k]; !ok {
+			t.Fatalf("test_instance.%s should be in the state:\n\n%s", k, state)
+		}
+	}
+
+	if _, err := os.Stat(progressPath); !os.IsNotExist(err) {
+		t.Fatalf("progress should be removed: %s", err)
+	}
+}
+
+func TestApply_planOutContinue(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	statePath := filepath.Join(tmp, DefaultStateFilename)
+	planOut := filepath.Join(tmp, "plan")
+
+	var lock sync.Mutex
+	var applied []string
+	fail := true
+
+	p := testProvider()
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		if s != nil && s.ID != "" {
+			return nil, nil
+		}
+
+		return &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": &terraform.ResourceAttrDiff{New: "bar"},
+			},
+		}, nil
+	}
+	p.ApplyFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		d *terraform.InstanceDiff) (*terraform.InstanceState, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if fail && info.Id == "test_instance.c" {
+			return nil, fmt.Errorf("failing %s", info.Id)
+		}
+
+		applied = append(applied, info.Id)
+		return &terraform.InstanceState{
+			ID:         info.Id,
+			Attributes: map[string]string{"ami": "bar"},
+		}, nil
+	}
+
+	// The first apply fails applying the third resource
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args := []string{
+		"-state", statePath,
+		"-plan-out", planOut,
+		testFixturePath("apply-continue"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "terraform apply -continue "+planOut) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// Continuing the saved plan only applies the remaining resources
+	lock.Lock()
+	fail = false
+	applied = nil
+	lock.Unlock()
+
+	ui = new(cli.MockUi)
+	c = &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state", statePath,
+		"-continue",
+		planOut,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !reflect.DeepEqual(applied, []string{"test_instance.c", "test_instance.d"}) {
+		t.Fatalf("bad: %#v", applied)
+	}
+}
+
+func TestApply_continueOtherPlan(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	planPath := testPlanFile(t, testPlan(t))
+	statePath := testTempFile(t)
+
+	progress := &ApplyProgress{
+		PlanId:  "other",
+		Applied: map[string]string{"test_instance.foo": "hash"},
+	}
+	progressPath := filepath.Join(
+		(&Meta{statePath: statePath}).stateDataDir(), DefaultApplyProgressFilename)
+	if err := progress.Write(progressPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-continue",
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "plan with ID other") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+func TestApply_continueNoProgress(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	planPath := testPlanFile(t, testPlan(t))
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-continue",
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "no progress was recorded") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+}
+
+// Applies to different states from the same directory each record their
+// progress in the data directory of their own state.
+func TestApply_continueStates(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	names := []string{"prod", "staging"}
+	errCh := make(chan error, len(names))
+	for _, name := range names {
+		go func(name string) {
+			errCh <- testApplyContinueState(tmp, name)
+		}(name)
+	}
+	for range names {
+		if err := <-errCh; err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	for _, name := range names {
+		statePath := filepath.Join(tmp, name+".tfstate")
+		dataDir := (&Meta{statePath: statePath}).stateDataDir()
+		if filepath.Dir(dataDir) != filepath.Join(DefaultDataDir, DefaultStatesDir) {
+			t.Fatalf("%s: bad: %s", name, dataDir)
+		}
+
+		progress, err := ReadApplyProgress(
+			filepath.Join(dataDir, DefaultApplyProgressFilename))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if progress == nil {
+			t.Fatalf("%s: progress should be recorded", name)
+		}
+		planId, err := testReadPlan(t, filepath.Join(tmp, name+".plan")).Id()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if progress.PlanId != planId || len(progress.Applied) != 2 {
+			t.Fatalf("%s: bad: %#v", name, progress)
+		}
+	}
+
+	// Nothing is recorded for the default state
+	defaultPath := filepath.Join(DefaultDataDir, DefaultApplyProgressFilename)
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Fatalf("progress should not be written: %s", err)
+	}
+}
+
+// testApplyContinueState plans and applies the apply-continue fixture to
+// the named state in dir, with the apply failing on the third resource.
+func testApplyContinueState(dir, name string) error {
+	statePath := filepath.Join(dir, name+".tfstate")
+	planPath := filepath.Join(dir, name+".plan")
+
+	p := testProvider()
+	p.ApplyFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		d *terraform.InstanceDiff) (*terraform.InstanceState, error) {
+		if info.Id == "test_instance.c" {
+			return nil, fmt.Errorf("failing %s", info.Id)
+		}
+
+		return &terraform.InstanceState{ID: info.Id}, nil
+	}
+
+	ui := new(cli.MockUi)
+	planCmd := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args := []string{
+		"-state", statePath,
+		"-out", planPath,
+		testFixturePath("apply-continue"),
+	}
+	if code := planCmd.Run(args); code != 0 {
+		return fmt.Errorf("%s: plan: %d\n\n%s", name, code, ui.ErrorWriter.String())
+	}
+
+	ui = new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state", statePath,
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		return fmt.Errorf("%s: apply: %d\n\n%s", name, code, ui.ErrorWriter.String())
+	}
+
+	return nil
+}
+
+func TestApply_planId(t *testing.T) {
+	plan := testPlan(t)
+	planPath := testPlanFile(t, plan)
+	statePath := testTempFile(t)
+
+	id, err := plan.Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-plan-id", id,
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if _, err := os.Stat(statePath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestApply_planIdMismatch(t *testing.T) {
+	planPath := testPlanFile(t, testPlan(t))
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-plan-id", "not-the-id",
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+
+	if _, err := os.Stat(statePath); err == nil {
+		t.Fatal("state should not be written")
+	}
+
+	actual := ui.ErrorWriter.String()
+	if !strings.Contains(actual, "doesn't match the\nexpected plan ID not-the-id") {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestApply_planIdNoPlan(t *testing.T) {
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-plan-id", "foo",
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+}
+
+func TestApply_planVersionMismatch(t *testing.T) {
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module:           testModule(t, "apply"),
+		TerraformVersion: "0.1.0",
+	})
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		planPath,
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	expected := strings.TrimSpace(`
-<no state>
-Outputs:
-
-bar = one
-foo = foovalue
-	`)
-	testStateOutput(t, statePath, expected)
-}
-
-func TestApply_noArgs(t *testing.T) {
-	cwd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	errStr := ui.ErrorWriter.String()
+	if !strings.Contains(errStr, "created by Terraform v0.1.0") {
+		t.Fatalf("bad: %s", errStr)
 	}
-	if err := os.Chdir(testFixturePath("plan")); err != nil {
+	if _, err := os.Stat(statePath); err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	defer os.Chdir(cwd)
+}
 
+func TestApply_planVersionMismatchError(t *testing.T) {
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module:           testModule(t, "apply"),
+		TerraformVersion: "0.1.0",
+	})
 	statePath := testTempFile(t)
 
 	p := testProvider()
@@ -471,41 +1246,25 @@ func TestApply_noArgs(t *testing.T) {
 
 	args := []string{
 		"-state", statePath,
+		"-version-mismatch", "error",
+		planPath,
 	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
-	}
-
-	if _, err := os.Stat(statePath); err != nil {
-		t.Fatalf("err: %s", err)
-	}
-
-	f, err := os.Open(statePath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
 	}
-	defer f.Close()
 
-	state, err := terraform.ReadState(f)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	if !strings.Contains(ui.ErrorWriter.String(), "created by Terraform v0.1.0") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
 	}
-	if state == nil {
-		t.Fatal("state should not be nil")
+	if p.ApplyCalled {
+		t.Fatalf("apply should not be called")
 	}
 }
 
-func TestApply_plan(t *testing.T) {
-	// Disable test mode so input would be asked
-	test = false
-	defer func() { test = true }()
-
-	// Set some default reader/writers for the inputs
-	defaultInputReader = new(bytes.Buffer)
-	defaultInputWriter = new(bytes.Buffer)
-
+func TestApply_planVersionMatch(t *testing.T) {
 	planPath := testPlanFile(t, &terraform.Plan{
-		Module: testModule(t, "apply"),
+		Module:           testModule(t, "apply"),
+		TerraformVersion: terraform.VersionString(),
 	})
 	statePath := testTempFile(t)
 
@@ -520,32 +1279,15 @@ func TestApply_plan(t *testing.T) {
 
 	args := []string{
 		"-state", statePath,
+		"-version-mismatch", "error",
 		planPath,
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	if p.InputCalled {
-		t.Fatalf("input should not be called for plans")
-	}
-
-	if _, err := os.Stat(statePath); err != nil {
-		t.Fatalf("err: %s", err)
-	}
-
-	f, err := os.Open(statePath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	defer f.Close()
-
-	state, err := terraform.ReadState(f)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	if state == nil {
-		t.Fatal("state should not be nil")
+	if strings.Contains(ui.ErrorWriter.String(), "created by Terraform") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
 	}
 }
 
@@ -679,6 +1421,66 @@ func TestApply_plan_remoteState(t *testing.T) {
 	}
 }
 
+func TestApply_plan_remoteStateChanged(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	remoteStatePath := filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)
+	if err := os.MkdirAll(filepath.Dir(remoteStatePath), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, tc := range []struct {
+		RemoteSerial int64
+		Err          bool
+	}{
+		// The remote state is the one the plan was made with
+		{3, false},
+
+		// Someone changed the remote state since the plan was made
+		{4, true},
+	} {
+		state := testState()
+		state.Version = terraform.StateVersion
+		state.Serial = 3
+		remoteState := state.DeepCopy()
+		remoteState.Serial = tc.RemoteSerial
+		conf, srv := testRemoteState(t, remoteState, 200)
+		defer srv.Close()
+		state.Remote = conf
+		planPath := testPlanFile(t, &terraform.Plan{
+			Module: testModule(t, "apply"),
+			State:  state,
+		})
+
+		p := testProvider()
+		ui := new(cli.MockUi)
+		c := &ApplyCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+
+		code := c.Run([]string{planPath})
+		if !tc.Err {
+			if code != 0 {
+				t.Fatalf("%d: bad: %d\n\n%s", tc.RemoteSerial, code, ui.ErrorWriter.String())
+			}
+			continue
+		}
+
+		if code != 1 {
+			t.Fatalf("%d: bad: %d", tc.RemoteSerial, code)
+		}
+		if !strings.Contains(ui.ErrorWriter.String(), "changed by someone else") {
+			t.Fatalf("%d: bad: %s", tc.RemoteSerial, ui.ErrorWriter.String())
+		}
+		if p.ApplyCalled {
+			t.Fatalf("%d: apply should not be called", tc.RemoteSerial)
+		}
+	}
+}
+
 func TestApply_planWithVarFile(t *testing.T) {
 	varFileDir := testTempDir(t)
 	varFilePath := filepath.Join(varFileDir, "terraform.tfvars")
@@ -1048,6 +1850,93 @@ func TestApply_stateNoExist(t *testing.T) {
 	}
 }
 
+func TestApply_persistFailed(t *testing.T) {
+	// The state can't be written since the device is always full
+	statePathOut := "/dev/full"
+	if _, err := os.Stat(statePathOut); err != nil {
+		t.Skipf("%s is needed to fail writing the state: %s", statePathOut, err)
+	}
+	recoveryPath := testTempFile(t)
+
+	p := testProvider()
+	p.ApplyReturn = &terraform.InstanceState{ID: "foo"}
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts:           testCtxConfig(p),
+			Ui:                    ui,
+			OperationRecoveryPath: recoveryPath,
+		},
+	}
+
+	args := []string{
+		"-state", testTempFile(t),
+		"-state-out", statePathOut,
+		"-backup", "-",
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !p.ApplyCalled {
+		t.Fatalf("apply should be called: %s", ui.ErrorWriter.String())
+	}
+
+	errOutput := ui.ErrorWriter.String()
+	if !strings.Contains(errOutput, recoveryPath) {
+		t.Fatalf("bad: %s", errOutput)
+	}
+
+	// The recovered state is complete
+	state := testStateRead(t, recoveryPath)
+	if state.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s\n\n%s", state, errOutput)
+	}
+}
+
+func TestApply_persistFailedChdir(t *testing.T) {
+	statePathOut := "/dev/full"
+	if _, err := os.Stat(statePathOut); err != nil {
+		t.Skipf("%s is needed to fail writing the state: %s", statePathOut, err)
+	}
+
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	if err := os.MkdirAll(filepath.Join(tmp, "work"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	p.ApplyReturn = &terraform.InstanceState{ID: "foo"}
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-chdir=work",
+		"-state", testTempFile(t),
+		"-state-out", statePathOut,
+		"-backup", "-",
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	// The state is recovered to the working directory
+	if _, err := os.Stat(DefaultErroredStateFilename); !os.IsNotExist(err) {
+		t.Fatalf("state should not be recovered to the current directory: %v", err)
+	}
+	state := testStateRead(t, filepath.Join(tmp, "work", DefaultErroredStateFilename))
+	if state.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
 func TestApply_sensitiveOutput(t *testing.T) {
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -1594,3 +2483,183 @@ const testApplyStateDiffStr = `
 ID = bar
 Tainted = false
 `
+
+// Applying with the in-memory remote state never writes the state to the
+// working directory.
+func TestApply_inmemRemoteState(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	conf := &terraform.RemoteState{
+		Type:   "inmem",
+		Config: map[string]string{"name": "apply-inmem"},
+	}
+	ui := new(cli.MockUi)
+	rc := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	args := []string{
+		"-backend", conf.Type,
+		"-backend-config", "name=" + conf.Config["name"],
+	}
+	if code := rc.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	p := testProvider()
+	p.DiffReturn = &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": &terraform.ResourceAttrDiff{New: "bar"},
+		},
+	}
+	p.ApplyReturn = &terraform.InstanceState{ID: "foo"}
+	ui = new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run([]string{testFixturePath("apply")}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The state is in memory
+	client, err := remote.NewClient(conf.Type, conf.Config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	durable := &remote.State{Client: client}
+	if err := durable.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s := durable.State(); s == nil || !s.HasResources() {
+		t.Fatalf("bad: %#v", s)
+	}
+
+	// Only the configuration of the remote state is in the working
+	// directory, as with any other remote state.
+	files, err := filepath.Glob("*")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(files) != 1 || files[0] != DefaultDataDir {
+		t.Fatalf("bad: %#v", files)
+	}
+}
+
+func TestApply_planDestroy(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	// Confirm the destroy
+	defaultInputReader = bytes.NewBufferString("yes\n")
+	defaultInputWriter = new(bytes.Buffer)
+
+	statePath := testStateFile(t, testState())
+	planPath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	plan := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args := []string{
+		"-destroy",
+		"-input=false",
+		"-out", planPath,
+		"-state", statePath,
+		testFixturePath("apply"),
+	}
+	if code := plan.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !testReadPlan(t, planPath).Destroy {
+		t.Fatal("plan should be a destroy plan")
+	}
+
+	ui = new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state", statePath,
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if !strings.Contains(defaultInputWriter.(*bytes.Buffer).String(), "destroys all your managed infrastructure") {
+		t.Fatalf("bad: %s", defaultInputWriter)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Destroy complete! Resources: 1 destroyed.") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	state := testStateRead(t, statePath)
+	if len(state.RootModule().Resources) != 0 {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
+func TestApply_planDestroyCancel(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	// Don't confirm the destroy
+	defaultInputReader = bytes.NewBufferString("no\n")
+	defaultInputWriter = new(bytes.Buffer)
+
+	statePath := testStateFile(t, testState())
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module:  testModule(t, "apply"),
+		State:   testState(),
+		Destroy: true,
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"test_instance.foo": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+					},
+				},
+			},
+		},
+	})
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args := []string{
+		"-state", statePath,
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Destroy cancelled.") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
@@ -1,7 +1,9 @@
 package command
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/colorstring"
@@ -49,3 +51,63 @@ func (u *ColorizeUi) colorize(message string, color string) string {
 
 	return u.Colorize.Color(fmt.Sprintf("%s%s[reset]", color, message))
 }
+
+// UiWriter is an io.Writer that outputs what is written to it to the
+// Ui, one line at a time, so that large output can be streamed to the Ui
+// without building it all in memory first.
+//
+// Like output that is trimmed before being given to the Ui, blank lines
+// are dropped from the start and end of the output. Close must be called
+// once all the output was written to output the last line.
+type UiWriter struct {
+	Ui cli.Ui
+
+	buf    bytes.Buffer
+	blanks int
+	lines  int
+}
+
+func (w *UiWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+
+		line := string(w.buf.Next(idx + 1))
+		w.line(line[:idx])
+	}
+
+	return len(p), nil
+}
+
+// Close outputs the last line written, if it wasn't terminated by a
+// newline.
+func (w *UiWriter) Close() error {
+	if w.buf.Len() > 0 {
+		w.line(w.buf.String())
+		w.buf.Reset()
+	}
+
+	return nil
+}
+
+func (w *UiWriter) line(line string) {
+	// Hold on to blank lines until we know there is more output after
+	// them, and drop them completely at the start of the output.
+	if strings.TrimSpace(line) == "" {
+		if w.lines > 0 {
+			w.blanks++
+		}
+
+		return
+	}
+
+	for ; w.blanks > 0; w.blanks-- {
+		w.Ui.Output("")
+	}
+
+	w.Ui.Output(line)
+	w.lines++
+}
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/mitchellh/cli"
@@ -9,3 +10,34 @@ import (
 func TestColorizeUi_impl(t *testing.T) {
 	var _ cli.Ui = new(ColorizeUi)
 }
+
+func TestUiWriter(t *testing.T) {
+	ui := new(cli.MockUi)
+	w := &UiWriter{Ui: ui}
+
+	fmt.Fprint(w, "\n\nfoo\nb")
+	fmt.Fprint(w, "ar\n\n\nbaz\n\n")
+	fmt.Fprint(w, "qux")
+	if err := w.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := ui.OutputWriter.String()
+	expected := "foo\nbar\n\n\nbaz\n\nqux\n"
+	if actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestUiWriter_trailingBlank(t *testing.T) {
+	ui := new(cli.MockUi)
+	w := &UiWriter{Ui: ui}
+
+	fmt.Fprint(w, "foo\n\n")
+	w.Close()
+
+	actual := ui.OutputWriter.String()
+	if actual != "foo\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
@@ -0,0 +1,133 @@
+package command
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mitchellh/colorstring"
+)
+
+// The roles of the colors that changes are shown with, such as in plans.
+// The colorizer of Meta.Colorize has a color for each role, so they can be
+// used like colors, as in "[create]+ aws_instance.foo". They default to
+// the colors in defaultRoleColors, and can be overridden with
+// Meta.PlanColors or PlanColorsEnvVar, such as for accessibility.
+const (
+	ColorRoleCreate = "create"
+	ColorRoleUpdate = "update"
+	ColorRoleDelete = "delete"
+	ColorRoleRead   = "read"
+)
+
+// PlanColorsEnvVar is the name of the environment variable that can be
+// used to override the colors of the roles, as a comma-separated list of
+// role=color pairs such as "create=blue,delete=light_magenta".
+const PlanColorsEnvVar = "TF_PLAN_COLORS"
+
+// defaultRoleColors are the colors of the roles unless they're overridden.
+var defaultRoleColors = map[string]string{
+	ColorRoleCreate: "green",
+	ColorRoleUpdate: "yellow",
+	ColorRoleDelete: "red",
+	ColorRoleRead:   "cyan",
+}
+
+// colorCodeRe matches a raw color code, such as "1;34" for bold blue.
+var colorCodeRe = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// roleColors returns the colors for colorizing output: the default colors
+// of colorstring, along with a color for each role. The role colors are
+// overridden by those given, which map roles to the name of a color, such
+// as "blue", or to a raw color code, such as "1;34".
+func roleColors(overrides map[string]string) (map[string]string, error) {
+	colors := make(map[string]string, len(colorstring.DefaultColors)+len(defaultRoleColors))
+	for k, v := range colorstring.DefaultColors {
+		colors[k] = v
+	}
+	for role, color := range defaultRoleColors {
+		colors[role] = colorstring.DefaultColors[color]
+	}
+
+	for role, color := range overrides {
+		if _, ok := defaultRoleColors[role]; !ok {
+			return nil, fmt.Errorf(
+				"unknown color role %q, must be one of create, update, delete and read", role)
+		}
+
+		code, ok := colorstring.DefaultColors[color]
+		if !ok {
+			if !colorCodeRe.MatchString(color) {
+				return nil, fmt.Errorf("unknown color %q for %s", color, role)
+			}
+			code = color
+		}
+		colors[role] = code
+	}
+
+	return colors, nil
+}
+
+// parsePlanColors parses the value of PlanColorsEnvVar into the colors of
+// the roles it overrides.
+func parsePlanColors(v string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		idx := strings.Index(pair, "=")
+		if idx < 0 {
+			return nil, fmt.Errorf("expected role=color, got %q", pair)
+		}
+		result[strings.TrimSpace(pair[:idx])] = strings.TrimSpace(pair[idx+1:])
+	}
+
+	return result, nil
+}
+
+// roleColor returns the color to use for the given role in a string to be
+// colorized by c: the role itself if c has a color for it, which the
+// colorizer of Meta.Colorize does, or the default color of the role
+// otherwise.
+func roleColor(c *colorstring.Colorize, role string) string {
+	if c != nil {
+		if _, ok := c.Colors[role]; ok {
+			return role
+		}
+	}
+
+	return defaultRoleColors[role]
+}
+
+// colors returns the colors of the colorizer returned by Colorize. The
+// role colors are read from the environment, overridden by PlanColors. If
+// they aren't valid, a warning is logged and the defaults are used.
+func (m *Meta) colors() map[string]string {
+	overrides := make(map[string]string)
+	if v := os.Getenv(PlanColorsEnvVar); v != "" {
+		env, err := parsePlanColors(v)
+		if err != nil {
+			log.Printf("[WARN] Invalid value for %s, using the default colors: %s",
+				PlanColorsEnvVar, err)
+		}
+		for role, color := range env {
+			overrides[role] = color
+		}
+	}
+	for role, color := range m.PlanColors {
+		overrides[role] = color
+	}
+
+	colors, err := roleColors(overrides)
+	if err != nil {
+		log.Printf("[WARN] Invalid plan colors, using the defaults: %s", err)
+		colors, _ = roleColors(nil)
+	}
+
+	return colors
+}
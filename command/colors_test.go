@@ -0,0 +1,90 @@
+package command
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestMetaColorize_planColors(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": &terraform.ResourceAttrDiff{
+									NewComputed: true,
+									RequiresNew: true,
+								},
+							},
+						},
+						"aws_instance.bar": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Env        string
+		PlanColors map[string]string
+		Create     string
+		Delete     string
+	}{
+		// The defaults are the colors plans have always been shown with
+		{"", nil, "\x1b[32m+ aws_instance.foo", "\x1b[31m- aws_instance.bar"},
+
+		{
+			"create=blue,delete=1;35", nil,
+			"\x1b[34m+ aws_instance.foo", "\x1b[1;35m- aws_instance.bar",
+		},
+
+		// PlanColors take precedence over the environment
+		{
+			"create=blue", map[string]string{"create": "light_green"},
+			"\x1b[92m+ aws_instance.foo", "\x1b[31m- aws_instance.bar",
+		},
+
+		// Invalid colors are ignored
+		{"create=nope", nil, "\x1b[32m+ aws_instance.foo", "\x1b[31m- aws_instance.bar"},
+		{"nope=blue", nil, "\x1b[32m+ aws_instance.foo", "\x1b[31m- aws_instance.bar"},
+	}
+
+	defer os.Unsetenv(PlanColorsEnvVar)
+	for i, tc := range cases {
+		os.Setenv(PlanColorsEnvVar, tc.Env)
+
+		m := &Meta{color: true, PlanColors: tc.PlanColors}
+		actual := FormatPlan(&FormatPlanOpts{
+			Plan:        plan,
+			Color:       m.Colorize(),
+			ModuleDepth: -1,
+		})
+		for _, s := range []string{tc.Create, tc.Delete} {
+			if !strings.Contains(actual, s) {
+				t.Fatalf("%d: missing %q\n\n%q", i, s, actual)
+			}
+		}
+	}
+}
+
+func TestParsePlanColors(t *testing.T) {
+	actual, err := parsePlanColors(" create=blue, ,delete = 1;35 ")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(actual) != 2 || actual["create"] != "blue" || actual["delete"] != "1;35" {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	if _, err := parsePlanColors("create"); err == nil {
+		t.Fatal("should error")
+	}
+}
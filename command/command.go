@@ -1,65 +1,45 @@
 package command
 
-import (
-	"fmt"
-	"log"
-
-	"github.com/hashicorp/terraform/terraform"
-	"github.com/mitchellh/cli"
-)
-
 // Set to true when we're testing
 var test bool = false
 
 // DefaultDataDir is the default directory for storing local data.
 const DefaultDataDir = ".terraform"
 
+// DefaultStatesDir is the directory within the data directory with the
+// data of each state file other than the default one.
+const DefaultStatesDir = "states"
+
 // DefaultStateFilename is the default filename used for the state file.
 const DefaultStateFilename = "terraform.tfstate"
 
 // DefaultVarsFilename is the default filename used for vars
 const DefaultVarsFilename = "terraform.tfvars"
 
+// DefaultAutoVarsSuffix is the filename suffix of var files that are
+// loaded automatically in addition to DefaultVarsFilename.
+const DefaultAutoVarsSuffix = ".auto.tfvars"
+
 // DefaultBackupExtension is added to the state file to form the path
 const DefaultBackupExtension = ".backup"
 
-// DefaultParallelism is the limit Terraform places on total parallel
-// operations as it walks the dependency graph.
-const DefaultParallelism = 10
-
-func validateContext(ctx *terraform.Context, ui cli.Ui) bool {
-	log.Println("[INFO] Validating the context...")
-	ws, es := ctx.Validate()
-	log.Printf("[INFO] Validation result: %d warnings, %d errors", len(ws), len(es))
-
-	if len(ws) > 0 || len(es) > 0 {
-		ui.Output(
-			"There are warnings and/or errors related to your configuration. Please\n" +
-				"fix these before continuing.\n")
+// DefaultBackupsDir is the directory within the data directory of a state
+// where its backup is written if it can't be written next to the state.
+const DefaultBackupsDir = "backups"
 
-		if len(ws) > 0 {
-			ui.Warn("Warnings:\n")
-			for _, w := range ws {
-				ui.Warn(fmt.Sprintf("  * %s", w))
-			}
+// DefaultApplyProgressFilename is the filename within the data directory
+// where the progress of applying a plan file is recorded.
+const DefaultApplyProgressFilename = "apply-progress.json"
 
-			if len(es) > 0 {
-				ui.Output("")
-			}
-		}
+// DefaultErroredStateFilename is the filename in the working directory
+// where the state is written when it couldn't be saved to its real
+// location, so that the results of an operation aren't lost.
+const DefaultErroredStateFilename = "errored.tfstate"
 
-		if len(es) > 0 {
-			ui.Error("Errors:\n")
-			for _, e := range es {
-				ui.Error(fmt.Sprintf("  * %s", e))
-			}
-			return false
-		} else {
-			ui.Warn(fmt.Sprintf("\n"+
-				"No errors found. Continuing with %d warning(s).\n", len(ws)))
-			return true
-		}
-	}
+// DefaultMaxInputAttempts is the number of times a variable is asked for
+// before giving up when the answers aren't valid values for it.
+const DefaultMaxInputAttempts = 3
 
-	return true
-}
+// DefaultParallelism is the limit Terraform places on total parallel
+// operations as it walks the dependency graph.
+const DefaultParallelism = 10
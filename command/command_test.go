@@ -4,7 +4,6 @@ import (
 	"flag"
 	"io"
 	"io/ioutil"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,7 +12,9 @@ import (
 	"github.com/hashicorp/go-getter"
 	"github.com/hashicorp/terraform/config/module"
 	"github.com/hashicorp/terraform/helper/logging"
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
 )
 
 // This is the directory where our test fixtures are.
@@ -38,7 +39,7 @@ func TestMain(m *testing.M) {
 		logging.SetOutput()
 	} else {
 		// otherwise silence all logs
-		log.SetOutput(ioutil.Discard)
+		logging.SetWriter(ioutil.Discard)
 	}
 
 	os.Exit(m.Run())
@@ -220,6 +221,22 @@ func testStateFileRemote(t *testing.T, s *terraform.State) string {
 	return path
 }
 
+// testStateRead reads the state from the given path.
+func testStateRead(t *testing.T, path string) *terraform.State {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	s, err := terraform.ReadState(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return s
+}
+
 // testStateOutput tests that the state at the given path contains
 // the expected state string.
 func testStateOutput(t *testing.T, path string, expected string) {
@@ -401,3 +418,99 @@ func testStdoutCapture(t *testing.T, dst io.Writer) func() {
 		<-doneCh
 	}
 }
+
+// testHarness is a harness for running a command in tests without any
+// files: the state is kept in memory and the output goes to a MockUi.
+// Create the command with the Meta of the harness, run it with Run, and
+// check the result with the Expect functions.
+type testHarness struct {
+	t *testing.T
+
+	Meta  Meta
+	Ui    *cli.MockUi
+	State *state.InmemState
+
+	// Code is the exit status of the last Run.
+	Code int
+}
+
+// testCommandHarness returns a harness for commands using the given
+// provider with the given state, which may be nil for no state.
+func testCommandHarness(t *testing.T, p terraform.ResourceProvider, s *terraform.State) *testHarness {
+	store := new(state.InmemState)
+	if s != nil {
+		if err := store.WriteState(s); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	ui := new(cli.MockUi)
+	return &testHarness{
+		t: t,
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			stateStore:  store,
+		},
+		Ui:    ui,
+		State: store,
+	}
+}
+
+// Run runs the command with the given arguments, followed by the path of
+// the named fixture if it isn't empty.
+func (h *testHarness) Run(c cli.Command, fixture string, args ...string) *testHarness {
+	if fixture != "" {
+		args = append(args, testFixturePath(fixture))
+	}
+
+	h.Code = c.Run(args)
+	return h
+}
+
+// ExpectCode fails the test if the exit status of the last Run wasn't code.
+func (h *testHarness) ExpectCode(code int) *testHarness {
+	if h.Code != code {
+		h.t.Fatalf("bad: %d\n\n%s\n\n%s",
+			h.Code, h.Ui.ErrorWriter.String(), h.Ui.OutputWriter.String())
+	}
+
+	return h
+}
+
+// ExpectOutput fails the test if the output doesn't contain each of the
+// given strings.
+func (h *testHarness) ExpectOutput(expected ...string) *testHarness {
+	output := h.Ui.OutputWriter.String()
+	for _, s := range expected {
+		if !strings.Contains(output, s) {
+			h.t.Fatalf("output doesn't contain %q:\n\n%s", s, output)
+		}
+	}
+
+	return h
+}
+
+// ExpectError fails the test if the error output doesn't contain each of
+// the given strings.
+func (h *testHarness) ExpectError(expected ...string) *testHarness {
+	output := h.Ui.ErrorWriter.String()
+	for _, s := range expected {
+		if !strings.Contains(output, s) {
+			h.t.Fatalf("error output doesn't contain %q:\n\n%s", s, output)
+		}
+	}
+
+	return h
+}
+
+// ExpectState fails the test if the resulting state, as a string, isn't
+// the expected string. Surrounding whitespace is ignored.
+func (h *testHarness) ExpectState(expected string) *testHarness {
+	actual := strings.TrimSpace(h.State.State().String())
+	if expected = strings.TrimSpace(expected); actual != expected {
+		h.t.Fatalf("bad state:\n\n%s\n\nexpected:\n\n%s", actual, expected)
+	}
+
+	return h
+}
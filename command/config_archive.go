@@ -0,0 +1,235 @@
+package command
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// planFileMagic is what plan files written by terraform.WritePlan start
+// with. Along with planEncryptedMagic for encrypted plans, it's how plan
+// files are left out of configuration archives whatever they are named.
+const planFileMagic = "tfplan"
+
+// ArchiveConfig writes a tar.gz archive of the configuration in dir to w,
+// so that it can be sent to run an operation somewhere else, and returns
+// a hash of its contents.
+//
+// The archive has the root module and the modules downloaded to
+// .terraform/modules. Symlinks are followed, since modules from a local
+// source are linked into .terraform/modules, except to a directory they
+// are in, such as for a module with the source "./". State files and their
+// backups, plan files, the rest of .terraform and .git are left out.
+//
+// The archive is always the same for the same contents: the files are in
+// lexical order and no times, owners or permissions other than whether a
+// file is executable are recorded. So the hash can be used to tell if the
+// configuration has already been sent.
+func ArchiveConfig(dir string, w io.Writer) (string, error) {
+	h := sha256.New()
+	gzw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	tw := tar.NewWriter(io.MultiWriter(gzw, h))
+
+	if err := archiveConfigDir(tw, dir, "", make(map[string]bool)); err != nil {
+		return "", err
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gzw.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// archiveConfigDir adds the contents of the directory at path to the
+// archive, with names starting with the given prefix. The real paths of
+// the directories being added, dir and those it's in, are kept in parents,
+// so that symlinks back to them aren't followed forever.
+func archiveConfigDir(tw *tar.Writer, dir, prefix string, parents map[string]bool) error {
+	realPath, err := archiveConfigRealPath(dir)
+	if err != nil {
+		return err
+	}
+	parents[realPath] = true
+	defer delete(parents, realPath)
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// ReadDir returns the entries sorted by filename already
+	for _, info := range infos {
+		name := path.Join(prefix, info.Name())
+		fullPath := filepath.Join(dir, info.Name())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			info, err = os.Stat(fullPath)
+			if err != nil {
+				return fmt.Errorf("Error following symlink %s: %s", fullPath, err)
+			}
+		}
+
+		if info.IsDir() {
+			if archiveConfigSkipDir(name) {
+				continue
+			}
+			realPath, err := archiveConfigRealPath(fullPath)
+			if err != nil {
+				return err
+			}
+			if parents[realPath] {
+				continue
+			}
+
+			err = tw.WriteHeader(&tar.Header{
+				Name:     name + "/",
+				Mode:     0755,
+				Typeflag: tar.TypeDir,
+			})
+			if err != nil {
+				return err
+			}
+			if err := archiveConfigDir(tw, fullPath, name, parents); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || archiveConfigSkipFile(name) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(fullPath)
+		if err != nil {
+			return err
+		}
+		if bytes.HasPrefix(data, []byte(planFileMagic)) ||
+			bytes.HasPrefix(data, []byte(planEncryptedMagic)) {
+			continue
+		}
+
+		var mode int64 = 0644
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		err = tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     mode,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// archiveConfigRealPath returns the absolute path of the directory at path
+// with all symlinks resolved.
+func archiveConfigRealPath(path string) (string, error) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("Error following symlink %s: %s", path, err)
+	}
+
+	return filepath.Abs(realPath)
+}
+
+// archiveConfigSkipDir returns whether the directory with the given name
+// in the archive is left out of it.
+func archiveConfigSkipDir(name string) bool {
+	if path.Base(name) == ".git" {
+		return true
+	}
+
+	// Only the modules are needed from the data directory
+	if strings.HasPrefix(name, DefaultDataDir+"/") {
+		return name != DefaultDataDir+"/modules" &&
+			!strings.HasPrefix(name, DefaultDataDir+"/modules/")
+	}
+
+	return false
+}
+
+// archiveConfigSkipFile returns whether the file with the given name in
+// the archive is left out of it.
+func archiveConfigSkipFile(name string) bool {
+	if strings.Contains(path.Base(name), ".tfstate") {
+		return true
+	}
+
+	// Files directly in the data directory, such as the remote state
+	// cache, aren't needed
+	return path.Dir(name) == DefaultDataDir
+}
+
+// ExtractConfig extracts an archive written by ArchiveConfig into dir,
+// which must exist.
+func ExtractConfig(r io.Reader, dir string) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("Error reading configuration archive: %s", err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading configuration archive: %s", err)
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Invalid path in configuration archive: %s", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			f, err := os.OpenFile(
+				target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf(
+				"Unsupported entry in configuration archive: %s", hdr.Name)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/terraform/config/module"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestArchiveConfig(t *testing.T) {
+	td := testTempDir(t)
+	defer os.RemoveAll(td)
+
+	files := map[string]string{
+		"main.tf": `
+module "child" {
+    source = "./child"
+}
+
+resource "test_instance" "foo" {
+    ami = "bar"
+}
+`,
+		"child/main.tf": `
+resource "test_instance" "bar" {
+    ami = "baz"
+}
+`,
+		DefaultStateFilename:                          `{}`,
+		DefaultStateFilename + DefaultBackupExtension: `{}`,
+		".git/HEAD":                    "ref: refs/heads/master",
+		".terraform/terraform.tfstate": `{}`,
+	}
+	for name, contents := range files {
+		path := filepath.Join(td, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Plan files are left out whatever they are named
+	planFile, err := os.Create(filepath.Join(td, "saved"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := terraform.WritePlan(&terraform.Plan{}, planFile); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	planFile.Close()
+	planFile, err = os.Create(filepath.Join(td, "saved-encrypted"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := writePlan(&terraform.Plan{}, planFile, []byte("key")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	planFile.Close()
+
+	// A symlink back to a directory it's in isn't followed forever
+	if err := os.Symlink("..", filepath.Join(td, "child", "parent")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Get the modules like "terraform get" would
+	mod := testArchiveConfigModule(t, td)
+
+	var buf bytes.Buffer
+	hash, err := ArchiveConfig(td, &buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The archive is the same every time
+	var again bytes.Buffer
+	hashAgain, err := ArchiveConfig(td, &again)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if hash != hashAgain || !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Fatalf("archives differ: %s %s", hash, hashAgain)
+	}
+
+	extracted := testTempDir(t)
+	defer os.RemoveAll(extracted)
+	if err := ExtractConfig(&buf, extracted); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range []string{"main.tf", "child/main.tf", ".terraform/modules"} {
+		if _, err := os.Stat(filepath.Join(extracted, name)); err != nil {
+			t.Fatalf("%s should be in the archive: %s", name, err)
+		}
+	}
+	excluded := []string{
+		DefaultStateFilename,
+		DefaultStateFilename + DefaultBackupExtension,
+		".git",
+		".terraform/terraform.tfstate",
+		"saved",
+		"saved-encrypted",
+		"child/parent",
+	}
+	for _, name := range excluded {
+		if _, err := os.Stat(filepath.Join(extracted, name)); !os.IsNotExist(err) {
+			t.Fatalf("%s shouldn't be in the archive: %v", name, err)
+		}
+	}
+
+	// Planning the extracted copy gives the same plan
+	expected := testArchiveConfigPlan(t, mod)
+	actual := testArchiveConfigPlan(t, testArchiveConfigModule(t, extracted))
+	if !strings.Contains(expected, "test_instance.bar") || actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+func testArchiveConfigModule(t *testing.T, dir string) *module.Tree {
+	mod, err := module.NewTreeModule("", dir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s := &getter.FolderStorage{StorageDir: filepath.Join(dir, DefaultDataDir, "modules")}
+	if err := mod.Load(s, module.GetModeGet); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return mod
+}
+
+func testArchiveConfigPlan(t *testing.T, mod *module.Tree) string {
+	opts := testCtxConfig(testProvider())
+	opts.Module = mod
+	ctx, err := terraform.NewContext(opts)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return plan.Diff.String()
+}
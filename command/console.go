@@ -3,7 +3,6 @@ package command
 import (
 	"bufio"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/terraform/helper/wrappedstreams"
@@ -30,7 +29,7 @@ func (c *ConsoleCommand) Run(args []string) int {
 		return 1
 	}
 
-	pwd, err := os.Getwd()
+	pwd, err := c.pwd()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		return 1
@@ -49,7 +48,7 @@ func (c *ConsoleCommand) Run(args []string) int {
 	}
 
 	// Build the context based on the arguments given
-	ctx, _, err := c.Context(contextOpts{
+	ctx, _, err := c.ContextForConsole(contextOpts{
 		Path:        configPath,
 		PathEmptyOk: true,
 		StatePath:   c.Meta.statePath,
@@ -124,8 +123,10 @@ Options:
                          flag can be set multiple times.
 
   -var-file=foo          Set variables in the Terraform configuration from
-                         a file. If "terraform.tfvars" is present, it will be
-                         automatically loaded if this flag is not specified.
+                         a file. If "terraform.tfvars" or any ".auto.tfvars"
+                         files are present, they will be automatically loaded.
+                         A directory or glob pattern loads all the matching
+                         files, in lexical order.
 
 
 `
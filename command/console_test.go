@@ -3,10 +3,13 @@ package command
 import (
 	"bytes"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/repl"
+	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
 
@@ -84,3 +87,80 @@ func TestConsole_tfvars(t *testing.T) {
 		t.Fatalf("bad: %q", actual)
 	}
 }
+
+func TestConsole_state(t *testing.T) {
+	statePath := testStateFile(t, testConsoleState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ConsoleCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	var output bytes.Buffer
+	defer testStdinPipe(t, strings.NewReader("test_instance.foo.ami\n"))()
+	outCloser := testStdoutCapture(t, &output)
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	code := c.Run(args)
+	outCloser()
+	if code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := output.String()
+	if actual != "ami-123\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestMetaContextForConsole(t *testing.T) {
+	statePath := testStateFile(t, testConsoleState())
+
+	m := &Meta{
+		ContextOpts: testCtxConfig(testProvider()),
+		Ui:          new(cli.MockUi),
+	}
+	ctx, s, err := m.ContextForConsole(contextOpts{
+		Path:      testFixturePath("refresh"),
+		StatePath: statePath,
+		Lock:      true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if s.State().RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", s.State())
+	}
+
+	// The state isn't locked
+	if _, err := os.Stat(filepath.Join(filepath.Dir(statePath), ".state.tfstate.lock.info")); !os.IsNotExist(err) {
+		t.Fatalf("state should not be locked: %v", err)
+	}
+
+	session := &repl.Session{Interpolater: ctx.Interpolater()}
+	actual, err := session.Handle("upper(test_instance.foo.ami)")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual != "AMI-123" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+// testConsoleState returns a state for the "refresh" fixture with
+// attributes to evaluate.
+func testConsoleState() *terraform.State {
+	state := testState()
+	state.RootModule().Resources["test_instance.foo"].Primary.Attributes = map[string]string{
+		"id":  "bar",
+		"ami": "ami-123",
+	}
+	return state
+}
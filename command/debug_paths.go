@@ -0,0 +1,72 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DebugPathsCommand is a Command implementation that shows the paths of
+// the files in the data directory that operations on a state use.
+type DebugPathsCommand struct {
+	Meta
+}
+
+func (c *DebugPathsCommand) Run(args []string) int {
+	args = c.Meta.process(args, false)
+	cmdFlags := c.Meta.flagSet("debug paths")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if len(cmdFlags.Args()) > 0 {
+		c.Ui.Error("The debug paths command expects no arguments.")
+		cmdFlags.Usage()
+		return 1
+	}
+
+	dataDir := c.DataDir()
+	stateDataDir := c.stateDataDir()
+	paths := []struct {
+		Name string
+		Path string
+	}{
+		{"Data directory", dataDir},
+		{"Modules", filepath.Join(dataDir, "modules")},
+		{"Remote state cache", c.StateOpts().RemotePath},
+		{"State data directory", stateDataDir},
+		{"Input cache", filepath.Join(stateDataDir, DefaultInputCacheFilename)},
+		{"Apply progress", filepath.Join(stateDataDir, DefaultApplyProgressFilename)},
+	}
+	for _, p := range paths {
+		c.Ui.Output(fmt.Sprintf("%-22s %s", p.Name+":", p.Path))
+	}
+
+	return 0
+}
+
+func (c *DebugPathsCommand) Help() string {
+	helpText := `
+Usage: terraform debug paths [options]
+
+  Show the paths of the files in the data directory that are used by
+  operations on a state.
+
+  Modules and the remote state cache are shared by all the states in a
+  working directory. The input cache and the progress of applies are
+  kept apart for each state file given with -state, so that operations
+  on different state files don't overwrite each other's.
+
+Options:
+
+  -state=path         Path to the state file to show the paths for.
+                      Defaults to "terraform.tfstate".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *DebugPathsCommand) Synopsis() string {
+	return "Show the paths used in the data directory"
+}
@@ -0,0 +1,35 @@
+package command
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestDebugPaths(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &DebugPathsCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{"-state", "prod.tfstate"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	m := &Meta{statePath: "prod.tfstate"}
+	expected := []string{
+		"Modules:               " + filepath.Join(DefaultDataDir, "modules"),
+		"Apply progress:        " + filepath.Join(m.stateDataDir(), DefaultApplyProgressFilename),
+	}
+	output := ui.OutputWriter.String()
+	for _, line := range expected {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("bad: %s", output)
+		}
+	}
+}
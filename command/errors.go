@@ -0,0 +1,107 @@
+package command
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// The errors below are returned for the failures that programs running
+// commands most often need to handle differently. They may be wrapped
+// with errwrap to add context, so use errwrap.ContainsType or
+// errwrap.GetType to check for them rather than a type assertion.
+
+// ErrStateLocked is the error when the state is locked by another
+// operation and the lock couldn't be acquired within the lock timeout.
+type ErrStateLocked struct {
+	// Lock is the error from the state, with the information of the
+	// current lock holder if it is known.
+	Lock *state.LockError
+}
+
+func (e *ErrStateLocked) Error() string {
+	return e.Lock.Error()
+}
+
+// ErrStateNotFound is the error when an operation that only makes sense
+// with existing state, such as refresh, is run without a state file.
+type ErrStateNotFound struct {
+	Path string
+}
+
+func (e *ErrStateNotFound) Error() string {
+	return fmt.Sprintf("state file not found: %s", e.Path)
+}
+
+// ErrValidation is the error when the configuration isn't valid. Errors
+// are all the errors found, and Warnings are any warnings found along
+// with them.
+type ErrValidation struct {
+	Errors   []error
+	Warnings []string
+}
+
+func (e *ErrValidation) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	return multierror.Append(nil, e.Errors...).Error()
+}
+
+// ErrPlanStale is the error when a plan was created from a state that is
+// behind the current state, or from a different state altogether, so
+// applying it would undo the changes made to the state since.
+type ErrPlanStale struct {
+	// PlanSerial and PlanLineage are of the state the plan was created
+	// from, and StateSerial and StateLineage of the current state.
+	PlanSerial   int64
+	PlanLineage  string
+	StateSerial  int64
+	StateLineage string
+}
+
+func (e *ErrPlanStale) Error() string {
+	if e.lineageDiffers() {
+		return fmt.Sprintf(
+			"This plan was created for a different state (lineage %s)\n"+
+				"than the current state (lineage %s).",
+			e.PlanLineage, e.StateLineage)
+	}
+
+	return fmt.Sprintf(
+		"This plan was created from the state at serial %d, but the\n"+
+			"current state is at serial %d. The state has changed since the\n"+
+			"plan was created, so please create a new plan before applying.",
+		e.PlanSerial, e.StateSerial)
+}
+
+// planStaleError returns an *ErrPlanStale if the plan state is behind the
+// current state or has a different lineage, and nil otherwise. Lineages
+// are only compared if both states have one.
+func planStaleError(planState, current *terraform.State) error {
+	if planState == nil || current == nil {
+		return nil
+	}
+
+	err := &ErrPlanStale{
+		PlanSerial:   planState.Serial,
+		PlanLineage:  planState.Lineage,
+		StateSerial:  current.Serial,
+		StateLineage: current.Lineage,
+	}
+	if err.lineageDiffers() || planState.Serial < current.Serial {
+		return err
+	}
+
+	return nil
+}
+
+// lineageDiffers returns true if both states have a lineage and they
+// aren't the same.
+func (e *ErrPlanStale) lineageDiffers() bool {
+	return e.PlanLineage != "" && e.StateLineage != "" &&
+		e.PlanLineage != e.StateLineage
+}
@@ -0,0 +1,124 @@
+package command
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/state"
+	"github.com/mitchellh/cli"
+)
+
+func TestErrStateLocked(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	m := &Meta{
+		ContextOpts: testCtxConfig(testProvider()),
+		Ui:          new(cli.MockUi),
+	}
+	_, _, err = m.Context(contextOpts{
+		Path:      testFixturePath("plan"),
+		StatePath: statePath,
+		Lock:      true,
+	})
+	if !errwrap.ContainsType(err, new(ErrStateLocked)) {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	locked := errwrap.GetType(err, new(ErrStateLocked)).(*ErrStateLocked)
+	if locked.Lock.Info == nil || locked.Lock.Info.ID != id {
+		t.Fatalf("bad: %#v", locked.Lock)
+	}
+}
+
+func TestErrStateNotFound(t *testing.T) {
+	path := filepath.Join(testTempDir(t), DefaultStateFilename)
+
+	err := refreshStateExists(path)
+	if !errwrap.ContainsType(err, new(ErrStateNotFound)) {
+		t.Fatalf("bad: %#v", err)
+	}
+	if notFound := errwrap.GetType(err, new(ErrStateNotFound)).(*ErrStateNotFound); notFound.Path != path {
+		t.Fatalf("bad: %#v", notFound)
+	}
+
+	if err := refreshStateExists(testStateFile(t, testState())); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestErrValidation(t *testing.T) {
+	p := testProvider()
+	m := &Meta{
+		ContextOpts: testCtxConfig(p),
+		Ui:          new(cli.MockUi),
+	}
+
+	// Errors in the configuration itself
+	_, _, err := m.Context(contextOpts{
+		Path: testFixturePath("apply-config-invalid"),
+	})
+	if verr, ok := err.(*ErrValidation); !ok || len(verr.Errors) != 1 {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Errors found when validating the context
+	p.ValidateResourceReturnErrors = []error{errors.New("invalid")}
+	ctx, _, err := m.Context(contextOpts{
+		Path: testFixturePath("plan"),
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = m.ValidateContext(ctx)
+	if !errwrap.ContainsType(err, new(ErrValidation)) {
+		t.Fatalf("bad: %#v", err)
+	}
+	if verr := err.(*ErrValidation); len(verr.Errors) != 1 {
+		t.Fatalf("bad: %#v", verr)
+	}
+}
+
+func TestErrPlanStale(t *testing.T) {
+	current := testState()
+	current.Lineage = "foo"
+	current.Serial = 2
+
+	cases := []struct {
+		Lineage string
+		Serial  int64
+		Stale   bool
+	}{
+		{"foo", 2, false},
+		{"foo", 3, false},
+		{"", 1, true},
+		{"foo", 1, true},
+		{"bar", 2, true},
+	}
+
+	for _, tc := range cases {
+		planState := current.DeepCopy()
+		planState.Lineage = tc.Lineage
+		planState.Serial = tc.Serial
+
+		err := planStaleError(planState, current)
+		if _, ok := err.(*ErrPlanStale); ok != tc.Stale {
+			t.Fatalf("%s %d: bad: %#v", tc.Lineage, tc.Serial, err)
+		}
+	}
+
+	if err := planStaleError(nil, current); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := planStaleError(current, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,78 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/go-homedir"
+)
+
+// FlagTargetFile is a flag.Value implementation for the -target-file flag.
+// The value is the path of a file with a resource address to target on
+// each line, which are appended to the targets given with -target. Blank
+// lines and comments starting with # are ignored.
+type FlagTargetFile []string
+
+func (v *FlagTargetFile) String() string {
+	return ""
+}
+
+func (v *FlagTargetFile) Set(raw string) error {
+	targets, err := readTargetFile(raw)
+	if err != nil {
+		return err
+	}
+
+	*v = append(*v, targets...)
+	return nil
+}
+
+// readTargetFile returns the resource addresses in the target file at the
+// given path. Every address that can't be parsed is reported with its line
+// number, rather than only the first one.
+func readTargetFile(raw string) ([]string, error) {
+	path, err := homedir.Expand(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding path: %s", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading target file: %s", err)
+	}
+	defer f.Close()
+
+	var targets []string
+	var errs error
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		t := scanner.Text()
+		if idx := strings.Index(t, "#"); idx >= 0 {
+			t = t[:idx]
+		}
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+
+		if _, err := terraform.ParseResourceAddress(t); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf(
+				"%s:%d: invalid target %q: %s", path, line, t, err))
+			continue
+		}
+
+		targets = append(targets, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading target file %s: %s", path, err)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return targets, nil
+}
@@ -0,0 +1,76 @@
+package command
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlagTargetFile_impl(t *testing.T) {
+	var _ flag.Value = new(FlagTargetFile)
+}
+
+func TestFlagTargetFile(t *testing.T) {
+	td := testTempDir(t)
+	defer os.RemoveAll(td)
+
+	files := map[string]string{
+		"targets.txt": strings.Join([]string{
+			"# Phase 1",
+			"aws_instance.web",
+			"",
+			"  module.vpc.aws_subnet.public  # indented",
+			"aws_instance.db[0]",
+		}, "\n"),
+		"more.txt": "aws_iam_role.admin\n",
+		"bad.txt": strings.Join([]string{
+			"aws_instance.web",
+			"# it's fine",
+			"aws_instance.web[nope]",
+			"",
+			"aws_instance.db",
+		}, "\n"),
+	}
+	for name, contents := range files {
+		path := filepath.Join(td, name)
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// Targets from a file are added to those already given
+	v := FlagTargetFile{"aws_vpc.main"}
+	for _, name := range []string{"targets.txt", "more.txt"} {
+		if err := v.Set(filepath.Join(td, name)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	expected := FlagTargetFile{
+		"aws_vpc.main",
+		"aws_instance.web",
+		"module.vpc.aws_subnet.public",
+		"aws_instance.db[0]",
+		"aws_iam_role.admin",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("bad: %#v", v)
+	}
+
+	// Invalid addresses are reported with their line number, and none of
+	// the targets in the file are added
+	err := v.Set(filepath.Join(td, "bad.txt"))
+	if err == nil || !strings.Contains(err.Error(), "bad.txt:3: invalid target") {
+		t.Fatalf("bad: %v", err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("bad: %#v", v)
+	}
+
+	if err := v.Set(filepath.Join(td, "missing.txt")); err == nil {
+		t.Fatal("should error")
+	}
+}
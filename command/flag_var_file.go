@@ -0,0 +1,103 @@
+package command
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/variables"
+	"github.com/mitchellh/go-homedir"
+)
+
+// FlagVarFile is a flag.Value implementation for the -var-file flag. The
+// value can be the path of a single variable file, a directory, which is
+// expanded to the *.tfvars and *.tfvars.json files within it, or a glob
+// pattern, such as vars/prod/*.tfvars. The files a directory or pattern
+// expand to are loaded in lexical order, so values in later files
+// override values in earlier ones.
+//
+// The variables are merged into Variables, and the paths of the files
+// loaded are appended to Files.
+type FlagVarFile struct {
+	Variables *map[string]interface{}
+	Files     *[]string
+}
+
+func (v *FlagVarFile) String() string {
+	return ""
+}
+
+func (v *FlagVarFile) Set(raw string) error {
+	paths, err := expandVarFile(raw)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := (*variables.FlagFile)(v.Variables).Set(path); err != nil {
+			return err
+		}
+
+		*v.Files = append(*v.Files, path)
+	}
+
+	return nil
+}
+
+// expandVarFile returns the paths of the variable files that the given
+// -var-file value refers to, in the order they should be loaded. It is an
+// error if a directory or pattern matches no files.
+func expandVarFile(raw string) ([]string, error) {
+	path, err := homedir.Expand(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error expanding path: %s", err)
+	}
+
+	var paths []string
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid -var-file pattern %q: %s", raw, err)
+		}
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() {
+				paths = append(paths, match)
+			}
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("No variable files match the -var-file pattern %q", raw)
+		}
+	} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", path, err)
+		}
+
+		for _, info := range infos {
+			name := info.Name()
+			if info.IsDir() {
+				continue
+			}
+			if !strings.HasSuffix(name, ".tfvars") && !strings.HasSuffix(name, ".tfvars.json") {
+				continue
+			}
+
+			paths = append(paths, filepath.Join(path, name))
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf(
+				"The -var-file directory %s doesn't contain any .tfvars or .tfvars.json files",
+				path)
+		}
+	} else {
+		// Errors for missing files are left to loading the file
+		return []string{path}, nil
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFlagVarFile_impl(t *testing.T) {
+	var _ flag.Value = new(FlagVarFile)
+}
+
+func TestFlagVarFile(t *testing.T) {
+	td := testTempDir(t)
+	defer os.RemoveAll(td)
+
+	files := map[string]string{
+		"prod/b.tfvars":      `foo = "b"` + "\n" + `b = "yes"`,
+		"prod/a.tfvars":      `foo = "a"` + "\n" + `a = "yes"`,
+		"prod/c.tfvars.json": `{"foo": "c"}`,
+		"prod/notes.txt":     `not = "loaded"`,
+		"single.tfvars":      `foo = "single"`,
+	}
+	for name, contents := range files {
+		path := filepath.Join(td, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(td, "empty"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cases := []struct {
+		Input  string
+		Files  []string
+		Output map[string]interface{}
+		Error  bool
+	}{
+		// A single file
+		{
+			"single.tfvars",
+			[]string{"single.tfvars"},
+			map[string]interface{}{"foo": "single"},
+			false,
+		},
+
+		// A directory is expanded to the variable files in it, in lexical
+		// order so that later files take precedence
+		{
+			"prod",
+			[]string{"prod/a.tfvars", "prod/b.tfvars", "prod/c.tfvars.json"},
+			map[string]interface{}{"foo": "c", "a": "yes", "b": "yes"},
+			false,
+		},
+
+		// Globs are sorted the same way
+		{
+			"prod/*.tfvars",
+			[]string{"prod/a.tfvars", "prod/b.tfvars"},
+			map[string]interface{}{"foo": "b", "a": "yes", "b": "yes"},
+			false,
+		},
+
+		// Patterns matching nothing are errors
+		{"prod/*.hcl", nil, nil, true},
+		{"empty", nil, nil, true},
+		{"missing.tfvars", nil, nil, true},
+	}
+
+	for _, tc := range cases {
+		var vars map[string]interface{}
+		var loaded []string
+		f := &FlagVarFile{Variables: &vars, Files: &loaded}
+		err := f.Set(filepath.Join(td, tc.Input))
+		if err != nil != tc.Error {
+			t.Fatalf("%s: bad error: %s", tc.Input, err)
+		}
+		if tc.Error {
+			continue
+		}
+
+		var expected []string
+		for _, name := range tc.Files {
+			expected = append(expected, filepath.Join(td, name))
+		}
+		if !reflect.DeepEqual(loaded, expected) {
+			t.Fatalf("%s: bad files: %#v", tc.Input, loaded)
+		}
+		if !reflect.DeepEqual(vars, tc.Output) {
+			t.Fatalf("%s: bad variables: %#v", tc.Input, vars)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/state/remote"
+	"github.com/mitchellh/colorstring"
+)
+
+// FormatDiagnosticOpts are the options for formatting an error.
+type FormatDiagnosticOpts struct {
+	// Err is the error to format. This is required.
+	Err error
+
+	// Color is the colorizer. This is required.
+	Color *colorstring.Colorize
+}
+
+// FormatDiagnostic takes an error and returns a string for showing it to
+// the user: a red "Error:" header with what failed, the errors it wraps
+// indented below it, and a suggestion of how to resolve it if it is one
+// of the errors we know how to resolve.
+func FormatDiagnostic(opts *FormatDiagnosticOpts) string {
+	if opts.Color == nil {
+		panic("colorize not given")
+	}
+
+	// The messages are written without colorizing them, since they may
+	// contain brackets such as those of resource addresses.
+	msgs := diagnosticMessages(opts.Err)
+	var buf bytes.Buffer
+	buf.WriteString(opts.Color.Color("[reset][bold][red]Error:[reset] "))
+	buf.WriteString(msgs[0])
+	buf.WriteString("\n")
+	for _, msg := range msgs[1:] {
+		buf.WriteString("\n")
+		for _, line := range strings.Split(strings.TrimRight(msg, "\n"), "\n") {
+			if line != "" {
+				line = "  " + line
+			}
+			buf.WriteString(line + "\n")
+		}
+	}
+
+	if suggestion := diagnosticSuggestion(opts.Err); suggestion != "" {
+		buf.WriteString("\n")
+		buf.WriteString(opts.Color.Color("[reset][bold]Suggestion:[reset] "))
+		buf.WriteString(suggestion)
+		buf.WriteString("\n")
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// diagnosticMessages splits an error wrapped with errwrap.Wrapf into the
+// message of each error in the chain, outermost first, with the message
+// of the error it wraps removed. The chain stops at an error whose message
+// doesn't end with the message of the error it wraps, such as one wrapped
+// with errwrap.Wrap only to be able to check its type.
+func diagnosticMessages(err error) []string {
+	var msgs []string
+	for {
+		w, ok := err.(errwrap.Wrapper)
+		if !ok {
+			break
+		}
+		wrapped := w.WrappedErrors()
+		if len(wrapped) != 2 || wrapped[0].Error() != err.Error() {
+			break
+		}
+
+		msg, inner := err.Error(), wrapped[1].Error()
+		if inner == "" || !strings.HasSuffix(msg, inner) {
+			break
+		}
+		head := strings.TrimRight(strings.TrimSuffix(msg, inner), ": \n")
+		if head == "" {
+			break
+		}
+
+		msgs = append(msgs, head)
+		err = wrapped[1]
+	}
+
+	return append(msgs, err.Error())
+}
+
+// diagnosticSuggestion returns how to resolve the error, or "" if it isn't
+// one of the errors we know how to resolve.
+func diagnosticSuggestion(err error) string {
+	if e, ok := errwrap.GetType(err, new(ErrStateLocked)).(*ErrStateLocked); ok {
+		unlock := "terraform force-unlock LOCK_ID"
+		if e.Lock != nil && e.Lock.Info != nil && e.Lock.Info.ID != "" {
+			unlock = "terraform force-unlock " + e.Lock.Info.ID
+		}
+
+		return fmt.Sprintf(
+			"Wait for the operation holding the lock to finish, or retry with\n"+
+				"a longer -lock-timeout. If it is no longer running, release the lock\n"+
+				"with '%s'.", unlock)
+	}
+
+	if errwrap.ContainsType(err, new(ErrStateNotFound)) {
+		return "Check that -state is the path of your state file. If the state is\n" +
+			"stored remotely, configure it with 'terraform remote config' first."
+	}
+
+	if errwrap.ContainsType(err, new(remote.ConflictError)) {
+		return "Run 'terraform refresh' to read the latest remote state, then run\n" +
+			"the command again."
+	}
+
+	return ""
+}
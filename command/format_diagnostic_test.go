@@ -0,0 +1,75 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/state/remote"
+	"github.com/mitchellh/colorstring"
+)
+
+func TestFormatDiagnostic_golden(t *testing.T) {
+	cases := map[string]error{
+		"locked": errwrap.Wrapf("Error locking state: {{err}}", &ErrStateLocked{
+			Lock: &state.LockError{
+				Info: &state.LockInfo{
+					ID:          "c7a5bd2e-7e52-4d6a-b4a1-8e6b2c1f0e9d",
+					Operation:   "apply",
+					OperationID: "f4ba5a22-2c2b-4f0e-a3e4-2d7b8e43c7a1",
+					Pid:         4242,
+					Hostname:    "build-01",
+					Version:     "0.8.0",
+					Created:     time.Date(2017, 1, 2, 15, 4, 5, 0, time.UTC),
+					Path:        "terraform.tfstate",
+				},
+			},
+		}),
+
+		"not-found": errwrap.Wrapf("Failed to load state: {{err}}", errwrap.Wrap(
+			errors.New("The Terraform state file for your infrastructure does not\nexist."),
+			&ErrStateNotFound{Path: "terraform.tfstate"})),
+
+		"conflict": errwrap.Wrapf("Error writing state file: {{err}}", &remote.ConflictError{
+			Serial:     4,
+			ReadSerial: 3,
+		}),
+	}
+
+	for name, err := range cases {
+		actual := FormatDiagnostic(&FormatDiagnosticOpts{
+			Err: err,
+			Color: &colorstring.Colorize{
+				Colors:  colorstring.DefaultColors,
+				Disable: true,
+			},
+		})
+
+		golden := filepath.Join(testFixturePath("format-diagnostic"), name+".txt")
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		if actual+"\n" != string(expected) {
+			t.Fatalf("%s: expected:\n\n%s\n\ngot:\n\n%s", name, expected, actual)
+		}
+	}
+}
+
+func TestFormatDiagnostic_unknown(t *testing.T) {
+	actual := FormatDiagnostic(&FormatDiagnosticOpts{
+		Err: fmt.Errorf("something broke"),
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+	})
+	if expected := "Error: something broke"; actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
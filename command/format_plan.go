@@ -1,11 +1,16 @@
 package command
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"path"
 	"sort"
 	"strings"
 
+	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/colorstring"
 )
@@ -21,38 +26,192 @@ type FormatPlanOpts struct {
 	// ModuleDepth is the depth of the modules to expand. By default this
 	// is zero which will not expand modules at all.
 	ModuleDepth int
+
+	// Renderer is the renderer used to output the plan. This is optional,
+	// the plan is rendered as human-readable text by default.
+	Renderer PlanRenderer
+
+	// MaxResources, if greater than zero, is the maximum number of
+	// resources shown for each kind of change, such as resources to add.
+	// The number of resources that weren't shown is output after the plan.
+	// This is only used by the default text format.
+	MaxResources int
+
+	// ShowSensitive, if true, shows the values of sensitive attributes
+	// instead of "<sensitive>". This is only meant for debugging locally,
+	// since the values end up in logs wherever the plan is output.
+	ShowSensitive bool
+
+	// Filter, if not empty, are glob patterns such as "aws_iam_*" that
+	// limit the resources shown to those whose address or type matches
+	// one of them. The number of resources hidden is output after the
+	// plan, which itself is unchanged. This is only used by the default
+	// text format.
+	Filter []string
+}
+
+// formatPlanSensitiveNames are the words that mark an attribute as
+// sensitive when they are part of its name, for attributes that hold
+// secrets but aren't marked as sensitive by their provider.
+var formatPlanSensitiveNames = []string{"password", "secret", "token"}
+
+// formatPlanSensitive returns whether the value of the attribute with the
+// given key should be hidden when formatting a plan. Attributes that the
+// provider marks as sensitive are hidden, as well as those whose name
+// suggests that they hold a secret.
+func formatPlanSensitive(
+	key string, attrDiff *terraform.ResourceAttrDiff, opts *FormatPlanOpts) bool {
+	if opts.ShowSensitive {
+		return false
+	}
+	if attrDiff.Sensitive {
+		return true
+	}
+
+	// Only the last part of the key is the name of the attribute, the
+	// rest are its parents and indexes.
+	name := key
+	if idx := strings.LastIndex(key, "."); idx >= 0 {
+		name = key[idx+1:]
+	}
+	name = strings.ToLower(name)
+	for _, s := range formatPlanSensitiveNames {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatPlanFiltered returns whether the resource with the given address
+// is hidden by the filter in the options. The key is the resource in the
+// diff of its module, which its type is taken from.
+func formatPlanFiltered(addr, key string, opts *FormatPlanOpts) bool {
+	if len(opts.Filter) == 0 {
+		return false
+	}
+
+	var resourceType string
+	if rsk, err := terraform.ParseResourceStateKey(key); err == nil {
+		resourceType = rsk.Type
+	}
+
+	for _, pattern := range opts.Filter {
+		if ok, _ := path.Match(pattern, addr); ok {
+			return false
+		}
+		if ok, _ := path.Match(pattern, resourceType); ok && resourceType != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PlanRenderer is the interface implemented by things that can render a
+// plan, so that plans can be output in formats other than the default
+// human-readable text.
+type PlanRenderer interface {
+	RenderPlan(w io.Writer, opts *FormatPlanOpts) error
 }
 
-// FormatPlan takes a plan and returns a
+// FormatPlan takes a plan and returns a string with the formatted plan.
+//
+// The whole formatted plan is built in memory, so FormatPlanWrite should
+// be preferred for plans that may be large.
 func FormatPlan(opts *FormatPlanOpts) string {
+	var buf bytes.Buffer
+	if err := FormatPlanWrite(&buf, opts); err != nil {
+		// Writing to a bytes.Buffer never fails, but a renderer might
+		return fmt.Sprintf("Error formatting plan: %s", err)
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// FormatPlanWrite takes a plan and writes the formatted plan to the given
+// writer as it is formatted. If the options have a Renderer, it is used to
+// render the plan instead of the default text format.
+func FormatPlanWrite(w io.Writer, opts *FormatPlanOpts) error {
+	if opts.Renderer != nil {
+		return opts.Renderer.RenderPlan(w, opts)
+	}
+
 	p := opts.Plan
-	if p.Diff == nil || p.Diff.Empty() {
-		return "This plan does nothing."
+	outputs := planOutputChanges(p)
+	if (p.Diff == nil || p.Diff.Empty()) && len(outputs) == 0 {
+		_, err := io.WriteString(w, "This plan does nothing.")
+		return err
 	}
 
 	if opts.Color == nil {
+		colors, _ := roleColors(nil)
 		opts.Color = &colorstring.Colorize{
-			Colors: colorstring.DefaultColors,
+			Colors: colors,
 			Reset:  false,
 		}
 	}
 
-	buf := new(bytes.Buffer)
-	for _, m := range p.Diff.Modules {
-		if len(m.Path)-1 <= opts.ModuleDepth || opts.ModuleDepth == -1 {
-			formatPlanModuleExpand(buf, m, opts)
-		} else {
-			formatPlanModuleSingle(buf, m, opts)
+	// The buffered writer keeps the first write error, which is returned
+	// by Flush, so we don't need to check every write below.
+	buf := bufio.NewWriter(w)
+	shown := make(map[string]int)
+	var hidden int
+	if p.Diff != nil {
+		for _, m := range p.Diff.Modules {
+			if len(m.Path)-1 <= opts.ModuleDepth || opts.ModuleDepth == -1 {
+				formatPlanModuleExpand(buf, m, opts, shown, &hidden)
+			} else {
+				formatPlanModuleSingle(buf, m, opts)
+			}
 		}
 	}
 
-	return strings.TrimSpace(buf.String())
+	// Tell how many resources weren't shown for each kind of change
+	for _, symbol := range formatPlanSymbols {
+		if n := shown[symbol] - opts.MaxResources; opts.MaxResources > 0 && n > 0 {
+			buf.WriteString(fmt.Sprintf(
+				"... and %d more resource(s) %s (save the plan with -out and\n"+
+					"use \"terraform show\" to see all of them)\n\n",
+				n, formatPlanSymbolNames[symbol]))
+		}
+	}
+
+	if hidden > 0 {
+		buf.WriteString(fmt.Sprintf(
+			"%d resource(s) hidden by filter (they are still part of the plan)\n\n",
+			hidden))
+	}
+
+	formatPlanOutputs(buf, outputs, opts)
+
+	return buf.Flush()
+}
+
+// formatPlanSymbols are the symbols for the kinds of change to a resource,
+// in the order the truncated resources are reported in.
+var formatPlanSymbols = []string{"+", "-/+", "~", "-", "<="}
+
+// formatPlanSymbolNames describe the kinds of change to a resource.
+var formatPlanSymbolNames = map[string]string{
+	"+":   "to add",
+	"-/+": "to replace",
+	"~":   "to change",
+	"-":   "to destroy",
+	"<=":  "to read",
 }
 
 // formatPlanModuleExpand will output the given module and all of its
-// resources.
+// resources. The number of resources of each kind of change is counted
+// in shown, to stop outputting them once opts.MaxResources is reached,
+// and the number of resources hidden by opts.Filter in hidden.
 func formatPlanModuleExpand(
-	buf *bytes.Buffer, m *terraform.ModuleDiff, opts *FormatPlanOpts) {
+	buf *bufio.Writer,
+	m *terraform.ModuleDiff,
+	opts *FormatPlanOpts,
+	shown map[string]int,
+	hidden *int) {
 	// Ignore empty diffs
 	if m.Empty() {
 		return
@@ -80,55 +239,30 @@ func formatPlanModuleExpand(
 
 		dataSource := strings.HasPrefix(name, "data.")
 
+		key := name
 		if moduleName != "" {
 			name = moduleName + "." + name
 		}
 
+		if formatPlanFiltered(name, key, opts) {
+			*hidden++
+			continue
+		}
+
 		// Determine the color for the text (green for adding, yellow
 		// for change, red for delete), and symbol, and output the
 		// resource header.
-		color := "yellow"
-		symbol := "~"
-		oldValues := true
-		switch rdiff.ChangeType() {
-		case terraform.DiffDestroyCreate:
-			color = "green"
-			symbol = "-/+"
-		case terraform.DiffCreate:
-			color = "green"
-			symbol = "+"
-			oldValues = false
-
-			// If we're "creating" a data resource then we'll present it
-			// to the user as a "read" operation, so it's clear that this
-			// operation won't change anything outside of the Terraform state.
-			// Unfortunately by the time we get here we only have the name
-			// to work with, so we need to cheat and exploit knowledge of the
-			// naming scheme for data resources.
-			if dataSource {
-				symbol = "<="
-				color = "cyan"
-			}
-		case terraform.DiffDestroy:
-			color = "red"
-			symbol = "-"
-		}
+		role, symbol, oldValues := formatPlanResourceChange(rdiff, dataSource)
+		color := roleColor(opts.Color, role)
 
-		var extraAttr []string
-		if rdiff.DestroyTainted {
-			extraAttr = append(extraAttr, "tainted")
-		}
-		if rdiff.DestroyDeposed {
-			extraAttr = append(extraAttr, "deposed")
-		}
-		var extraStr string
-		if len(extraAttr) > 0 {
-			extraStr = fmt.Sprintf(" (%s)", strings.Join(extraAttr, ", "))
+		shown[symbol]++
+		if opts.MaxResources > 0 && shown[symbol] > opts.MaxResources {
+			continue
 		}
 
 		buf.WriteString(opts.Color.Color(fmt.Sprintf(
 			"[%s]%s %s%s\n",
-			color, symbol, name, extraStr)))
+			color, symbol, name, formatPlanAnnotation(rdiff))))
 
 		// Get all the attributes that are changing, and sort them. Also
 		// determine the longest key so that we can align them all.
@@ -151,25 +285,29 @@ func formatPlanModuleExpand(
 		for _, attrK := range keys {
 			attrDiff := rdiff.Attributes[attrK]
 
+			sensitive := formatPlanSensitive(attrK, attrDiff, opts)
+
 			v := attrDiff.New
 			if v == "" && attrDiff.NewComputed {
 				v = "<computed>"
 			}
 
-			if attrDiff.Sensitive {
+			if sensitive {
 				v = "<sensitive>"
 			}
 
 			updateMsg := ""
 			if attrDiff.RequiresNew && rdiff.Destroy {
-				updateMsg = opts.Color.Color(" [red](forces new resource)")
-			} else if attrDiff.Sensitive && oldValues {
-				updateMsg = opts.Color.Color(" [yellow](attribute changed)")
+				updateMsg = opts.Color.Color(fmt.Sprintf(
+					" [%s](forces new resource)", roleColor(opts.Color, ColorRoleDelete)))
+			} else if sensitive && oldValues {
+				updateMsg = opts.Color.Color(fmt.Sprintf(
+					" [%s](attribute changed)", roleColor(opts.Color, ColorRoleUpdate)))
 			}
 
 			if oldValues {
 				var u string
-				if attrDiff.Sensitive {
+				if sensitive {
 					u = "<sensitive>"
 				} else {
 					u = attrDiff.Old
@@ -196,10 +334,135 @@ func formatPlanModuleExpand(
 	}
 }
 
+// formatPlanSummary returns the summary line of a plan with the numbers of
+// resources to add, change and destroy counted by the hook, to be colorized.
+func formatPlanSummary(h *CountHook) string {
+	// Mention the changes that aren't caused by the configuration
+	var extra []string
+	if n := h.ToReplaceTainted; n > 0 {
+		extra = append(extra, fmt.Sprintf("%d tainted", n))
+	}
+	if n := h.ToRemoveDeposed; n > 0 {
+		extra = append(extra, fmt.Sprintf("%d deposed", n))
+	}
+	var extraStr string
+	if len(extra) > 0 {
+		extraStr = fmt.Sprintf(" (%s)", strings.Join(extra, ", "))
+	}
+
+	return fmt.Sprintf(
+		"[reset][bold]Plan:[reset] "+
+			"%d to add, %d to change, %d to destroy%s.",
+		h.ToAdd+h.ToRemoveAndAdd,
+		h.ToChange,
+		h.ToRemove+h.ToRemoveAndAdd,
+		extraStr)
+}
+
+// planTypeSummaryMin is the number of changed resources above which the
+// summary of a plan is followed by the changes by resource type.
+const planTypeSummaryMin = 5
+
+// formatPlanTypeSummary returns a table of the planned changes counted by
+// the hook for each resource type, to be colorized and output after the
+// summary line. It is empty if no more than planTypeSummaryMin resources
+// change, since the summary line says enough then.
+func formatPlanTypeSummary(h *CountHook) string {
+	changed := h.ToAdd + h.ToChange + h.ToRemove + h.ToRemoveAndAdd
+	if changed <= planTypeSummaryMin || len(h.ToByType) == 0 {
+		return ""
+	}
+
+	types := make([]string, 0, len(h.ToByType))
+	typeLen := 0
+	for t := range h.ToByType {
+		types = append(types, t)
+		if len(t) > typeLen {
+			typeLen = len(t)
+		}
+	}
+	sort.Strings(types)
+
+	var buf bytes.Buffer
+	buf.WriteString("[reset][bold]Changes by resource type:[reset]\n\n")
+	for _, t := range types {
+		c := h.ToByType[t]
+
+		var parts []string
+		if c.ToAdd > 0 {
+			parts = append(parts, fmt.Sprintf("%d to add", c.ToAdd))
+		}
+		if c.ToChange > 0 {
+			parts = append(parts, fmt.Sprintf("%d to change", c.ToChange))
+		}
+		if c.ToRemove > 0 {
+			parts = append(parts, fmt.Sprintf("%d to destroy", c.ToRemove))
+		}
+
+		buf.WriteString(fmt.Sprintf("  %s%s  %s\n",
+			t, strings.Repeat(" ", typeLen-len(t)), strings.Join(parts, ", ")))
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// formatPlanAnnotation returns the note shown after the name of a resource
+// that explains a change that isn't caused by its configuration: the
+// resource is tainted, or it has deposed instances left over from a
+// previous apply. It is empty if there is nothing to explain.
+func formatPlanAnnotation(rdiff *terraform.InstanceDiff) string {
+	var notes []string
+	if rdiff.DestroyTainted {
+		if rdiff.ChangeType() == terraform.DiffDestroyCreate {
+			notes = append(notes, "tainted, forces replacement")
+		} else {
+			notes = append(notes, "tainted")
+		}
+	}
+	if rdiff.Replace {
+		notes = append(notes, "requested replacement")
+	}
+	if rdiff.DestroyDeposed {
+		notes = append(notes, "deposed object will be destroyed")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s)", strings.Join(notes, "; "))
+}
+
+// formatPlanResourceChange returns the color role and symbol used to show
+// the change to a resource, and whether the old values of its attributes
+// should be shown.
+func formatPlanResourceChange(
+	rdiff *terraform.InstanceDiff, dataSource bool) (string, string, bool) {
+	switch rdiff.ChangeType() {
+	case terraform.DiffDestroyCreate:
+		return ColorRoleCreate, "-/+", true
+	case terraform.DiffCreate:
+		// If we're "creating" a data resource then we'll present it
+		// to the user as a "read" operation, so it's clear that this
+		// operation won't change anything outside of the Terraform state.
+		// Unfortunately by the time we get here we only have the name
+		// to work with, so we need to cheat and exploit knowledge of the
+		// naming scheme for data resources.
+		if dataSource {
+			return ColorRoleRead, "<=", false
+		}
+
+		return ColorRoleCreate, "+", false
+	case terraform.DiffDestroy:
+		return ColorRoleDelete, "-", true
+	}
+
+	return ColorRoleUpdate, "~", true
+}
+
 // formatPlanModuleSingle will output the given module and all of its
 // resources.
 func formatPlanModuleSingle(
-	buf *bytes.Buffer, m *terraform.ModuleDiff, opts *FormatPlanOpts) {
+	buf *bufio.Writer, m *terraform.ModuleDiff, opts *FormatPlanOpts) {
 	// Ignore empty diffs
 	if m.Empty() {
 		return
@@ -210,16 +473,17 @@ func formatPlanModuleSingle(
 	// Determine the color for the text (green for adding, yellow
 	// for change, red for delete), and symbol, and output the
 	// resource header.
-	color := "yellow"
+	role := ColorRoleUpdate
 	symbol := "~"
 	switch m.ChangeType() {
 	case terraform.DiffCreate:
-		color = "green"
+		role = ColorRoleCreate
 		symbol = "+"
 	case terraform.DiffDestroy:
-		color = "red"
+		role = ColorRoleDelete
 		symbol = "-"
 	}
+	color := roleColor(opts.Color, role)
 
 	buf.WriteString(opts.Color.Color(fmt.Sprintf(
 		"[%s]%s %s\n",
@@ -229,3 +493,154 @@ func formatPlanModuleSingle(
 		len(m.Resources)))
 	buf.WriteString(opts.Color.Color("[reset]\n"))
 }
+
+// planOutputChange is a planned change to a root module output. Action is
+// "+" for new outputs, "~" for changed ones and "-" for outputs that were
+// removed from the configuration. Old is nil for new outputs and New is
+// nil for removed ones.
+type planOutputChange struct {
+	Name   string
+	Action string
+	Old    *terraform.OutputState
+	New    *terraform.OutputState
+}
+
+// planOutputChanges returns the changes to the root module outputs, sorted
+// by name, by comparing the outputs from before the plan with the planned
+// outputs. It is empty for plans that don't have planned
+// outputs, such as destroy plans.
+func planOutputChanges(p *terraform.Plan) []*planOutputChange {
+	if p.Outputs == nil {
+		return nil
+	}
+
+	old := p.PriorOutputs
+
+	// The planned outputs still have the ones that were removed from the
+	// configuration, since they are only deleted when applying.
+	declared := make(map[string]struct{})
+	if p.Module != nil && p.Module.Config() != nil {
+		for _, o := range p.Module.Config().Outputs {
+			declared[o.Name] = struct{}{}
+		}
+	}
+
+	var result []*planOutputChange
+	for name, v := range p.Outputs {
+		if _, ok := declared[name]; !ok {
+			continue
+		}
+
+		prev, ok := old[name]
+		switch {
+		case !ok:
+			result = append(result, &planOutputChange{Name: name, Action: "+", New: v})
+		case !prev.Equal(v):
+			result = append(result, &planOutputChange{Name: name, Action: "~", Old: prev, New: v})
+		}
+	}
+	for name, v := range old {
+		if _, ok := declared[name]; !ok {
+			result = append(result, &planOutputChange{Name: name, Action: "-", Old: v})
+		}
+	}
+
+	sort.Sort(planOutputChangesByName(result))
+	return result
+}
+
+type planOutputChangesByName []*planOutputChange
+
+func (s planOutputChangesByName) Len() int           { return len(s) }
+func (s planOutputChangesByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s planOutputChangesByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// formatPlanOutputs writes the "Outputs:" section of a plan with the given
+// output changes, in the same layout as the changes to resources.
+func formatPlanOutputs(
+	buf *bufio.Writer, changes []*planOutputChange, opts *FormatPlanOpts) {
+	if len(changes) == 0 {
+		return
+	}
+
+	nameLen := 0
+	for _, c := range changes {
+		if len(c.Name) > nameLen {
+			nameLen = len(c.Name)
+		}
+	}
+
+	buf.WriteString(opts.Color.Color("[reset][bold]Outputs:[reset]\n\n"))
+	for _, c := range changes {
+		role := ColorRoleUpdate
+		switch c.Action {
+		case "+":
+			role = ColorRoleCreate
+		case "-":
+			role = ColorRoleDelete
+		}
+		color := roleColor(opts.Color, role)
+
+		padding := strings.Repeat(" ", nameLen-len(c.Name))
+		var line string
+		switch c.Action {
+		case "+":
+			line = fmt.Sprintf("%s:%s %s", c.Name, padding,
+				formatPlanOutputValue(c.New, opts))
+		case "~":
+			line = fmt.Sprintf("%s:%s %s => %s", c.Name, padding,
+				formatPlanOutputValue(c.Old, opts), formatPlanOutputValue(c.New, opts))
+		default:
+			line = c.Name
+		}
+
+		buf.WriteString(opts.Color.Color(fmt.Sprintf(
+			"[%s]%s [reset]%s\n", color, c.Action, line)))
+	}
+	buf.WriteString("\n")
+}
+
+// formatPlanOutputValue returns the value of an output as it is shown in a
+// plan. Sensitive values are hidden, and values that won't be known until
+// apply are shown as "<computed>".
+func formatPlanOutputValue(o *terraform.OutputState, opts *FormatPlanOpts) string {
+	if o.Sensitive && !opts.ShowSensitive {
+		return "<sensitive>"
+	}
+	if outputValueComputed(o.Value) {
+		return "<computed>"
+	}
+
+	if s, ok := o.Value.(string); ok {
+		return fmt.Sprintf("%#v", s)
+	}
+	data, err := json.Marshal(o.Value)
+	if err != nil {
+		return fmt.Sprintf("%v", o.Value)
+	}
+
+	return string(data)
+}
+
+// outputValueComputed returns whether the value of an output, or any of
+// the elements of a list or map value, isn't known until apply.
+func outputValueComputed(v interface{}) bool {
+	switch v := v.(type) {
+	case string:
+		return v == config.UnknownVariableValue
+	case []interface{}:
+		for _, e := range v {
+			if outputValueComputed(e) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if outputValueComputed(e) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
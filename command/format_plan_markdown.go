@@ -0,0 +1,159 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// PlanMarkdown is a PlanRenderer that renders a plan as Markdown, such as
+// for pasting into a pull request comment. The plan is rendered as a
+// summary table of the changes followed by a collapsible section with a
+// diff block for each module.
+//
+// The color and module depth options are ignored: Markdown has no colors
+// and every module is always expanded.
+type PlanMarkdown struct{}
+
+// RenderPlan implements PlanRenderer.
+func (PlanMarkdown) RenderPlan(w io.Writer, opts *FormatPlanOpts) error {
+	p := opts.Plan
+	if p.Diff == nil || p.Diff.Empty() {
+		_, err := io.WriteString(w, "This plan does nothing.\n")
+		return err
+	}
+
+	// The buffered writer keeps the first write error, which is returned
+	// by Flush, so we don't need to check every write below.
+	buf := bufio.NewWriter(w)
+
+	var add, change, destroy int
+	for _, m := range p.Diff.Modules {
+		for name, rdiff := range m.Resources {
+			// We don't count anything for data sources
+			if strings.HasPrefix(name, "data.") {
+				continue
+			}
+
+			switch rdiff.ChangeType() {
+			case terraform.DiffDestroyCreate:
+				add++
+				destroy++
+			case terraform.DiffCreate:
+				add++
+			case terraform.DiffDestroy:
+				destroy++
+			case terraform.DiffUpdate:
+				change++
+			}
+		}
+	}
+
+	buf.WriteString("| Add | Change | Destroy |\n")
+	buf.WriteString("|----:|-------:|--------:|\n")
+	fmt.Fprintf(buf, "| %d | %d | %d |\n", add, change, destroy)
+
+	for _, m := range p.Diff.Modules {
+		formatPlanMarkdownModule(buf, m, opts)
+	}
+
+	return buf.Flush()
+}
+
+// formatPlanMarkdownModule outputs a collapsible section with a diff block
+// of the resources in the given module.
+func formatPlanMarkdownModule(
+	buf *bufio.Writer, m *terraform.ModuleDiff, opts *FormatPlanOpts) {
+	// Ignore empty diffs
+	if m.Empty() {
+		return
+	}
+
+	moduleName := "root"
+	if !m.IsRoot() {
+		moduleName = fmt.Sprintf("module.%s", strings.Join(m.Path[1:], "."))
+	}
+
+	names := make([]string, 0, len(m.Resources))
+	for name, rdiff := range m.Resources {
+		if !rdiff.Empty() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(buf, "\n<details><summary>%s (%d resource(s))</summary>\n\n",
+		moduleName, len(names))
+	buf.WriteString("```diff\n")
+
+	for _, name := range names {
+		rdiff := m.Resources[name]
+		_, symbol, oldValues := formatPlanResourceChange(
+			rdiff, strings.HasPrefix(name, "data."))
+
+		fmt.Fprintf(buf, "%s %s%s\n", symbol, name, formatPlanAnnotation(rdiff))
+
+		// Every line of the resource is prefixed by the first character
+		// of its symbol so that the diff highlighting covers all of it.
+		// Data source reads don't change anything, so they are shown as
+		// context instead.
+		prefix := symbol[:1]
+		if symbol == "<=" {
+			prefix = " "
+		}
+
+		keyLen := 0
+		keys := make([]string, 0, len(rdiff.Attributes))
+		for key := range rdiff.Attributes {
+			// Skip the ID since we do that specially
+			if key == "id" {
+				continue
+			}
+
+			keys = append(keys, key)
+			if len(key) > keyLen {
+				keyLen = len(key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, attrK := range keys {
+			attrDiff := rdiff.Attributes[attrK]
+			sensitive := formatPlanSensitive(attrK, attrDiff, opts)
+
+			v := attrDiff.New
+			if v == "" && attrDiff.NewComputed {
+				v = "<computed>"
+			}
+
+			u := attrDiff.Old
+			if sensitive {
+				u = "<sensitive>"
+				v = "<sensitive>"
+			}
+
+			updateMsg := ""
+			if attrDiff.RequiresNew && rdiff.Destroy {
+				updateMsg = " (forces new resource)"
+			} else if sensitive && oldValues {
+				updateMsg = " (attribute changed)"
+			}
+
+			pad := strings.Repeat(" ", keyLen-len(attrK))
+			if oldValues {
+				fmt.Fprintf(buf, "%s     %s:%s %#v => %#v%s\n",
+					prefix, attrK, pad, u, v, updateMsg)
+			} else {
+				fmt.Fprintf(buf, "%s     %s:%s %#v%s\n",
+					prefix, attrK, pad, v, updateMsg)
+			}
+		}
+	}
+
+	buf.WriteString("```\n\n")
+	buf.WriteString("</details>\n")
+}
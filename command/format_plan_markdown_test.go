@@ -0,0 +1,115 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestPlanMarkdown_impl(t *testing.T) {
+	var _ PlanRenderer = PlanMarkdown{}
+}
+
+func TestPlanMarkdown(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.add": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": &terraform.ResourceAttrDiff{
+									NewComputed: true,
+									RequiresNew: true,
+								},
+								"ami": &terraform.ResourceAttrDiff{
+									New: "ami-123",
+								},
+								"private_ip": &terraform.ResourceAttrDiff{
+									NewComputed: true,
+								},
+							},
+						},
+						"aws_instance.change": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"tags.Name": &terraform.ResourceAttrDiff{
+									Old: "foo",
+									New: "bar",
+								},
+								"password": &terraform.ResourceAttrDiff{
+									Old: "hunter2",
+									New: "hunter3",
+								},
+							},
+						},
+						"aws_instance.destroy": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+						"data.aws_ami.read": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": &terraform.ResourceAttrDiff{
+									NewComputed: true,
+									RequiresNew: true,
+								},
+								"name": &terraform.ResourceAttrDiff{
+									New: "ubuntu",
+								},
+							},
+						},
+					},
+				},
+				&terraform.ModuleDiff{
+					Path: []string{"root", "child"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.replace": &terraform.InstanceDiff{
+							Destroy: true,
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami": &terraform.ResourceAttrDiff{
+									Old:         "ami-123",
+									New:         "ami-456",
+									RequiresNew: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := FormatPlanWrite(&buf, &FormatPlanOpts{
+		Plan:     plan,
+		Renderer: PlanMarkdown{},
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	golden := filepath.Join(testFixturePath("format-plan-markdown"), "plan.md")
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if actual := buf.String(); actual != string(expected) {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+func TestPlanMarkdown_empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FormatPlanWrite(&buf, &FormatPlanOpts{
+		Plan:     &terraform.Plan{},
+		Renderer: PlanMarkdown{},
+	}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if actual := buf.String(); actual != "This plan does nothing.\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
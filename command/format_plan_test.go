@@ -1,9 +1,15 @@
 package command
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/colorstring"
 )
@@ -36,7 +42,55 @@ func TestFormatPlan_destroyDeposed(t *testing.T) {
 	actual := FormatPlan(opts)
 
 	expected := strings.TrimSpace(`
-- aws_instance.foo (deposed)
+- aws_instance.foo (deposed object will be destroyed)
+	`)
+	if actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+func TestFormatPlan_destroyTainted(t *testing.T) {
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_instance.foo": &terraform.InstanceDiff{
+							DestroyTainted: true,
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami": &terraform.ResourceAttrDiff{
+									Old:         "foo",
+									New:         "foo",
+									RequiresNew: true,
+								},
+							},
+						},
+						"aws_instance.bar": &terraform.InstanceDiff{
+							Destroy:        true,
+							DestroyDeposed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+	opts := &FormatPlanOpts{
+		Plan: plan,
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+		ModuleDepth: 1,
+	}
+
+	actual := FormatPlan(opts)
+
+	expected := strings.TrimSpace(`
+- aws_instance.bar (deposed object will be destroyed)
+
+-/+ aws_instance.foo (tainted, forces replacement)
+    ami: "foo" => "foo"
 	`)
 	if actual != expected {
 		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
@@ -87,6 +141,277 @@ func TestFormatPlan_displayInterpolations(t *testing.T) {
 	}
 }
 
+// Test that sensitive values are hidden and the attributes are aligned
+func TestFormatPlan_sensitive(t *testing.T) {
+	opts := &FormatPlanOpts{
+		Plan: testFormatPlanSensitive(),
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+		ModuleDepth: 1,
+	}
+
+	actual := FormatPlan(opts)
+
+	expected := strings.TrimSpace(`
+~ aws_db_instance.foo
+    ami:                 "ami-123" => "ami-456"
+    api_token:           "<sensitive>" => "<sensitive>" (attribute changed)
+    master_password:     "<sensitive>" => "<sensitive>" (attribute changed)
+    provider_credential: "<sensitive>" => "<sensitive>" (attribute changed)
+	`)
+	if actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+// Test that sensitive values can be shown for debugging
+func TestFormatPlan_showSensitive(t *testing.T) {
+	opts := &FormatPlanOpts{
+		Plan: testFormatPlanSensitive(),
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+		ModuleDepth:   1,
+		ShowSensitive: true,
+	}
+
+	actual := FormatPlan(opts)
+	for _, v := range []string{"hunter2", "abc", "def", "s3cr3t"} {
+		if !strings.Contains(actual, v) {
+			t.Fatalf("expected %q in:\n\n%s", v, actual)
+		}
+	}
+	if strings.Contains(actual, "<sensitive>") {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestFormatPlanSensitive(t *testing.T) {
+	cases := []struct {
+		Key       string
+		Sensitive bool
+		Expected  bool
+	}{
+		{"ami", false, false},
+		{"ami", true, true},
+		{"password", false, true},
+		{"connection.Password", false, true},
+		{"client_secret", false, true},
+		{"tags.auth_token", false, true},
+		{"tokens.#", false, false},
+		{"password_length.0.size", false, false},
+	}
+
+	for _, tc := range cases {
+		attrDiff := &terraform.ResourceAttrDiff{Sensitive: tc.Sensitive}
+		actual := formatPlanSensitive(tc.Key, attrDiff, new(FormatPlanOpts))
+		if actual != tc.Expected {
+			t.Fatalf("%s: expected %v", tc.Key, tc.Expected)
+		}
+	}
+}
+
+// testFormatPlanSensitive returns a plan with attributes that are
+// sensitive by their name and by the provider schema.
+func testFormatPlanSensitive() *terraform.Plan {
+	return &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_db_instance.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami": &terraform.ResourceAttrDiff{
+									Old: "ami-123",
+									New: "ami-456",
+								},
+								"api_token": &terraform.ResourceAttrDiff{
+									Old: "abc",
+									New: "def",
+								},
+								"master_password": &terraform.ResourceAttrDiff{
+									Old: "",
+									New: "hunter2",
+								},
+								"provider_credential": &terraform.ResourceAttrDiff{
+									Old:       "",
+									New:       "s3cr3t",
+									Sensitive: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Test that only the first resources of each kind of change are shown
+func TestFormatPlan_maxResources(t *testing.T) {
+	resources := make(map[string]*terraform.InstanceDiff)
+	for i := 0; i < 50; i++ {
+		resources[fmt.Sprintf("aws_instance.add.%d", i)] = &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": &terraform.ResourceAttrDiff{
+					New:         "ami-123",
+					RequiresNew: true,
+				},
+			},
+		}
+	}
+	for i := 0; i < 3; i++ {
+		resources[fmt.Sprintf("aws_instance.destroy.%d", i)] = &terraform.InstanceDiff{
+			Destroy: true,
+		}
+	}
+
+	opts := &FormatPlanOpts{
+		Plan: &terraform.Plan{
+			Diff: &terraform.Diff{
+				Modules: []*terraform.ModuleDiff{
+					&terraform.ModuleDiff{
+						Path:      []string{"root"},
+						Resources: resources,
+					},
+				},
+			},
+		},
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+		ModuleDepth:  -1,
+		MaxResources: 5,
+	}
+
+	actual := FormatPlan(opts)
+
+	var add, destroy int
+	for _, line := range strings.Split(actual, "\n") {
+		if strings.HasPrefix(line, "+ ") {
+			add++
+		}
+		if strings.HasPrefix(line, "- ") {
+			destroy++
+		}
+	}
+	if add != 5 || destroy != 3 {
+		t.Fatalf("bad: %d to add, %d to destroy:\n\n%s", add, destroy, actual)
+	}
+
+	if !strings.Contains(actual, "... and 45 more resource(s) to add") {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+	if strings.Contains(actual, "to destroy (") {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestFormatPlan_filter(t *testing.T) {
+	add := &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"name": &terraform.ResourceAttrDiff{
+				New:         "foo",
+				RequiresNew: true,
+			},
+		},
+	}
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_iam_role.admin":                add,
+						"aws_iam_policy.admin":              add,
+						"aws_instance.web.0":                add,
+						"aws_instance.web.1":                add,
+						"data.aws_iam_policy_document.read": add,
+					},
+				},
+				&terraform.ModuleDiff{
+					Path: []string{"root", "users"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"aws_iam_user.foo":  add,
+						"aws_s3_bucket.foo": add,
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		Filter []string
+		Shown  []string
+		Hidden int
+	}{
+		{
+			[]string{"aws_iam_*"},
+			[]string{
+				"aws_iam_policy.admin",
+				"aws_iam_role.admin",
+				"data.aws_iam_policy_document.read",
+				"module.users.aws_iam_user.foo",
+			},
+			3,
+		},
+		{
+			[]string{"module.users.*", "aws_instance.web.1"},
+			[]string{
+				"aws_instance.web.1",
+				"module.users.aws_iam_user.foo",
+				"module.users.aws_s3_bucket.foo",
+			},
+			4,
+		},
+		{
+			nil,
+			[]string{
+				"aws_iam_policy.admin",
+				"aws_iam_role.admin",
+				"aws_instance.web.0",
+				"aws_instance.web.1",
+				"data.aws_iam_policy_document.read",
+				"module.users.aws_iam_user.foo",
+				"module.users.aws_s3_bucket.foo",
+			},
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		actual := FormatPlan(&FormatPlanOpts{
+			Plan: plan,
+			Color: &colorstring.Colorize{
+				Colors:  colorstring.DefaultColors,
+				Disable: true,
+			},
+			ModuleDepth: -1,
+			Filter:      tc.Filter,
+		})
+
+		var shown []string
+		for _, line := range strings.Split(actual, "\n") {
+			if strings.HasPrefix(line, "+ ") || strings.HasPrefix(line, "<= ") {
+				shown = append(shown, line[strings.Index(line, " ")+1:])
+			}
+		}
+		if !reflect.DeepEqual(shown, tc.Shown) {
+			t.Fatalf("%v: bad: %#v\n\n%s", tc.Filter, shown, actual)
+		}
+
+		note := fmt.Sprintf("%d resource(s) hidden by filter", tc.Hidden)
+		if strings.Contains(actual, note) != (tc.Hidden > 0) {
+			t.Fatalf("%v: bad:\n\n%s", tc.Filter, actual)
+		}
+	}
+}
+
 // Test that a root level data source gets a special plan output on create
 func TestFormatPlan_rootDataSource(t *testing.T) {
 	plan := &terraform.Plan{
@@ -168,3 +493,200 @@ func TestFormatPlan_nestedDataSource(t *testing.T) {
 		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
 	}
 }
+
+func TestFormatPlanWrite(t *testing.T) {
+	opts := &FormatPlanOpts{
+		Plan: testFormatPlanLarge(10),
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+		ModuleDepth: -1,
+	}
+
+	var buf bytes.Buffer
+	if err := FormatPlanWrite(&buf, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := strings.TrimSpace(buf.String())
+	expected := FormatPlan(opts)
+	if actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+func TestFormatPlanWrite_renderer(t *testing.T) {
+	plan := testFormatPlanLarge(3)
+	r := new(testPlanRenderer)
+	opts := &FormatPlanOpts{
+		Plan:     plan,
+		Renderer: r,
+	}
+
+	var buf bytes.Buffer
+	if err := FormatPlanWrite(&buf, opts); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if r.Plan != plan {
+		t.Fatalf("bad: %#v", r.Plan)
+	}
+	if actual := buf.String(); actual != "rendered" {
+		t.Fatalf("bad: %q", actual)
+	}
+	if actual := FormatPlan(opts); actual != "rendered" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func BenchmarkFormatPlan(b *testing.B) {
+	opts := &FormatPlanOpts{
+		Plan:        testFormatPlanLarge(10000),
+		ModuleDepth: -1,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.WriteString(ioutil.Discard, FormatPlan(opts))
+	}
+}
+
+func BenchmarkFormatPlanWrite(b *testing.B) {
+	opts := &FormatPlanOpts{
+		Plan:        testFormatPlanLarge(10000),
+		ModuleDepth: -1,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := FormatPlanWrite(ioutil.Discard, opts); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+// testFormatPlanLarge returns a plan that creates the given number of
+// resources, each with a few attributes.
+func testFormatPlanLarge(n int) *terraform.Plan {
+	resources := make(map[string]*terraform.InstanceDiff, n)
+	for i := 0; i < n; i++ {
+		resources[fmt.Sprintf("test_instance.foo.%d", i)] = &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"ami": &terraform.ResourceAttrDiff{
+					New: fmt.Sprintf("ami-%08d", i),
+				},
+				"instance_type": &terraform.ResourceAttrDiff{
+					New: "t2.micro",
+				},
+				"private_ip": &terraform.ResourceAttrDiff{
+					NewComputed: true,
+				},
+			},
+		}
+	}
+
+	return &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path:      []string{"root"},
+					Resources: resources,
+				},
+			},
+		},
+	}
+}
+
+type testPlanRenderer struct {
+	Plan *terraform.Plan
+}
+
+func (r *testPlanRenderer) RenderPlan(w io.Writer, opts *FormatPlanOpts) error {
+	r.Plan = opts.Plan
+	_, err := io.WriteString(w, "rendered")
+	return err
+}
+
+func TestFormatPlanTypeSummary(t *testing.T) {
+	h := new(CountHook)
+	for i := 0; i < planTypeSummaryMin; i++ {
+		h.PostDiff(&terraform.InstanceInfo{Id: fmt.Sprintf("test_instance.foo.%d", i)},
+			&terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"ami": &terraform.ResourceAttrDiff{New: "bar", RequiresNew: true},
+				},
+			})
+	}
+
+	// Few changes are only summarized by the plan line
+	if actual := formatPlanTypeSummary(h); actual != "" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	h.PostDiff(&terraform.InstanceInfo{Id: "test_volume.bar"},
+		&terraform.InstanceDiff{Destroy: true})
+	expected := "[reset][bold]Changes by resource type:[reset]\n\n" +
+		"  test_instance  5 to add\n" +
+		"  test_volume    1 to destroy"
+	if actual := formatPlanTypeSummary(h); actual != expected {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestFormatPlan_outputs(t *testing.T) {
+	plan := &terraform.Plan{
+		Module: testModule(t, "plan-outputs"),
+		PriorOutputs: map[string]*terraform.OutputState{
+			"changed": &terraform.OutputState{Type: "string", Value: "foo", Sensitive: true},
+		},
+		Outputs: map[string]*terraform.OutputState{
+			"changed": &terraform.OutputState{Type: "string", Value: "bar", Sensitive: true},
+			"new": &terraform.OutputState{
+				Type:  "list",
+				Value: []interface{}{"a", config.UnknownVariableValue},
+			},
+		},
+	}
+	opts := &FormatPlanOpts{
+		Plan: plan,
+		Color: &colorstring.Colorize{
+			Colors:  colorstring.DefaultColors,
+			Disable: true,
+		},
+	}
+
+	// Plans with only output changes are still shown
+	actual := FormatPlan(opts)
+	expected := strings.TrimSpace(`
+Outputs:
+
+~ changed: <sensitive> => <sensitive>
++ new:     <computed>
+	`)
+	if actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+
+	// Known values are shown as JSON, except for strings
+	plan.Outputs["new"].Value = []interface{}{"a", "b"}
+	opts.ShowSensitive = true
+	actual = FormatPlan(opts)
+	expected = strings.TrimSpace(`
+Outputs:
+
+~ changed: "foo" => "bar"
++ new:     ["a","b"]
+	`)
+	if actual != expected {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+
+	// Nothing is shown for plans without planned outputs
+	plan.Outputs = nil
+	if actual := FormatPlan(opts); actual != "This plan does nothing." {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -3,7 +3,6 @@ package command
 import (
 	"flag"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/terraform/config/module"
@@ -37,7 +36,7 @@ func (c *GetCommand) Run(args []string) int {
 		path = args[0]
 	} else {
 		var err error
-		path, err = os.Getwd()
+		path, err = c.pwd()
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		}
@@ -3,7 +3,6 @@ package command
 import (
 	"flag"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/terraform/dag"
@@ -44,7 +43,7 @@ func (c *GraphCommand) Run(args []string) int {
 		path = args[0]
 	} else {
 		var err error
-		path, err = os.Getwd()
+		path, err = c.pwd()
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		}
@@ -108,7 +107,9 @@ Usage: terraform graph [options] [DIR]
   Outputs the visual execution graph of Terraform resources according to
   configuration files in DIR (or the current directory if omitted).
 
-  The graph is outputted in DOT format. The typical program that can
+  The graph includes the resources in the state, which is the remote
+  state if remote state is configured. The graph is outputted in DOT
+  format. The typical program that can
   read this format is GraphViz, but many web services are also available
   to read this format.
 
@@ -126,7 +127,7 @@ Options:
   -no-color      If specified, output won't contain any color.
 
   -type=plan     Type of graph to output. Can be: plan, plan-destroy, apply,
-                 legacy.
+                 refresh, legacy.
 
 `
 	return strings.TrimSpace(helpText)
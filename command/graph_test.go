@@ -2,6 +2,7 @@ package command
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -116,3 +117,104 @@ func TestGraph_plan(t *testing.T) {
 		t.Fatalf("doesn't look like digraph: %s", output)
 	}
 }
+
+func TestGraph_planNodes(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &GraphCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-type=plan",
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		`"[root] provider.test" [label = "provider.test", shape = "diamond"]`,
+		`"[root] test_instance.foo" [label = "test_instance.foo", shape = "box"]`,
+		`"[root] test_instance.foo" -> "[root] provider.test"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %s in:\n\n%s", expected, output)
+		}
+	}
+}
+
+func TestGraph_refresh(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &GraphCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-type=refresh",
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		`"[root] provider.test"`,
+		`"[root] test_instance.foo"`,
+		`"[root] test_instance.foo" -> "[root] provider.test"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %s in:\n\n%s", expected, output)
+		}
+	}
+}
+
+func TestGraph_remoteState(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// The remote state has a resource that isn't in the configuration
+	s := testState()
+	s.RootModule().Resources["test_instance.orphan"] = &terraform.ResourceState{
+		Type:    "test_instance",
+		Primary: &terraform.InstanceState{ID: "baz"},
+	}
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), cache)
+
+	ui := new(cli.MockUi)
+	c := &GraphCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		`"[root] test_instance.foo"`,
+		`"[root] test_instance.orphan (orphan)"`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %s in:\n\n%s", expected, output)
+		}
+	}
+}
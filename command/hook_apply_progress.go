@@ -0,0 +1,187 @@
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// ApplyProgress is the progress of applying a plan file. It records the
+// resources that were applied successfully so that a partially failed
+// apply can be continued later without applying them a second time.
+type ApplyProgress struct {
+	// PlanId is the ID of the plan file being applied. The progress is
+	// never used to continue applying a plan with a different ID.
+	PlanId string `json:"plan_id"`
+
+	// Applied maps the address of every resource that was applied to the
+	// hash of the diff from the plan that was applied to it.
+	Applied map[string]string `json:"applied"`
+}
+
+// ReadApplyProgress reads the apply progress at the given path. If there
+// is no file at the path, nil is returned with no error.
+func ReadApplyProgress(path string) (*ApplyProgress, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result ApplyProgress
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("Error reading apply progress %s: %s", path, err)
+	}
+	if result.Applied == nil {
+		result.Applied = make(map[string]string)
+	}
+
+	return &result, nil
+}
+
+// Write writes the apply progress to the given path.
+func (p *ApplyProgress) Write(path string) error {
+	data, err := json.MarshalIndent(p, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Skip removes the resources that were already applied from the given
+// diff. Only resources whose diff is unchanged since they were applied are
+// removed. The number of removed resources is returned.
+func (p *ApplyProgress) Skip(d *terraform.Diff) (int, error) {
+	count := 0
+	for _, m := range d.Modules {
+		for k, rd := range m.Resources {
+			hash, ok := p.Applied[applyProgressKey(m.Path, k)]
+			if !ok {
+				continue
+			}
+
+			current, err := instanceDiffHash(rd)
+			if err != nil {
+				return count, err
+			}
+			if current != hash {
+				continue
+			}
+
+			delete(m.Resources, k)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// ApplyProgressHook is a hook that records the resources of a plan that
+// were applied successfully to an ApplyProgress as the apply happens,
+// writing the progress out after every resource.
+type ApplyProgressHook struct {
+	terraform.NilHook
+	sync.Mutex
+
+	// Path is the path to write the progress to. If this is empty, the
+	// hook does nothing.
+	Path string
+
+	// Progress is where the progress of the apply is recorded.
+	Progress *ApplyProgress
+
+	// hashes are the hashes of the diffs in the plan being applied. The
+	// diff itself can't be used since it is modified during the apply.
+	hashes map[string]string
+}
+
+// SetDiff sets the diff of the plan that is being applied. This must be
+// called before the apply starts.
+func (h *ApplyProgressHook) SetDiff(d *terraform.Diff) error {
+	h.Lock()
+	defer h.Unlock()
+
+	h.hashes = make(map[string]string)
+	if d == nil {
+		return nil
+	}
+
+	for _, m := range d.Modules {
+		for k, rd := range m.Resources {
+			hash, err := instanceDiffHash(rd)
+			if err != nil {
+				return err
+			}
+
+			h.hashes[applyProgressKey(m.Path, k)] = hash
+		}
+	}
+
+	return nil
+}
+
+func (h *ApplyProgressHook) PostApply(
+	n *terraform.InstanceInfo,
+	s *terraform.InstanceState,
+	applyerr error) (terraform.HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.Path == "" || h.Progress == nil || applyerr != nil {
+		return terraform.HookActionContinue, nil
+	}
+
+	key := applyProgressKey(n.ModulePath, n.Id)
+	hash, ok := h.hashes[key]
+	if !ok {
+		return terraform.HookActionContinue, nil
+	}
+
+	if h.Progress.Applied == nil {
+		h.Progress.Applied = make(map[string]string)
+	}
+	h.Progress.Applied[key] = hash
+
+	// Failing to record progress only means a later -continue will apply
+	// this resource again, so it isn't worth failing the apply for.
+	if err := h.Progress.Write(h.Path); err != nil {
+		log.Printf("[WARN] Error writing apply progress to %s: %s", h.Path, err)
+	}
+
+	return terraform.HookActionContinue, nil
+}
+
+// applyProgressKey returns the key used to record the resource with the
+// given state ID in the module with the given path.
+func applyProgressKey(path []string, id string) string {
+	info := &terraform.InstanceInfo{Id: id, ModulePath: path}
+	return info.HumanId()
+}
+
+// instanceDiffHash returns a hash of the given diff so that it can be
+// determined later whether the diff for a resource has changed.
+func instanceDiffHash(d *terraform.InstanceDiff) (string, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+func TestApplyProgressHook_impl(t *testing.T) {
+	var _ terraform.Hook = new(ApplyProgressHook)
+}
+
+func TestApplyProgressHook(t *testing.T) {
+	path := filepath.Join(testTempDir(t), DefaultApplyProgressFilename)
+	diff := &terraform.Diff{
+		Modules: []*terraform.ModuleDiff{
+			&terraform.ModuleDiff{
+				Path: terraform.RootModulePath,
+				Resources: map[string]*terraform.InstanceDiff{
+					"test_instance.foo": &terraform.InstanceDiff{
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": &terraform.ResourceAttrDiff{New: "bar"},
+						},
+					},
+					"test_instance.bar": &terraform.InstanceDiff{
+						Attributes: map[string]*terraform.ResourceAttrDiff{
+							"ami": &terraform.ResourceAttrDiff{New: "bar"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	hook := &ApplyProgressHook{
+		Path:     path,
+		Progress: &ApplyProgress{PlanId: "foo"},
+	}
+	if err := hook.SetDiff(diff); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	info := &terraform.InstanceInfo{
+		Id:         "test_instance.foo",
+		ModulePath: terraform.RootModulePath,
+	}
+	if _, err := hook.PostApply(info, nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	progress, err := ReadApplyProgress(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if progress.PlanId != "foo" || len(progress.Applied) != 1 {
+		t.Fatalf("bad: %#v", progress)
+	}
+
+	// Only the applied resource should be skipped
+	skipped, err := progress.Skip(diff)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if skipped != 1 {
+		t.Fatalf("bad: %d", skipped)
+	}
+	if _, ok := diff.RootModule().Resources["test_instance.bar"]; !ok {
+		t.Fatalf("bad: %s", diff)
+	}
+}
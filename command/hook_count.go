@@ -19,6 +19,17 @@ type CountHook struct {
 	ToRemove       int
 	ToRemoveAndAdd int
 
+	// ToReplaceTainted and ToRemoveDeposed count the resources that are
+	// replaced because they are tainted, and the resources that have
+	// deposed instances to destroy. These resources are also counted in
+	// the counts above.
+	ToReplaceTainted int
+	ToRemoveDeposed  int
+
+	// ToByType are the planned changes counted by resource type, for the
+	// resources whose type is known.
+	ToByType map[string]*ResourceTypeCounts
+
 	pending map[string]countHookAction
 
 	sync.Mutex
@@ -85,6 +96,27 @@ func (h *CountHook) PostApply(
 	return terraform.HookActionContinue, nil
 }
 
+// CountDiff counts the changes in a diff that was already made, such as
+// the diff of a saved plan, the same way they're counted while planning.
+func (h *CountHook) CountDiff(d *terraform.Diff) {
+	if d == nil {
+		return
+	}
+
+	for _, m := range d.Modules {
+		for name, rdiff := range m.Resources {
+			if rdiff.Empty() {
+				continue
+			}
+
+			h.PostDiff(&terraform.InstanceInfo{
+				Id:         name,
+				ModulePath: m.Path,
+			}, rdiff)
+		}
+	}
+}
+
 func (h *CountHook) PostDiff(
 	n *terraform.InstanceInfo, d *terraform.InstanceDiff) (
 	terraform.HookAction, error) {
@@ -96,16 +128,64 @@ func (h *CountHook) PostDiff(
 		return terraform.HookActionContinue, nil
 	}
 
+	if d.GetDestroyDeposed() {
+		h.ToRemoveDeposed += 1
+	}
+
 	switch d.ChangeType() {
 	case terraform.DiffDestroyCreate:
 		h.ToRemoveAndAdd += 1
+		if d.GetDestroyTainted() {
+			h.ToReplaceTainted += 1
+		}
+		h.countType(n, 1, 0, 1)
 	case terraform.DiffCreate:
 		h.ToAdd += 1
+		h.countType(n, 1, 0, 0)
 	case terraform.DiffDestroy:
 		h.ToRemove += 1
+		h.countType(n, 0, 0, 1)
 	case terraform.DiffUpdate:
 		h.ToChange += 1
+		h.countType(n, 0, 1, 0)
 	}
 
 	return terraform.HookActionContinue, nil
 }
+
+// countType adds the given change to the counts of the type of the given
+// resource in ToByType. Resources whose type isn't known aren't counted.
+func (h *CountHook) countType(n *terraform.InstanceInfo, add, change, remove int) {
+	t := n.Type
+	if t == "" {
+		if idx := strings.Index(n.Id, "."); idx > 0 {
+			t = n.Id[:idx]
+		}
+	}
+	if t == "" {
+		return
+	}
+
+	if h.ToByType == nil {
+		h.ToByType = make(map[string]*ResourceTypeCounts)
+	}
+
+	c, ok := h.ToByType[t]
+	if !ok {
+		c = new(ResourceTypeCounts)
+		h.ToByType[t] = c
+	}
+
+	c.ToAdd += add
+	c.ToChange += change
+	c.ToRemove += remove
+}
+
+// ResourceTypeCounts are the numbers of planned changes to the resources of
+// a single type. Replaced resources are counted in both ToAdd and ToRemove,
+// like they are in the summary of a plan.
+type ResourceTypeCounts struct {
+	ToAdd    int
+	ToChange int
+	ToRemove int
+}
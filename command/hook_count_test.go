@@ -29,6 +29,41 @@ func TestCountHookPostDiff_DestroyDeposed(t *testing.T) {
 	expected.ToChange = 0
 	expected.ToRemoveAndAdd = 0
 	expected.ToRemove = 1
+	expected.ToRemoveDeposed = 1
+
+	if !reflect.DeepEqual(expected, h) {
+		t.Fatalf("Expected %#v, got %#v instead.",
+			expected, h)
+	}
+}
+
+func TestCountHookPostDiff_DestroyTainted(t *testing.T) {
+	h := new(CountHook)
+
+	resources := map[string]*terraform.InstanceDiff{
+		"foo": &terraform.InstanceDiff{
+			DestroyTainted: true,
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"foo": &terraform.ResourceAttrDiff{RequiresNew: true},
+			},
+		},
+		"bar": &terraform.InstanceDiff{
+			Destroy: true,
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"foo": &terraform.ResourceAttrDiff{RequiresNew: true},
+			},
+		},
+	}
+
+	n := &terraform.InstanceInfo{} // TODO
+
+	for _, d := range resources {
+		h.PostDiff(n, d)
+	}
+
+	expected := new(CountHook)
+	expected.ToRemoveAndAdd = 2
+	expected.ToReplaceTainted = 1
 
 	if !reflect.DeepEqual(expected, h) {
 		t.Fatalf("Expected %#v, got %#v instead.",
@@ -241,3 +276,42 @@ func TestCountHookPostDiff_DataSource(t *testing.T) {
 			expected, h)
 	}
 }
+
+func TestCountHookPostDiff_byType(t *testing.T) {
+	h := new(CountHook)
+
+	resources := map[string]*terraform.InstanceDiff{
+		"aws_instance.foo": &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"foo": &terraform.ResourceAttrDiff{RequiresNew: true},
+			},
+		},
+		"aws_instance.bar": &terraform.InstanceDiff{
+			Destroy: true,
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"foo": &terraform.ResourceAttrDiff{RequiresNew: true},
+			},
+		},
+		"aws_instance.baz.1": &terraform.InstanceDiff{
+			Attributes: map[string]*terraform.ResourceAttrDiff{
+				"foo": &terraform.ResourceAttrDiff{Old: "a", New: "b"},
+			},
+		},
+		"aws_security_group.foo": &terraform.InstanceDiff{Destroy: true},
+		"aws_security_group.bar": &terraform.InstanceDiff{},
+		"data.aws_ami.foo":       &terraform.InstanceDiff{Destroy: true},
+	}
+
+	for k, d := range resources {
+		n := &terraform.InstanceInfo{Id: k}
+		h.PostDiff(n, d)
+	}
+
+	expected := map[string]*ResourceTypeCounts{
+		"aws_instance":       &ResourceTypeCounts{ToAdd: 2, ToChange: 1, ToRemove: 1},
+		"aws_security_group": &ResourceTypeCounts{ToRemove: 1},
+	}
+	if !reflect.DeepEqual(h.ToByType, expected) {
+		t.Fatalf("bad: %#v", h.ToByType)
+	}
+}
@@ -1,7 +1,9 @@
 package command
 
 import (
+	"log"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
@@ -31,3 +33,64 @@ func (h *StateHook) PostStateUpdate(
 	// Continue forth
 	return terraform.HookActionContinue, nil
 }
+
+// StateCheckpointHook is a hook that persists the state as it is updated
+// during an operation such as refresh, which otherwise only persists the
+// state once it is done, so that the progress isn't all lost if it crashes.
+// The state is persisted once Count updates were made since the last time,
+// or once Interval has passed since then. Either is disabled if zero.
+type StateCheckpointHook struct {
+	terraform.NilHook
+	sync.Mutex
+
+	State    state.State
+	Count    int
+	Interval time.Duration
+
+	pending int
+	last    time.Time
+}
+
+func (h *StateCheckpointHook) PostStateUpdate(
+	s *terraform.State) (terraform.HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.State == nil {
+		return terraform.HookActionContinue, nil
+	}
+	if h.last.IsZero() {
+		h.last = time.Now()
+	}
+
+	h.pending++
+	if (h.Count <= 0 || h.pending < h.Count) &&
+		(h.Interval <= 0 || time.Since(h.last) < h.Interval) {
+		return terraform.HookActionContinue, nil
+	}
+
+	// The state is only updated once a resource is done, so it's valid to
+	// persist at any point: resources that weren't done yet keep their
+	// prior state.
+	log.Printf("[INFO] Checkpointing the state after %d update(s)", h.pending)
+	if err := h.State.WriteState(s); err != nil {
+		return terraform.HookActionHalt, err
+	}
+	if err := h.State.PersistState(); err != nil {
+		return terraform.HookActionHalt, err
+	}
+
+	h.pending = 0
+	h.last = time.Now()
+	return terraform.HookActionContinue, nil
+}
+
+// Stop stops any more checkpoints from being persisted, waiting for one in
+// progress to finish. This is for when the operation is abandoned while
+// it's still running, so that its state isn't persisted behind our back.
+func (h *StateCheckpointHook) Stop() {
+	h.Lock()
+	defer h.Unlock()
+
+	h.State = nil
+}
@@ -2,6 +2,7 @@ package command
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
@@ -27,3 +28,60 @@ func TestStateHook(t *testing.T) {
 		t.Fatalf("bad state: %#v", is.State())
 	}
 }
+
+func TestStateCheckpointHook_impl(t *testing.T) {
+	var _ terraform.Hook = new(StateCheckpointHook)
+}
+
+func TestStateCheckpointHook(t *testing.T) {
+	is := &state.InmemState{}
+	hook := &StateCheckpointHook{State: is, Count: 2, Interval: time.Hour}
+
+	// Only every other update is persisted
+	s := state.TestStateInitial()
+	for i := 1; i <= 3; i++ {
+		action, err := hook.PostStateUpdate(s)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if action != terraform.HookActionContinue {
+			t.Fatalf("bad: %v", action)
+		}
+
+		if persisted := is.State() != nil; persisted != (i == 2) {
+			t.Fatalf("%d: bad: %#v", i, is.State())
+		}
+		is.WriteState(nil)
+	}
+
+	// Nothing is persisted once stopped
+	hook.Stop()
+	if _, err := hook.PostStateUpdate(s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if is.State() != nil {
+		t.Fatalf("bad: %#v", is.State())
+	}
+}
+
+func TestStateCheckpointHook_interval(t *testing.T) {
+	is := &state.InmemState{}
+	hook := &StateCheckpointHook{State: is, Interval: time.Minute}
+
+	s := state.TestStateInitial()
+	if _, err := hook.PostStateUpdate(s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if is.State() != nil {
+		t.Fatalf("bad: %#v", is.State())
+	}
+
+	// Once the interval has passed since the last checkpoint
+	hook.last = time.Now().Add(-time.Hour)
+	if _, err := hook.PostStateUpdate(s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !is.State().Equal(s) {
+		t.Fatalf("bad: %#v", is.State())
+	}
+}
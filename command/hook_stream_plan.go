@@ -0,0 +1,41 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/colorstring"
+)
+
+// StreamPlanHook is a hook that outputs a line for each resource as soon
+// as its change is planned, so that the progress of very large plans can
+// be followed instead of waiting for the whole plan to be output at the
+// end. Resources that don't change aren't output.
+type StreamPlanHook struct {
+	terraform.NilHook
+	sync.Mutex
+
+	Colorize *colorstring.Colorize
+	Ui       cli.Ui
+}
+
+func (h *StreamPlanHook) PostDiff(
+	n *terraform.InstanceInfo, d *terraform.InstanceDiff) (
+	terraform.HookAction, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if d.Empty() {
+		return terraform.HookActionContinue, nil
+	}
+
+	role, symbol, _ := formatPlanResourceChange(d, strings.HasPrefix(n.Id, "data."))
+	h.Ui.Output(h.Colorize.Color(fmt.Sprintf(
+		"[%s]%s %s%s",
+		roleColor(h.Colorize, role), symbol, n.HumanId(), formatPlanAnnotation(d))))
+
+	return terraform.HookActionContinue, nil
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+	"github.com/mitchellh/colorstring"
+)
+
+func TestStreamPlanHook_impl(t *testing.T) {
+	var _ terraform.Hook = new(StreamPlanHook)
+}
+
+func TestStreamPlanHookPostDiff(t *testing.T) {
+	ui := new(cli.MockUi)
+	h := &StreamPlanHook{
+		Colorize: &colorstring.Colorize{Colors: colorstring.DefaultColors, Disable: true},
+		Ui:       ui,
+	}
+
+	cases := []struct {
+		Info *terraform.InstanceInfo
+		Diff *terraform.InstanceDiff
+	}{
+		{
+			&terraform.InstanceInfo{Id: "aws_instance.web.3", ModulePath: []string{"root"}},
+			&terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"ami": &terraform.ResourceAttrDiff{Old: "foo", New: "bar"},
+				},
+			},
+		},
+		{
+			&terraform.InstanceInfo{Id: "aws_instance.db", ModulePath: []string{"root", "child"}},
+			&terraform.InstanceDiff{Destroy: true},
+		},
+		{
+			&terraform.InstanceInfo{Id: "data.aws_ami.ubuntu", ModulePath: []string{"root"}},
+			&terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"id": &terraform.ResourceAttrDiff{NewComputed: true, RequiresNew: true},
+				},
+			},
+		},
+		{
+			// Resources that don't change aren't output
+			&terraform.InstanceInfo{Id: "aws_instance.unchanged", ModulePath: []string{"root"}},
+			&terraform.InstanceDiff{},
+		},
+	}
+	for _, tc := range cases {
+		if _, err := h.PostDiff(tc.Info, tc.Diff); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	expected := "~ aws_instance.web.3\n" +
+		"- module.child.aws_instance.db\n" +
+		"<= data.aws_ami.ubuntu\n"
+	if actual := ui.OutputWriter.String(); actual != expected {
+		t.Fatalf("bad:\n%s", actual)
+	}
+}
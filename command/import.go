@@ -3,7 +3,6 @@ package command
 import (
 	"fmt"
 	"log"
-	"os"
 	"strings"
 
 	"github.com/hashicorp/terraform/terraform"
@@ -16,21 +15,22 @@ type ImportCommand struct {
 }
 
 func (c *ImportCommand) Run(args []string) int {
+	var configPath string
+	args = c.Meta.process(args, true)
+
 	// Get the pwd since its our default -config flag value
-	pwd, err := os.Getwd()
+	pwd, err := c.pwd()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		return 1
 	}
 
-	var configPath string
-	args = c.Meta.process(args, true)
-
 	cmdFlags := c.Meta.flagSet("import")
 	cmdFlags.IntVar(&c.Meta.parallelism, "parallelism", 0, "parallelism")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.StringVar(&c.Meta.stateOutPath, "state-out", "", "path")
 	cmdFlags.StringVar(&c.Meta.backupPath, "backup", "", "path")
+	cmdFlags.StringVar(&c.Meta.backupDir, "backup-dir", "", "path")
 	cmdFlags.StringVar(&configPath, "config", pwd, "path")
 	cmdFlags.StringVar(&c.Meta.provider, "provider", "", "provider")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
@@ -51,6 +51,7 @@ func (c *ImportCommand) Run(args []string) int {
 		PathEmptyOk: true,
 		StatePath:   c.Meta.statePath,
 		Parallelism: c.Meta.parallelism,
+		WritesState: true,
 	})
 	if err != nil {
 		c.Ui.Error(err.Error())
@@ -125,6 +126,10 @@ Options:
                       modifying. Defaults to the "-state-out" path with
                       ".backup" extension. Set to "-" to disable backup.
 
+  -backup-dir=path    Directory to write the backup to, keeping its name.
+                      By default, it is written next to the state, or to
+                      within .terraform with a warning if that fails.
+
   -config=path        Path to a directory of Terraform configuration files
                       to use to configure the provider. Defaults to pwd.
                       If no config files are present, they must be provided
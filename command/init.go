@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-getter"
@@ -20,12 +21,16 @@ type InitCommand struct {
 }
 
 func (c *InitCommand) Run(args []string) int {
-	var remoteBackend string
+	var remoteBackend, remoteConfigFile string
+	var get bool
 	args = c.Meta.process(args, false)
 	remoteConfig := make(map[string]string)
 	cmdFlags := flag.NewFlagSet("init", flag.ContinueOnError)
 	cmdFlags.StringVar(&remoteBackend, "backend", "", "")
 	cmdFlags.Var((*FlagStringKV)(&remoteConfig), "backend-config", "config")
+	cmdFlags.StringVar(&remoteConfigFile, "backend-config-file", "", "path")
+	cmdFlags.BoolVar(&get, "get", true, "get")
+	cmdFlags.BoolVar(&c.Meta.input, "input", true, "input")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
@@ -33,6 +38,20 @@ func (c *InitCommand) Run(args []string) int {
 
 	remoteBackend = strings.ToLower(remoteBackend)
 
+	// The values given on the command line override the ones in the file
+	if remoteConfigFile != "" {
+		fileConfig, err := loadBackendConfigFile(remoteConfigFile)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+
+		for k, v := range remoteConfig {
+			fileConfig[k] = v
+		}
+		remoteConfig = fileConfig
+	}
+
 	var path string
 	args = cmdFlags.Args()
 	if len(args) > 2 {
@@ -40,16 +59,22 @@ func (c *InitCommand) Run(args []string) int {
 		cmdFlags.Usage()
 		return 1
 	} else if len(args) < 1 {
-		c.Ui.Error("The init command expects at least one arguments.\n")
-		cmdFlags.Usage()
-		return 1
+		// Without a SOURCE, the configuration in the working directory
+		// is initialized.
+		pwd, err := c.pwd()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
+			return 1
+		}
+
+		return c.initDir(pwd, remoteBackend, remoteConfig, get)
 	}
 
 	if len(args) == 2 {
 		path = args[1]
 	} else {
 		var err error
-		path, err = os.Getwd()
+		path, err = c.pwd()
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		}
@@ -138,9 +163,79 @@ func (c *InitCommand) Run(args []string) int {
 	return 0
 }
 
+// initDir initializes the existing configuration in the given directory:
+// the remote state is configured if a backend is given, and the modules
+// used by the configuration are downloaded unless get is false.
+func (c *InitCommand) initDir(
+	path string, backend string, backendConfig map[string]string, get bool) int {
+	// Load the configuration to make sure it is valid before we do
+	// anything with it.
+	mod, err := module.NewTreeModule("", path)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading configuration: %s", err))
+		return 1
+	}
+
+	mode := module.GetModeNone
+	if get {
+		c.Ui.Output("Downloading modules (if any)...")
+		mode = module.GetModeGet
+	}
+
+	modulesLoaded := true
+	if err := mod.Load(c.moduleStorage(c.DataDir()), mode); err != nil {
+		notFound, ok := err.(*module.ErrModulesNotFound)
+		if !ok {
+			c.Ui.Error(fmt.Sprintf("Error downloading modules: %s", err))
+			return 1
+		}
+
+		// Without -get, missing modules are fine: they will be reported
+		// by every command until they are downloaded.
+		c.Ui.Output(fmt.Sprintf(
+			"The following modules haven't been downloaded yet:\n\n"+
+				"  * module.%s\n\n"+
+				"Run \"terraform get\" to download them.",
+			strings.Join(notFound.Modules, "\n  * module.")))
+		modulesLoaded = false
+	}
+
+	// The children can only be validated if they were loaded
+	if modulesLoaded {
+		if err := mod.Validate(); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error validating configuration: %s", err))
+			return 1
+		}
+	}
+
+	// Configure the remote state the same way "terraform remote config"
+	// does, including copying the existing state if it is moving.
+	if backend != "" {
+		args := []string{"-backend", backend}
+		keys := make([]string, 0, len(backendConfig))
+		for k := range backendConfig {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			args = append(args, "-backend-config", k+"="+backendConfig[k])
+		}
+		args = append(args, fmt.Sprintf("-input=%t", c.Meta.input))
+
+		remoteCmd := &RemoteConfigCommand{Meta: c.Meta}
+		if code := remoteCmd.Run(args); code != 0 {
+			return code
+		}
+	}
+
+	c.Ui.Output(c.Colorize().Color(
+		"[reset][bold][green]Terraform has been successfully initialized!"))
+	return 0
+}
+
 func (c *InitCommand) Help() string {
 	helpText := `
-Usage: terraform init [options] SOURCE [PATH]
+Usage: terraform init [options] [SOURCE [PATH]]
 
   Downloads the module given by SOURCE into the PATH. The PATH defaults
   to the working directory. PATH must be empty of any Terraform files.
@@ -150,6 +245,11 @@ Usage: terraform init [options] SOURCE [PATH]
   Git, it will not preserve the Git history, it will only copy the
   latest files.
 
+  If no SOURCE is given, the configuration in the working directory is
+  initialized instead: it is validated, the modules it uses are
+  downloaded, and the remote state is configured if -backend is given.
+  This is safe to run more than once.
+
 Options:
 
   -backend=atlas         Specifies the type of remote backend. If not
@@ -157,6 +257,17 @@ Options:
 
   -backend-config="k=v"  Specifies configuration for the remote storage
                          backend. This can be specified multiple times.
+                         These override the values from -backend-config-file.
+
+  -backend-config-file=path  Path to an HCL or JSON file of configuration
+                         for the remote storage backend, in the format of
+                         'key = "value"'.
+
+  -get=true              Download the modules used by the configuration.
+                         Only used when no SOURCE is given.
+
+  -input=true            Ask for input, such as whether to copy the existing
+                         state when the remote state configuration changes.
 
   -no-color           If specified, output won't contain any color.
 
@@ -165,5 +276,5 @@ Options:
 }
 
 func (c *InitCommand) Synopsis() string {
-	return "Initializes Terraform configuration from a module"
+	return "Initialize a new or existing Terraform configuration"
 }
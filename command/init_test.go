@@ -1,8 +1,12 @@
 package command
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/terraform"
@@ -88,6 +92,10 @@ func TestInit_multipleArgs(t *testing.T) {
 }
 
 func TestInit_noArgs(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// There is no configuration in the working directory to initialize
 	ui := new(cli.MockUi)
 	c := &InitCommand{
 		Meta: Meta{
@@ -287,3 +295,251 @@ func TestInit_remoteStateWithRemote(t *testing.T) {
 		t.Fatalf("should have failed: \n%s", ui.OutputWriter.String())
 	}
 }
+
+// The remote state configured by init is saved in the data dir and used
+// by later commands without having to configure it again.
+func TestInit_remoteStateReuse(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend", "HTTP",
+		"-backend-config", "address=" + conf.Config["address"],
+		testFixturePath("init"),
+		tmp,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	cache := testStateRead(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename))
+	if !cache.Remote.Equals(conf) {
+		t.Fatalf("bad: %#v", cache.Remote)
+	}
+
+	// Plan with no remote state flags at all
+	p := testProvider()
+	ui = new(cli.MockUi)
+	pc := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	if code := pc.Run([]string{testFixturePath("plan")}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	// Verify that the plan used the remote state
+	actual := strings.TrimSpace(p.DiffState.String())
+	expected := strings.TrimSpace(testPlanStateStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestInit_dir(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	testInitModuleConfig(t, tmp)
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run(nil); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "successfully initialized") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// The module must have been downloaded
+	if !strings.Contains(output, "Get: file://") {
+		t.Fatalf("module should be downloaded: %s", output)
+	}
+
+	// There is no remote state without a backend
+	if _, err := os.Stat(filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)); err == nil {
+		t.Fatal("remote state should not be configured")
+	}
+}
+
+func TestInit_dirNoGet(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	testInitModuleConfig(t, tmp)
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{"-get=false"}); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if strings.Contains(output, "Get: ") {
+		t.Fatalf("module should not be downloaded: %s", output)
+	}
+	if !strings.Contains(output, "module.foo") {
+		t.Fatalf("missing module should be reported: %s", output)
+	}
+}
+
+func TestInit_dirInvalid(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// A module without a source is invalid
+	err := ioutil.WriteFile(filepath.Join(tmp, "main.tf"), []byte(`module "foo" {}`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run(nil); code != 1 {
+		t.Fatalf("bad: %d\n%s", code, ui.OutputWriter.String())
+	}
+}
+
+func TestInit_dirRemoteState(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	testInitModuleConfig(t, tmp)
+
+	conf, srv := testRemoteState(t, terraform.NewState(), 200)
+	defer srv.Close()
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend", "HTTP",
+		"-backend-config", "address=" + conf.Config["address"],
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	cache := testStateRead(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename))
+	if !cache.Remote.Equals(conf) {
+		t.Fatalf("bad: %#v", cache.Remote)
+	}
+}
+
+func TestInit_dirRemoteStateInvalid(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	testInitModuleConfig(t, tmp)
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	// The HTTP backend requires an address
+	args := []string{"-backend", "http"}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "address") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	if _, err := os.Stat(filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)); err == nil {
+		t.Fatal("remote state should not be configured")
+	}
+}
+
+// Initializing again with a different remote state location offers to copy
+// the existing state there.
+func TestInit_dirRemoteStateMigrate(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+	testInitModuleConfig(t, tmp)
+
+	s := testState()
+	_, oldSrv := testRemoteState(t, s, 200)
+	defer oldSrv.Close()
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), s)
+
+	newConf, newSrv, written := testRemoteStateRecorder(t)
+	defer newSrv.Close()
+
+	test = false
+	defer func() { test = true }()
+	defaultInputReader = bytes.NewBufferString("yes\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &InitCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend", "http",
+		"-backend-config", "address=" + newConf.Config["address"],
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	if written.State == nil || written.State.Lineage != s.Lineage {
+		t.Fatalf("state should be copied: %#v", written.State)
+	}
+}
+
+// testInitModuleConfig writes a configuration that uses a local module to
+// the given directory.
+func testInitModuleConfig(t *testing.T, dir string) {
+	config := fmt.Sprintf("module \"foo\" {\n  source = %q\n}\n",
+		testFixturePath("get/foo"))
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf"), []byte(config), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,174 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// DefaultInputCacheFilename is the name of the file in the data directory
+// where the answers given for provider configuration are saved.
+const DefaultInputCacheFilename = "input.json"
+
+// InputCacheUIInput is an implementation of terraform.UIInput that saves
+// the answers given for provider configuration, such as a region, so that
+// later operations don't have to ask for them again. The answers are saved
+// to a JSON file with the answers for each provider keyed by the provider
+// name.
+//
+// Only the answers for the settings in inputCacheKeys are saved, since
+// providers don't mark most of their credentials as sensitive. Answers to
+// questions marked as sensitive are never saved either. Other questions,
+// such as for variables, are always asked.
+type InputCacheUIInput struct {
+	// UIInput asks for the answers that aren't saved.
+	UIInput terraform.UIInput
+
+	// Path is the path to the file where the answers are saved.
+	Path string
+
+	// Reuse, if true, returns the saved answers instead of asking again.
+	// If false, everything is asked again and the new answers replace
+	// the saved ones.
+	Reuse bool
+
+	l       sync.Mutex
+	answers map[string]map[string]string
+}
+
+func (i *InputCacheUIInput) Input(opts *terraform.InputOpts) (string, error) {
+	provider, key, ok := inputCacheKey(opts.Id)
+	if !ok {
+		return i.UIInput.Input(opts)
+	}
+	secret := opts.Sensitive || !inputCacheKeys[key]
+
+	i.l.Lock()
+	defer i.l.Unlock()
+
+	if i.answers == nil {
+		answers, err := readInputCache(i.Path)
+		if err != nil {
+			// The answers can always be given again, so this isn't
+			// worth failing the operation for.
+			log.Printf("[WARN] Error reading input cache, ignoring: %s", err)
+		}
+		if answers == nil {
+			answers = make(map[string]map[string]string)
+		}
+		i.answers = answers
+	}
+
+	if i.Reuse && !secret {
+		if v, ok := i.answers[provider][key]; ok {
+			log.Printf("[INFO] Using saved input for %s", opts.Id)
+			return v, nil
+		}
+	}
+
+	v, err := i.UIInput.Input(opts)
+	if err != nil {
+		return v, err
+	}
+
+	if secret || v == "" {
+		// Make sure a value saved before the question was sensitive, or
+		// before the key was left out of the saved ones, doesn't stay
+		// around.
+		if _, ok := i.answers[provider][key]; !ok {
+			return v, nil
+		}
+		delete(i.answers[provider], key)
+	} else {
+		if i.answers[provider] == nil {
+			i.answers[provider] = make(map[string]string)
+		}
+		i.answers[provider][key] = v
+	}
+
+	if err := writeInputCache(i.Path, i.answers); err != nil {
+		log.Printf("[WARN] Error writing input cache: %s", err)
+	}
+
+	return v, nil
+}
+
+// inputCacheKeys are the provider configuration keys whose answers are
+// saved: settings that are asked for often and are never secret. Anything
+// else may be a credential, such as the secret_key of the aws provider or
+// the token of the digitalocean provider, which aren't marked sensitive.
+var inputCacheKeys = map[string]bool{
+	"region":                  true,
+	"profile":                 true,
+	"shared_credentials_file": true,
+	"project":                 true,
+	"zone":                    true,
+	"endpoint":                true,
+	"url":                     true,
+	"auth_url":                true,
+	"datacenter":              true,
+	"tenant_name":             true,
+	"domain_name":             true,
+}
+
+// inputCacheKey returns the provider name and configuration key of the
+// question with the given input ID, if the question is for provider
+// configuration. Provider names can contain a period for aliases, but
+// the keys asked for are always top-level, so the key is what follows
+// the last period.
+func inputCacheKey(id string) (string, string, bool) {
+	const prefix = "provider."
+	if !strings.HasPrefix(id, prefix) {
+		return "", "", false
+	}
+
+	id = id[len(prefix):]
+	idx := strings.LastIndex(id, ".")
+	if idx <= 0 || idx == len(id)-1 {
+		return "", "", false
+	}
+
+	return id[:idx], id[idx+1:], true
+}
+
+// readInputCache reads the saved answers at the given path. If there is
+// no file at the path, nil is returned with no error.
+func readInputCache(path string) (map[string]map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result map[string]map[string]string
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("Error reading input cache %s: %s", path, err)
+	}
+
+	return result, nil
+}
+
+// writeInputCache writes the saved answers to the given path.
+func writeInputCache(path string, answers map[string]map[string]string) error {
+	data, err := json.MarshalIndent(answers, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// The answers aren't secret, but they are only meant for this user
+	return ioutil.WriteFile(path, data, 0600)
+}
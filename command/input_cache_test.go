@@ -0,0 +1,278 @@
+package command
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestInputCacheUIInput_impl(t *testing.T) {
+	var _ terraform.UIInput = new(InputCacheUIInput)
+}
+
+func TestInputCacheUIInput(t *testing.T) {
+	path := filepath.Join(tempDir(t), DefaultInputCacheFilename)
+
+	input := &terraform.MockUIInput{
+		InputReturnMap: map[string]string{
+			"provider.aws.region":         "us-east-1",
+			"provider.aws.west.region":    "us-west-2",
+			"provider.aws.secret_key":     "hunter2",
+			"var.foo":                     "bar",
+			"provider.aws.something_else": "",
+		},
+	}
+	i := &InputCacheUIInput{UIInput: input, Path: path, Reuse: true}
+	for _, opts := range []*terraform.InputOpts{
+		{Id: "provider.aws.region"},
+		{Id: "provider.aws.west.region"},
+		{Id: "provider.aws.secret_key", Sensitive: true},
+		{Id: "provider.aws.something_else"},
+		{Id: "var.foo"},
+	} {
+		if _, err := i.Input(opts); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	answers, err := readInputCache(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]map[string]string{
+		"aws":      map[string]string{"region": "us-east-1"},
+		"aws.west": map[string]string{"region": "us-west-2"},
+	}
+	if !reflect.DeepEqual(answers, expected) {
+		t.Fatalf("bad: %#v", answers)
+	}
+
+	// A new input reuses the saved answers
+	input = &terraform.MockUIInput{InputReturnString: "other"}
+	i = &InputCacheUIInput{UIInput: input, Path: path, Reuse: true}
+	v, err := i.Input(&terraform.InputOpts{Id: "provider.aws.region"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "us-east-1" || input.InputCalled {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// Sensitive questions and variables are always asked
+	for _, opts := range []*terraform.InputOpts{
+		{Id: "provider.aws.secret_key", Sensitive: true},
+		{Id: "var.foo"},
+	} {
+		input.InputCalled = false
+		if _, err := i.Input(opts); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !input.InputCalled {
+			t.Fatalf("%s should be asked", opts.Id)
+		}
+	}
+}
+
+func TestInputCacheUIInput_noReuse(t *testing.T) {
+	path := filepath.Join(tempDir(t), DefaultInputCacheFilename)
+	err := writeInputCache(path, map[string]map[string]string{
+		"aws": map[string]string{"region": "us-east-1"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := &terraform.MockUIInput{InputReturnString: "eu-west-1"}
+	i := &InputCacheUIInput{UIInput: input, Path: path}
+	v, err := i.Input(&terraform.InputOpts{Id: "provider.aws.region"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "eu-west-1" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// The new answer replaces the saved one
+	answers, err := readInputCache(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if answers["aws"]["region"] != "eu-west-1" {
+		t.Fatalf("bad: %#v", answers)
+	}
+}
+
+func TestInputCacheUIInput_sensitiveRemoved(t *testing.T) {
+	path := filepath.Join(tempDir(t), DefaultInputCacheFilename)
+	err := writeInputCache(path, map[string]map[string]string{
+		"aws": map[string]string{"token": "foo"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	input := &terraform.MockUIInput{InputReturnString: "bar"}
+	i := &InputCacheUIInput{UIInput: input, Path: path, Reuse: true}
+	v, err := i.Input(&terraform.InputOpts{
+		Id:        "provider.aws.token",
+		Sensitive: true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "bar" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	answers, err := readInputCache(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := answers["aws"]["token"]; ok {
+		t.Fatalf("bad: %#v", answers)
+	}
+}
+
+func TestInputCacheUIInput_credentials(t *testing.T) {
+	path := filepath.Join(tempDir(t), DefaultInputCacheFilename)
+
+	// Providers don't mark all their credentials as sensitive
+	input := &terraform.MockUIInput{
+		InputReturnMap: map[string]string{
+			"provider.aws.region":         "us-east-1",
+			"provider.aws.secret_key":     "hunter2",
+			"provider.digitalocean.token": "swordfish",
+		},
+	}
+	i := &InputCacheUIInput{UIInput: input, Path: path, Reuse: true}
+	for id := range input.InputReturnMap {
+		if _, err := i.Input(&terraform.InputOpts{Id: id}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	answers, err := readInputCache(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := map[string]map[string]string{
+		"aws": map[string]string{"region": "us-east-1"},
+	}
+	if !reflect.DeepEqual(answers, expected) {
+		t.Fatalf("bad: %#v", answers)
+	}
+
+	// They are asked again
+	input = &terraform.MockUIInput{InputReturnString: "other"}
+	i = &InputCacheUIInput{UIInput: input, Path: path, Reuse: true}
+	v, err := i.Input(&terraform.InputOpts{Id: "provider.aws.secret_key"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "other" || !input.InputCalled {
+		t.Fatalf("bad: %q", v)
+	}
+}
+
+func TestInputCacheKey(t *testing.T) {
+	cases := []struct {
+		Id       string
+		Provider string
+		Key      string
+		Ok       bool
+	}{
+		{"provider.aws.region", "aws", "region", true},
+		{"provider.aws.west.region", "aws.west", "region", true},
+		{"provider.aws", "", "", false},
+		{"provider.aws.", "", "", false},
+		{"var.foo", "", "", false},
+		{"destroy", "", "", false},
+	}
+
+	for _, tc := range cases {
+		provider, key, ok := inputCacheKey(tc.Id)
+		if provider != tc.Provider || key != tc.Key || ok != tc.Ok {
+			t.Fatalf("%s: bad: %q %q %v", tc.Id, provider, key, ok)
+		}
+	}
+}
+
+func TestRefresh_inputCache(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	dataDir := tempDir(t)
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	p.InputFn = func(i terraform.UIInput, c *terraform.ResourceConfig) (*terraform.ResourceConfig, error) {
+		for _, opts := range []*terraform.InputOpts{
+			{Id: "region", Query: "region"},
+			{Id: "password", Query: "password", Sensitive: true},
+		} {
+			v, err := i.Input(opts)
+			if err != nil {
+				return nil, err
+			}
+			c.Config[opts.Id] = v
+		}
+
+		return c, nil
+	}
+
+	for n, expected := range []int{2, 1} {
+		ui := new(cli.MockUi)
+		input := &terraform.MockUIInput{
+			InputReturnMap: map[string]string{
+				"provider.test.region":   "us-east-1",
+				"provider.test.password": "hunter2",
+			},
+		}
+		var asked int
+		input.InputFn = func(opts *terraform.InputOpts) (string, error) {
+			asked++
+			return input.InputReturnMap[opts.Id], nil
+		}
+
+		opts := testCtxConfig(p)
+		opts.UIInput = input
+		c := &RefreshCommand{
+			Meta: Meta{
+				ContextOpts: opts,
+				Ui:          ui,
+				dataDir:     dataDir,
+			},
+		}
+
+		args := []string{
+			"-state", statePath,
+			testFixturePath("refresh"),
+		}
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("%d: bad: %d\n\n%s", n, code, ui.ErrorWriter.String())
+		}
+
+		if asked != expected {
+			t.Fatalf("%d: asked %d times, expected %d", n, asked, expected)
+		}
+	}
+
+	m := &Meta{statePath: statePath, dataDir: dataDir}
+	data, err := ioutil.ReadFile(filepath.Join(m.stateDataDir(), DefaultInputCacheFilename))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(data), "us-east-1") {
+		t.Fatalf("bad: %s", data)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "password") {
+		t.Fatalf("sensitive answer was saved: %s", data)
+	}
+}
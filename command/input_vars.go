@@ -0,0 +1,185 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// errVariableInputAborted is returned when no value is given for a
+// required variable, such as when the input is closed with Ctrl-D.
+var errVariableInputAborted = errors.New(
+	"no value was given for a required variable, aborting")
+
+// VariableUIInput is an implementation of terraform.UIInput that helps
+// with asking for the values of unset root module variables. Before the
+// first variable is asked for, it outputs the names of all the variables
+// that need values. Answering "?" outputs the description of the variable
+// from the configuration, and an empty answer aborts the operation instead
+// of asking for the same variable again. Answers that aren't valid for the
+// type of the variable are asked for again, up to MaxAttempts times.
+//
+// Questions that aren't for root module variables are passed through to
+// UIInput unchanged.
+type VariableUIInput struct {
+	// UIInput asks for the values.
+	UIInput terraform.UIInput
+
+	// Ui is used to output the list of variables and descriptions.
+	Ui cli.Ui
+
+	// Variables are the root module variables from the configuration,
+	// and Unset are the names of the ones that will be asked for.
+	Variables []*config.Variable
+	Unset     []string
+
+	// MaxAttempts is the number of answers, including "?", that are
+	// accepted for each variable before giving up on it. If zero,
+	// DefaultMaxInputAttempts is used.
+	MaxAttempts int
+
+	l        sync.Mutex
+	preamble bool
+	answered map[string]struct{}
+}
+
+func (i *VariableUIInput) Input(opts *terraform.InputOpts) (string, error) {
+	if !strings.HasPrefix(opts.Id, "var.") {
+		return i.UIInput.Input(opts)
+	}
+	name := opts.Id[len("var."):]
+
+	i.l.Lock()
+	defer i.l.Unlock()
+
+	if !i.preamble {
+		i.preamble = true
+		if len(i.Unset) > 0 {
+			i.Ui.Output(formatUnsetVariables(i.Unset))
+		}
+	}
+
+	max := i.MaxAttempts
+	if max <= 0 {
+		max = DefaultMaxInputAttempts
+	}
+
+	variable := i.variable(name)
+	for attempt := 1; ; attempt++ {
+		v, err := i.UIInput.Input(opts)
+		if err != nil {
+			return v, err
+		}
+
+		switch v {
+		case "?":
+			i.Ui.Output(i.describe(name))
+		case "":
+			return "", errVariableInputAborted
+		default:
+			if variable == nil {
+				return v, nil
+			}
+
+			_, err := terraform.ParseVariableInput(name, v, variable.Type())
+			if err == nil {
+				if i.answered == nil {
+					i.answered = make(map[string]struct{})
+				}
+				i.answered[name] = struct{}{}
+				return v, nil
+			}
+
+			i.Ui.Error(err.Error())
+		}
+
+		if attempt >= max {
+			return "", i.attemptsError(name, max)
+		}
+	}
+}
+
+// attemptsError returns the error for giving up on the given variable,
+// listing all the variables that still don't have a value.
+func (i *VariableUIInput) attemptsError(name string, max int) error {
+	var unsatisfied []string
+	for _, n := range i.Unset {
+		if _, ok := i.answered[n]; !ok {
+			unsatisfied = append(unsatisfied, "var."+n)
+		}
+	}
+	if len(unsatisfied) == 0 {
+		unsatisfied = []string{"var." + name}
+	}
+
+	return fmt.Errorf(
+		"no valid value was given for var.%s after %d attempts, aborting.\n"+
+			"These variables still need values: %s",
+		name, max, strings.Join(unsatisfied, ", "))
+}
+
+// variable returns the variable with the given name from the configuration,
+// or nil if it isn't declared.
+func (i *VariableUIInput) variable(name string) *config.Variable {
+	for _, v := range i.Variables {
+		if v.Name == name {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// describe returns the description of the variable with the given name
+// for answering "?".
+func (i *VariableUIInput) describe(name string) string {
+	v := i.variable(name)
+	if v == nil {
+		return fmt.Sprintf("var.%s is not declared in the configuration.", name)
+	}
+
+	if v.Description == "" {
+		return fmt.Sprintf("var.%s (%s) has no description.", name, v.Type().Printable())
+	}
+
+	return fmt.Sprintf("var.%s (%s): %s", name, v.Type().Printable(), v.Description)
+}
+
+// formatUnsetVariables returns the message output before asking for the
+// given unset variables.
+func formatUnsetVariables(names []string) string {
+	what := "variables need values"
+	if len(names) == 1 {
+		what = "variable needs a value"
+	}
+
+	return fmt.Sprintf(
+		"%d %s: %s\n"+
+			"Enter \"?\" to see the description of a variable, or nothing to abort.\n",
+		len(names), what, strings.Join(names, ", "))
+}
+
+// unsetVariables returns the sorted names of the root module variables
+// that Context.Input will ask for: those that have no value and no default.
+func unsetVariables(vars []*config.Variable, values map[string]interface{}) []string {
+	var result []string
+	for _, v := range vars {
+		if _, ok := values[v.Name]; ok {
+			continue
+		}
+		if v.Default != nil || v.Type() == config.VariableTypeUnknown {
+			continue
+		}
+
+		result = append(result, v.Name)
+	}
+	sort.Strings(result)
+
+	return result
+}
@@ -0,0 +1,189 @@
+package command
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestVariableUIInput_impl(t *testing.T) {
+	var _ terraform.UIInput = new(VariableUIInput)
+}
+
+func TestVariableUIInput(t *testing.T) {
+	ui := new(cli.MockUi)
+	answers := []string{"?", "bar"}
+	input := &terraform.MockUIInput{
+		InputFn: func(opts *terraform.InputOpts) (string, error) {
+			v := answers[0]
+			answers = answers[1:]
+			return v, nil
+		},
+	}
+	i := &VariableUIInput{
+		UIInput: input,
+		Ui:      ui,
+		Variables: []*config.Variable{
+			{Name: "foo", Description: "The foo to use."},
+			{Name: "baz"},
+		},
+		Unset: []string{"baz", "foo"},
+	}
+
+	v, err := i.Input(&terraform.InputOpts{Id: "var.foo", Query: "var.foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "bar" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "2 variables need values: baz, foo") {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "var.foo (string): The foo to use.") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// The list is only output once
+	ui.OutputWriter.Reset()
+	answers = []string{"?", "qux"}
+	if _, err := i.Input(&terraform.InputOpts{Id: "var.baz", Query: "var.baz"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	output = ui.OutputWriter.String()
+	if strings.Contains(output, "need values") {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "var.baz (string) has no description.") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestVariableUIInput_empty(t *testing.T) {
+	ui := new(cli.MockUi)
+	input := &terraform.MockUIInput{InputReturnString: ""}
+	i := &VariableUIInput{
+		UIInput:   input,
+		Ui:        ui,
+		Variables: []*config.Variable{{Name: "foo"}},
+		Unset:     []string{"foo"},
+	}
+
+	_, err := i.Input(&terraform.InputOpts{Id: "var.foo", Query: "var.foo"})
+	if err != errVariableInputAborted {
+		t.Fatalf("bad: %#v", err)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "1 variable needs a value: foo") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestVariableUIInput_provider(t *testing.T) {
+	ui := new(cli.MockUi)
+	input := &terraform.MockUIInput{InputReturnString: ""}
+	i := &VariableUIInput{
+		UIInput: input,
+		Ui:      ui,
+		Unset:   []string{"foo"},
+	}
+
+	// Empty answers for other questions are passed through
+	v, err := i.Input(&terraform.InputOpts{Id: "provider.aws.region"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != "" || ui.OutputWriter != nil {
+		t.Fatalf("bad: %q %q", v, ui.OutputWriter.String())
+	}
+}
+
+func TestUnsetVariables(t *testing.T) {
+	vars := []*config.Variable{
+		{Name: "b"},
+		{Name: "a"},
+		{Name: "set"},
+		{Name: "default", Default: "foo"},
+	}
+	actual := unsetVariables(vars, map[string]interface{}{"set": "bar"})
+	expected := []string{"a", "b"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestVariableUIInput_maxAttempts(t *testing.T) {
+	attempts := 0
+	ui := new(cli.MockUi)
+	input := &terraform.MockUIInput{
+		InputFn: func(opts *terraform.InputOpts) (string, error) {
+			attempts++
+			return "[not a list", nil
+		},
+	}
+	i := &VariableUIInput{
+		UIInput: input,
+		Ui:      ui,
+		Variables: []*config.Variable{
+			{Name: "bar", DeclaredType: "list"},
+			{Name: "foo"},
+		},
+		Unset: []string{"bar", "foo"},
+	}
+
+	_, err := i.Input(&terraform.InputOpts{Id: "var.bar", Query: "var.bar"})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if attempts != DefaultMaxInputAttempts {
+		t.Fatalf("bad: %d", attempts)
+	}
+	if !strings.Contains(err.Error(), "These variables still need values: var.bar, var.foo") {
+		t.Fatalf("bad: %s", err)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Cannot parse value for variable bar") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+func TestVariableUIInput_maxAttemptsRetry(t *testing.T) {
+	ui := new(cli.MockUi)
+	answers := []string{"?", "[not a list", `["a", "b"]`}
+	input := &terraform.MockUIInput{
+		InputFn: func(opts *terraform.InputOpts) (string, error) {
+			v := answers[0]
+			answers = answers[1:]
+			return v, nil
+		},
+	}
+	i := &VariableUIInput{
+		UIInput:   input,
+		Ui:        ui,
+		Variables: []*config.Variable{{Name: "foo", DeclaredType: "list"}},
+		Unset:     []string{"foo"},
+	}
+
+	// The third answer is valid
+	v, err := i.Input(&terraform.InputOpts{Id: "var.foo", Query: "var.foo"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v != `["a", "b"]` {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// With fewer attempts it gives up
+	answers = []string{"?", "?", "?"}
+	i.MaxAttempts = 2
+	if _, err := i.Input(&terraform.InputOpts{Id: "var.foo", Query: "var.foo"}); err == nil {
+		t.Fatal("should error")
+	}
+	if len(answers) != 1 {
+		t.Fatalf("bad: %#v", answers)
+	}
+}
@@ -1,11 +1,13 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
 
 	"github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/state/remote"
 	"github.com/kardianos/osext"
 )
 
@@ -42,6 +44,12 @@ func (c *InternalPluginCommand) Run(args []string) int {
 	pluginType := args[0]
 	pluginName := args[1]
 
+	// This isn't a plugin, but a way for tools to find out what can be
+	// configured for the remote state.
+	if pluginType == "backend-schema" {
+		return c.backendSchema(pluginName)
+	}
+
 	log.SetPrefix(fmt.Sprintf("%s-%s (internal) ", pluginName, pluginType))
 
 	switch pluginType {
@@ -73,12 +81,34 @@ func (c *InternalPluginCommand) Run(args []string) int {
 	return 0
 }
 
+// backendSchema outputs the schema of the configuration of the remote
+// state backend with the given name as JSON.
+func (c *InternalPluginCommand) backendSchema(name string) int {
+	schema, err := remote.Schema(name)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error encoding schema: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(string(data))
+	return 0
+}
+
 func (c *InternalPluginCommand) Help() string {
 	helpText := `
 Usage: terraform internal-plugin pluginType pluginName
 
   Runs an internally-compiled version of a plugin from the terraform binary.
 
+  With the pluginType "backend-schema", the schema of the configuration of
+  the remote state backend pluginName is output as JSON instead.
+
   NOTE: this is an internal command and you should not call it yourself.
 `
 
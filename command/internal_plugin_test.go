@@ -2,7 +2,13 @@
 
 package command
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/terraform/state/remote"
+	"github.com/mitchellh/cli"
+)
 
 func TestInternalPlugin_InternalProviders(t *testing.T) {
 	// Note this is a randomish sample and does not check for all plugins
@@ -32,3 +38,29 @@ func TestInternalPlugin_BuildPluginCommandString(t *testing.T) {
 		t.Errorf("Expected command to end with %s; got:\n%s\n", expected, actual)
 	}
 }
+
+func TestInternalPlugin_backendSchema(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &InternalPluginCommand{Meta: Meta{Ui: ui}}
+
+	if code := c.Run([]string{"backend-schema", "local"}); code != 0 {
+		t.Fatalf("bad: %d\n%s", code, ui.ErrorWriter.String())
+	}
+
+	var schema remote.ConfigSchema
+	if err := json.Unmarshal(ui.OutputWriter.Bytes(), &schema); err != nil {
+		t.Fatalf("err: %s\n%s", err, ui.OutputWriter.String())
+	}
+	if path := schema["path"]; path == nil || !path.Required {
+		t.Fatalf("bad: %#v", schema)
+	}
+}
+
+func TestInternalPlugin_backendSchemaUnknown(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &InternalPluginCommand{Meta: Meta{Ui: ui}}
+
+	if code := c.Run([]string{"backend-schema", "nope"}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+}
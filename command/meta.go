@@ -2,6 +2,7 @@ package command
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"flag"
 	"fmt"
 	"io"
@@ -9,17 +10,25 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/config/module"
 	"github.com/hashicorp/terraform/helper/experiment"
+	"github.com/hashicorp/terraform/helper/logging"
 	"github.com/hashicorp/terraform/helper/variables"
 	"github.com/hashicorp/terraform/helper/wrappedstreams"
 	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/state/remote"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 	"github.com/mitchellh/colorstring"
@@ -31,26 +40,157 @@ type Meta struct {
 	ContextOpts *terraform.ContextOpts
 	Ui          cli.Ui
 
+	// StatePersistHook, if set, is called with the final state every time
+	// PersistState successfully writes it out. It is called after any
+	// backup of the prior state has been written and after the new state
+	// has been persisted, so the hook only ever sees durable state. If the
+	// hook returns an error, the command treats it as a failure to persist.
+	//
+	// Since a Meta is created for each command invocation, this also acts
+	// as a per-operation hook.
+	StatePersistHook func(*terraform.State) error
+
+	// LogWriter, if set, receives the log output of the operation run by
+	// the command, such as plan or apply, filtered by the TF_LOG level.
+	// The logs still go to their regular output as well.
+	LogWriter io.Writer
+
+	// FailOnInput, if set, makes every request for input fail with an
+	// error naming what was asked for instead of prompting. This is for
+	// running without a terminal, such as in CI, where a prompt would
+	// wait forever.
+	FailOnInput bool
+
+	// OperationID is the unique ID of the operation run by the command. It
+	// is recorded in the state lock and the logs so that they can be
+	// correlated. If this is empty, the ID is read from the environment
+	// variable in OperationIDEnvVar, or a new one is generated.
+	OperationID string
+
+	// OperationSource describes who or what started the operation run by
+	// the command, such as "CI job 1234". It is shown in the header of the
+	// operation and recorded in the state lock and the logs. If this is
+	// empty, it is read from the environment variable in
+	// OperationSourceEnvVar.
+	OperationSource string
+
+	// OperationAnnotations are extra key/value information about the
+	// operation run by the command, such as the user that triggered it,
+	// recorded in the state lock and the logs.
+	OperationAnnotations map[string]string
+
+	// PlanEncryptionKey, if set, is the passphrase that plan files are
+	// encrypted with when they are written, and decrypted with when they
+	// are read. If this is empty, the passphrase is read from the
+	// environment variable in PlanEncryptionKeyEnvVar.
+	PlanEncryptionKey []byte
+
+	// PlanPolicy, if set, is called with the plan before it is applied. If
+	// it returns an error, the apply is aborted without changing anything.
+	// The apply command can also run a policy command given by a flag.
+	PlanPolicy PlanPolicy
+
+	// MaxInputAttempts is the number of times a variable is asked for when
+	// the answers aren't valid values for it, after which the operation
+	// fails. If zero, DefaultMaxInputAttempts is used.
+	MaxInputAttempts int
+
+	// OperationRecoveryPath is where the state is written when it can't be
+	// saved after an operation changed it, so that the changes aren't
+	// lost. If empty, DefaultErroredStateFilename is used.
+	OperationRecoveryPath string
+
+	// OperationHooks are called at the boundaries of the operation run by
+	// the command.
+	OperationHooks OperationHooks
+
+	// OperationMetrics, if set, collects metrics of the operation run by
+	// the command, such as how long it took and whether it failed.
+	OperationMetrics OperationMetrics
+
+	// PlanColors overrides the colors of the roles changes are shown with,
+	// such as ColorRoleCreate, mapping them to the name of a color or to a
+	// raw color code. These take precedence over PlanColorsEnvVar.
+	PlanColors map[string]string
+
+	// StaleLockTimeout, if set, lets the lock on a local state file be
+	// taken over if it's older than this and was acquired by a process on
+	// this host that no longer runs, such as one that crashed. If zero,
+	// it is read from the environment variable in LockTakeoverEnvVar.
+	// The -lock-takeover flag overrides it. Locks are never taken over by
+	// default.
+	StaleLockTimeout time.Duration
+
+	// StateLockPath, if set, is the path of the lock info file of a local
+	// state file, instead of the one next to the state file. The
+	// -state-lock-file flag overrides it. All the processes using the state
+	// must use the same lock path, or a warning is output.
+	StateLockPath string
+
+	// DisableStateAutoUpgrade stops local state files in an older format
+	// from being upgraded to the current format when they're written, so
+	// that writing them is an error instead. By default, they are
+	// upgraded after the original is backed up.
+	DisableStateAutoUpgrade bool
+
 	// State read when calling `Context`. This is available after calling
 	// `Context`.
 	state       state.State
 	stateResult *StateResult
 
+	// The lock held on the state, if the state was locked when calling
+	// `Context`. This is released with unlockState.
+	stateLock   state.Locker
+	stateLockID string
+
+	// Plan read when calling `Context` with the path to a plan file. This
+	// is available after calling `Context`.
+	plan *terraform.Plan
+
 	// This can be set by the command itself to provide extra hooks.
 	extraHooks []terraform.Hook
 
 	// This can be set by tests to change some directories
 	dataDir string
 
+	// This can be set by tests to keep the state somewhere other than in
+	// the local or remote state file. See StateOpts.Store.
+	stateStore state.State
+
+	// workingDir is the directory given with -chdir. The default paths of
+	// the configuration, state, variable files and data directory are
+	// within it instead of the current directory.
+	workingDir string
+
 	// Variables for the context (private)
 	autoKey       string
 	autoVariables map[string]interface{}
 	input         bool
+	inputCache    bool
+	inputOverride *bool
 	variables     map[string]interface{}
+	varFiles      []string
 
 	// Targets for this context (private)
 	targets []string
 
+	// Warnings collected while running the operation. These are output
+	// with showWarnings once the operation completes. If warningsAsErrors
+	// is set, any warnings make the operation fail once it completes. If
+	// compactWarnings is set, similar warnings are output as one.
+	warnings         []string
+	warningsShown    bool
+	warningsAsErrors bool
+	compactWarnings  bool
+
+	// quiet, set with -quiet, suppresses the header naming the state that
+	// the operation uses.
+	quiet bool
+
+	// Redacts the values of sensitive variables from the Ui and the
+	// captured logs. The values are added once the context is loaded.
+	redactor *Redactor
+
 	color bool
 	oldUi cli.Ui
 
@@ -66,21 +206,30 @@ type Meta struct {
 	// If not provided, the StatePath is used causing the old state to
 	// be overriden.
 	//
+	// operationStart is when the operation run by the command started
+	//
 	// backupPath is used to backup the state file before writing a modified
 	// version. It defaults to stateOutPath + DefaultBackupExtension
 	//
+	// backupDir is the directory to write the backup to instead of the
+	// directory of stateOutPath, keeping the name of the backup
+	//
 	// parallelism is used to control the number of concurrent operations
 	// allowed when walking the graph
 	//
 	// shadow is used to enable/disable the shadow graph
 	//
 	// provider is to specify specific resource providers
-	statePath    string
-	stateOutPath string
-	backupPath   string
-	parallelism  int
-	shadow       bool
-	provider     string
+	statePath      string
+	stateOutPath   string
+	stateOverride  bool
+	operation      string
+	operationStart time.Time
+	backupPath     string
+	backupDir      string
+	parallelism    int
+	shadow         bool
+	provider       string
 }
 
 // initStatePaths is used to initialize the default values for
@@ -105,7 +254,7 @@ func (m *Meta) StateOutPath() string {
 // Colorize returns the colorization structure for a command.
 func (m *Meta) Colorize() *colorstring.Colorize {
 	return &colorstring.Colorize{
-		Colors:  colorstring.DefaultColors,
+		Colors:  m.colors(),
 		Disable: !m.color,
 		Reset:   true,
 	}
@@ -114,14 +263,68 @@ func (m *Meta) Colorize() *colorstring.Colorize {
 // Context returns a Terraform Context taking into account the context
 // options used to initialize this meta configuration.
 func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
-	opts := m.contextOpts()
+	// Let the program running the command know before anything is loaded
+	if err := m.preOperation(copts.Operation); err != nil {
+		return nil, false, err
+	}
+
+	// The operation decides about input before anything asks for it
+	if copts.Input != nil {
+		m.inputOverride = copts.Input
+	}
+
+	b := &contextBuilder{Opts: m.contextOpts()}
+	opts := b.Opts
+
+	// Save the answers given for provider configuration so that later
+	// operations don't ask for them again.
+	opts.UIInput = &InputCacheUIInput{
+		UIInput: opts.UIInput,
+		Path:    filepath.Join(m.stateDataDir(), DefaultInputCacheFilename),
+		Reuse:   m.inputCache,
+	}
 
 	// First try to just read the plan directly from the path given.
 	f, err := os.Open(copts.Path)
 	if err == nil {
-		plan, err := terraform.ReadPlan(f)
+		plan, err := readPlan(f, m.planEncryptionKey())
 		f.Close()
+		if _, ok := err.(*planDecryptError); ok {
+			return nil, false, fmt.Errorf("Error loading plan: %s", err)
+		}
 		if err == nil {
+			// If we were given a plan ID then verify that this is the
+			// plan we expect before doing anything else with it.
+			if copts.PlanId != "" {
+				id, err := plan.Id()
+				if err != nil {
+					return nil, false, err
+				}
+				if id != copts.PlanId {
+					return nil, false, fmt.Errorf(
+						"The plan file %q has ID %s, which doesn't match the\n"+
+							"expected plan ID %s. The plan file may have been\n"+
+							"overwritten by another plan. Please verify the plan and\n"+
+							"try again.",
+						copts.Path, id, copts.PlanId)
+				}
+			}
+
+			// Plans are only meant to be applied by the version of
+			// Terraform that created them.
+			if err := m.checkPlanVersion(plan, copts); err != nil {
+				return nil, false, err
+			}
+
+			// If we're continuing a partially applied plan, remove what
+			// was already applied and use the current state instead.
+			if copts.Progress != nil {
+				if err := m.continuePlan(plan, copts.Progress); err != nil {
+					return nil, false, err
+				}
+			}
+			m.plan = plan
+
 			// Setup our state, force it to use our plan's state
 			stateOpts := m.StateOpts()
 			if plan != nil {
@@ -137,6 +340,22 @@ func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
 			// Set our state
 			m.state = result.State
 
+			if copts.Lock {
+				if err := m.lockState(m.state, copts); err != nil {
+					return nil, false, err
+				}
+			}
+
+			// The plan was made from the remote state as it was then, so it
+			// can't be applied if someone changed the state since.
+			if result.Remote != nil {
+				if rs, ok := result.Remote.Durable.(*remote.State); ok {
+					if err := rs.CheckRemote(plan.State); err != nil {
+						return nil, false, errwrap.Wrapf("Error loading plan: {{err}}", err)
+					}
+				}
+			}
+
 			// this is used for printing the saved location later
 			if m.stateOutPath == "" {
 				m.stateOutPath = result.StatePath
@@ -150,8 +369,14 @@ func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
 						"variable values, create a new plan file.")
 			}
 
+			b.Merge(copts, true)
 			ctx, err := plan.Context(opts)
-			return ctx, true, err
+			if err != nil {
+				return nil, true, err
+			}
+
+			redactVariables(m.redactor, ctx)
+			return ctx, true, nil
 		}
 	}
 
@@ -159,9 +384,7 @@ func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
 	if copts.StatePath != "" {
 		m.statePath = copts.StatePath
 	}
-
-	// Tell the context if we're in a destroy plan / apply
-	opts.Destroy = copts.Destroy
+	m.stateOverride = copts.StateOverride
 
 	// Store the loaded state
 	state, err := m.State()
@@ -169,15 +392,58 @@ func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
 		return nil, false, err
 	}
 
+	// A state file used in place of the remote state isn't the state of
+	// record, so it is never written and there is nothing to lock.
+	if m.stateResult != nil && m.stateResult.Override {
+		if err := checkOverrideLineage(m.stateResult, copts.StateOverrideForce); err != nil {
+			return nil, false, err
+		}
+
+		log.Printf("[INFO] Using state file %s instead of the remote state", m.statePath)
+		state = &readOnlyState{StateReader: state, StateRefresher: state}
+		m.state = state
+		copts.Lock = false
+	}
+
+	// Lock the state and refresh it once we hold the lock, since it may
+	// have been modified by whoever held the lock before us.
+	if copts.Lock {
+		if err := m.lockState(state, copts); err != nil {
+			return nil, false, err
+		}
+
+		if err := state.RefreshState(); err != nil {
+			return nil, false, fmt.Errorf("Error reading state: %s", err)
+		}
+	}
+
+	// If the state file can't be written, such as when it needs an upgrade
+	// that is disabled, fail now rather than after changing anything.
+	if copts.WritesState && m.stateResult != nil && !m.stateResult.Override &&
+		m.stateResult.Remote == nil && m.stateResult.Local != nil {
+		if err := m.stateResult.Local.CheckWrite(); err != nil {
+			return nil, false, err
+		}
+	}
+
 	// Load the root module
 	var mod *module.Tree
 	if copts.Path != "" {
+		if _, err := os.Stat(copts.Path); os.IsNotExist(err) {
+			return nil, false, fmt.Errorf(
+				strings.TrimSpace(errConfigDirNotFound), copts.Path)
+		}
+
 		mod, err = module.NewTreeModule("", copts.Path)
 
 		// Check for the error where we have no config files but
 		// allow that. If that happens, clear the error.
-		if errwrap.ContainsType(err, new(config.ErrNoConfigsFound)) &&
-			copts.PathEmptyOk {
+		if errwrap.ContainsType(err, new(config.ErrNoConfigsFound)) {
+			if !copts.PathEmptyOk {
+				return nil, false, fmt.Errorf(
+					strings.TrimSpace(errNoConfigsFound), copts.Path)
+			}
+
 			log.Printf(
 				"[WARN] Empty configuration dir, ignoring: %s", copts.Path)
 			err = nil
@@ -193,29 +459,294 @@ func (m *Meta) Context(copts contextOpts) (*terraform.Context, bool, error) {
 
 	err = mod.Load(m.moduleStorage(m.DataDir()), copts.GetMode)
 	if err != nil {
+		if notFound, ok := err.(*module.ErrModulesNotFound); ok {
+			return nil, false, fmt.Errorf(strings.TrimSpace(errModulesNotFound),
+				"  * module."+strings.Join(notFound.Modules, "\n  * module."))
+		}
+
 		return nil, false, fmt.Errorf("Error downloading modules: %s", err)
 	}
 
+	// Check the version requirements of all the modules before anything
+	// else, since the configuration of a newer version may not even be
+	// valid for this one.
+	if err := terraform.CheckRequiredVersion(mod); err != nil {
+		return nil, false, err
+	}
+
 	// Validate the module right away
 	if err := mod.Validate(); err != nil {
-		return nil, false, err
+		return nil, false, &ErrValidation{Errors: []error{err}}
 	}
 
+	// List the variables that will be asked for before asking for the
+	// first one. The unset variables are only known once the context has
+	// merged in the values from the environment.
+	varInput := &VariableUIInput{
+		UIInput:     opts.UIInput,
+		Ui:          m.Ui,
+		Variables:   mod.Config().Variables,
+		MaxAttempts: m.MaxInputAttempts,
+	}
+	opts.UIInput = varInput
+
+	b.Merge(copts, false)
 	opts.Module = mod
-	opts.Parallelism = copts.Parallelism
+	opts.SkipProviderConfig = copts.SkipProviderConfig &&
+		(copts.Destroy || !moduleHasResources(mod))
 	opts.State = state.State()
 	ctx, err := terraform.NewContext(opts)
-	return ctx, false, err
+	if err != nil {
+		return nil, false, err
+	}
+	varInput.Unset = unsetVariables(varInput.Variables, ctx.Variables())
+
+	redactVariables(m.redactor, ctx)
+	return ctx, false, nil
+}
+
+// moduleHasResources returns true if there are any resources, managed or
+// data, in the configuration of the module or its children.
+func moduleHasResources(mod *module.Tree) bool {
+	if c := mod.Config(); c != nil && len(c.Resources) > 0 {
+		return true
+	}
+	for _, child := range mod.Children() {
+		if moduleHasResources(child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContextForConsole returns a context for evaluating interpolations, such
+// as for the console command, along with the state it was built with. The
+// context is built the same way as for the other operations, with the
+// same state, variables and input settings, and unset variables are asked
+// for like they are for a plan. The state is never locked or written, so
+// this is safe to use while another operation is running.
+func (m *Meta) ContextForConsole(copts contextOpts) (*terraform.Context, state.State, error) {
+	copts.Lock = false
+
+	// Piped stdin is where the expressions to evaluate come from, so
+	// nothing can be asked for then.
+	if m.StdinPiped() {
+		input := false
+		copts.Input = &input
+	}
+
+	ctx, _, err := m.Context(copts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only variables are asked for since providers are never configured
+	// for evaluating interpolations.
+	mode := m.InputMode() &^ terraform.InputModeProvider
+	if err := ctx.Input(mode); err != nil {
+		return nil, nil, fmt.Errorf("Error configuring: %s", err)
+	}
+
+	return ctx, m.state, nil
+}
+
+// continuePlan prepares the plan to continue a previous apply of it that
+// partially failed. The resources that were already applied are removed
+// from the diff, and the plan state is replaced with the current state,
+// which has the results of those resources.
+func (m *Meta) continuePlan(plan *terraform.Plan, progress *ApplyProgress) error {
+	id, err := plan.Id()
+	if err != nil {
+		return err
+	}
+	if id != progress.PlanId {
+		return fmt.Errorf(
+			"The recorded apply progress is for the plan with ID %s, but the\n"+
+				"plan being applied has ID %s. Only an apply of the same plan\n"+
+				"can be continued.",
+			progress.PlanId, id)
+	}
+
+	result, err := State(m.StateOpts())
+	if err != nil {
+		return fmt.Errorf("Error loading state to continue apply: %s", err)
+	}
+
+	skipped, err := progress.Skip(plan.Diff)
+	if err != nil {
+		return err
+	}
+	log.Printf("[INFO] Continuing apply of plan %s, skipping %d resources", id, skipped)
+
+	if result.State != nil {
+		plan.State = result.State.State()
+	}
+
+	return nil
+}
+
+// lockState locks the given state if it supports locking, waiting up to
+// the lock timeout in copts if someone else holds the lock. The lock is
+// released by unlockState.
+func (m *Meta) lockState(s state.State, copts contextOpts) error {
+	l, ok := s.(state.Locker)
+	if !ok {
+		return nil
+	}
+
+	info := state.NewLockInfo()
+	info.Operation = copts.Operation
+	info.OperationID = m.operationID()
+	info.Source = m.operationSource()
+	info.Annotations = m.OperationAnnotations
+	id, err := state.LockWithTimeout(l, info, copts.LockTimeout, func(held *state.LockInfo) {
+		m.Ui.Output("Waiting for state lock...")
+		if held != nil {
+			log.Printf(
+				"[INFO] Operation %s waiting for state lock %s held by operation %q",
+				info.OperationID, held.ID, held.OperationID)
+		}
+	})
+	if lockErr, ok := err.(*state.LockError); ok {
+		err = &ErrStateLocked{Lock: lockErr}
+	}
+	if err != nil {
+		return errwrap.Wrapf("Error locking state: {{err}}", err)
+	}
+	if id == "" {
+		// The state doesn't actually support locking
+		return nil
+	}
+	m.warnLockTakeover(s)
+	m.warnLockPathMismatch(s)
+
+	m.stateLock = l
+	m.stateLockID = id
+	return nil
+}
+
+// warnLockTakeover outputs a warning if locking the state took over the
+// stale lock of a process that no longer runs.
+func (m *Meta) warnLockTakeover(s state.State) {
+	if b, ok := s.(*state.BackupState); ok {
+		s = b.Real
+	}
+	ls, ok := s.(*state.LocalState)
+	if !ok || ls.TookOverLock == nil {
+		return
+	}
+
+	stale := ls.TookOverLock
+	m.Ui.Warn(fmt.Sprintf(
+		"Took over the stale state lock %s, which was acquired at %s by\n"+
+			"pid %d on this host for %q and is no longer running.\n",
+		stale.ID, stale.Created, stale.Pid, stale.Operation))
+}
+
+// warnLockPathMismatch outputs a warning if another process locked the
+// local state with a different lock info file than ours.
+func (m *Meta) warnLockPathMismatch(s state.State) {
+	if b, ok := s.(*state.BackupState); ok {
+		s = b.Real
+	}
+	ls, ok := s.(*state.LocalState)
+	if !ok || ls.LockPathMismatch == "" {
+		return
+	}
+
+	m.Ui.Warn(fmt.Sprintf(
+		"The state %s is also locked with the lock file %s, while\n"+
+			"this operation locked it with another one. The locks don't keep\n"+
+			"the operations from changing the state at the same time. Use the\n"+
+			"same -state-lock-file for every operation on this state.\n",
+		ls.Path, ls.LockPathMismatch))
+}
+
+// warnBackupFallback outputs a warning that the state backup was written
+// to the given fallback path, since writing it next to the state failed.
+func (m *Meta) warnBackupFallback(path string, err error) {
+	m.Ui.Warn(fmt.Sprintf(
+		"The state backup couldn't be written next to the state, so it was\n"+
+			"written to %s instead: %s\n\n"+
+			"Use -backup-dir to write backups to a directory of your choice.\n",
+		path, err))
+}
+
+// unlockState releases the lock acquired on the state by Context, if any.
+// This should be deferred by commands that lock the state.
+func (m *Meta) unlockState() {
+	if m.stateLock == nil {
+		return
+	}
+
+	if err := m.stateLock.Unlock(m.stateLockID); err != nil {
+		m.Ui.Error(fmt.Sprintf("Error unlocking state: %s", err))
+	}
+
+	m.stateLock = nil
+	m.stateLockID = ""
 }
 
 // DataDir returns the directory where local data will be stored.
 func (m *Meta) DataDir() string {
-	dataDir := DefaultDataDir
 	if m.dataDir != "" {
-		dataDir = m.dataDir
+		return m.dataDir
+	}
+
+	return m.workingPath(DefaultDataDir)
+}
+
+// stateDataDir returns the directory for the data of operations on the
+// state, such as the input cache and the apply progress. The default state
+// uses the data directory itself, and each other state file given with
+// -state gets its own directory within it, so that operations on
+// different states in one working directory don't overwrite each other's
+// files. Modules are shared by all of them.
+func (m *Meta) stateDataDir() string {
+	return m.stateDataDirFor(m.StateOpts().LocalPath)
+}
+
+// stateDataDirFor returns the stateDataDir of the state file at the given
+// local path.
+func (m *Meta) stateDataDirFor(localPath string) string {
+	path, err := filepath.Abs(localPath)
+	if err != nil {
+		return m.DataDir()
+	}
+	defaultPath, err := filepath.Abs(m.workingPath(DefaultStateFilename))
+	if err != nil || path == defaultPath {
+		return m.DataDir()
+	}
+
+	// The name is only there to make the directory recognizable; the hash
+	// tells apart state files with the same name in other directories.
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(
+		m.DataDir(), DefaultStatesDir, fmt.Sprintf("%s-%x", name, sum[:4]))
+}
+
+// workingPath returns the given relative path within the working directory
+// given with -chdir. Without -chdir, and for absolute paths, the path is
+// returned unchanged.
+func (m *Meta) workingPath(path string) string {
+	if m.workingDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(m.workingDir, path)
+}
+
+// pwd returns the directory of the configuration to use when none is
+// given: the working directory given with -chdir, or else the current
+// directory.
+func (m *Meta) pwd() (string, error) {
+	if m.workingDir != "" {
+		return m.workingDir, nil
 	}
 
-	return dataDir
+	return os.Getwd()
 }
 
 const (
@@ -228,18 +759,10 @@ const (
 // InputMode returns the type of input we should ask for in the form of
 // terraform.InputMode which is passed directly to Context.Input.
 func (m *Meta) InputMode() terraform.InputMode {
-	if test || !m.input {
+	if test || !m.inputEnabled() {
 		return 0
 	}
 
-	if envVar := os.Getenv(InputModeEnvVar); envVar != "" {
-		if v, err := strconv.ParseBool(envVar); err == nil {
-			if !v {
-				return 0
-			}
-		}
-	}
-
 	var mode terraform.InputMode
 	mode |= terraform.InputModeProvider
 	mode |= terraform.InputModeVar
@@ -280,23 +803,48 @@ func (m *Meta) StateRaw(opts *StateOpts) (*StateResult, error) {
 
 // StateOpts returns the default state options
 func (m *Meta) StateOpts() *StateOpts {
+	// Only the default state path is within the working directory given
+	// with -chdir. Other paths given with -state are kept relative to the
+	// current directory.
 	localPath := m.statePath
-	if localPath == "" {
-		localPath = DefaultStateFilename
+	if localPath == "" || localPath == DefaultStateFilename {
+		localPath = m.workingPath(DefaultStateFilename)
 	}
 	remotePath := filepath.Join(m.DataDir(), DefaultStateFilename)
 
 	return &StateOpts{
-		LocalPath:     localPath,
-		LocalPathOut:  m.stateOutPath,
-		RemotePath:    remotePath,
-		RemoteRefresh: true,
-		BackupPath:    m.backupPath,
+		LocalPath:          localPath,
+		LocalPathOut:       m.stateOutPath,
+		RemotePath:         remotePath,
+		RemoteRefresh:      true,
+		BackupPath:         m.backupPath,
+		BackupDir:          m.backupDir,
+		BackupFallbackDir:  filepath.Join(m.stateDataDirFor(localPath), DefaultBackupsDir),
+		OnBackupFallback:   m.warnBackupFallback,
+		DisableAutoUpgrade: m.DisableStateAutoUpgrade,
+		StaleLockTimeout:   m.staleLockTimeout(),
+		LockPath:           m.StateLockPath,
+		Store:              m.stateStore,
+
+		// Only a state file given explicitly can override the remote state
+		LocalOverride: m.stateOverride && m.statePath != "" &&
+			m.statePath != DefaultStateFilename,
 	}
 }
 
 // UIInput returns a UIInput object to be used for asking for input.
+// A UIInput set on ContextOpts takes precedence over asking on the CLI.
 func (m *Meta) UIInput() terraform.UIInput {
+	// An operation that disabled input also fails anything that asks for
+	// input outside of Context.Input, rather than prompting anyways.
+	if m.FailOnInput || (m.inputOverride != nil && !*m.inputOverride) {
+		return new(FailUIInput)
+	}
+
+	if m.ContextOpts != nil && m.ContextOpts.UIInput != nil {
+		return m.ContextOpts.UIInput
+	}
+
 	return &UIInput{
 		Colorize: m.Colorize(),
 	}
@@ -305,16 +853,111 @@ func (m *Meta) UIInput() terraform.UIInput {
 // PersistState is used to write out the state, handling backup of
 // the existing state file and respecting path configurations.
 func (m *Meta) PersistState(s *terraform.State) error {
-	if err := m.state.WriteState(s); err != nil {
+	err := m.state.WriteState(s)
+	if err == nil {
+		err = m.state.PersistState()
+	}
+	if err == errStateReadOnly {
+		// Nothing is lost, the state of record was never to be written
+		return err
+	}
+	if _, ok := err.(*state.WriteRefusedError); ok {
+		// The state file must be kept as it is, so saving the state to
+		// put in its place later would only invite overwriting it
 		return err
 	}
+	if err != nil {
+		return m.persistStateErrored(s, err)
+	}
+
+	if m.StatePersistHook != nil {
+		if err := m.StatePersistHook(m.state.State()); err != nil {
+			return errwrap.Wrapf("State persist hook failed: {{err}}", err)
+		}
+	}
 
-	return m.state.PersistState()
+	return nil
+}
+
+// persistStateErrored writes the state to the recovery path after it
+// couldn't be saved, and returns an error that explains how to put it back
+// where it belongs. The state is never dropped, even if it can't be
+// written there either: the in-memory state held by m.state is kept.
+func (m *Meta) persistStateErrored(s *terraform.State, err error) error {
+	path := m.OperationRecoveryPath
+	if path == "" {
+		path = m.workingPath(DefaultErroredStateFilename)
+	}
+
+	f, ferr := os.Create(path)
+	if ferr == nil {
+		ferr = terraform.WriteState(s, f)
+		if cerr := f.Close(); ferr == nil {
+			ferr = cerr
+		}
+	}
+	if ferr != nil {
+		return errwrap.Wrap(fmt.Errorf(
+			"%s\n\nThe state also couldn't be written to %s: %s",
+			err, path, ferr), err)
+	}
+
+	move := "mv"
+	if runtime.GOOS == "windows" {
+		move = "move"
+	}
+
+	statePath := m.StateOutPath()
+	if m.stateResult != nil {
+		statePath = m.stateResult.StatePath
+	}
+
+	var steps string
+	if m.stateResult != nil && m.stateResult.Remote != nil {
+		steps = fmt.Sprintf(
+			"    %s %s %s\n"+
+				"    terraform remote push",
+			move, path, statePath)
+	} else {
+		steps = fmt.Sprintf(
+			"    %s %s %s",
+			move, path, statePath)
+	}
+
+	return errwrap.Wrap(fmt.Errorf(
+		"%s\n\n"+
+			"The state was written to %s instead so that the changes made to\n"+
+			"your infrastructure aren't lost. If another program, such as a virus\n"+
+			"scanner or an editor, had the state file open, close it. Once the\n"+
+			"problem above is fixed, put the state back with:\n\n%s\n\n"+
+			"Until then, commands can use the state with -state=%s.",
+		err, path, steps, path), err)
 }
 
 // Input returns true if we should ask for input for context.
 func (m *Meta) Input() bool {
-	return !test && m.input && len(m.variables) == 0
+	return !test && m.inputEnabled() && len(m.variables) == 0
+}
+
+// inputEnabled returns whether asking for input is enabled by the -input
+// flag and the TF_INPUT environment variable, unless the options of the
+// operation given to Context override them. Input and InputMode both go
+// by this so that they agree about whether to ask for input.
+func (m *Meta) inputEnabled() bool {
+	if m.inputOverride != nil {
+		return *m.inputOverride
+	}
+	if !m.input {
+		return false
+	}
+
+	if envVar := os.Getenv(InputModeEnvVar); envVar != "" {
+		if v, err := strconv.ParseBool(envVar); err == nil && !v {
+			return false
+		}
+	}
+
+	return true
 }
 
 // StdinPiped returns true if the input is piped.
@@ -347,21 +990,95 @@ func (m *Meta) contextOpts() *terraform.ContextOpts {
 	for k, v := range m.variables {
 		vs[k] = v
 	}
+	if len(m.varFiles) > 0 {
+		log.Printf("[INFO] Loaded variables from -var-file: %s",
+			strings.Join(m.varFiles, ", "))
+	}
 	opts.Variables = vs
-	opts.Targets = m.targets
+	opts.Targets = normalizeTargets(m.targets)
 	opts.UIInput = m.UIInput()
 	opts.Shadow = m.shadow
 
+	// Turn panics during an operation, such as from a hook or provider,
+	// into errors so that the state is still persisted and the state
+	// lock released.
+	opts.RecoverPanics = true
+
 	return &opts
 }
 
+// normalizeTargets returns the given resource targets in canonical form,
+// sorted and without duplicates, so that the same targets given in any
+// order or more than once have the same effect and show up the same in
+// logs and plans. Targets that can't be parsed are kept as given for the
+// context to report.
+func normalizeTargets(targets []string) []string {
+	if len(targets) == 0 {
+		return targets
+	}
+
+	seen := make(map[string]struct{}, len(targets))
+	result := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if addr, err := terraform.ParseResourceAddress(t); err == nil {
+			t = addr.String()
+		}
+
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		result = append(result, t)
+	}
+	sort.Strings(result)
+
+	if !reflect.DeepEqual(result, targets) {
+		log.Printf("[INFO] Normalized targets %q to %q", targets, result)
+	}
+
+	return result
+}
+
+// contextBuilder builds the options for the context of an operation by
+// merging the options given for the operation to Context into the context
+// options from the Meta.
+type contextBuilder struct {
+	Opts *terraform.ContextOpts
+}
+
+// Merge merges the options of an operation into the context options. The
+// fields of contextOpts that aren't merged are only used by Context to load
+// the configuration, state and plan. Adding a field to contextOpts without
+// deciding which it is fails TestContextBuilder_fields.
+//
+// When a plan file is being applied, the plan already records what it
+// does, so only the options for how it is applied are merged.
+func (b *contextBuilder) Merge(copts contextOpts, plan bool) {
+	b.Opts.Parallelism = copts.Parallelism
+
+	if !plan {
+		// Tell the context if we're in a destroy plan / apply
+		b.Opts.Destroy = copts.Destroy
+		b.Opts.PlanMode = copts.PlanMode
+
+		// Plans aren't refreshed, so this only matters without one
+		b.Opts.RefreshSkip = copts.RefreshSkip
+
+		// A plan records the resources to replace in its diff
+		b.Opts.ForceReplace = copts.ForceReplace
+		b.Opts.OverridePreventDestroy = copts.OverridePreventDestroy
+	}
+}
+
 // flags adds the meta flags to the given FlagSet.
 func (m *Meta) flagSet(n string) *flag.FlagSet {
 	f := flag.NewFlagSet(n, flag.ContinueOnError)
 	f.BoolVar(&m.input, "input", true, "input")
+	f.BoolVar(&m.inputCache, "input-cache", true, "input cache")
 	f.Var((*variables.Flag)(&m.variables), "var", "variables")
-	f.Var((*variables.FlagFile)(&m.variables), "var-file", "variable file")
+	f.Var(&FlagVarFile{Variables: &m.variables, Files: &m.varFiles}, "var-file", "variable file")
 	f.Var((*FlagStringSlice)(&m.targets), "target", "resource to target")
+	f.Var((*FlagTargetFile)(&m.targets), "target-file", "file of resources to target")
 
 	if m.autoKey != "" {
 		f.Var((*variables.FlagFile)(&m.autoVariables), m.autoKey, "variable file")
@@ -426,41 +1143,148 @@ func (m *Meta) process(args []string, vars bool) []string {
 		}
 	}
 
+	// Set the working directory. This has to be known before the default
+	// variable files are found below.
+	for i, v := range args {
+		if strings.HasPrefix(v, "-chdir=") {
+			m.workingDir = v[len("-chdir="):]
+			args = append(args[:i], args[i+1:]...)
+			break
+		}
+	}
+
 	// Set the UI
+	if m.redactor == nil {
+		m.redactor = new(Redactor)
+	}
 	m.oldUi = m.Ui
 	m.Ui = &cli.ConcurrentUi{
-		Ui: &ColorizeUi{
-			Colorize:   m.Colorize(),
-			ErrorColor: "[red]",
-			WarnColor:  "[yellow]",
-			Ui:         m.oldUi,
+		Ui: &RedactUi{
+			Redactor: m.redactor,
+			Ui: &ColorizeUi{
+				Colorize:   m.Colorize(),
+				ErrorColor: "[red]",
+				WarnColor:  "[yellow]",
+				Ui:         m.oldUi,
+			},
 		},
 	}
 
-	// If we support vars and the default var file exists, add it to
-	// the args...
+	// If we support vars and any of the default var files exist, add
+	// them to the front of the args so that explicit -var-file and -var
+	// flags take precedence over them.
 	m.autoKey = ""
 	if vars {
-		if _, err := os.Stat(DefaultVarsFilename); err == nil {
+		if paths := defaultVarFiles(m.workingPath(".")); len(paths) > 0 {
 			m.autoKey = "var-file-default"
-			args = append(args, "", "")
-			copy(args[2:], args[0:])
-			args[0] = "-" + m.autoKey
-			args[1] = DefaultVarsFilename
-		}
+			autoArgs := make([]string, 0, len(paths)*2+len(args))
+			for _, path := range paths {
+				autoArgs = append(autoArgs, "-"+m.autoKey, path)
+			}
 
-		if _, err := os.Stat(DefaultVarsFilename + ".json"); err == nil {
-			m.autoKey = "var-file-default"
-			args = append(args, "", "")
-			copy(args[2:], args[0:])
-			args[0] = "-" + m.autoKey
-			args[1] = DefaultVarsFilename + ".json"
+			args = append(autoArgs, args...)
 		}
 	}
 
 	return args
 }
 
+// defaultVarFiles returns the variable files within dir that are loaded
+// automatically, in the order they should be merged. Values in later files
+// override values in earlier ones, so the precedence from lowest to highest
+// is: terraform.tfvars, terraform.tfvars.json, and then any *.auto.tfvars
+// or *.auto.tfvars.json files in lexical order.
+func defaultVarFiles(dir string) []string {
+	var result []string
+	for _, name := range []string{DefaultVarsFilename, DefaultVarsFilename + ".json"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			result = append(result, path)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return result
+	}
+
+	// ReadDir returns the entries sorted by filename already
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			continue
+		}
+		if !strings.HasSuffix(name, DefaultAutoVarsSuffix) &&
+			!strings.HasSuffix(name, DefaultAutoVarsSuffix+".json") {
+			continue
+		}
+
+		result = append(result, filepath.Join(dir, name))
+	}
+
+	return result
+}
+
+// captureLogs starts capturing the log output to LogWriter for the
+// operation with the given name, if LogWriter is set. The returned function
+// stops the capture and must be called once the operation is done.
+func (m *Meta) captureLogs(op string) func() {
+	if m.LogWriter == nil {
+		return func() {}
+	}
+
+	id := fmt.Sprintf("%s-%d", op, atomic.AddUint64(&logCaptureId, 1))
+	w := m.redactor.Writer(logging.NewLevelFilter(m.LogWriter))
+	stop := logging.Capture(w, id)
+	log.Printf("[INFO] Starting %s operation with ID %s", op, m.operationID())
+	if source := m.operationSource(); source != "" {
+		log.Printf("[INFO] Operation %s source: %s", m.operationID(), source)
+	}
+	keys := make([]string, 0, len(m.OperationAnnotations))
+	for k := range m.OperationAnnotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		log.Printf("[INFO] Operation %s annotation: %s=%s",
+			m.operationID(), k, m.OperationAnnotations[k])
+	}
+	return stop
+}
+
+// operationID returns the ID of the operation run by the command,
+// generating one the first time if one wasn't given.
+func (m *Meta) operationID() string {
+	if m.OperationID == "" {
+		m.OperationID = os.Getenv(OperationIDEnvVar)
+	}
+	if m.OperationID == "" {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			// This only happens if the random source fails, and the
+			// ID is only used for correlation anyways.
+			log.Printf("[WARN] Error generating operation ID: %s", err)
+			return ""
+		}
+		m.OperationID = id
+	}
+
+	return m.OperationID
+}
+
+// operationSource returns who or what started the operation run by the
+// command, or "" if that isn't known.
+func (m *Meta) operationSource() string {
+	if m.OperationSource == "" {
+		m.OperationSource = os.Getenv(OperationSourceEnvVar)
+	}
+
+	return m.OperationSource
+}
+
+// logCaptureId is used to give every log capture a unique ID.
+var logCaptureId uint64
+
 // uiHook returns the UiHook to use with the context.
 func (m *Meta) uiHook() *UiHook {
 	return &UiHook{
@@ -479,6 +1303,57 @@ const (
 	ModuleDepthEnvVar = "TF_MODULE_DEPTH"
 )
 
+// OperationIDEnvVar is the name of the environment variable that can be
+// used to give the ID of the operation, such as by an orchestrator that
+// wants to find the operation's state lock.
+const OperationIDEnvVar = "TF_OPERATION_ID"
+
+// OperationSourceEnvVar is the name of the environment variable that can
+// be used to describe who or what started the operation, such as by an
+// orchestrator that wants to record the job that ran it.
+const OperationSourceEnvVar = "TF_OPERATION_SOURCE"
+
+// LockTakeoverEnvVar is the name of the environment variable that can be
+// used to set the StaleLockTimeout, as a duration such as "1h".
+const LockTakeoverEnvVar = "TF_LOCK_TAKEOVER"
+
+// addCompactWarningsFlag adds the -compact-warnings flag, which is on by
+// default, for the commands that output warnings.
+func (m *Meta) addCompactWarningsFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&m.compactWarnings, "compact-warnings", true, "compact-warnings")
+}
+
+// addLockTimeoutFlag adds the -lock-timeout flag, used as the LockTimeout
+// of contextOpts, to the given flag set, along with the -lock-takeover
+// flag for StaleLockTimeout and the -state-lock-file flag for
+// StateLockPath.
+func (m *Meta) addLockTimeoutFlag(flags *flag.FlagSet, lockTimeout *time.Duration) {
+	flags.DurationVar(lockTimeout, "lock-timeout", 0, "lock-timeout")
+	flags.DurationVar(&m.StaleLockTimeout, "lock-takeover", m.StaleLockTimeout, "lock-takeover")
+	flags.StringVar(&m.StateLockPath, "state-lock-file", m.StateLockPath, "path")
+}
+
+// staleLockTimeout returns the StaleLockTimeout, reading it from the
+// environment if it isn't set.
+func (m *Meta) staleLockTimeout() time.Duration {
+	if m.StaleLockTimeout != 0 {
+		return m.StaleLockTimeout
+	}
+
+	v := os.Getenv(LockTakeoverEnvVar)
+	if v == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("[WARN] Invalid value for %s, not taking over locks: %s",
+			LockTakeoverEnvVar, err)
+		return 0
+	}
+
+	return timeout
+}
+
 func (m *Meta) addModuleDepthFlag(flags *flag.FlagSet, moduleDepth *int) {
 	flags.IntVar(moduleDepth, "module-depth", ModuleDepthDefault, "module-depth")
 	if envVar := os.Getenv(ModuleDepthEnvVar); envVar != "" {
@@ -488,6 +1363,159 @@ func (m *Meta) addModuleDepthFlag(flags *flag.FlagSet, moduleDepth *int) {
 	}
 }
 
+// ValidateContext validates the context, returning an *ErrValidation if
+// there are any errors. Warnings alone aren't an error: they are recorded
+// so that showWarnings can output them once the operation has completed.
+func (m *Meta) ValidateContext(ctx *terraform.Context) error {
+	// Variables may have been given as input since the context was loaded
+	redactVariables(m.redactor, ctx)
+
+	log.Println("[INFO] Validating the context...")
+	ws, es := ctx.Validate()
+	log.Printf("[INFO] Validation result: %d warnings, %d errors", len(ws), len(es))
+
+	if len(es) == 0 {
+		m.warnings = append(m.warnings, ws...)
+		return nil
+	}
+
+	return &ErrValidation{Errors: es, Warnings: ws}
+}
+
+// validateContext validates the context with ValidateContext. Any errors
+// are output right away, along with the warnings, and the *ErrValidation
+// is returned.
+func (m *Meta) validateContext(ctx *terraform.Context) error {
+	err := m.ValidateContext(ctx)
+	if err == nil {
+		return nil
+	}
+
+	verr := err.(*ErrValidation)
+	m.Ui.Output(
+		"There are warnings and/or errors related to your configuration. Please\n" +
+			"fix these before continuing.\n")
+
+	if len(verr.Warnings) > 0 {
+		m.Ui.Warn("Warnings:\n")
+		for _, w := range verr.Warnings {
+			m.Ui.Warn(fmt.Sprintf("  * %s", w))
+		}
+
+		m.Ui.Output("")
+	}
+
+	m.Ui.Error("Errors:\n")
+	for _, e := range verr.Errors {
+		m.Ui.Error(fmt.Sprintf("  * %s", e))
+	}
+
+	return err
+}
+
+// checkPlanVersion compares the version of Terraform that created the plan
+// with the running version. Plans created before the version was recorded
+// are treated as a mismatch since we can't know what created them.
+func (m *Meta) checkPlanVersion(plan *terraform.Plan, copts contextOpts) error {
+	current := terraform.VersionString()
+	if plan.TerraformVersion == current {
+		return nil
+	}
+
+	recorded := plan.TerraformVersion
+	if recorded == "" {
+		recorded = "an unknown version"
+	} else {
+		recorded = "v" + recorded
+	}
+
+	msg := fmt.Sprintf(
+		"The plan file %q was created by Terraform %s, but this is\n"+
+			"Terraform v%s. Applying a plan with a different version may not\n"+
+			"make the changes that were shown when it was created.",
+		copts.Path, recorded, current)
+	if copts.VersionMismatch == "error" {
+		return fmt.Errorf("%s Please run \"terraform plan\" again.", msg)
+	}
+
+	m.warnings = append(m.warnings, msg)
+	return nil
+}
+
+// StateDescription returns the description of the state read when calling
+// Context, or an empty string if no state was read yet.
+func (m *Meta) StateDescription() string {
+	if m.stateResult == nil {
+		return ""
+	}
+
+	return m.stateResult.Description()
+}
+
+// outputOperationHeader outputs who or what started the operation, if
+// that is known, and which state the operation uses, unless -quiet was
+// given. With -state, -chdir and remote state all able to change the
+// state, it isn't always obvious.
+func (m *Meta) outputOperationHeader() {
+	if m.quiet {
+		return
+	}
+
+	var lines []string
+	if source := m.operationSource(); source != "" {
+		lines = append(lines, fmt.Sprintf("[reset][bold]Source:[reset] %s", source))
+	}
+	if desc := m.StateDescription(); desc != "" {
+		lines = append(lines, fmt.Sprintf("[reset][bold]Using state:[reset] %s", desc))
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	m.Ui.Output(m.Colorize().Color(strings.Join(lines, "\n") + "\n"))
+}
+
+// Warnings returns all the warnings collected during the operation run by
+// the command. The list is in full even if similar warnings were output
+// as one with -compact-warnings.
+func (m *Meta) Warnings() []string {
+	return m.warnings
+}
+
+// showWarnings outputs the warnings collected during the operation, if
+// any, along with a count. They are only ever shown once.
+func (m *Meta) showWarnings() {
+	if len(m.warnings) == 0 || m.warningsShown {
+		return
+	}
+	m.warningsShown = true
+
+	warnings := m.warnings
+	if m.compactWarnings {
+		warnings = compactWarnings(warnings)
+	}
+
+	m.Ui.Warn(fmt.Sprintf("\nWarnings (%d):\n", len(m.warnings)))
+	for _, w := range warnings {
+		m.Ui.Warn(fmt.Sprintf("  * %s", w))
+	}
+}
+
+// warningsExitCode returns the exit code for an operation that completed
+// with the given code. If -warnings-as-errors was given and there are
+// warnings, they are output followed by an error, and the operation fails.
+func (m *Meta) warningsExitCode(code int) int {
+	if !m.warningsAsErrors || len(m.warnings) == 0 {
+		return code
+	}
+
+	n := len(m.warnings)
+	m.showWarnings()
+	m.Ui.Error(fmt.Sprintf(
+		"\nError: %d warning(s) treated as errors because -warnings-as-errors was set.", n))
+	return 1
+}
+
 // outputShadowError outputs the error from ctx.ShadowError. If the
 // error is nil then nothing happens. If output is false then it isn't
 // outputted to the user (you can define logic to guard against outputting).
@@ -531,6 +1559,33 @@ func (m *Meta) outputShadowError(err error, output bool) bool {
 	return true
 }
 
+const errConfigDirNotFound = `
+The configuration directory %s doesn't exist.
+
+Check the directory given as an argument for typos. If no directory is
+given, the configuration in the current directory is used.
+`
+
+const errNoConfigsFound = `
+No configuration files found in %s
+
+The directory must contain Terraform configuration files ending in ".tf"
+or ".tf.json". Check the directory given as an argument for typos. If no
+directory is given, the configuration in the current directory is used.
+
+To run against an empty configuration anyways, such as to destroy
+everything in the state, pass "-allow-empty" to plan, apply or destroy.
+`
+
+const errModulesNotFound = `
+Error loading modules: the following modules haven't been downloaded yet:
+
+%s
+
+Run "terraform get" to download the modules used by this configuration,
+or pass "-get=true" to download missing modules automatically.
+`
+
 // contextOpts are the options used to load a context from a command.
 type contextOpts struct {
 	// Path to the directory where the root module is.
@@ -552,6 +1607,67 @@ type contextOpts struct {
 	// Set to true when running a destroy plan/apply.
 	Destroy bool
 
+	// PlanMode is the kind of plan to create, such as a refresh-only plan.
+	PlanMode terraform.PlanMode
+
 	// Number of concurrent operations allowed
 	Parallelism int
+
+	// RefreshSkip are the addresses of the resources that aren't refreshed
+	// before planning. They are still planned with their current state.
+	RefreshSkip []string
+
+	// ForceReplace are the addresses of the resources to plan to destroy
+	// and create again, even if their configuration doesn't require it.
+	ForceReplace []string
+
+	// OverridePreventDestroy allows a destroy to destroy resources that
+	// have lifecycle.prevent_destroy set.
+	OverridePreventDestroy bool
+
+	// SkipProviderConfig, if true, defers configuring the providers until
+	// they're needed if the plan only destroys, because it's a destroy
+	// plan or there are no resources in the configuration. Set it when
+	// the plan isn't refreshed, so that removing everything doesn't need
+	// valid provider credentials.
+	SkipProviderConfig bool
+
+	// PlanId, if set, is the expected ID of the plan file at Path. If
+	// Path is a plan file with a different ID, loading the context fails.
+	PlanId string
+
+	// VersionMismatch is what to do when the plan file at Path was created
+	// by a different version of Terraform: "error" fails loading the
+	// context, anything else records a warning.
+	VersionMismatch string
+
+	// Lock, if true, locks the state for the operation with the name in
+	// Operation. If the state is already locked, acquiring the lock is
+	// retried until LockTimeout has passed.
+	Lock        bool
+	LockTimeout time.Duration
+	Operation   string
+
+	// Progress, if set, is the progress of a previous apply of the plan
+	// file at Path that partially failed. The resources that were already
+	// applied are skipped.
+	Progress *ApplyProgress
+
+	// WritesState, if true, means the operation writes the state when it's
+	// done, such as apply or refresh. Loading the context then fails if a
+	// local state file couldn't be written, before anything is changed.
+	WritesState bool
+
+	// StateOverride, if true, lets a state file given with -state be used
+	// even though remote state is configured, in place of the remote
+	// state. The state is then read-only and isn't locked, and it must
+	// have the same lineage as the remote state unless StateOverrideForce
+	// is set.
+	StateOverride      bool
+	StateOverrideForce bool
+
+	// Input, if set, says whether to ask for input during the operation,
+	// overriding the -input flag and TF_INPUT. It is kept on Meta, so
+	// InputMode and Input agree with it once the context is created.
+	Input *bool
 }
@@ -1,14 +1,18 @@
 package command
 
 import (
+	"errors"
 	"flag"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
 )
 
 func TestMetaColorize(t *testing.T) {
@@ -163,6 +167,58 @@ func TestMetaInputMode_defaultVars(t *testing.T) {
 	}
 }
 
+func TestMetaProcess_defaultVarFiles(t *testing.T) {
+	// Create a temporary directory for our cwd
+	d := tempDir(t)
+	if err := os.MkdirAll(d, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(d); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	files := map[string]string{
+		DefaultVarsFilename:           "a = \"tfvars\"\nb = \"tfvars\"\nc = \"tfvars\"\nd = \"tfvars\"\ne = \"tfvars\"\n",
+		DefaultVarsFilename + ".json": `{"b": "json", "c": "json", "d": "json", "e": "json"}`,
+		"a.auto.tfvars":               "c = \"a-auto\"\nd = \"a-auto\"\ne = \"a-auto\"\n",
+		"b.auto.tfvars.json":          `{"d": "b-auto", "e": "b-auto"}`,
+		"explicit.tfvars":             "e = \"explicit\"\nf = \"explicit\"\n",
+		"ignored.tfvars":              "a = \"ignored\"\n",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	m := &Meta{ContextOpts: new(terraform.ContextOpts)}
+	args := []string{"-var-file", "explicit.tfvars", "-var", "f=var"}
+	args = m.process(args, true)
+
+	fs := m.flagSet("foo")
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := m.contextOpts().Variables
+	expected := map[string]interface{}{
+		"a": "tfvars",
+		"b": "json",
+		"c": "a-auto",
+		"d": "b-auto",
+		"e": "explicit",
+		"f": "var",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestMetaInputMode_vars(t *testing.T) {
 	test = false
 	defer func() { test = true }()
@@ -184,6 +240,292 @@ func TestMetaInputMode_vars(t *testing.T) {
 	}
 }
 
+func TestMetaUIInput(t *testing.T) {
+	m := &Meta{ContextOpts: new(terraform.ContextOpts)}
+	if _, ok := m.UIInput().(*UIInput); !ok {
+		t.Fatalf("bad: %#v", m.UIInput())
+	}
+	if _, ok := m.contextOpts().UIInput.(*UIInput); !ok {
+		t.Fatalf("bad: %#v", m.contextOpts().UIInput)
+	}
+}
+
+func TestMetaUIInput_contextOpts(t *testing.T) {
+	input := new(terraform.MockUIInput)
+	m := &Meta{ContextOpts: &terraform.ContextOpts{UIInput: input}}
+	if m.UIInput() != input {
+		t.Fatalf("bad: %#v", m.UIInput())
+	}
+	if m.contextOpts().UIInput != input {
+		t.Fatalf("bad: %#v", m.contextOpts().UIInput)
+	}
+}
+
+func TestMetaUIInput_failOnInput(t *testing.T) {
+	m := &Meta{
+		ContextOpts: &terraform.ContextOpts{UIInput: new(terraform.MockUIInput)},
+		FailOnInput: true,
+	}
+	if _, ok := m.UIInput().(*FailUIInput); !ok {
+		t.Fatalf("bad: %#v", m.UIInput())
+	}
+}
+
+func TestMetaContext_input(t *testing.T) {
+	test = false
+	defer func() { test = true }()
+
+	cases := []struct {
+		Flag     string
+		Override bool
+	}{
+		{"-input=true", false},
+		{"-input=false", true},
+	}
+
+	for _, tc := range cases {
+		m := &Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          new(cli.MockUi),
+		}
+		if err := m.flagSet("test").Parse([]string{tc.Flag}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		input := tc.Override
+		_, _, err := m.Context(contextOpts{
+			Path:  testFixturePath("plan"),
+			Input: &input,
+		})
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// The operation decides over the flag
+		if (m.InputMode() != 0) != tc.Override || m.Input() != tc.Override {
+			t.Fatalf("%s: bad: %d %t", tc.Flag, m.InputMode(), m.Input())
+		}
+		if _, ok := m.UIInput().(*FailUIInput); ok == tc.Override {
+			t.Fatalf("%s: bad: %#v", tc.Flag, m.UIInput())
+		}
+	}
+}
+
+func TestMetaContext_stateOverride(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), cache)
+
+	statePath := testStateFile(t, s)
+
+	// Without the override, having both is an error
+	m := &Meta{
+		ContextOpts: testCtxConfig(testProvider()),
+		Ui:          new(cli.MockUi),
+	}
+	_, _, err := m.Context(contextOpts{
+		Path:      testFixturePath("plan"),
+		StatePath: statePath,
+	})
+	if err == nil || !strings.Contains(err.Error(), "also present") {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	m = &Meta{
+		ContextOpts: testCtxConfig(testProvider()),
+		Ui:          new(cli.MockUi),
+	}
+	_, _, err = m.Context(contextOpts{
+		Path:          testFixturePath("plan"),
+		StatePath:     statePath,
+		StateOverride: true,
+		Lock:          true,
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer m.unlockState()
+
+	if !m.stateResult.Override || m.stateResult.StatePath != statePath {
+		t.Fatalf("bad: %#v", m.stateResult)
+	}
+
+	// The state can't be written or locked
+	if err := m.PersistState(s); err != errStateReadOnly {
+		t.Fatalf("bad: %#v", err)
+	}
+	if _, ok := m.state.(state.Locker); ok {
+		t.Fatalf("bad: %#v", m.state)
+	}
+	if m.stateLock != nil {
+		t.Fatalf("bad: %#v", m.stateLock)
+	}
+}
+
+func TestMeta_stateDataDir(t *testing.T) {
+	prod := (&Meta{statePath: "prod.tfstate"}).stateDataDir()
+	if filepath.Dir(prod) != filepath.Join(DefaultDataDir, DefaultStatesDir) ||
+		!strings.HasPrefix(filepath.Base(prod), "prod-") {
+		t.Fatalf("bad: %s", prod)
+	}
+
+	cases := []struct {
+		StatePath string
+		Expected  string
+	}{
+		// The default state uses the data directory itself
+		{"", DefaultDataDir},
+		{DefaultStateFilename, DefaultDataDir},
+
+		// Each other state file has its own directory
+		{"prod.tfstate", prod},
+		{filepath.Join("other", "prod.tfstate"), ""},
+	}
+
+	for _, tc := range cases {
+		actual := (&Meta{statePath: tc.StatePath}).stateDataDir()
+		if tc.Expected == "" {
+			if actual == prod || actual == DefaultDataDir {
+				t.Fatalf("%s: bad: %s", tc.StatePath, actual)
+			}
+			continue
+		}
+		if actual != tc.Expected {
+			t.Fatalf("%s: bad: %s", tc.StatePath, actual)
+		}
+	}
+}
+
+func TestContextBuilder_fields(t *testing.T) {
+	// Every field of contextOpts is either merged into the context options
+	// by contextBuilder.Merge, or used by Meta.Context to load the
+	// configuration, state and plan. When adding a field, handle it in
+	// Merge or Context and add it here.
+	merged := map[string]bool{
+		"Destroy":                true,
+		"ForceReplace":           true,
+		"OverridePreventDestroy": true,
+		"PlanMode":               true,
+		"Parallelism":            true,
+		"RefreshSkip":            true,
+	}
+	loaded := map[string]bool{
+		"Path":               true,
+		"PathEmptyOk":        true,
+		"StatePath":          true,
+		"GetMode":            true,
+		"VersionMismatch":    true,
+		"PlanId":             true,
+		"Lock":               true,
+		"LockTimeout":        true,
+		"Operation":          true,
+		"Progress":           true,
+		"WritesState":        true,
+		"Input":              true,
+		"StateOverride":      true,
+		"StateOverrideForce": true,
+		"SkipProviderConfig": true,
+	}
+
+	typ := reflect.TypeOf(contextOpts{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		if !merged[name] && !loaded[name] {
+			t.Errorf("contextOpts.%s isn't handled by contextBuilder", name)
+		}
+	}
+}
+
+func TestContextBuilderMerge(t *testing.T) {
+	cases := []struct {
+		Name  string
+		Opts  contextOpts
+		Plan  bool
+		Check func(*terraform.ContextOpts) bool
+	}{
+		{
+			"Destroy",
+			contextOpts{Destroy: true},
+			false,
+			func(o *terraform.ContextOpts) bool { return o.Destroy },
+		},
+		{
+			"Destroy with a plan",
+			contextOpts{Destroy: true},
+			true,
+			func(o *terraform.ContextOpts) bool { return !o.Destroy },
+		},
+		{
+			"Parallelism",
+			contextOpts{Parallelism: 3},
+			false,
+			func(o *terraform.ContextOpts) bool { return o.Parallelism == 3 },
+		},
+		{
+			"Parallelism with a plan",
+			contextOpts{Parallelism: 3},
+			true,
+			func(o *terraform.ContextOpts) bool { return o.Parallelism == 3 },
+		},
+		{
+			"RefreshSkip",
+			contextOpts{RefreshSkip: []string{"test_instance.foo"}},
+			false,
+			func(o *terraform.ContextOpts) bool { return len(o.RefreshSkip) == 1 },
+		},
+		{
+			"RefreshSkip with a plan",
+			contextOpts{RefreshSkip: []string{"test_instance.foo"}},
+			true,
+			func(o *terraform.ContextOpts) bool { return len(o.RefreshSkip) == 0 },
+		},
+		{
+			"ForceReplace",
+			contextOpts{ForceReplace: []string{"test_instance.foo"}},
+			false,
+			func(o *terraform.ContextOpts) bool { return len(o.ForceReplace) == 1 },
+		},
+		{
+			"ForceReplace with a plan",
+			contextOpts{ForceReplace: []string{"test_instance.foo"}},
+			true,
+			func(o *terraform.ContextOpts) bool { return len(o.ForceReplace) == 0 },
+		},
+	}
+
+	for _, tc := range cases {
+		b := &contextBuilder{Opts: new(terraform.ContextOpts)}
+		b.Merge(tc.Opts, tc.Plan)
+		if !tc.Check(b.Opts) {
+			t.Fatalf("%s: bad: %#v", tc.Name, b.Opts)
+		}
+	}
+}
+
+func TestContextBuilderMerge_keep(t *testing.T) {
+	input := new(terraform.MockUIInput)
+	b := &contextBuilder{Opts: &terraform.ContextOpts{
+		Shadow:  true,
+		Targets: []string{"foo"},
+		UIInput: input,
+	}}
+	b.Merge(contextOpts{Parallelism: 3}, false)
+
+	if !b.Opts.Shadow || !reflect.DeepEqual(b.Opts.Targets, []string{"foo"}) {
+		t.Fatalf("bad: %#v", b.Opts)
+	}
+	if b.Opts.UIInput != input {
+		t.Fatalf("bad: %#v", b.Opts.UIInput)
+	}
+}
+
 func TestMeta_initStatePaths(t *testing.T) {
 	m := new(Meta)
 	m.initStatePaths()
@@ -272,3 +614,146 @@ func TestMeta_addModuleDepthFlag(t *testing.T) {
 		}
 	}
 }
+
+func TestMetaOperationID(t *testing.T) {
+	old := os.Getenv(OperationIDEnvVar)
+	defer os.Setenv(OperationIDEnvVar, old)
+	os.Setenv(OperationIDEnvVar, "")
+
+	// A new ID is generated once and then kept
+	m := new(Meta)
+	id := m.operationID()
+	if id == "" {
+		t.Fatal("should generate an ID")
+	}
+	if other := m.operationID(); other != id {
+		t.Fatalf("bad: %s != %s", other, id)
+	}
+	if other := new(Meta).operationID(); other == id {
+		t.Fatalf("IDs should be unique: %s", other)
+	}
+
+	// The ID can be given in the environment
+	os.Setenv(OperationIDEnvVar, "from-env")
+	if id := new(Meta).operationID(); id != "from-env" {
+		t.Fatalf("bad: %s", id)
+	}
+
+	// The ID set on the Meta wins
+	m = &Meta{OperationID: "foo"}
+	if id := m.operationID(); id != "foo" {
+		t.Fatalf("bad: %s", id)
+	}
+}
+
+// testFailPersistState is a state.State that fails to persist.
+type testFailPersistState struct {
+	state.InmemState
+}
+
+func (s *testFailPersistState) PersistState() error {
+	return errors.New("the file is in use")
+}
+
+func TestMetaPersistState_errored(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	m := &Meta{
+		state: new(testFailPersistState),
+		stateResult: &StateResult{
+			StatePath: "terraform.tfstate",
+		},
+	}
+
+	err := m.PersistState(testState())
+	if err == nil {
+		t.Fatal("should error")
+	}
+	for _, s := range []string{"the file is in use", "errored.tfstate terraform.tfstate"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+	if strings.Contains(err.Error(), "remote push") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	actual := testStateRead(t, filepath.Join(tmp, DefaultErroredStateFilename))
+	if actual.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Remote state is pushed after it is put back in the cache
+	m.stateResult.Remote = &state.CacheState{}
+	m.stateResult.StatePath = filepath.Join(".terraform", "terraform.tfstate")
+	err = m.PersistState(testState())
+	if err == nil || !strings.Contains(err.Error(), "terraform remote push") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestNormalizeTargets(t *testing.T) {
+	cases := []struct {
+		Input  []string
+		Output []string
+	}{
+		{nil, nil},
+		{
+			[]string{"test_instance.b", "test_instance.a", "test_instance.b"},
+			[]string{"test_instance.a", "test_instance.b"},
+		},
+		{
+			// Addresses are canonicalized before removing duplicates
+			[]string{"module.foo.test_instance.a[0]", "module.foo.test_instance.a[0]", "module.foo"},
+			[]string{"module.foo", "module.foo.test_instance.a[0]"},
+		},
+		{
+			// Invalid addresses are kept for the context to report
+			[]string{"test_instance.a", "not a target!"},
+			[]string{"not a target!", "test_instance.a"},
+		},
+	}
+
+	for _, tc := range cases {
+		actual := normalizeTargets(tc.Input)
+		if !reflect.DeepEqual(actual, tc.Output) {
+			t.Fatalf("%q: bad: %q", tc.Input, actual)
+		}
+	}
+}
+
+func TestMetaProcess_chdir(t *testing.T) {
+	m := new(Meta)
+	args := m.process([]string{"-chdir=work", "-state", "foo.tfstate", "bar"}, false)
+	if !reflect.DeepEqual(args, []string{"-state", "foo.tfstate", "bar"}) {
+		t.Fatalf("bad: %#v", args)
+	}
+
+	if actual := m.DataDir(); actual != filepath.Join("work", DefaultDataDir) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual, _ := m.pwd(); actual != "work" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// The default state path is in the working directory
+	if actual := m.StateOpts().LocalPath; actual != filepath.Join("work", DefaultStateFilename) {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Other state paths are relative to the current directory
+	m.statePath = "foo.tfstate"
+	if actual := m.StateOpts().LocalPath; actual != "foo.tfstate" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// Absolute paths are kept
+	abs, err := filepath.Abs("foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := m.workingPath(abs); actual != abs {
+		t.Fatalf("bad: %s", actual)
+	}
+}
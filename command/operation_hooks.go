@@ -0,0 +1,87 @@
+package command
+
+import (
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// OperationHooks are callbacks for programs running commands, called at
+// the boundaries of the operation that a command such as plan, apply or
+// refresh runs. Unlike a terraform.Hook, which is called for each
+// resource, they're called once per operation. Any of them may be nil.
+type OperationHooks struct {
+	// PreOperation is called with the name of the operation, such as
+	// "plan", before the state is loaded. If it returns an error, the
+	// operation isn't run.
+	PreOperation func(operation string) error
+
+	// PostPlan is called with the plan once it is created, before it is
+	// written to a plan file or applied. If it returns an error, the
+	// operation is aborted before anything is saved.
+	PostPlan func(*terraform.Plan) error
+
+	// PostOperation is called with the name of the operation and the exit
+	// status of the command once it is done, after the state is persisted
+	// and unlocked. It is called whether the operation succeeded or not,
+	// but only if PreOperation was called.
+	PostOperation func(operation string, exitCode int)
+}
+
+// preOperation records that the given operation is starting and calls the
+// PreOperation hook. Nothing is done for an empty operation, which is
+// loading a context for something other than running an operation.
+func (m *Meta) preOperation(operation string) error {
+	if operation == "" {
+		return nil
+	}
+	m.operation = operation
+	m.operationStart = time.Now()
+	m.operationMetrics().IncOperation(operation, OperationStarted)
+
+	if m.OperationHooks.PreOperation == nil {
+		return nil
+	}
+	if err := m.OperationHooks.PreOperation(operation); err != nil {
+		return errwrap.Wrapf("Pre-operation hook failed: {{err}}", err)
+	}
+
+	return nil
+}
+
+// postPlan calls the PostPlan hook with the plan that was created.
+func (m *Meta) postPlan(plan *terraform.Plan) error {
+	m.operationMetrics().ObservePlanSize(planSize(plan))
+
+	if m.OperationHooks.PostPlan == nil {
+		return nil
+	}
+	if err := m.OperationHooks.PostPlan(plan); err != nil {
+		return errwrap.Wrapf("Post-plan hook failed: {{err}}", err)
+	}
+
+	return nil
+}
+
+// postOperation calls the PostOperation hook with the exit status of the
+// command if an operation was started.
+func (m *Meta) postOperation(code int) {
+	if m.operation == "" {
+		return
+	}
+
+	result := OperationSucceeded
+	if code == 1 {
+		result = OperationFailed
+	}
+	metrics := m.operationMetrics()
+	metrics.IncOperation(m.operation, result)
+	metrics.ObserveDuration(m.operation, time.Since(m.operationStart))
+
+	if m.OperationHooks.PostOperation == nil {
+		return
+	}
+
+	m.OperationHooks.PostOperation(m.operation, code)
+}
@@ -0,0 +1,182 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// testOperationHooks returns hooks that record the calls made to them in
+// calls, and check that the state at statePath is unlocked by the time
+// PostOperation is called.
+func testOperationHooks(t *testing.T, statePath string, calls *[]string, planErr error) OperationHooks {
+	return OperationHooks{
+		PreOperation: func(op string) error {
+			*calls = append(*calls, "pre "+op)
+			return nil
+		},
+		PostPlan: func(p *terraform.Plan) error {
+			*calls = append(*calls, "plan")
+			return planErr
+		},
+		PostOperation: func(op string, code int) {
+			*calls = append(*calls, fmt.Sprintf("post %s %d", op, code))
+
+			lockPath := filepath.Join(
+				filepath.Dir(statePath), "."+filepath.Base(statePath)+".lock.info")
+			if _, err := os.Stat(lockPath); err == nil {
+				t.Errorf("state %s is still locked", statePath)
+			}
+		},
+	}
+}
+
+func TestOperationHooks_plan(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	var calls []string
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts:    testCtxConfig(testProvider()),
+			Ui:             ui,
+			OperationHooks: testOperationHooks(t, statePath, &calls, nil),
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := []string{"pre plan", "plan", "post plan 0"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+}
+
+func TestOperationHooks_planAbort(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	outPath := filepath.Join(testTempDir(t), "plan")
+
+	var calls []string
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+			OperationHooks: testOperationHooks(
+				t, statePath, &calls, errors.New("not today")),
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "not today") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The plan isn't saved
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Fatalf("plan file should not be written: %s", err)
+	}
+
+	expected := []string{"pre plan", "plan", "post plan 1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+}
+
+func TestOperationHooks_applyAbort(t *testing.T) {
+	originalState := testState()
+	statePath := testStateFile(t, originalState)
+
+	var calls []string
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			OperationHooks: testOperationHooks(
+				t, statePath, &calls, errors.New("not today")),
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	// Nothing is applied or saved
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if actual := testStateRead(t, statePath); !actual.Equal(originalState) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if _, err := os.Stat(statePath + DefaultBackupExtension); !os.IsNotExist(err) {
+		t.Fatalf("backup should not be written: %s", err)
+	}
+
+	expected := []string{"pre apply", "plan", "post apply 1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+}
+
+func TestOperationHooks_preOperationAbort(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	var calls []string
+	hooks := testOperationHooks(t, statePath, &calls, nil)
+	hooks.PreOperation = func(op string) error {
+		calls = append(calls, "pre "+op)
+		return errors.New("not today")
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts:    testCtxConfig(p),
+			Ui:             ui,
+			OperationHooks: hooks,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
+	}
+
+	expected := []string{"pre refresh", "post refresh 1"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("bad: %#v", calls)
+	}
+}
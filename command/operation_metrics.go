@@ -0,0 +1,119 @@
+package command
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// The results of operations counted by OperationMetrics.IncOperation.
+const (
+	OperationStarted   = "started"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// OperationMetrics collects metrics of the operations that commands such
+// as plan, apply and refresh run, for programs running commands that want
+// to export them, such as to Prometheus. Meta.OperationMetrics may be nil,
+// in which case nothing is collected.
+//
+// The methods may be called concurrently by commands that run at the same
+// time with the same metrics.
+type OperationMetrics interface {
+	// IncOperation counts an operation, such as "plan", that has the given
+	// result: OperationStarted when it starts, and then OperationSucceeded
+	// or OperationFailed when it's done. An operation fails if its command
+	// exits with status 1; other statuses, such as 2 for a plan with
+	// changes with -detailed-exitcode, are successes.
+	IncOperation(operation, result string)
+
+	// ObserveDuration records how long an operation took, from when it
+	// started until its command was done.
+	ObserveDuration(operation string, d time.Duration)
+
+	// ObservePlanSize records the number of resources with changes in a
+	// plan that was created.
+	ObservePlanSize(n int)
+}
+
+// nullOperationMetrics is the OperationMetrics used when none are set,
+// which collects nothing.
+type nullOperationMetrics struct{}
+
+func (nullOperationMetrics) IncOperation(string, string)           {}
+func (nullOperationMetrics) ObserveDuration(string, time.Duration) {}
+func (nullOperationMetrics) ObservePlanSize(int)                   {}
+
+// InmemOperationMetrics is an OperationMetrics implementation that keeps
+// the metrics in memory, such as for tests or for programs that export
+// them on their own. The zero value is ready to use.
+type InmemOperationMetrics struct {
+	// Operations are the counts of the operations by operation and then
+	// by result, Durations the durations by operation in the order they
+	// were observed, and PlanSizes the sizes of the plans. They should
+	// only be read once the commands collecting them are done.
+	Operations map[string]map[string]int
+	Durations  map[string][]time.Duration
+	PlanSizes  []int
+
+	mu sync.Mutex
+}
+
+func (m *InmemOperationMetrics) IncOperation(operation, result string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Operations == nil {
+		m.Operations = make(map[string]map[string]int)
+	}
+	if m.Operations[operation] == nil {
+		m.Operations[operation] = make(map[string]int)
+	}
+	m.Operations[operation][result]++
+}
+
+func (m *InmemOperationMetrics) ObserveDuration(operation string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Durations == nil {
+		m.Durations = make(map[string][]time.Duration)
+	}
+	m.Durations[operation] = append(m.Durations[operation], d)
+}
+
+func (m *InmemOperationMetrics) ObservePlanSize(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.PlanSizes = append(m.PlanSizes, n)
+}
+
+// operationMetrics returns the OperationMetrics to collect metrics with.
+func (m *Meta) operationMetrics() OperationMetrics {
+	if m.OperationMetrics == nil {
+		return nullOperationMetrics{}
+	}
+
+	return m.OperationMetrics
+}
+
+// planSize returns the number of resources with changes in the plan.
+func planSize(plan *terraform.Plan) int {
+	if plan == nil || plan.Diff == nil {
+		return 0
+	}
+
+	n := 0
+	for _, m := range plan.Diff.Modules {
+		for _, rd := range m.Resources {
+			if !rd.Empty() {
+				n++
+			}
+		}
+	}
+
+	return n
+}
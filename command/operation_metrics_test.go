@@ -0,0 +1,138 @@
+package command
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestOperationMetrics(t *testing.T) {
+	metrics := new(InmemOperationMetrics)
+	statePath := testTempFile(t)
+
+	meta := func(p *terraform.MockResourceProvider) Meta {
+		return Meta{
+			ContextOpts:      testCtxConfig(p),
+			Ui:               new(cli.MockUi),
+			OperationMetrics: metrics,
+		}
+	}
+
+	// A plan that creates a resource
+	plan := &PlanCommand{Meta: meta(testProvider())}
+	if code := plan.Run([]string{"-state", statePath, testFixturePath("plan")}); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// A plan that fails
+	p := testProvider()
+	p.DiffFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		return nil, errors.New("failed")
+	}
+	plan = &PlanCommand{Meta: meta(p)}
+	if code := plan.Run([]string{"-state", statePath, testFixturePath("plan")}); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// A plan with changes and -detailed-exitcode still succeeds
+	plan = &PlanCommand{Meta: meta(testProvider())}
+	args := []string{"-detailed-exitcode", "-state", statePath, testFixturePath("plan")}
+	if code := plan.Run(args); code != 2 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// An apply, which plans once too
+	apply := &ApplyCommand{Meta: meta(testProvider()), ShutdownCh: make(chan struct{})}
+	if code := apply.Run([]string{"-state", statePath, testFixturePath("apply")}); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	// A refresh, which doesn't plan
+	refresh := &RefreshCommand{Meta: meta(testProvider())}
+	if code := refresh.Run([]string{"-state", statePath, testFixturePath("refresh")}); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	expected := map[string]map[string]int{
+		"plan": {
+			OperationStarted:   3,
+			OperationSucceeded: 2,
+			OperationFailed:    1,
+		},
+		"apply": {
+			OperationStarted:   1,
+			OperationSucceeded: 1,
+		},
+		"refresh": {
+			OperationStarted:   1,
+			OperationSucceeded: 1,
+		},
+	}
+	if !reflect.DeepEqual(metrics.Operations, expected) {
+		t.Fatalf("bad: %#v", metrics.Operations)
+	}
+
+	for op, results := range expected {
+		if n := len(metrics.Durations[op]); n != results[OperationStarted] {
+			t.Fatalf("%s: bad: %d durations", op, n)
+		}
+		for _, d := range metrics.Durations[op] {
+			if d <= 0 {
+				t.Fatalf("%s: bad: %s", op, d)
+			}
+		}
+	}
+
+	// The plans that were created, including the one of the apply
+	if !reflect.DeepEqual(metrics.PlanSizes, []int{1, 1, 1}) {
+		t.Fatalf("bad: %#v", metrics.PlanSizes)
+	}
+}
+
+func TestPlanSize(t *testing.T) {
+	cases := []struct {
+		Plan     *terraform.Plan
+		Expected int
+	}{
+		{nil, 0},
+		{&terraform.Plan{}, 0},
+		{
+			&terraform.Plan{
+				Diff: &terraform.Diff{
+					Modules: []*terraform.ModuleDiff{
+						&terraform.ModuleDiff{
+							Path: []string{"root"},
+							Resources: map[string]*terraform.InstanceDiff{
+								"test_instance.foo": &terraform.InstanceDiff{
+									Destroy: true,
+								},
+								"test_instance.bar": &terraform.InstanceDiff{},
+							},
+						},
+						&terraform.ModuleDiff{
+							Path: []string{"root", "child"},
+							Resources: map[string]*terraform.InstanceDiff{
+								"test_instance.baz": &terraform.InstanceDiff{
+									Destroy: true,
+								},
+							},
+						},
+					},
+				},
+			},
+			2,
+		},
+	}
+
+	for i, tc := range cases {
+		if actual := planSize(tc.Plan); actual != tc.Expected {
+			t.Fatalf("%d: bad: %d", i, actual)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// The types of operation an OperationRequest can describe.
+const (
+	OperationTypePlan    = "plan"
+	OperationTypeApply   = "apply"
+	OperationTypeRefresh = "refresh"
+)
+
+// OperationRequestVersion is the version of the JSON format of
+// OperationRequest. It is increased whenever a change to the format isn't
+// compatible with readers of the previous version.
+const OperationRequestVersion = 1
+
+// OperationRequest describes an operation to run, such as a plan, in a form
+// that can be encoded as JSON and sent to run somewhere else, such as by a
+// service that runs Terraform remotely. It is read and written with
+// ReadOperationRequest and WriteOperationRequest.
+//
+// Only the settings of the operation are included. The configuration
+// can't be encoded, so the configuration directory has to be packaged and
+// sent separately, and the side running the operation loads it from
+// wherever it was unpacked. The input and output of the operation are the
+// UI of the side running it, so the variables that need values must all
+// be set in Variables.
+type OperationRequest struct {
+	// Version is the version of the format, OperationRequestVersion when
+	// written by this version of Terraform.
+	Version int `json:"version"`
+
+	// Type is the type of the operation, one of the OperationType
+	// constants.
+	Type string `json:"type"`
+
+	// ID is the unique ID of the operation, which is used to correlate the
+	// logs and the state lock of the operation with the side requesting it.
+	ID string `json:"id"`
+
+	// Source describes who or what requested the operation, and
+	// Annotations are extra information about it. Both are recorded in
+	// the state lock and the logs of the operation.
+	Source      string            `json:"source,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Targets are the resource addresses given with -target, if any.
+	Targets []string `json:"targets"`
+
+	// Variables are the values of the root module variables, including the
+	// ones loaded from variable files.
+	Variables map[string]interface{} `json:"variables"`
+
+	// Destroy is true if the operation plans or applies destroying
+	// everything that is managed.
+	Destroy bool `json:"destroy"`
+
+	// PlanId is the ID of the saved plan to apply, for apply operations
+	// that apply a plan file. The plan file itself has to be sent
+	// separately like the configuration.
+	PlanId string `json:"plan_id,omitempty"`
+}
+
+// ReadOperationRequest reads an operation request in the JSON format
+// written by WriteOperationRequest.
+func ReadOperationRequest(src io.Reader) (*OperationRequest, error) {
+	var r OperationRequest
+	if err := json.NewDecoder(src).Decode(&r); err != nil {
+		return nil, fmt.Errorf("Error decoding operation request: %s", err)
+	}
+
+	if r.Version != OperationRequestVersion {
+		return nil, fmt.Errorf(
+			"Operation request version %d isn't supported by this version of\n"+
+				"Terraform, which supports version %d.",
+			r.Version, OperationRequestVersion)
+	}
+
+	switch r.Type {
+	case OperationTypePlan, OperationTypeApply, OperationTypeRefresh:
+	default:
+		return nil, fmt.Errorf("Unknown operation type in operation request: %q", r.Type)
+	}
+
+	return &r, nil
+}
+
+// WriteOperationRequest writes the operation request as JSON.
+func WriteOperationRequest(r *OperationRequest, dst io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return fmt.Errorf("Error encoding operation request: %s", err)
+	}
+
+	data = append(data, '\n')
+	_, err = dst.Write(data)
+	return err
+}
+
+// OperationRequest returns the request for an operation of the given type
+// with the settings of this Meta, from the flags that have been parsed.
+// The ID, source and annotations are those of the operation this Meta
+// runs, so that the remote operation is correlated with it.
+func (m *Meta) OperationRequest(typ string, destroy bool, planId string) *OperationRequest {
+	vars := make(map[string]interface{})
+	for k, v := range m.autoVariables {
+		vars[k] = v
+	}
+	for k, v := range m.variables {
+		vars[k] = v
+	}
+
+	targets := m.targets
+	if targets == nil {
+		targets = []string{}
+	}
+
+	return &OperationRequest{
+		Version:     OperationRequestVersion,
+		Type:        typ,
+		ID:          m.operationID(),
+		Source:      m.operationSource(),
+		Annotations: m.OperationAnnotations,
+		Targets:     targets,
+		Variables:   vars,
+		Destroy:     destroy,
+		PlanId:      planId,
+	}
+}
+
+// SetOperationRequest sets up this Meta to run the operation described by
+// the request, as if its settings had been given as flags. The type,
+// Destroy and PlanId aren't settings of Meta, so they are up to the
+// caller to act on.
+func (m *Meta) SetOperationRequest(r *OperationRequest) {
+	m.OperationID = r.ID
+	m.OperationSource = r.Source
+	m.OperationAnnotations = r.Annotations
+	m.targets = r.Targets
+	m.variables = r.Variables
+	m.autoVariables = nil
+}
@@ -0,0 +1,120 @@
+package command
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestOperationRequest_golden(t *testing.T) {
+	r := &OperationRequest{
+		Version: OperationRequestVersion,
+		Type:    OperationTypePlan,
+		ID:      "4f1b2a3c",
+		Targets: []string{"aws_instance.foo"},
+		Variables: map[string]interface{}{
+			"foo":   "bar",
+			"zones": []interface{}{"a", "b"},
+			"amis":  map[string]interface{}{"us-east-1": "ami-123"},
+		},
+		Destroy: true,
+	}
+
+	var buf bytes.Buffer
+	if err := WriteOperationRequest(r, &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected, err := ioutil.ReadFile(
+		filepath.Join(testFixturePath("operation-request"), "request.json"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != string(expected) {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, buf.String())
+	}
+
+	actual, err := ReadOperationRequest(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(actual, r) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestReadOperationRequest_invalid(t *testing.T) {
+	cases := map[string]string{
+		`{"version": 2, "type": "plan"}`: "version 2 isn't supported",
+		`{"version": 1, "type": "push"}`: "Unknown operation type",
+		`{"version": 1,`:                 "Error decoding",
+	}
+
+	for input, expected := range cases {
+		_, err := ReadOperationRequest(strings.NewReader(input))
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("%s: bad: %v", input, err)
+		}
+	}
+}
+
+func TestMetaOperationRequest(t *testing.T) {
+	m := &Meta{
+		Ui:                   new(cli.MockUi),
+		OperationID:          "foo",
+		OperationSource:      "CI job 1234",
+		OperationAnnotations: map[string]string{"user": "alice"},
+	}
+	f := m.flagSet("test")
+	args := []string{
+		"-var", "foo=bar",
+		"-target", "test_instance.foo",
+	}
+	if err := f.Parse(args); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	r := m.OperationRequest(OperationTypeApply, false, "plan-id")
+	expected := &OperationRequest{
+		Version:   OperationRequestVersion,
+		Type:      OperationTypeApply,
+		ID:          "foo",
+		Source:      "CI job 1234",
+		Annotations: map[string]string{"user": "alice"},
+		Targets:     []string{"test_instance.foo"},
+		Variables:   map[string]interface{}{"foo": "bar"},
+		PlanId:      "plan-id",
+	}
+	if !reflect.DeepEqual(r, expected) {
+		t.Fatalf("bad: %#v", r)
+	}
+
+	// The request round-trips into the same settings on another Meta
+	var buf bytes.Buffer
+	if err := WriteOperationRequest(r, &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	read, err := ReadOperationRequest(&buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	remote := &Meta{ContextOpts: testCtxConfig(testProvider()), Ui: new(cli.MockUi)}
+	remote.SetOperationRequest(read)
+	if !reflect.DeepEqual(remote.OperationRequest(OperationTypeApply, false, "plan-id"), r) {
+		t.Fatalf("bad: %#v", remote.OperationRequest(OperationTypeApply, false, "plan-id"))
+	}
+
+	opts := remote.contextOpts()
+	if !reflect.DeepEqual(opts.Variables, map[string]interface{}{"foo": "bar"}) {
+		t.Fatalf("bad: %#v", opts.Variables)
+	}
+	if !reflect.DeepEqual(opts.Targets, []string{"test_instance.foo"}) {
+		t.Fatalf("bad: %#v", opts.Targets)
+	}
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// PlanResult is the result of running PlanCommand. Everything the command
+// outputs about the result is recorded here too, so that programs that run
+// the command with a Ui that discards the output, or that can't parse it,
+// still know what happened.
+type PlanResult struct {
+	// Plan is the plan that was created, or the saved plan that was
+	// given. It is nil if the command failed before there was one.
+	Plan *terraform.Plan
+
+	// FromPlanFile is true if a saved plan was given instead of a
+	// configuration, so it was only shown and nothing was planned.
+	FromPlanFile bool
+
+	// Empty is true if the plan has no changes.
+	Empty bool
+
+	// Changes are the numbers of resources the plan adds, changes and
+	// destroys, as in the summary of the plan.
+	Changes *CountHook
+
+	// ApplyEstimate is how many resource operations applying the plan
+	// runs, and how many of them have to run one after the other. It is
+	// nil if the plan has no changes, or if it couldn't be estimated.
+	ApplyEstimate *terraform.ApplyEstimate
+
+	// OutPath and PlanId are the path the plan was saved to with -out and
+	// its ID. They are empty if the plan wasn't saved.
+	OutPath string
+	PlanId  string
+
+	// Err is the error the command failed with, if it failed.
+	Err error
+}
+
+// RefreshResult is the result of running RefreshCommand, like PlanResult
+// is for PlanCommand.
+type RefreshResult struct {
+	// State is the refreshed state, and StatePath is where it was
+	// written. State is nil if the refresh failed, and StatePath is empty
+	// if the state wasn't written.
+	State     *terraform.State
+	StatePath string
+
+	// Changed and Removed are the addresses of the resources that were
+	// found to be changed or removed outside of Terraform.
+	Changed []string
+	Removed []string
+
+	// Err is the error the command failed with, if it failed. A refresh
+	// that timed out can fail after its state was written.
+	Err error
+}
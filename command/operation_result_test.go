@@ -0,0 +1,189 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// testDiscardUi returns a Ui that discards all output, like programs that
+// run commands without a terminal do, so that tests can only pass if the
+// result has everything.
+func testDiscardUi() cli.Ui {
+	return &cli.BasicUi{
+		Reader:      new(os.File),
+		Writer:      ioutil.Discard,
+		ErrorWriter: ioutil.Discard,
+	}
+}
+
+func TestPlanResult(t *testing.T) {
+	outPath := filepath.Join(testTempDir(t), "plan")
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module: testModule(t, "plan"),
+		Diff:   new(terraform.Diff),
+	})
+
+	cases := []struct {
+		Name   string
+		State  *terraform.State
+		Args   []string
+		Code   int
+		Check  func(*PlanResult) bool
+		ErrNil bool
+	}{
+		{
+			"changes",
+			nil,
+			[]string{testFixturePath("plan")},
+			0,
+			func(r *PlanResult) bool {
+				return r.Plan != nil && !r.Empty && r.Changes.ToAdd == 1 &&
+					!r.FromPlanFile && r.OutPath == "" && r.PlanId == ""
+			},
+			true,
+		},
+		{
+			"no changes",
+			testState(),
+			[]string{testFixturePath("plan")},
+			0,
+			func(r *PlanResult) bool {
+				return r.Plan != nil && r.Empty && r.Changes.ToAdd == 0
+			},
+			true,
+		},
+		{
+			"detailed exit code",
+			nil,
+			[]string{"-detailed-exitcode", testFixturePath("plan")},
+			2,
+			func(r *PlanResult) bool { return !r.Empty },
+			true,
+		},
+		{
+			"saved",
+			nil,
+			[]string{"-out", outPath, testFixturePath("plan")},
+			0,
+			func(r *PlanResult) bool {
+				id, _ := r.Plan.Id()
+				return r.OutPath == outPath && r.PlanId != "" && r.PlanId == id
+			},
+			true,
+		},
+		{
+			"plan file",
+			nil,
+			[]string{planPath},
+			0,
+			func(r *PlanResult) bool { return r.FromPlanFile && r.Plan != nil },
+			true,
+		},
+		{
+			"invalid config",
+			nil,
+			[]string{testFixturePath("apply-config-invalid")},
+			1,
+			func(r *PlanResult) bool {
+				_, ok := r.Err.(*ErrValidation)
+				return ok && r.Plan == nil
+			},
+			false,
+		},
+		{
+			"unknown format",
+			nil,
+			[]string{"-out-format", "yaml", testFixturePath("plan")},
+			1,
+			func(r *PlanResult) bool { return r.Plan == nil },
+			false,
+		},
+		{
+			"bad flag",
+			nil,
+			[]string{"-nope"},
+			1,
+			func(r *PlanResult) bool { return r.Plan == nil },
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		args := tc.Args
+		if tc.State != nil {
+			args = append([]string{"-state", testStateFile(t, tc.State)}, args...)
+		}
+
+		c := &PlanCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          testDiscardUi(),
+			},
+		}
+		if code := c.Run(args); code != tc.Code {
+			t.Fatalf("%s: bad: %d %v", tc.Name, code, c.Result.Err)
+		}
+		if (c.Result.Err == nil) != tc.ErrNil {
+			t.Fatalf("%s: bad error: %v", tc.Name, c.Result.Err)
+		}
+		if !tc.Check(c.Result) {
+			t.Fatalf("%s: bad: %#v", tc.Name, c.Result)
+		}
+	}
+}
+
+func TestRefreshResult(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{ID: "yes"}
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          testDiscardUi(),
+		},
+	}
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d %v", code, c.Result.Err)
+	}
+
+	r := c.Result
+	if r.Err != nil || r.StatePath != statePath {
+		t.Fatalf("bad: %#v", r)
+	}
+	if rs := r.State.RootModule().Resources["test_instance.foo"]; rs.Primary.ID != "yes" {
+		t.Fatalf("bad: %#v", r.State)
+	}
+	if len(r.Changed) != 1 || r.Changed[0] != "test_instance.foo" || len(r.Removed) != 0 {
+		t.Fatalf("bad: %#v %#v", r.Changed, r.Removed)
+	}
+
+	// Errors that are only output otherwise
+	c = &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          testDiscardUi(),
+		},
+	}
+	args = []string{
+		"-state", filepath.Join(testTempDir(t), "missing.tfstate"),
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !errwrap.ContainsType(c.Result.Err, new(ErrStateNotFound)) || c.Result.State != nil {
+		t.Fatalf("bad: %#v", c.Result)
+	}
+}
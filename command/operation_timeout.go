@@ -0,0 +1,76 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// ErrTimeout is returned when an operation doesn't finish before the
+// deadline set with the -timeout flag.
+var ErrTimeout = errors.New("operation timed out")
+
+// operationTimeoutGrace is how long an operation is given to stop
+// gracefully once its deadline has passed before it is abandoned. It is a
+// variable so that tests can shorten it.
+var operationTimeoutGrace = 30 * time.Second
+
+// operationDeadline returns the deadline for an operation started now with
+// the given -timeout. A zero deadline means that there is no limit.
+func operationDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(timeout)
+}
+
+// runWithDeadline calls f, which runs an operation with ctx, and stops ctx
+// if f hasn't returned by the deadline. At the deadline, ctx is stopped
+// gracefully, which lets the resources being worked on finish, and f is
+// given operationTimeoutGrace to return after that.
+//
+// The error is ErrTimeout if the deadline was reached, and finished is
+// false if f didn't return during the grace period. In that case f is
+// abandoned while still running, so nothing it sets may be used, and the
+// state it works on must not be persisted.
+func (m *Meta) runWithDeadline(
+	ctx *terraform.Context, deadline time.Time, f func()) (finished bool, err error) {
+	if deadline.IsZero() {
+		f()
+		return true, nil
+	}
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		f()
+	}()
+
+	timer := time.NewTimer(deadline.Sub(time.Now()))
+	defer timer.Stop()
+
+	select {
+	case <-doneCh:
+		return true, nil
+	case <-timer.C:
+	}
+
+	m.Ui.Error(fmt.Sprintf(
+		"The operation didn't finish within the -timeout. Stopping it gracefully,\n"+
+			"it will be abandoned if it hasn't stopped within %s.",
+		operationTimeoutGrace))
+
+	// Stop blocks until the operation is done, which could be never if a
+	// provider is stuck, so we wait for f with our own grace timer instead.
+	go ctx.Stop()
+
+	select {
+	case <-doneCh:
+		return true, ErrTimeout
+	case <-time.After(operationTimeoutGrace):
+		return false, ErrTimeout
+	}
+}
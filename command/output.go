@@ -51,14 +51,9 @@ func (c *OutputCommand) Run(args []string) int {
 		return 1
 	}
 
-	if module == "" {
-		module = "root"
-	} else {
-		module = "root." + module
-	}
-
 	// Get the proper module we want to get outputs for
-	modPath := strings.Split(module, ".")
+	modPath := outputModulePath(module)
+	module = strings.Join(modPath, ".")
 
 	state := stateStore.State()
 	mod := state.ModuleByPath(modPath)
@@ -95,11 +90,19 @@ func (c *OutputCommand) Run(args []string) int {
 
 	v, ok := mod.Outputs[name]
 	if !ok {
+		names := make([]string, 0, len(mod.Outputs))
+		for k := range mod.Outputs {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
 		c.Ui.Error(fmt.Sprintf(
-			"The output variable requested could not be found in the state\n" +
-				"file. If you recently added this to your configuration, be\n" +
-				"sure to run `terraform apply`, since the state won't be updated\n" +
-				"with new output variables until that command is run."))
+			"The output variable %q could not be found in the state for\n"+
+				"the module %s. The available outputs are: %s\n\n"+
+				"If you recently added this to your configuration, be\n"+
+				"sure to run `terraform apply`, since the state won't be updated\n"+
+				"with new output variables until that command is run.",
+			name, module, strings.Join(names, ", ")))
 		return 1
 	}
 
@@ -122,7 +125,7 @@ func (c *OutputCommand) Run(args []string) int {
 			c.Ui.Output(formatMapOutput("", "", output))
 			return 0
 		default:
-			c.Ui.Error(fmt.Sprintf("Unknown output type: %T", v.Type))
+			c.Ui.Error(fmt.Sprintf("Unknown output type: %T", v.Value))
 			return 1
 		}
 	}
@@ -130,6 +133,31 @@ func (c *OutputCommand) Run(args []string) int {
 	return 0
 }
 
+// outputModulePath returns the path of the module given with the -module
+// flag. Nested modules can be given either with the names of the modules
+// separated by periods, such as "foo.bar", or as they are addressed in the
+// configuration, such as "module.foo.module.bar".
+func outputModulePath(module string) []string {
+	path := []string{"root"}
+	if module == "" {
+		return path
+	}
+
+	parts := strings.Split(module, ".")
+	if parts[0] != "module" {
+		return append(path, parts...)
+	}
+
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "module" && i+1 < len(parts) {
+			i++
+		}
+		path = append(path, parts[i])
+	}
+
+	return path
+}
+
 func formatNestedList(indent string, outputList []interface{}) string {
 	outputBuf := new(bytes.Buffer)
 	outputBuf.WriteString(fmt.Sprintf("%s[", indent))
@@ -259,7 +287,8 @@ Options:
   -no-color        If specified, output won't contain any color.
 
   -module=name     If specified, returns the outputs for a
-                   specific module
+                   specific module. Nested modules are given as
+                   "foo.bar" or "module.foo.module.bar".
 
   -json            If specified, machine readable output will be
                    printed in JSON format
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -497,3 +498,159 @@ func TestOutput_stateDefault(t *testing.T) {
 		t.Fatalf("bad: %#v", actual)
 	}
 }
+
+func TestOutput_nestedModule(t *testing.T) {
+	originalState := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+				Outputs: map[string]*terraform.OutputState{
+					"foo": {
+						Value: "bar",
+						Type:  "string",
+					},
+				},
+			},
+			{
+				Path: []string{"root", "child"},
+				Outputs: map[string]*terraform.OutputState{
+					"foo": {
+						Value: "child",
+						Type:  "string",
+					},
+				},
+			},
+			{
+				Path: []string{"root", "child", "grandchild"},
+				Outputs: map[string]*terraform.OutputState{
+					"list": {
+						Value: []interface{}{"a", "b"},
+						Type:  "list",
+					},
+					"map": {
+						Value: map[string]interface{}{"key": "value"},
+						Type:  "map",
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, originalState)
+
+	cases := []struct {
+		Args     []string
+		Expected string
+	}{
+		{
+			[]string{"-module", "child", "foo"},
+			"child",
+		},
+		{
+			[]string{"-module", "child.grandchild", "list"},
+			"a,\nb",
+		},
+		{
+			[]string{"-module", "module.child.module.grandchild", "map"},
+			"key = value",
+		},
+		{
+			[]string{"-module", "child.grandchild", "-json", "list"},
+			"{\n    \"sensitive\": false,\n    \"type\": \"list\",\n    \"value\": [\n        \"a\",\n        \"b\"\n    ]\n}",
+		},
+		{
+			[]string{"-module", "module.child.module.grandchild", "-json", "map"},
+			"{\n    \"sensitive\": false,\n    \"type\": \"map\",\n    \"value\": {\n        \"key\": \"value\"\n    }\n}",
+		},
+	}
+
+	for _, tc := range cases {
+		ui := new(cli.MockUi)
+		c := &OutputCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+
+		args := append([]string{"-state", statePath}, tc.Args...)
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("%v: bad: \n%s", tc.Args, ui.ErrorWriter.String())
+		}
+
+		actual := strings.TrimSpace(ui.OutputWriter.String())
+		if actual != tc.Expected {
+			t.Fatalf("%v: bad:\n%#v\n%#v", tc.Args, tc.Expected, actual)
+		}
+	}
+}
+
+func TestOutput_missingListsAvailable(t *testing.T) {
+	originalState := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			{
+				Path: []string{"root"},
+			},
+			{
+				Path: []string{"root", "child"},
+				Outputs: map[string]*terraform.OutputState{
+					"foo": {
+						Value: "bar",
+						Type:  "string",
+					},
+					"baz": {
+						Value: "qux",
+						Type:  "string",
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, originalState)
+
+	ui := new(cli.MockUi)
+	c := &OutputCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-module", "child",
+		"missing",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: \n%s", ui.OutputWriter.String())
+	}
+
+	actual := ui.ErrorWriter.String()
+	for _, expected := range []string{
+		`"missing"`,
+		"root.child",
+		"The available outputs are: baz, foo",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Fatalf("bad: expected %q in:\n%s", expected, actual)
+		}
+	}
+}
+
+func TestOutputModulePath(t *testing.T) {
+	cases := map[string][]string{
+		"":                         []string{"root"},
+		"foo":                      []string{"root", "foo"},
+		"foo.bar":                  []string{"root", "foo", "bar"},
+		"module.foo":               []string{"root", "foo"},
+		"module.foo.module.bar":    []string{"root", "foo", "bar"},
+		"module.foo.module.module": []string{"root", "foo", "module"},
+	}
+
+	for input, expected := range cases {
+		if actual := outputModulePath(input); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("%q: bad: %#v", input, actual)
+		}
+	}
+}
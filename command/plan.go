@@ -1,12 +1,19 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/config/module"
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -14,42 +21,111 @@ import (
 // configuration to an actual infrastructure and shows the differences.
 type PlanCommand struct {
 	Meta
+
+	// Result is set to the result of the plan when Run returns.
+	Result *PlanResult
 }
 
-func (c *PlanCommand) Run(args []string) int {
-	var destroy, refresh, detailed bool
-	var outPath string
-	var moduleDepth int
+func (c *PlanCommand) Run(args []string) (code int) {
+	var destroy, refresh, refreshOnly, detailed, get, allowEmpty, stream, force bool
+	var outPath, outFormat string
+	var refreshSkip, replace, filter []string
+	var moduleDepth, maxDiff int
+	var lockTimeout, timeout time.Duration
 
+	c.Result = new(PlanResult)
 	args = c.Meta.process(args, true)
 
+	// Call the hook once everything else, such as unlocking, is done
+	defer func() { c.postOperation(code) }()
+
+	// Output any warnings collected during the operation once it is done
+	defer c.showWarnings()
+
+	// Release the state lock acquired by Context once we're done
+	defer c.unlockState()
+
+	// Capture the logs of the operation if requested
+	defer c.captureLogs("plan")()
+
 	cmdFlags := c.Meta.flagSet("plan")
+	cmdFlags.BoolVar(&allowEmpty, "allow-empty", false, "allow-empty")
 	cmdFlags.BoolVar(&destroy, "destroy", false, "destroy")
 	cmdFlags.BoolVar(&refresh, "refresh", true, "refresh")
+	cmdFlags.BoolVar(&refreshOnly, "refresh-only", false, "refresh-only")
+	cmdFlags.Var((*FlagStringSlice)(&refreshSkip), "refresh-skip", "resource to skip refreshing")
+	cmdFlags.Var((*FlagStringSlice)(&replace), "replace", "resource to replace")
+	cmdFlags.Var((*FlagStringSlice)(&filter), "filter", "resource pattern to show")
+	cmdFlags.BoolVar(&get, "get", false, "get")
+	cmdFlags.BoolVar(&force, "force", false, "force")
 	c.addModuleDepthFlag(cmdFlags, &moduleDepth)
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
+	c.addCompactWarningsFlag(cmdFlags)
+	cmdFlags.IntVar(&maxDiff, "max-diff", 0, "max-diff")
 	cmdFlags.StringVar(&outPath, "out", "", "path")
+	cmdFlags.StringVar(&outFormat, "out-format", "text", "format")
 	cmdFlags.IntVar(
 		&c.Meta.parallelism, "parallelism", DefaultParallelism, "parallelism")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	cmdFlags.BoolVar(&stream, "stream", false, "stream")
+	cmdFlags.BoolVar(&c.Meta.quiet, "quiet", false, "quiet")
+	cmdFlags.DurationVar(&timeout, "timeout", 0, "timeout")
 	cmdFlags.BoolVar(&detailed, "detailed-exitcode", false, "detailed-exitcode")
+	cmdFlags.BoolVar(&c.Meta.warningsAsErrors, "warnings-as-errors", false, "warnings-as-errors")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
+		c.Result.Err = err
 		return 1
 	}
 
+	var renderer PlanRenderer
+	switch outFormat {
+	case "text":
+	case "markdown":
+		renderer = PlanMarkdown{}
+	default:
+		return c.fail(fmt.Errorf(
+			"Unknown plan output format %q. Valid formats are \"text\" and\n"+
+				"\"markdown\".", outFormat))
+	}
+
+	for _, pattern := range filter {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return c.fail(fmt.Errorf("Invalid -filter pattern %q: %s", pattern, err))
+		}
+	}
+	if len(filter) > 0 && stream {
+		return c.fail(errors.New(
+			"The plan can't be filtered with -filter when it is output with -stream."))
+	}
+
+	planMode := terraform.PlanModeNormal
+	if refreshOnly {
+		if destroy || !refresh {
+			return c.fail(errors.New(
+				"A refresh-only plan can't be created with -destroy or -refresh=false."))
+		}
+
+		planMode = terraform.PlanModeRefreshOnly
+	}
+	if len(replace) > 0 && (destroy || refreshOnly) {
+		return c.fail(errors.New(
+			"Resources can't be replaced with -replace in a -destroy or -refresh-only plan."))
+	}
+
 	var path string
 	args = cmdFlags.Args()
 	if len(args) > 1 {
-		c.Ui.Error(
+		c.fail(errors.New(
 			"The plan command expects at most one argument with the path\n" +
-				"to a Terraform configuration.\n")
+				"to a Terraform configuration.\n"))
 		cmdFlags.Usage()
 		return 1
 	} else if len(args) == 1 {
 		path = args[0]
 	} else {
 		var err error
-		path, err = os.Getwd()
+		path, err = c.pwd()
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		}
@@ -57,20 +133,55 @@ func (c *PlanCommand) Run(args []string) int {
 
 	countHook := new(CountHook)
 	c.Meta.extraHooks = []terraform.Hook{countHook}
+	if stream {
+		c.Meta.extraHooks = append(c.Meta.extraHooks, &StreamPlanHook{
+			Colorize: c.Colorize(),
+			Ui:       c.Ui,
+		})
+	}
 
 	// This is going to keep track of shadow errors
 	var shadowErr error
 
+	getMode := module.GetModeNone
+	if get {
+		getMode = module.GetModeGet
+	}
+
 	ctx, planned, err := c.Context(contextOpts{
 		Destroy:     destroy,
+		PlanMode:    planMode,
 		Path:        path,
+		PathEmptyOk: allowEmpty,
 		StatePath:   c.Meta.statePath,
+		GetMode:     getMode,
 		Parallelism: c.Meta.parallelism,
+		RefreshSkip: refreshSkip,
+		Lock:        true,
+		LockTimeout: lockTimeout,
+		Operation:   "plan",
+
+		ForceReplace: replace,
+
+		SkipProviderConfig: !refresh,
+
+		StateOverride:      true,
+		StateOverrideForce: force,
 	})
 	if err != nil {
-		c.Ui.Error(err.Error())
-		return 1
+		return c.fail(err)
+	}
+
+	// A plan of a state that overrides the remote state is only for seeing
+	// what it would plan: applied later, it would change the remote state
+	// as if it were the one planned.
+	if outPath != "" && c.stateResult != nil && c.stateResult.Override {
+		return c.fail(fmt.Errorf(
+			"The -out flag can't be used when -state overrides the remote state,\n" +
+				"since the plan would be applied to the remote state, which it wasn't\n" +
+				"made from. Plan without -state to save a plan that can be applied."))
 	}
+	c.Result.FromPlanFile = planned
 	if planned {
 		c.Ui.Output(c.Colorize().Color(
 			"[reset][bold][yellow]" +
@@ -81,17 +192,18 @@ func (c *PlanCommand) Run(args []string) int {
 
 		// Disable refreshing no matter what since we only want to show the plan
 		refresh = false
+		refreshOnly = false
 	}
 
-	err = terraform.SetDebugInfo(DefaultDataDir)
+	c.outputOperationHeader()
+
+	err = terraform.SetDebugInfo(c.DataDir())
 	if err != nil {
-		c.Ui.Error(err.Error())
-		return 1
+		return c.fail(err)
 	}
 
 	if err := ctx.Input(c.InputMode()); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error configuring: %s", err))
-		return 1
+		return c.fail(errwrap.Wrapf("Error configuring: {{err}}", err))
 	}
 
 	// Record any shadow errors for later
@@ -100,39 +212,115 @@ func (c *PlanCommand) Run(args []string) int {
 			err, "input operation:"))
 	}
 
-	if !validateContext(ctx, c.Ui) {
+	if err := c.validateContext(ctx); err != nil {
+		c.Result.Err = err
 		return 1
 	}
 
+	// The timeout covers both the refresh and the plan. Nothing is
+	// persisted by plan, so a timed out plan is just an error.
+	deadline := operationDeadline(timeout)
+
+	// The state before refreshing is what a refresh-only plan is compared
+	// with to find the changes made outside of Terraform.
+	var prior *terraform.State
+	if refreshOnly {
+		prior = c.state.State()
+	}
+
 	if refresh {
 		c.Ui.Output("Refreshing Terraform state in-memory prior to plan...")
 		c.Ui.Output("The refreshed state will be used to calculate this plan, but")
 		c.Ui.Output("will not be persisted to local or remote state storage.\n")
-		_, err := ctx.Refresh()
+		var refreshErr error
+		_, err := c.runWithDeadline(ctx, deadline, func() {
+			_, refreshErr = ctx.Refresh()
+		})
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error refreshing state: %s", err))
-			return 1
+			return c.fail(errwrap.Wrapf(
+				fmt.Sprintf("Error refreshing state: {{err}} after %s", timeout), err))
+		}
+		if refreshErr != nil {
+			return c.fail(errwrap.Wrapf("Error refreshing state: {{err}}", refreshErr))
 		}
 		c.Ui.Output("")
 	}
 
-	plan, err := ctx.Plan()
+	if stream {
+		c.Ui.Output(strings.TrimSpace(planHeaderStream) + "\n")
+		if note := planTargetsNote(c.Meta.targets); note != "" {
+			c.Ui.Output(note)
+		}
+	}
+
+	var plan *terraform.Plan
+	var planErr error
+	_, err = c.runWithDeadline(ctx, deadline, func() {
+		plan, planErr = ctx.Plan()
+	})
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error running plan: %s", err))
-		return 1
+		return c.fail(errwrap.Wrapf(
+			fmt.Sprintf("Error running plan: {{err}} after %s", timeout), err))
+	}
+	if planErr != nil {
+		return c.fail(errwrap.Wrapf("Error running plan: {{err}}", planErr))
 	}
+	var changed, removed []string
+	if refreshOnly {
+		changed, removed = terraform.StateDrift(prior, plan.State)
+	}
+	c.Result.Plan = plan
+	c.Result.Changes = countHook
+	c.Result.Empty = plan.Diff.Empty() && len(changed)+len(removed) == 0
 
-	if outPath != "" {
-		log.Printf("[INFO] Writing plan output to: %s", outPath)
-		f, err := os.Create(outPath)
-		if err == nil {
-			defer f.Close()
-			err = terraform.WritePlan(plan, f)
+	// The estimate is only a hint, so the plan doesn't fail without it
+	if !refreshOnly && !plan.Diff.Empty() {
+		estimate, err := ctx.ApplyEstimate()
+		if err != nil {
+			log.Printf("[WARN] Error estimating the apply of the plan: %s", err)
 		}
+		c.Result.ApplyEstimate = estimate
+	}
+
+	if err := c.postPlan(plan); err != nil {
+		return c.fail(err)
+	}
+
+	var planId string
+	if outPath != "" {
+		planId, err = plan.Id()
 		if err != nil {
-			c.Ui.Error(fmt.Sprintf("Error writing plan file: %s", err))
-			return 1
+			return c.fail(err)
+		}
+
+		log.Printf("[INFO] Writing plan %s output to: %s", planId, outPath)
+		if err := writePlanFile(plan, outPath, c.planEncryptionKey()); err != nil {
+			return c.fail(errwrap.Wrapf("Error writing plan file: {{err}}", err))
+		}
+		c.Result.OutPath = outPath
+		c.Result.PlanId = planId
+	}
+
+	// A refresh-only plan has no changes, what it shows is the drift
+	if refreshOnly {
+		if c.Result.Empty {
+			c.Ui.Output(
+				"No changes. Refreshing found no changes made outside of Terraform,\n" +
+					"so the state already matches the real physical resources.")
+			return c.exitCode(0)
+		}
+
+		c.Ui.Output(c.Colorize().Color(formatStateDrift(changed, removed)))
+		c.Ui.Output(strings.TrimSpace(planRefreshOnlyFooter))
+		if outPath != "" {
+			c.Ui.Output(fmt.Sprintf(
+				"\nThe plan was saved to: %s\nPlan ID: %s", outPath, planId))
 		}
+
+		if detailed {
+			return c.exitCode(2)
+		}
+		return c.exitCode(0)
 	}
 
 	if plan.Diff.Empty() {
@@ -141,29 +329,54 @@ func (c *PlanCommand) Run(args []string) int {
 				"could not detect any differences between your configuration and\n" +
 				"the real physical resources that exist. As a result, Terraform\n" +
 				"doesn't need to do anything.")
-		return 0
+		return c.exitCode(0)
 	}
 
-	if outPath == "" {
-		c.Ui.Output(strings.TrimSpace(planHeaderNoOutput) + "\n")
+	if stream {
+		// The changes were already output as they were planned
+		if outPath != "" {
+			c.Ui.Output(fmt.Sprintf(
+				"\nThe plan was saved to: %s\nPlan ID: %s", outPath, planId))
+		}
 	} else {
-		c.Ui.Output(fmt.Sprintf(
-			strings.TrimSpace(planHeaderYesOutput)+"\n",
-			outPath))
-	}
+		if outPath == "" {
+			c.Ui.Output(strings.TrimSpace(planHeaderNoOutput) + "\n")
+		} else {
+			c.Ui.Output(fmt.Sprintf(
+				strings.TrimSpace(planHeaderYesOutput)+"\n",
+				outPath, planId))
+		}
 
-	c.Ui.Output(FormatPlan(&FormatPlanOpts{
-		Plan:        plan,
-		Color:       c.Colorize(),
-		ModuleDepth: moduleDepth,
-	}))
+		c.Ui.Output(fmt.Sprintf("Generated by Terraform v%s\n", plan.TerraformVersion))
+		if note := planTargetsNote(c.Meta.targets); note != "" {
+			c.Ui.Output(note)
+		}
+
+		// Stream the plan to the UI since it can be very large
+		planOut := &UiWriter{Ui: c.Ui}
+		err = FormatPlanWrite(planOut, &FormatPlanOpts{
+			Plan:         plan,
+			Color:        c.Colorize(),
+			ModuleDepth:  moduleDepth,
+			MaxResources: maxDiff,
+			Renderer:     renderer,
+			Filter:       filter,
+		})
+		planOut.Close()
+		if err != nil {
+			return c.fail(errwrap.Wrapf("Error formatting plan: {{err}}", err))
+		}
+	}
 
-	c.Ui.Output(c.Colorize().Color(fmt.Sprintf(
-		"[reset][bold]Plan:[reset] "+
-			"%d to add, %d to change, %d to destroy.",
-		countHook.ToAdd+countHook.ToRemoveAndAdd,
-		countHook.ToChange,
-		countHook.ToRemove+countHook.ToRemoveAndAdd)))
+	c.Ui.Output(c.Colorize().Color(formatPlanSummary(countHook)))
+	if summary := formatPlanTypeSummary(countHook); summary != "" {
+		c.Ui.Output(c.Colorize().Color("\n" + summary))
+	}
+	if e := c.Result.ApplyEstimate; e != nil {
+		c.Ui.Output(fmt.Sprintf(
+			"\nEstimated apply: %d operations, critical path %d",
+			e.Operations, e.CriticalPath))
+	}
 
 	// Record any shadow errors for later
 	if err := ctx.ShadowError(); err != nil {
@@ -175,9 +388,32 @@ func (c *PlanCommand) Run(args []string) int {
 	c.outputShadowError(shadowErr, true)
 
 	if detailed {
-		return 2
+		return c.exitCode(2)
+	}
+	return c.exitCode(0)
+}
+
+// fail records err as the error the command failed with and outputs it,
+// returning the exit status for failing.
+func (c *PlanCommand) fail(err error) int {
+	c.Result.Err = err
+	c.Ui.Error(FormatDiagnostic(&FormatDiagnosticOpts{
+		Err:   err,
+		Color: c.Colorize(),
+	}))
+	return 1
+}
+
+// exitCode returns the exit status for a plan that succeeded with code,
+// which is a failure if warnings are treated as errors.
+func (c *PlanCommand) exitCode(code int) int {
+	n := len(c.warnings)
+	if code = c.warningsExitCode(code); code == 1 {
+		c.Result.Err = fmt.Errorf(
+			"%d warning(s) treated as errors because -warnings-as-errors was set", n)
 	}
-	return 0
+
+	return code
 }
 
 func (c *PlanCommand) Help() string {
@@ -196,6 +432,15 @@ Usage: terraform plan [options] [DIR-OR-PLAN]
 
 Options:
 
+  -allow-empty        Allow the configuration directory to have no Terraform
+                      configuration files, planning as if the configuration
+                      was empty. Without it, an empty directory is an error.
+
+  -compact-warnings=true
+                      Output warnings that differ only in what they're for,
+                      such as the same deprecated argument in many resources,
+                      as one warning followed by how many more there are.
+
   -destroy            If set, a plan will be generated to destroy all resources
                       managed by the given configuration and state.
 
@@ -205,8 +450,37 @@ Options:
                       1 - Errored
                       2 - Succeeded, there is a diff
 
+  -filter=pattern     Only show the resources whose address or type matches
+                      the glob pattern, such as "aws_iam_*", and how many
+                      were hidden. The summary and the plan file written
+                      with -out still have all of them. Can be given
+                      multiple times.
+
+  -force              With -state, plan against the state file even if it
+                      has a different lineage than the remote state.
+
+  -get=false          Download any modules for this configuration that haven't
+                      been downloaded yet. Modules that were already
+                      downloaded are not updated.
+
   -input=true         Ask for input for variables if not directly set.
 
+  -input-cache=true   Reuse the answers given for provider configuration
+                      by earlier runs, saved in .terraform/input.json.
+                      Credentials are never saved.
+
+  -lock-takeover=0s   Take over a state lock older than this that was
+                      acquired by a process on this host that is no longer
+                      running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s    Duration to retry a state lock held by another
+                      operation before giving up.
+
+  -max-diff=n         Show at most n resources for each kind of change, such
+                      as resources to add, and how many more there are. The
+                      plan file written with -out always has all of them.
+                      By default, all resources are shown.
+
   -module-depth=n     Specifies the depth of modules to show in the output.
                       This does not affect the plan itself, only the output
                       shown. By default, this is -1, which will expand all.
@@ -214,26 +488,77 @@ Options:
   -no-color           If specified, output won't contain any color.
 
   -out=path           Write a plan file to the given path. This can be used as
-                      input to the "apply" command.
+                      input to the "apply" command. The ID of the plan is
+                      shown in the output and can be given to "apply" with
+                      the "-plan-id" flag. If TF_PLAN_ENCRYPTION_KEY is
+                      set, the plan file is encrypted with it.
+
+  -out-format=text    The format to output the plan in. This is either "text"
+                      or "markdown", which renders the plan in a form that
+                      can be pasted into pull request comments.
 
   -parallelism=n      Limit the number of concurrent operations. Defaults to 10.
 
+  -quiet              Don't output which state file or remote state the plan
+                      uses.
+
   -refresh=true       Update state prior to checking for differences.
 
+  -refresh-only       Only show the changes that refreshing finds were made
+                      outside of Terraform, without planning any changes
+                      for the configuration. Applying such a plan only
+                      saves the refreshed state.
+
+  -refresh-skip=res   Resource address to leave out of the refresh. The
+                      resource is still planned using its current state.
+                      This flag can be used multiple times.
+
+  -replace=res        Resource address to replace: it is planned to be
+                      destroyed and created again, even if its
+                      configuration hasn't changed. It must be in the
+                      state. This flag can be used multiple times.
+
   -state=statefile    Path to a Terraform state file to use to look
                       up Terraform-managed resources. By default it will
                       use the state "terraform.tfstate" if it exists.
+                      If remote state is configured, this state file is
+                      used instead, read-only, and the plan can't be saved
+                      with -out.
+
+  -state-lock-file=path
+                      Path of the lock file of the local state. Defaults to
+                      a file next to the state, such as
+                      ".terraform.tfstate.lock.info". Every operation on
+                      the state must use the same lock file.
+
+  -stream             Output each resource as soon as its change is planned,
+                      instead of the whole plan once it is done. Only the
+                      summary is output at the end.
 
   -target=resource    Resource to target. Operation will be limited to this
                       resource and its dependencies. This flag can be used
                       multiple times.
 
+  -target-file=path   File with a resource to target on each line, in addition
+                      to those given with -target. Blank lines and comments
+                      starting with # are ignored. This flag can be used
+                      multiple times.
+
+  -timeout=0s         Duration the refresh and plan can run before they are
+                      stopped with an error. Zero means no limit.
+
   -var 'foo=bar'      Set a variable in the Terraform configuration. This
                       flag can be set multiple times.
 
   -var-file=foo       Set variables in the Terraform configuration from
-                      a file. If "terraform.tfvars" is present, it will be
-                      automatically loaded if this flag is not specified.
+                      a file. If "terraform.tfvars" or any ".auto.tfvars"
+                      files are present, they will be automatically loaded.
+                      A directory or glob pattern loads all the matching
+                      files, in lexical order.
+
+  -warnings-as-errors If set, the plan fails with an exit code of 1 if
+                      there are any warnings, such as for deprecated
+                      arguments. The plan is still output.
 `
 	return strings.TrimSpace(helpText)
 }
@@ -242,6 +567,52 @@ func (c *PlanCommand) Synopsis() string {
 	return "Generate and show an execution plan"
 }
 
+// writePlanFile writes the plan to the given path, encrypted with the key
+// if it isn't empty. The plan is written to a temporary file in the same
+// directory first and then renamed into place so that concurrent plans
+// writing to the same path can't interleave their output, and a partially
+// written plan file is never visible at the path.
+func writePlanFile(plan *terraform.Plan, path string, key []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+
+	if err := writePlan(plan, f, key); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	// Make sure the plan is on disk before anything goes on to use it,
+	// such as apply applying it right after writing it.
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// planTargetsNote returns the note output with the header of a plan that
+// is limited to the given targets, with how many there are once all the
+// -target and -target-file targets are merged. It is empty without targets.
+func planTargetsNote(targets []string) string {
+	targets = normalizeTargets(targets)
+	if len(targets) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"This plan is limited to %d target(s) and their dependencies.\n",
+		len(targets))
+}
+
 const planHeaderNoOutput = `
 The Terraform execution plan has been generated and is shown below.
 Resources are shown in alphabetical order for quick scanning. Green resources
@@ -253,6 +624,15 @@ Note: You didn't specify an "-out" parameter to save this plan, so when
 "apply" is called, Terraform can't guarantee this is what will execute.
 `
 
+const planHeaderStream = `
+Each resource is shown as soon as its change is planned, in the order that
+the changes are planned. Green resources will be created (or destroyed and
+then created if an existing resource exists), yellow resources are being
+changed in-place, and red resources will be destroyed. Cyan entries are data
+sources to be read. Use "terraform show" with a saved plan to see the
+changed attributes.
+`
+
 const planHeaderYesOutput = `
 The Terraform execution plan has been generated and is shown below.
 Resources are shown in alphabetical order for quick scanning. Green resources
@@ -265,4 +645,11 @@ with this plan file and Terraform will exactly execute this execution
 plan.
 
 Path: %s
+Plan ID: %s
+`
+
+const planRefreshOnlyFooter = `
+This is a refresh-only plan, so no changes to the infrastructure are
+planned and the configuration isn't compared. Applying this plan only
+saves the refreshed state, to record the changes above.
 `
@@ -0,0 +1,178 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// PlanEncryptionKeyEnvVar is the name of the environment variable that
+// can be used to give the passphrase that plan files are encrypted with.
+const PlanEncryptionKeyEnvVar = "TF_PLAN_ENCRYPTION_KEY"
+
+// An encrypted plan file starts with the magic bytes and the format
+// version, followed by the salt used to derive the key from the
+// passphrase, the nonce, and the plan encrypted with AES-GCM. The
+// header up to and including the nonce is authenticated as well.
+const planEncryptedMagic = "tfencplan"
+const planEncryptedVersion byte = 1
+
+const (
+	planEncryptedSaltSize   = 16
+	planEncryptedNonceSize  = 12
+	planEncryptedHeaderSize = len(planEncryptedMagic) + 1 +
+		planEncryptedSaltSize + planEncryptedNonceSize
+
+	// planEncryptedIterations is the number of PBKDF2 iterations used to
+	// derive the key from the passphrase.
+	planEncryptedIterations = 100000
+)
+
+// planDecryptError is the error returned by readPlan when a plan file is
+// encrypted but can't be decrypted. Unlike other errors reading a plan,
+// this means the file definitely is a plan.
+type planDecryptError struct {
+	msg string
+}
+
+func (e *planDecryptError) Error() string {
+	return e.msg
+}
+
+// planEncryptionKey returns the passphrase to encrypt and decrypt plan
+// files with. If it is empty, plan files aren't encrypted.
+func (m *Meta) planEncryptionKey() []byte {
+	if len(m.PlanEncryptionKey) > 0 {
+		return m.PlanEncryptionKey
+	}
+
+	return []byte(os.Getenv(PlanEncryptionKeyEnvVar))
+}
+
+// readPlan reads a plan that was written by writePlan. Plans that aren't
+// encrypted are read whether a key is given or not.
+func readPlan(src io.Reader, key []byte) (*terraform.Plan, error) {
+	r := bufio.NewReader(src)
+	magic, err := r.Peek(len(planEncryptedMagic))
+	if err != nil || string(magic) != planEncryptedMagic {
+		return terraform.ReadPlan(r)
+	}
+
+	if len(key) == 0 {
+		return nil, &planDecryptError{fmt.Sprintf(
+			"The plan file is encrypted. Please set the %s\n"+
+				"environment variable to the key the plan was created with.",
+			PlanEncryptionKeyEnvVar)}
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < planEncryptedHeaderSize {
+		return nil, &planDecryptError{"The encrypted plan file is truncated."}
+	}
+	if v := data[len(planEncryptedMagic)]; v != planEncryptedVersion {
+		return nil, &planDecryptError{fmt.Sprintf(
+			"The plan file is encrypted with an unknown format version: %d", v)}
+	}
+
+	header := data[:planEncryptedHeaderSize]
+	salt := header[len(planEncryptedMagic)+1 : len(header)-planEncryptedNonceSize]
+	nonce := header[len(header)-planEncryptedNonceSize:]
+
+	aead, err := planEncryptionAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	// GCM only returns the plaintext once all of it is authenticated, so
+	// nothing from a file with the wrong key or that was modified is used.
+	plaintext, err := aead.Open(nil, nonce, data[len(header):], header)
+	if err != nil {
+		return nil, &planDecryptError{
+			"Error decrypting the plan file. Either the encryption key is\n" +
+				"wrong, or the file was modified after it was created."}
+	}
+
+	return terraform.ReadPlan(bytes.NewReader(plaintext))
+}
+
+// writePlan writes the plan to dst, encrypted with the given key if the
+// key isn't empty.
+func writePlan(plan *terraform.Plan, dst io.Writer, key []byte) error {
+	if len(key) == 0 {
+		return terraform.WritePlan(plan, dst)
+	}
+
+	var buf bytes.Buffer
+	if err := terraform.WritePlan(plan, &buf); err != nil {
+		return err
+	}
+
+	header := make([]byte, planEncryptedHeaderSize)
+	copy(header, planEncryptedMagic)
+	header[len(planEncryptedMagic)] = planEncryptedVersion
+	if _, err := io.ReadFull(rand.Reader, header[len(planEncryptedMagic)+1:]); err != nil {
+		return fmt.Errorf("Error generating plan encryption salt: %s", err)
+	}
+	salt := header[len(planEncryptedMagic)+1 : len(header)-planEncryptedNonceSize]
+	nonce := header[len(header)-planEncryptedNonceSize:]
+
+	aead, err := planEncryptionAEAD(key, salt)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(aead.Seal(header, nonce, buf.Bytes(), header))
+	return err
+}
+
+// planEncryptionAEAD returns the AES-GCM cipher for the key derived from
+// the passphrase and salt.
+func planEncryptionAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key := planEncryptionDeriveKey(passphrase, salt, planEncryptedIterations)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// planEncryptionDeriveKey derives a 256-bit key from the passphrase with
+// PBKDF2-HMAC-SHA256. Since the key is exactly the size of the hash, only
+// the first block of PBKDF2 is needed.
+func planEncryptionDeriveKey(passphrase, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, passphrase)
+
+	var blockIndex [4]byte
+	binary.BigEndian.PutUint32(blockIndex[:], 1)
+	prf.Write(salt)
+	prf.Write(blockIndex[:])
+	u := prf.Sum(nil)
+
+	key := make([]byte, len(u))
+	copy(key, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+
+	return key
+}
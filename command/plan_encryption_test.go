@@ -0,0 +1,244 @@
+package command
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestPlanEncryptionDeriveKey(t *testing.T) {
+	// Test vectors for PBKDF2-HMAC-SHA256 from RFC 7914
+	cases := []struct {
+		Passphrase string
+		Salt       string
+		Iterations int
+		Expected   string
+	}{
+		{
+			"passwd", "salt", 1,
+			"55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc",
+		},
+		{
+			"Password", "NaCl", 80000,
+			"4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56",
+		},
+	}
+
+	for _, tc := range cases {
+		key := planEncryptionDeriveKey(
+			[]byte(tc.Passphrase), []byte(tc.Salt), tc.Iterations)
+		if actual := hex.EncodeToString(key); actual != tc.Expected {
+			t.Fatalf("%s: bad: %s", tc.Passphrase, actual)
+		}
+	}
+}
+
+func TestReadPlan_encrypted(t *testing.T) {
+	plan := &terraform.Plan{
+		Module: testModule(t, "apply"),
+		Vars: map[string]interface{}{
+			"password": "hunter2",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writePlan(plan, &buf, []byte("secret")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	data := buf.Bytes()
+	if bytes.Contains(data, []byte("hunter2")) {
+		t.Fatal("plan should be encrypted")
+	}
+
+	// The right key reads the plan
+	actual, err := readPlan(bytes.NewReader(data), []byte("secret"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual.Vars["password"] != "hunter2" {
+		t.Fatalf("bad: %#v", actual.Vars)
+	}
+
+	// A missing or wrong key, or a modified file, fails
+	modified := make([]byte, len(data))
+	copy(modified, data)
+	modified[len(modified)-1] ^= 1
+	cases := []struct {
+		Data []byte
+		Key  string
+		Err  string
+	}{
+		{data, "", PlanEncryptionKeyEnvVar},
+		{data, "wrong", "Error decrypting the plan file"},
+		{modified, "secret", "Error decrypting the plan file"},
+		{data[:planEncryptedHeaderSize-1], "secret", "truncated"},
+	}
+	for i, tc := range cases {
+		actual, err := readPlan(bytes.NewReader(tc.Data), []byte(tc.Key))
+		if _, ok := err.(*planDecryptError); !ok {
+			t.Fatalf("%d: bad error: %#v", i, err)
+		}
+		if !strings.Contains(err.Error(), tc.Err) {
+			t.Fatalf("%d: bad error: %s", i, err)
+		}
+		if actual != nil {
+			t.Fatalf("%d: plan should be nil", i)
+		}
+	}
+}
+
+func TestReadPlan_unencrypted(t *testing.T) {
+	plan := &terraform.Plan{Module: testModule(t, "apply")}
+
+	var buf bytes.Buffer
+	if err := writePlan(plan, &buf, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Plans that aren't encrypted are read even when a key is given
+	if _, err := readPlan(&buf, []byte("secret")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestPlan_encrypted(t *testing.T) {
+	outPath := testTempFile(t)
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts:       testCtxConfig(p),
+			Ui:                ui,
+			PlanEncryptionKey: []byte("secret"),
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	data, err := ioutil.ReadFile(outPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.HasPrefix(data, []byte(planEncryptedMagic)) {
+		t.Fatal("plan should be encrypted")
+	}
+	if bytes.Contains(data, []byte("test_instance")) {
+		t.Fatal("plan should be encrypted")
+	}
+
+	// Applying the plan uses the key from the environment
+	old := os.Getenv(PlanEncryptionKeyEnvVar)
+	defer os.Setenv(PlanEncryptionKeyEnvVar, old)
+	os.Setenv(PlanEncryptionKeyEnvVar, "secret")
+
+	ui = new(cli.MockUi)
+	apply := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args = []string{
+		"-state-out", testTempFile(t),
+		outPath,
+	}
+	if code := apply.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestApply_planEncryptedWrongKey(t *testing.T) {
+	planPath := testTempFile(t)
+	err := writePlanFile(&terraform.Plan{
+		Module: testModule(t, "apply"),
+	}, planPath, []byte("secret"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts:       testCtxConfig(p),
+			Ui:                ui,
+			PlanEncryptionKey: []byte("wrong"),
+		},
+	}
+
+	args := []string{
+		"-state", testTempFile(t),
+		planPath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "Error decrypting the plan file") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+}
+
+func TestShow_planEncryptedNoKey(t *testing.T) {
+	old := os.Getenv(PlanEncryptionKeyEnvVar)
+	defer os.Setenv(PlanEncryptionKeyEnvVar, old)
+	os.Setenv(PlanEncryptionKeyEnvVar, "")
+
+	planPath := testTempFile(t)
+	err := writePlanFile(&terraform.Plan{
+		Module: testModule(t, "apply"),
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"test_instance.foo": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+					},
+				},
+			},
+		},
+	}, planPath, []byte("secret"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &ShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	if code := c.Run([]string{planPath}); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "plan file is encrypted") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if strings.Contains(ui.OutputWriter.String(), "test_instance.foo") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
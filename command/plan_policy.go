@@ -0,0 +1,189 @@
+package command
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// PlanPolicy checks a plan before it is applied, such as to enforce the
+// rules of an organization. If it returns an error, the plan is rejected
+// and isn't applied.
+type PlanPolicy func(*terraform.Plan) error
+
+// PlanPolicyCommand returns a PlanPolicy that runs the given command with
+// the shell, with the plan written to its stdin as JSON. The plan is
+// rejected if the command exits with a non-zero status, and the error
+// includes whatever the command wrote to stderr.
+func PlanPolicyCommand(command string) PlanPolicy {
+	return func(p *terraform.Plan) error {
+		data, err := json.MarshalIndent(newPlanPolicyJSON(p), "", "    ")
+		if err != nil {
+			return fmt.Errorf("Error encoding plan for the policy command: %s", err)
+		}
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", command)
+		} else {
+			cmd = exec.Command("/bin/sh", "-c", command)
+		}
+
+		var stderr bytes.Buffer
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				return fmt.Errorf("Plan rejected by policy command %q: %s", command, err)
+			}
+
+			return fmt.Errorf("Plan rejected by policy command %q: %s\n\n%s",
+				command, err, msg)
+		}
+
+		return nil
+	}
+}
+
+// planPolicyJSON is the plan as it is given to policy commands. Variables
+// and the state aren't included since they often hold secrets, and the
+// values of sensitive attributes are hidden like when the plan is output.
+type planPolicyJSON struct {
+	TerraformVersion string              `json:"terraform_version"`
+	Targets          []string            `json:"targets"`
+	Changes          []*planPolicyChange `json:"changes"`
+
+	// ChangesByType are the numbers of changes for each resource type,
+	// counted like in the summary of the plan.
+	ChangesByType map[string]*planPolicyTypeCounts `json:"changes_by_type"`
+
+	// OutputChanges are the numbers of root module outputs that are added,
+	// changed and removed by the plan.
+	OutputChanges *planPolicyTypeCounts `json:"output_changes"`
+}
+
+// planPolicyChange is the change of a single resource in planPolicyJSON.
+// Action is one of "create", "read", "update", "destroy" and "replace".
+type planPolicyChange struct {
+	Module     []string                        `json:"module"`
+	Name       string                          `json:"name"`
+	Action     string                          `json:"action"`
+	Tainted    bool                            `json:"tainted"`
+	Replace    bool                            `json:"replace"`
+	Attributes map[string]*planPolicyAttribute `json:"attributes"`
+}
+
+type planPolicyTypeCounts struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+type planPolicyAttribute struct {
+	Old         string `json:"old"`
+	New         string `json:"new"`
+	NewComputed bool   `json:"new_computed"`
+	RequiresNew bool   `json:"requires_new"`
+	Sensitive   bool   `json:"sensitive"`
+}
+
+func newPlanPolicyJSON(p *terraform.Plan) *planPolicyJSON {
+	result := &planPolicyJSON{
+		TerraformVersion: p.TerraformVersion,
+		Targets:          p.Targets,
+		Changes:          make([]*planPolicyChange, 0),
+		ChangesByType:    make(map[string]*planPolicyTypeCounts),
+		OutputChanges:    new(planPolicyTypeCounts),
+	}
+	for _, c := range planOutputChanges(p) {
+		switch c.Action {
+		case "+":
+			result.OutputChanges.Add++
+		case "~":
+			result.OutputChanges.Change++
+		case "-":
+			result.OutputChanges.Destroy++
+		}
+	}
+	if p.Diff == nil {
+		return result
+	}
+
+	countHook := new(CountHook)
+	countHook.CountDiff(p.Diff)
+	for t, c := range countHook.ToByType {
+		result.ChangesByType[t] = &planPolicyTypeCounts{
+			Add:     c.ToAdd,
+			Change:  c.ToChange,
+			Destroy: c.ToRemove,
+		}
+	}
+
+	for _, m := range p.Diff.Modules {
+		names := make([]string, 0, len(m.Resources))
+		for name := range m.Resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			rdiff := m.Resources[name]
+			if rdiff.Empty() {
+				continue
+			}
+
+			change := &planPolicyChange{
+				Module:     m.Path,
+				Name:       name,
+				Action:     planPolicyAction(rdiff, strings.HasPrefix(name, "data.")),
+				Tainted:    rdiff.DestroyTainted,
+				Replace:    rdiff.Replace,
+				Attributes: make(map[string]*planPolicyAttribute),
+			}
+			for key, attrDiff := range rdiff.Attributes {
+				attr := &planPolicyAttribute{
+					Old:         attrDiff.Old,
+					New:         attrDiff.New,
+					NewComputed: attrDiff.NewComputed,
+					RequiresNew: attrDiff.RequiresNew,
+				}
+				if formatPlanSensitive(key, attrDiff, &FormatPlanOpts{}) {
+					attr.Old = "<sensitive>"
+					attr.New = "<sensitive>"
+					attr.Sensitive = true
+				}
+
+				change.Attributes[key] = attr
+			}
+
+			result.Changes = append(result.Changes, change)
+		}
+	}
+
+	return result
+}
+
+// planPolicyAction returns the name of the action for the given change.
+func planPolicyAction(rdiff *terraform.InstanceDiff, dataSource bool) string {
+	switch rdiff.ChangeType() {
+	case terraform.DiffDestroyCreate:
+		return "replace"
+	case terraform.DiffCreate:
+		if dataSource {
+			return "read"
+		}
+
+		return "create"
+	case terraform.DiffDestroy:
+		return "destroy"
+	}
+
+	return "update"
+}
@@ -0,0 +1,231 @@
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// testPlanPolicyNoDestroy is a policy command that rejects plans that
+// destroy anything.
+const testPlanPolicyNoDestroy = `if grep -q '"action": "destroy"'; then echo "destroying is not allowed" >&2; exit 1; fi`
+
+func testPlanPolicySkip(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("policy command tests use a POSIX shell")
+	}
+}
+
+func TestPlanPolicyCommand(t *testing.T) {
+	testPlanPolicySkip(t)
+
+	plan := &terraform.Plan{
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"test_instance.foo": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := PlanPolicyCommand(testPlanPolicyNoDestroy)(plan)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "destroying is not allowed") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if err := PlanPolicyCommand("cat > /dev/null")(plan); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestNewPlanPolicyJSON(t *testing.T) {
+	state := testState()
+	state.RootModule().Resources["test_instance.foo"].Primary.Attributes = map[string]string{
+		"id":       "bar",
+		"password": "swordfish",
+	}
+	plan := &terraform.Plan{
+		State: state,
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"test_instance.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami":      &terraform.ResourceAttrDiff{Old: "foo", New: "bar"},
+								"password": &terraform.ResourceAttrDiff{Old: "", New: "hunter2"},
+							},
+						},
+						"data.test_data_source.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": &terraform.ResourceAttrDiff{NewComputed: true, RequiresNew: true},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	data, err := json.Marshal(newPlanPolicyJSON(plan))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, v := range []string{"hunter2", "swordfish"} {
+		if strings.Contains(string(data), v) {
+			t.Fatalf("sensitive value in policy JSON: %s", data)
+		}
+	}
+
+	result := newPlanPolicyJSON(plan)
+	if len(result.Changes) != 2 {
+		t.Fatalf("bad: %#v", result.Changes)
+	}
+	if c := result.Changes[0]; c.Name != "data.test_data_source.foo" || c.Action != "read" {
+		t.Fatalf("bad: %#v", c)
+	}
+	if c := result.Changes[1]; c.Name != "test_instance.foo" || c.Action != "update" {
+		t.Fatalf("bad: %#v", c)
+	}
+
+	// Data sources aren't counted by type
+	expected := map[string]*planPolicyTypeCounts{
+		"test_instance": &planPolicyTypeCounts{Change: 1},
+	}
+	if !reflect.DeepEqual(result.ChangesByType, expected) {
+		t.Fatalf("bad: %#v", result.ChangesByType)
+	}
+
+	// Output changes are counted
+	plan.PriorOutputs = map[string]*terraform.OutputState{
+		"changed": &terraform.OutputState{Type: "string", Value: "foo"},
+		"removed": &terraform.OutputState{Type: "string", Value: "foo"},
+	}
+	plan.Outputs = map[string]*terraform.OutputState{
+		"changed": &terraform.OutputState{Type: "string", Value: "bar"},
+		"new":     &terraform.OutputState{Type: "string", Value: "bar"},
+		"removed": &terraform.OutputState{Type: "string", Value: "foo"},
+	}
+	plan.Module = testModule(t, "plan-outputs")
+	result = newPlanPolicyJSON(plan)
+	if c := result.OutputChanges; c.Add != 1 || c.Change != 1 || c.Destroy != 1 {
+		t.Fatalf("bad: %#v", c)
+	}
+}
+
+func TestApply_policyCommand(t *testing.T) {
+	testPlanPolicySkip(t)
+
+	statePath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// Creating is allowed by the policy
+	args := []string{
+		"-state", statePath,
+		"-policy-command", testPlanPolicyNoDestroy,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !p.ApplyCalled {
+		t.Fatal("apply should be called")
+	}
+}
+
+func TestApply_policyCommandDestroy(t *testing.T) {
+	testPlanPolicySkip(t)
+
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Destroy: true,
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-state", statePath,
+		"-policy-command", testPlanPolicyNoDestroy,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "destroying is not allowed") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// Nothing was destroyed
+	actual := testStateRead(t, statePath)
+	if actual.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestApply_planPolicy(t *testing.T) {
+	statePath := testTempFile(t)
+
+	var called *terraform.Plan
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			PlanPolicy: func(plan *terraform.Plan) error {
+				called = plan
+				return errors.New("rejected")
+			},
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("apply"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if called == nil || called.Diff.Empty() {
+		t.Fatalf("bad: %#v", called)
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "rejected") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
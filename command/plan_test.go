@@ -2,12 +2,20 @@ package command
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
@@ -82,26 +90,11 @@ func TestPlan_destroy(t *testing.T) {
 	}
 
 	outPath := testTempFile(t)
-	statePath := testStateFile(t, originalState)
 
 	p := testProvider()
-	ui := new(cli.MockUi)
-	c := &PlanCommand{
-		Meta: Meta{
-			ContextOpts: testCtxConfig(p),
-			Ui:          ui,
-		},
-	}
-
-	args := []string{
-		"-destroy",
-		"-out", outPath,
-		"-state", statePath,
-		testFixturePath("plan"),
-	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
-	}
+	h := testCommandHarness(t, p, originalState)
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan", "-destroy", "-out", outPath).
+		ExpectCode(0)
 
 	if !p.RefreshCalled {
 		t.Fatal("refresh should be called")
@@ -118,24 +111,9 @@ func TestPlan_destroy(t *testing.T) {
 }
 
 func TestPlan_noState(t *testing.T) {
-	tmp, cwd := testCwd(t)
-	defer testFixCwd(t, tmp, cwd)
-
 	p := testProvider()
-	ui := new(cli.MockUi)
-	c := &PlanCommand{
-		Meta: Meta{
-			ContextOpts: testCtxConfig(p),
-			Ui:          ui,
-		},
-	}
-
-	args := []string{
-		testFixturePath("plan"),
-	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
-	}
+	h := testCommandHarness(t, p, nil)
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan").ExpectCode(0)
 
 	// Verify that refresh was called
 	if p.RefreshCalled {
@@ -150,13 +128,9 @@ func TestPlan_noState(t *testing.T) {
 	}
 }
 
-func TestPlan_outPath(t *testing.T) {
-	tf, err := ioutil.TempFile("", "tf")
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	outPath := tf.Name()
-	os.Remove(tf.Name())
+func TestPlan_moduleMissing(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -167,53 +141,25 @@ func TestPlan_outPath(t *testing.T) {
 		},
 	}
 
-	p.DiffReturn = &terraform.InstanceDiff{
-		Destroy: true,
-	}
-
 	args := []string{
-		"-out", outPath,
-		testFixturePath("plan"),
+		testFixturePath("plan-module-missing"),
 	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
 	}
 
-	f, err := os.Open(outPath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	actual := ui.ErrorWriter.String()
+	if !strings.Contains(actual, "* module.child") {
+		t.Fatalf("missing module should be listed: %s", actual)
 	}
-	defer f.Close()
-
-	if _, err := terraform.ReadPlan(f); err != nil {
-		t.Fatalf("err: %s", err)
+	if !strings.Contains(actual, `Run "terraform get"`) {
+		t.Fatalf("get hint should be shown: %s", actual)
 	}
 }
 
-func TestPlan_outPathNoChange(t *testing.T) {
-	originalState := &terraform.State{
-		Modules: []*terraform.ModuleState{
-			&terraform.ModuleState{
-				Path: []string{"root"},
-				Resources: map[string]*terraform.ResourceState{
-					"test_instance.foo": &terraform.ResourceState{
-						Type: "test_instance",
-						Primary: &terraform.InstanceState{
-							ID: "bar",
-						},
-					},
-				},
-			},
-		},
-	}
-	statePath := testStateFile(t, originalState)
-
-	tf, err := ioutil.TempFile("", "tf")
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	outPath := tf.Name()
-	os.Remove(tf.Name())
+func TestPlan_moduleMissingGet(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -225,21 +171,22 @@ func TestPlan_outPathNoChange(t *testing.T) {
 	}
 
 	args := []string{
-		"-out", outPath,
-		"-state", statePath,
-		testFixturePath("plan"),
+		"-get=true",
+		testFixturePath("plan-module-missing"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	plan := testReadPlan(t, outPath)
-	if !plan.Diff.Empty() {
-		t.Fatalf("Expected empty plan to be written to plan file, got: %s", plan)
+	if _, err := os.Stat(filepath.Join(DefaultDataDir, "modules")); err != nil {
+		t.Fatalf("modules should be downloaded: %s", err)
 	}
 }
 
-func TestPlan_refresh(t *testing.T) {
+func TestPlan_requiredVersion(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
 	p := testProvider()
 	ui := new(cli.MockUi)
 	c := &PlanCommand{
@@ -250,33 +197,17 @@ func TestPlan_refresh(t *testing.T) {
 	}
 
 	args := []string{
-		"-refresh=false",
-		testFixturePath("plan"),
+		"-get=true",
+		testFixturePath("plan-required-version"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
-
-	if p.RefreshCalled {
-		t.Fatal("refresh should not be called")
-	}
 }
 
-func TestPlan_state(t *testing.T) {
-	// Write out some prior state
-	tf, err := ioutil.TempFile("", "tf")
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	statePath := tf.Name()
-	defer os.Remove(tf.Name())
-
-	originalState := testState()
-	err = terraform.WriteState(originalState, tf)
-	tf.Close()
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
+func TestPlan_requiredVersionBad(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -288,51 +219,39 @@ func TestPlan_state(t *testing.T) {
 	}
 
 	args := []string{
-		"-state", statePath,
-		testFixturePath("plan"),
-	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+		"-get=true",
+		testFixturePath("plan-required-version-bad"),
 	}
-
-	// Verify that the provider was called with the existing state
-	actual := strings.TrimSpace(p.DiffState.String())
-	expected := strings.TrimSpace(testPlanStateStr)
-	if actual != expected {
-		t.Fatalf("bad:\n\n%s", actual)
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
 	}
-}
-
-func TestPlan_stateDefault(t *testing.T) {
-	originalState := testState()
-
-	// Write the state file in a temporary directory with the
-	// default filename.
-	td, err := ioutil.TempDir("", "tf")
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	if p.DiffCalled {
+		t.Fatal("diff should not be called")
 	}
-	statePath := filepath.Join(td, DefaultStateFilename)
 
-	f, err := os.Create(statePath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	// The requirement of the child module is checked too, and the error
+	// names the file that declared it.
+	output := ui.ErrorWriter.String()
+	expected := []string{
+		"Module: module.child\n",
+		"Required version: >= 99.0.0\n",
+		"Current version: " + terraform.SemVersion.String() + "\n",
+		string(filepath.Separator) + "versions.tf",
 	}
-	err = terraform.WriteState(originalState, f)
-	f.Close()
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	for _, v := range expected {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
 	}
+}
 
-	// Change to that directory
-	cwd, err := os.Getwd()
+func TestPlan_outPath(t *testing.T) {
+	tf, err := ioutil.TempFile("", "tf")
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	if err := os.Chdir(filepath.Dir(statePath)); err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	defer os.Chdir(cwd)
+	outPath := tf.Name()
+	os.Remove(tf.Name())
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -343,25 +262,31 @@ func TestPlan_stateDefault(t *testing.T) {
 		},
 	}
 
+	p.DiffReturn = &terraform.InstanceDiff{
+		Destroy: true,
+	}
+
 	args := []string{
+		"-out", outPath,
 		testFixturePath("plan"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	// Verify that the provider was called with the existing state
-	actual := strings.TrimSpace(p.DiffState.String())
-	expected := strings.TrimSpace(testPlanStateDefaultStr)
-	if actual != expected {
-		t.Fatalf("bad:\n\n%s", actual)
+	f, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	if _, err := terraform.ReadPlan(f); err != nil {
+		t.Fatalf("err: %s", err)
 	}
 }
 
-func TestPlan_stateFuture(t *testing.T) {
-	originalState := testState()
-	originalState.TFVersion = "99.99.99"
-	statePath := testStateFile(t, originalState)
+func TestPlan_maxDiff(t *testing.T) {
+	outPath := testTempFile(t)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -372,37 +297,44 @@ func TestPlan_stateFuture(t *testing.T) {
 		},
 	}
 
+	p.DiffReturn = &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": &terraform.ResourceAttrDiff{
+				New:         "bar",
+				RequiresNew: true,
+			},
+		},
+	}
+
 	args := []string{
-		"-state", statePath,
-		testFixturePath("plan"),
+		"-max-diff", "2",
+		"-out", outPath,
+		testFixturePath("plan-max-diff"),
 	}
-	if code := c.Run(args); code == 0 {
-		t.Fatal("should fail")
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	f, err := os.Open(statePath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	output := ui.OutputWriter.String()
+	if n := strings.Count(output, "+ test_instance.foo."); n != 2 {
+		t.Fatalf("bad: %d resources shown:\n\n%s", n, output)
 	}
-
-	newState, err := terraform.ReadState(f)
-	f.Close()
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	if !strings.Contains(output, "... and 8 more resource(s) to add") {
+		t.Fatalf("bad:\n\n%s", output)
 	}
-
-	if !newState.Equal(originalState) {
-		t.Fatalf("bad: %#v", newState)
+	if !strings.Contains(output, "10 to add, 0 to change, 0 to destroy") {
+		t.Fatalf("bad:\n\n%s", output)
 	}
-	if newState.TFVersion != originalState.TFVersion {
-		t.Fatalf("bad: %#v", newState)
+
+	// The plan file has all the resources
+	plan := testReadPlan(t, outPath)
+	if n := len(plan.Diff.RootModule().Resources); n != 10 {
+		t.Fatalf("bad: %d resources in plan", n)
 	}
 }
 
-func TestPlan_statePast(t *testing.T) {
-	originalState := testState()
-	originalState.TFVersion = "0.1.0"
-	statePath := testStateFile(t, originalState)
+func TestPlan_filter(t *testing.T) {
+	outPath := testTempFile(t)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -413,29 +345,46 @@ func TestPlan_statePast(t *testing.T) {
 		},
 	}
 
+	p.DiffReturn = &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": &terraform.ResourceAttrDiff{
+				New:         "bar",
+				RequiresNew: true,
+			},
+		},
+	}
+
 	args := []string{
-		"-state", statePath,
-		testFixturePath("plan"),
+		"-filter", "test_vol*",
+		"-out", outPath,
+		testFixturePath("plan-type-summary"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
-}
-
-func TestPlan_validate(t *testing.T) {
-	// This is triggered by not asking for input so we have to set this to false
-	test = false
-	defer func() { test = true }()
 
-	cwd, err := os.Getwd()
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	output := ui.OutputWriter.String()
+	if n := strings.Count(output, "+ test_volume.bar."); n != 2 {
+		t.Fatalf("bad: %d volumes shown:\n\n%s", n, output)
 	}
-	if err := os.Chdir(testFixturePath("plan-invalid")); err != nil {
-		t.Fatalf("err: %s", err)
+	if strings.Contains(output, "+ test_instance.") {
+		t.Fatalf("bad:\n\n%s", output)
 	}
-	defer os.Chdir(cwd)
+	if !strings.Contains(output, "4 resource(s) hidden by filter") {
+		t.Fatalf("bad:\n\n%s", output)
+	}
+	if !strings.Contains(output, "6 to add, 0 to change, 0 to destroy") {
+		t.Fatalf("bad:\n\n%s", output)
+	}
+
+	// The plan file has all the resources
+	plan := testReadPlan(t, outPath)
+	if n := len(plan.Diff.RootModule().Resources); n != 6 {
+		t.Fatalf("bad: %d resources in plan", n)
+	}
+}
 
+func TestPlan_filterInvalid(t *testing.T) {
 	p := testProvider()
 	ui := new(cli.MockUi)
 	c := &PlanCommand{
@@ -445,18 +394,24 @@ func TestPlan_validate(t *testing.T) {
 		},
 	}
 
-	args := []string{}
+	args := []string{
+		"-filter", "test_[",
+		testFixturePath("plan"),
+	}
 	if code := c.Run(args); code != 1 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
 	}
-
-	actual := ui.ErrorWriter.String()
-	if !strings.Contains(actual, "can't reference") {
-		t.Fatalf("bad: %s", actual)
+	if !strings.Contains(ui.ErrorWriter.String(), "Invalid -filter pattern") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if p.DiffCalled {
+		t.Fatal("diff should not be called")
 	}
 }
 
-func TestPlan_vars(t *testing.T) {
+func TestPlan_version(t *testing.T) {
+	outPath := testTempFile(t)
+
 	p := testProvider()
 	ui := new(cli.MockUi)
 	c := &PlanCommand{
@@ -466,39 +421,41 @@ func TestPlan_vars(t *testing.T) {
 		},
 	}
 
-	actual := ""
-	p.DiffFn = func(
-		info *terraform.InstanceInfo,
-		s *terraform.InstanceState,
-		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
-		if v, ok := c.Config["value"]; ok {
-			actual = v.(string)
-		}
-
-		return nil, nil
+	p.DiffReturn = &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": &terraform.ResourceAttrDiff{
+				New:         "bar",
+				RequiresNew: true,
+			},
+		},
 	}
 
 	args := []string{
-		"-var", "foo=bar",
-		testFixturePath("plan-vars"),
+		"-out", outPath,
+		testFixturePath("plan"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	if actual != "bar" {
-		t.Fatal("didn't work")
+	expected := fmt.Sprintf("Generated by Terraform v%s", terraform.VersionString())
+	if output := ui.OutputWriter.String(); !strings.Contains(output, expected) {
+		t.Fatalf("bad:\n\n%s", output)
 	}
-}
 
-func TestPlan_varsUnset(t *testing.T) {
-	// Disable test mode so input would be asked
-	test = false
-	defer func() { test = true }()
+	plan := testReadPlan(t, outPath)
+	if plan.TerraformVersion != terraform.VersionString() {
+		t.Fatalf("bad: %q", plan.TerraformVersion)
+	}
+}
 
-	defaultInputReader = bytes.NewBufferString("bar\n")
+func TestPlan_tainted(t *testing.T) {
+	s := testState()
+	s.RootModule().Resources["test_instance.foo"].Primary.Tainted = true
+	statePath := testStateFile(t, s)
 
 	p := testProvider()
+	p.DiffReturn = &terraform.InstanceDiff{DestroyTainted: true}
 	ui := new(cli.MockUi)
 	c := &PlanCommand{
 		Meta: Meta{
@@ -508,68 +465,126 @@ func TestPlan_varsUnset(t *testing.T) {
 	}
 
 	args := []string{
-		testFixturePath("plan-vars"),
+		"-state", statePath,
+		testFixturePath("plan"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
-}
 
-func TestPlan_varFile(t *testing.T) {
-	varFilePath := testTempFile(t)
-	if err := ioutil.WriteFile(varFilePath, []byte(planVarFile), 0644); err != nil {
-		t.Fatalf("err: %s", err)
+	output := ui.OutputWriter.String()
+	for _, expected := range []string{
+		"-/+ test_instance.foo (tainted, forces replacement)",
+		"1 to add, 0 to change, 1 to destroy (1 tainted).",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("bad: expected %q in:\n\n%s", expected, output)
+		}
 	}
+}
+
+func TestPlan_logWriter(t *testing.T) {
+	outPath := testTempFile(t)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
+	logs := new(bytes.Buffer)
 	c := &PlanCommand{
 		Meta: Meta{
 			ContextOpts: testCtxConfig(p),
 			Ui:          ui,
+			LogWriter:   logs,
 		},
 	}
 
-	actual := ""
-	p.DiffFn = func(
-		info *terraform.InstanceInfo,
-		s *terraform.InstanceState,
-		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
-		if v, ok := c.Config["value"]; ok {
-			actual = v.(string)
-		}
+	args := []string{
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
 
-		return nil, nil
+	if !strings.Contains(logs.String(), "[INFO] Writing plan ") {
+		t.Fatalf("bad: %s", logs.String())
+	}
+
+	// Nothing is captured once the command is done
+	logs.Reset()
+	log.Printf("[INFO] done")
+	if logs.Len() > 0 {
+		t.Fatalf("bad: %s", logs.String())
+	}
+}
+
+func TestPlan_outPathPlanId(t *testing.T) {
+	outPath := testTempFile(t)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	p.DiffReturn = &terraform.InstanceDiff{
+		Destroy: true,
 	}
 
 	args := []string{
-		"-var-file", varFilePath,
-		testFixturePath("plan-vars"),
+		"-out", outPath,
+		testFixturePath("plan"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	if actual != "bar" {
-		t.Fatal("didn't work")
+	id, err := testReadPlan(t, outPath).Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
 	}
-}
 
-func TestPlan_varFileDefault(t *testing.T) {
-	varFileDir := testTempDir(t)
-	varFilePath := filepath.Join(varFileDir, "terraform.tfvars")
-	if err := ioutil.WriteFile(varFilePath, []byte(planVarFile), 0644); err != nil {
-		t.Fatalf("err: %s", err)
+	actual := ui.OutputWriter.String()
+	if !strings.Contains(actual, "Plan ID: "+id) {
+		t.Fatalf("plan ID %s should be in output:\n\n%s", id, actual)
 	}
 
-	cwd, err := os.Getwd()
+	// The temporary file used to write the plan should be gone
+	infos, err := ioutil.ReadDir(filepath.Dir(outPath))
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	if err := os.Chdir(varFileDir); err != nil {
+	if len(infos) != 1 {
+		t.Fatalf("only the plan file should exist: %#v", infos)
+	}
+}
+
+func TestPlan_outPathNoChange(t *testing.T) {
+	originalState := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+						},
+					},
+				},
+			},
+		},
+	}
+	statePath := testStateFile(t, originalState)
+
+	tf, err := ioutil.TempFile("", "tf")
+	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	defer os.Chdir(cwd)
+	outPath := tf.Name()
+	os.Remove(tf.Name())
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -580,39 +595,59 @@ func TestPlan_varFileDefault(t *testing.T) {
 		},
 	}
 
-	actual := ""
-	p.DiffFn = func(
-		info *terraform.InstanceInfo,
-		s *terraform.InstanceState,
-		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
-		if v, ok := c.Config["value"]; ok {
-			actual = v.(string)
-		}
+	args := []string{
+		"-out", outPath,
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
 
-		return nil, nil
+	plan := testReadPlan(t, outPath)
+	if !plan.Diff.Empty() {
+		t.Fatalf("Expected empty plan to be written to plan file, got: %s", plan)
+	}
+}
+
+func TestPlan_refresh(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
 	}
 
 	args := []string{
-		testFixturePath("plan-vars"),
+		"-refresh=false",
+		testFixturePath("plan"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	if actual != "bar" {
-		t.Fatal("didn't work")
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
 	}
 }
 
-func TestPlan_detailedExitcode(t *testing.T) {
-	cwd, err := os.Getwd()
+func TestPlan_lockTimeout(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	// Hold the lock until the plan has had to wait for it
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	if err := os.Chdir(testFixturePath("plan")); err != nil {
-		t.Fatalf("err: %s", err)
-	}
-	defer os.Chdir(cwd)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		if err := ls.Unlock(id); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -623,21 +658,89 @@ func TestPlan_detailedExitcode(t *testing.T) {
 		},
 	}
 
-	args := []string{"-detailed-exitcode"}
-	if code := c.Run(args); code != 2 {
+	args := []string{
+		"-state", statePath,
+		"-lock-timeout", "10s",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
+
+	if !strings.Contains(ui.OutputWriter.String(), "Waiting for state lock...") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	// The plan must release the lock when it is done
+	id, err = ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ls.Unlock(id)
 }
 
-func TestPlan_detailedExitcode_emptyDiff(t *testing.T) {
-	cwd, err := os.Getwd()
+func TestPlan_lockTimeoutExpired(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ls := &state.LocalState{Path: statePath}
+	info := state.NewLockInfo()
+	info.Operation = "apply"
+	id, err := ls.Lock(info)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	if err := os.Chdir(testFixturePath("plan-emptydiff")); err != nil {
+	defer ls.Unlock(id)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-lock-timeout", "100ms",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	expected := []string{
+		"Error locking state",
+		id,
+		"Operation: apply",
+		info.Hostname,
+	}
+	for _, v := range expected {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
+	}
+	if p.DiffCalled {
+		t.Fatal("diff should not be called")
+	}
+}
+
+func TestPlan_lockTakeover(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	// A lock left behind by a process on this host that has since exited
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	info := state.NewLockInfo()
+	info.Operation = "apply"
+	info.Pid = cmd.Process.Pid
+	info.Created = time.Now().Add(-time.Hour)
+	if _, err := (&state.LocalState{Path: statePath}).Lock(info); err != nil {
 		t.Fatalf("err: %s", err)
 	}
-	defer os.Chdir(cwd)
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -648,26 +751,2004 @@ func TestPlan_detailedExitcode_emptyDiff(t *testing.T) {
 		},
 	}
 
-	args := []string{"-detailed-exitcode"}
+	args := []string{
+		"-state", statePath,
+		"-lock-takeover", "1m",
+		testFixturePath("plan"),
+	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "Took over the stale state lock "+info.ID) {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// The lock is released once the plan is done
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ls.Unlock(id)
 }
 
-const planVarFile = `
-foo = "bar"
-`
+func TestPlan_stateLockFile(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	lockPath := filepath.Join(testTempDir(t), "state.lock")
 
-const testPlanNoStateStr = `
-<not created>
-`
+	// The plan locks the state with the lock file given
+	var locked bool
+	p := testProvider()
+	p.DiffFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		_, err := os.Stat(lockPath)
+		locked = err == nil
+		return nil, nil
+	}
 
-const testPlanStateStr = `
-ID = bar
-Tainted = false
-`
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
 
-const testPlanStateDefaultStr = `
-ID = bar
-Tainted = false
-`
+	args := []string{
+		"-state", statePath,
+		"-state-lock-file", lockPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !locked {
+		t.Fatal("state should be locked with the lock file")
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("lock should be released: %v", err)
+	}
+
+	// A lock with the same lock file keeps the plan from running
+	ls := &state.LocalState{Path: statePath, LockPath: lockPath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	ui = new(cli.MockUi)
+	c = &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if output := ui.ErrorWriter.String(); !strings.Contains(output, id) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_stateLockFileMismatch(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	lockPath := filepath.Join(testTempDir(t), "state.lock")
+
+	// Another process holds a lock on the state with another lock file
+	ls := &state.LocalState{Path: statePath, LockPath: lockPath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "is also locked with the lock file "+lockPath) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_lockOperationID(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	dir, file := filepath.Split(statePath)
+	lockPath := filepath.Join(dir, "."+file+".lock.info")
+
+	// Read the lock held by the plan while it is running
+	var info state.LockInfo
+	p := testProvider()
+	p.DiffFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		data, err := ioutil.ReadFile(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	var logs bytes.Buffer
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			LogWriter:   &logs,
+			OperationID: "foo-123",
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if info.OperationID != "foo-123" || info.Operation != "plan" {
+		t.Fatalf("bad: %#v", info)
+	}
+	if !strings.Contains(logs.String(), "operation with ID foo-123") {
+		t.Fatalf("bad: %s", logs.String())
+	}
+}
+
+func TestPlan_operationSource(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	dir, file := filepath.Split(statePath)
+	lockPath := filepath.Join(dir, "."+file+".lock.info")
+
+	// Read the lock held by the plan while it is running
+	var info state.LockInfo
+	p := testProvider()
+	p.DiffFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		data, err := ioutil.ReadFile(lockPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &info); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	var logs bytes.Buffer
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts:          testCtxConfig(p),
+			Ui:                   ui,
+			LogWriter:            &logs,
+			OperationID:          "foo-123",
+			OperationSource:      "CI job 1234",
+			OperationAnnotations: map[string]string{"user": "alice"},
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if info.Source != "CI job 1234" ||
+		!reflect.DeepEqual(info.Annotations, map[string]string{"user": "alice"}) {
+		t.Fatalf("bad: %#v", info)
+	}
+	for _, v := range []string{
+		"Operation foo-123 source: CI job 1234",
+		"Operation foo-123 annotation: user=alice",
+	} {
+		if !strings.Contains(logs.String(), v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, logs.String())
+		}
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Source: CI job 1234\n") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestPlan_hookPanic(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ctxOpts := testCtxConfig(p)
+	ctxOpts.Hooks = []terraform.Hook{new(testPanicHook)}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: ctxOpts,
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "captured panic: PostDiff panic") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// The state lock must have been released
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ls.Unlock(id)
+}
+
+func TestPlan_outFormatMarkdown(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-out-format", "markdown",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "```diff\n+ test_instance.foo\n") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_outFormatInvalid(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-out-format", "html",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+}
+
+func TestPlan_state(t *testing.T) {
+	// Write out some prior state
+	tf, err := ioutil.TempFile("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	statePath := tf.Name()
+	defer os.Remove(tf.Name())
+
+	originalState := testState()
+	err = terraform.WriteState(originalState, tf)
+	tf.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// Verify that the provider was called with the existing state
+	actual := strings.TrimSpace(p.DiffState.String())
+	expected := strings.TrimSpace(testPlanStateStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestPlan_stateDefault(t *testing.T) {
+	originalState := testState()
+
+	// Write the state file in a temporary directory with the
+	// default filename.
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	statePath := filepath.Join(td, DefaultStateFilename)
+
+	f, err := os.Create(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = terraform.WriteState(originalState, f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Change to that directory
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(filepath.Dir(statePath)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// Verify that the provider was called with the existing state
+	actual := strings.TrimSpace(p.DiffState.String())
+	expected := strings.TrimSpace(testPlanStateDefaultStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestPlan_stateFuture(t *testing.T) {
+	originalState := testState()
+	originalState.TFVersion = "99.99.99"
+	statePath := testStateFile(t, originalState)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code == 0 {
+		t.Fatal("should fail")
+	}
+
+	f, err := os.Open(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	newState, err := terraform.ReadState(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !newState.Equal(originalState) {
+		t.Fatalf("bad: %#v", newState)
+	}
+	if newState.TFVersion != originalState.TFVersion {
+		t.Fatalf("bad: %#v", newState)
+	}
+}
+
+func TestPlan_statePast(t *testing.T) {
+	originalState := testState()
+	originalState.TFVersion = "0.1.0"
+	statePath := testStateFile(t, originalState)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_validate(t *testing.T) {
+	// This is triggered by not asking for input so we have to set this to false
+	test = false
+	defer func() { test = true }()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(testFixturePath("plan-invalid")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := ui.ErrorWriter.String()
+	if !strings.Contains(actual, "can't reference") {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestPlan_providerAliasBad(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("plan-provider-alias-bad"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if p.DiffCalled {
+		t.Fatal("diff should not be called")
+	}
+
+	actual := ui.ErrorWriter.String()
+	expected := "provider alias must be defined by the module or a parent: test.west (used by test_instance.foo)"
+	if !strings.Contains(actual, expected) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestPlan_validateWarnings(t *testing.T) {
+	p := testProvider()
+	p.ValidateResourceReturnWarns = []string{"argument is deprecated"}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if !p.DiffCalled {
+		t.Fatal("diff should be called")
+	}
+
+	actual := ui.ErrorWriter.String()
+	if !strings.Contains(actual, "Warnings (1):") {
+		t.Fatalf("bad: %s", actual)
+	}
+	if n := strings.Count(actual, "argument is deprecated"); n != 1 {
+		t.Fatalf("warning should be output once, got %d:\n\n%s", n, actual)
+	}
+}
+
+func TestPlan_refreshSkip(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-refresh-skip", "test_instance.foo",
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
+	}
+	if !p.DiffCalled {
+		t.Fatal("diff should be called")
+	}
+}
+
+func TestPlan_refreshSkipInvalid(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-refresh-skip", "data.test_data_source.foo",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "is a data source") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_noResourcesSkipProviderConfig(t *testing.T) {
+	p := testProvider()
+	p.ConfigureReturnError = fmt.Errorf("no credentials")
+
+	// Without a refresh, planning to destroy everything doesn't need the
+	// provider to be configured
+	h := testCommandHarness(t, p, testState())
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan-no-resources", "-refresh=false").
+		ExpectCode(0).
+		ExpectOutput("- test_instance.foo")
+	if p.ConfigureCalled {
+		t.Fatal("configure should not be called")
+	}
+
+	// The refresh needs it
+	h = testCommandHarness(t, p, testState())
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan-no-resources").
+		ExpectCode(1).
+		ExpectError("no credentials")
+}
+
+func TestPlan_replace(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	outPath := filepath.Join(testTempDir(t), "plan")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-replace", "test_instance.foo",
+		"-no-color",
+		"-state", statePath,
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "-/+ test_instance.foo (requested replacement)") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// Applying the plan replaces the resource
+	p = testProvider()
+	p.ApplyFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		d *terraform.InstanceDiff) (*terraform.InstanceState, error) {
+		if d.Destroy {
+			return nil, nil
+		}
+		return &terraform.InstanceState{ID: "baz"}, nil
+	}
+	ui = new(cli.MockUi)
+	apply := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state-out", statePath,
+		outPath,
+	}
+	if code := apply.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	state := testStateRead(t, statePath)
+	if id := state.RootModule().Resources["test_instance.foo"].Primary.ID; id != "baz" {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
+func TestPlan_replaceInvalid(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	cases := []struct {
+		Args []string
+		Err  string
+	}{
+		{
+			[]string{"-replace", "test_instance.nope"},
+			"isn't in the state",
+		},
+		{
+			[]string{"-replace", "test_instance.foo", "-target", "test_instance.bar"},
+			"isn't included in the targets",
+		},
+		{
+			[]string{"-replace", "test_instance.foo", "-destroy"},
+			"-destroy or -refresh-only",
+		},
+	}
+
+	for _, tc := range cases {
+		ui := new(cli.MockUi)
+		c := &PlanCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+
+		args := append(tc.Args, "-state", statePath, testFixturePath("plan"))
+		if code := c.Run(args); code != 1 {
+			t.Fatalf("%v: bad: %d\n\n%s", tc.Args, code, ui.OutputWriter.String())
+		}
+		if !strings.Contains(ui.ErrorWriter.String(), tc.Err) {
+			t.Fatalf("%v: bad: %s", tc.Args, ui.ErrorWriter.String())
+		}
+	}
+}
+
+func TestPlan_warningsAsErrors(t *testing.T) {
+	p := testProvider()
+	p.ValidateResourceReturnWarns = []string{"argument is deprecated"}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-warnings-as-errors",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The plan is still made and output
+	if !p.DiffCalled {
+		t.Fatal("diff should be called")
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "test_instance.foo") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	actual := ui.ErrorWriter.String()
+	if !strings.Contains(actual, "argument is deprecated") {
+		t.Fatalf("bad: %s", actual)
+	}
+	if !strings.Contains(actual, "1 warning(s) treated as errors") {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestPlan_warningsAsErrorsNoWarnings(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-warnings-as-errors",
+		"-detailed-exitcode",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 2 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_compactWarnings(t *testing.T) {
+	for _, compact := range []bool{true, false} {
+		p := testProvider()
+		p.ValidateResourceReturnWarns = []string{"argument is deprecated"}
+
+		h := testCommandHarness(t, p, nil)
+		c := &PlanCommand{Meta: h.Meta}
+		h.Run(c, "apply-continue", fmt.Sprintf("-compact-warnings=%t", compact)).
+			ExpectCode(0).
+			ExpectError("Warnings (4):")
+
+		output := h.Ui.ErrorWriter.String()
+		expected := 4
+		if compact {
+			expected = 1
+			h.ExpectError("(and 3 more similar warnings)")
+		}
+		if n := strings.Count(output, "argument is deprecated"); n != expected {
+			t.Fatalf("%t: bad: %s", compact, output)
+		}
+
+		// The full list of warnings is always available
+		if ws := c.Warnings(); len(ws) != 4 {
+			t.Fatalf("%t: bad: %#v", compact, ws)
+		}
+	}
+}
+
+func TestPlan_vars(t *testing.T) {
+	p := testProvider()
+	actual := ""
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		if v, ok := c.Config["value"]; ok {
+			actual = v.(string)
+		}
+
+		return nil, nil
+	}
+
+	h := testCommandHarness(t, p, nil)
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan-vars", "-var", "foo=bar").
+		ExpectCode(0)
+
+	if actual != "bar" {
+		t.Fatal("didn't work")
+	}
+}
+
+func TestPlan_varsOut(t *testing.T) {
+	outPath := testTempFile(t)
+
+	h := testCommandHarness(t, testProvider(), nil)
+	h.Run(&PlanCommand{Meta: h.Meta}, "plan-vars",
+		"-var", "foo=bar", "-out", outPath).
+		ExpectCode(0)
+
+	// The variables are saved in the plan for applying it
+	plan := testReadPlan(t, outPath)
+	if v := plan.Vars["foo"]; v != "bar" {
+		t.Fatalf("bad: %#v", plan.Vars)
+	}
+}
+
+func TestPlan_varsComplex(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	actual := make(map[string]interface{})
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		for k, v := range c.Config {
+			actual[k] = v
+		}
+
+		return nil, nil
+	}
+
+	args := []string{
+		"-var", `subnets=["a", "b"]`,
+		"-var", `tags={ nested = { key = "value" } }`,
+		"-var", "name=foo[0]",
+		testFixturePath("plan-vars-complex"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := map[string]interface{}{
+		"subnets": "a,b",
+		"tag":     "value",
+		"name":    "foo[0]",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestPlan_varsMalformed(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-var", `subnets=["a", "b"`,
+		testFixturePath("plan-vars-complex"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+}
+
+func TestPlan_varsUnset(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	defaultInputReader = bytes.NewBufferString("bar\n")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("plan-vars"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_varFile(t *testing.T) {
+	varFilePath := testTempFile(t)
+	if err := ioutil.WriteFile(varFilePath, []byte(planVarFile), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	actual := ""
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		if v, ok := c.Config["value"]; ok {
+			actual = v.(string)
+		}
+
+		return nil, nil
+	}
+
+	args := []string{
+		"-var-file", varFilePath,
+		testFixturePath("plan-vars"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if actual != "bar" {
+		t.Fatal("didn't work")
+	}
+}
+
+func TestPlan_varFileDefault(t *testing.T) {
+	varFileDir := testTempDir(t)
+	varFilePath := filepath.Join(varFileDir, "terraform.tfvars")
+	if err := ioutil.WriteFile(varFilePath, []byte(planVarFile), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(varFileDir); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	actual := ""
+	p.DiffFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState,
+		c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		if v, ok := c.Config["value"]; ok {
+			actual = v.(string)
+		}
+
+		return nil, nil
+	}
+
+	args := []string{
+		testFixturePath("plan-vars"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if actual != "bar" {
+		t.Fatal("didn't work")
+	}
+}
+
+func TestPlan_varsEnv(t *testing.T) {
+	varFilePath := testTempFile(t)
+	if err := ioutil.WriteFile(varFilePath, []byte(planVarFile), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	defer os.Unsetenv("TF_VAR_foo")
+	os.Setenv("TF_VAR_foo", "env")
+
+	// The environment has the lowest precedence, below -var-file and -var
+	cases := []struct {
+		Args     []string
+		Expected string
+	}{
+		{nil, "env"},
+		{[]string{"-var-file", varFilePath}, "bar"},
+		{[]string{"-var-file", varFilePath, "-var", "foo=flag"}, "flag"},
+	}
+
+	for i, tc := range cases {
+		p := testProvider()
+		ui := new(cli.MockUi)
+		c := &PlanCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+
+		actual := ""
+		p.DiffFn = func(
+			info *terraform.InstanceInfo,
+			s *terraform.InstanceState,
+			c *terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+			if v, ok := c.Config["value"]; ok {
+				actual = v.(string)
+			}
+
+			return nil, nil
+		}
+
+		args := append(tc.Args, testFixturePath("plan-vars"))
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("%d: bad: %d\n\n%s", i, code, ui.ErrorWriter.String())
+		}
+
+		if actual != tc.Expected {
+			t.Fatalf("%d: bad: %q", i, actual)
+		}
+	}
+}
+
+func TestPlan_detailedExitcode(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(testFixturePath("plan")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-detailed-exitcode"}
+	if code := c.Run(args); code != 2 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_detailedExitcode_emptyDiff(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := os.Chdir(testFixturePath("plan-emptydiff")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Chdir(cwd)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{"-detailed-exitcode"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_emptyDir(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", testTempFile(t),
+		dir,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "No configuration files found in "+dir) {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "-allow-empty") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_dirNotExist(t *testing.T) {
+	dir := filepath.Join(testFixturePath("plan"), "typo")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", testTempFile(t),
+		dir,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, dir+" doesn't exist") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_allowEmpty(t *testing.T) {
+	dir := tempDir(t)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	outPath := testTempFile(t)
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-allow-empty",
+		"-out", outPath,
+		"-state", statePath,
+		dir,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// Everything in the state is destroyed by an empty configuration
+	plan := testReadPlan(t, outPath)
+	rdiff := plan.Diff.RootModule().Resources["test_instance.foo"]
+	if rdiff == nil || !rdiff.Destroy {
+		t.Fatalf("bad: %s", plan.Diff)
+	}
+}
+
+const planVarFile = `
+foo = "bar"
+`
+
+const testPlanNoStateStr = `
+<not created>
+`
+
+const testPlanStateStr = `
+ID = bar
+Tainted = false
+`
+
+const testPlanStateDefaultStr = `
+ID = bar
+Tainted = false
+`
+
+// testPanicHook is a hook that panics in PostDiff.
+type testPanicHook struct {
+	terraform.NilHook
+}
+
+func (*testPanicHook) PostDiff(
+	*terraform.InstanceInfo, *terraform.InstanceDiff) (terraform.HookAction, error) {
+	panic("PostDiff panic")
+}
+
+func TestPlan_targetsNormalized(t *testing.T) {
+	plan := func(targets ...string) (*terraform.Plan, string) {
+		outPath := testTempFile(t)
+
+		p := testProvider()
+		ui := new(cli.MockUi)
+		c := &PlanCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+
+		args := []string{"-out", outPath, "-no-color"}
+		for _, target := range targets {
+			args = append(args, "-target", target)
+		}
+		args = append(args, testFixturePath("apply-continue"))
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+		}
+
+		// The plan path and ID are different each time
+		result := testReadPlan(t, outPath)
+		id, err := result.Id()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		output := ui.OutputWriter.String()
+		output = strings.Replace(output, outPath, "PATH", -1)
+		output = strings.Replace(output, id, "ID", -1)
+		return result, output
+	}
+
+	expectedPlan, expectedOutput := plan("test_instance.d", "test_instance.b")
+	if !reflect.DeepEqual(expectedPlan.Targets, []string{"test_instance.b", "test_instance.d"}) {
+		t.Fatalf("bad: %q", expectedPlan.Targets)
+	}
+
+	actualPlan, actualOutput := plan(
+		"test_instance.b", "test_instance.d", "test_instance.b")
+	if !reflect.DeepEqual(actualPlan.Targets, expectedPlan.Targets) {
+		t.Fatalf("bad: %q", actualPlan.Targets)
+	}
+	if actualOutput != expectedOutput {
+		t.Fatalf("output differs:\n\n%s\n\n%s", actualOutput, expectedOutput)
+	}
+}
+
+func TestPlan_targets(t *testing.T) {
+	outPath := testTempFile(t)
+
+	h := testCommandHarness(t, testProvider(), nil)
+	h.Run(&PlanCommand{Meta: h.Meta}, "apply-continue",
+		"-target", "test_instance.a", "-out", outPath).
+		ExpectCode(0).
+		ExpectOutput("+ test_instance.a", "1 to add")
+
+	// Only the targeted resource is in the plan, which keeps the targets
+	plan := testReadPlan(t, outPath)
+	if !reflect.DeepEqual(plan.Targets, []string{"test_instance.a"}) {
+		t.Fatalf("bad: %q", plan.Targets)
+	}
+	resources := plan.Diff.RootModule().Resources
+	if _, ok := resources["test_instance.a"]; !ok || len(resources) != 1 {
+		t.Fatalf("bad: %#v", resources)
+	}
+}
+
+func TestPlan_targetFile(t *testing.T) {
+	outPath := testTempFile(t)
+	targetPath := testTempFile(t)
+	targets := "# first phase\n\ntest_instance.a\ntest_instance.b # after a\n"
+	if err := ioutil.WriteFile(targetPath, []byte(targets), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	h := testCommandHarness(t, testProvider(), nil)
+	h.Run(&PlanCommand{Meta: h.Meta}, "apply-continue",
+		"-target-file", targetPath,
+		"-target", "test_instance.c",
+		"-target", "test_instance.a",
+		"-out", outPath).
+		ExpectCode(0).
+		ExpectOutput(
+			"This plan is limited to 3 target(s) and their dependencies.",
+			"3 to add")
+
+	// The targets from the file are merged with the others
+	plan := testReadPlan(t, outPath)
+	expected := []string{"test_instance.a", "test_instance.b", "test_instance.c"}
+	if !reflect.DeepEqual(plan.Targets, expected) {
+		t.Fatalf("bad: %q", plan.Targets)
+	}
+}
+
+func TestPlan_targetFileInvalid(t *testing.T) {
+	targetPath := testTempFile(t)
+	targets := "test_instance.a\n\ntest_instance.b[x]\n"
+	if err := ioutil.WriteFile(targetPath, []byte(targets), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-target-file", targetPath,
+		testFixturePath("apply-continue"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if err := c.Result.Err; err == nil || !strings.Contains(err.Error(), ":3: invalid target") {
+		t.Fatalf("bad: %v", err)
+	}
+	if p.DiffCalled {
+		t.Fatal("diff should not be called")
+	}
+}
+
+func TestPlan_stream(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-stream",
+		"-no-color",
+		testFixturePath("plan-stream"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	var streamed []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "+ ") {
+			streamed = append(streamed, line)
+		}
+	}
+	sort.Strings(streamed)
+
+	expected := []string{
+		"+ test_instance.bar",
+		"+ test_instance.baz",
+		"+ test_instance.foo.0",
+		"+ test_instance.foo.1",
+		"+ test_instance.foo.2",
+	}
+	if !reflect.DeepEqual(streamed, expected) {
+		t.Fatalf("bad: %#v\n\n%s", streamed, output)
+	}
+
+	// The plan isn't output again at the end, but the summary is
+	if strings.Contains(output, "ami:") {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "Plan: 5 to add, 0 to change, 0 to destroy.") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_chdir(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// The working directory has the configuration, state and variables
+	workDir := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	config, err := ioutil.ReadFile(filepath.Join(testFixturePath("apply-vars"), "main.tf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	files := map[string]string{
+		"main.tf":          string(config),
+		"terraform.tfvars": `foo = "bar"`,
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(workDir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+	f, err := os.Create(filepath.Join(workDir, DefaultStateFilename))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = terraform.WriteState(testState(), f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-chdir=work",
+		"-out", "foo.tfplan",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The plan is written relative to the current directory
+	if _, err := os.Stat(filepath.Join(workDir, "foo.tfplan")); !os.IsNotExist(err) {
+		t.Fatalf("plan should not be in the working directory: %v", err)
+	}
+	plan := testReadPlan(t, filepath.Join(tmp, "foo.tfplan"))
+
+	// The variables and state are from the working directory
+	if v := plan.Vars["foo"]; v != "bar" {
+		t.Fatalf("bad: %#v", plan.Vars)
+	}
+	if plan.State.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", plan.State)
+	}
+	if c.DataDir() != filepath.Join("work", DefaultDataDir) {
+		t.Fatalf("bad: %s", c.DataDir())
+	}
+}
+
+func TestPlan_timeout(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	p.DiffFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState,
+		*terraform.ResourceConfig) (*terraform.InstanceDiff, error) {
+		time.Sleep(500 * time.Millisecond)
+		return nil, nil
+	}
+
+	start := time.Now()
+	args := []string{
+		"-timeout", "50ms",
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("took too long: %s", d)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Error running plan: "+ErrTimeout.Error()) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_stateHeader(t *testing.T) {
+	s := testState()
+	s.Serial = 42
+	statePath := testStateFile(t, s)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := fmt.Sprintf("Using state: %s (serial 42)", statePath)
+	if output := ui.OutputWriter.String(); !strings.HasPrefix(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+	if c.StateDescription() != expected[len("Using state: "):] {
+		t.Fatalf("bad: %s", c.StateDescription())
+	}
+
+	// The header isn't output with -quiet
+	ui = new(cli.MockUi)
+	c = &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-quiet",
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if output := ui.OutputWriter.String(); strings.Contains(output, "Using state:") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_stateHeaderRemote(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	remotePath := filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)
+	testRemoteConfigCache(t, remotePath, cache)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	expected := fmt.Sprintf(
+		"Using state: remote state http at %s (serial %d), cached in %s",
+		srv.URL, testStateRead(t, remotePath).Serial,
+		filepath.Join(DefaultDataDir, DefaultStateFilename))
+	if output := ui.OutputWriter.String(); !strings.HasPrefix(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_refreshOnly(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	outPath := filepath.Join(testTempDir(t), "plan")
+
+	// The instance was changed outside of Terraform, and the configuration
+	// would change it back.
+	p := testProvider()
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{
+		ID:         "bar",
+		Attributes: map[string]string{"ami": "baz"},
+	}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-refresh-only",
+		"-detailed-exitcode",
+		"-no-color",
+		"-state", statePath,
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 2 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "~ test_instance.foo") ||
+		!strings.Contains(output, "refresh-only plan") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// No changes are proposed for the configuration
+	if p.DiffCalled || !c.Result.Plan.Diff.Empty() {
+		t.Fatalf("bad: %s", c.Result.Plan.Diff)
+	}
+
+	// Applying the plan only saves the refreshed state
+	p = testProvider()
+	ui = new(cli.MockUi)
+	apply := &ApplyCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+	args = []string{
+		"-state-out", statePath,
+		outPath,
+	}
+	if code := apply.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if p.ApplyCalled || p.RefreshCalled {
+		t.Fatal("nothing should be applied or refreshed")
+	}
+
+	state := testStateRead(t, statePath)
+	if v := state.RootModule().Resources["test_instance.foo"].Primary.Attributes["ami"]; v != "baz" {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
+func TestPlan_refreshOnlyNoDrift(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-refresh-only",
+		"-detailed-exitcode",
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+	if !c.Result.Empty || !strings.Contains(ui.OutputWriter.String(), "No changes.") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	// It has to refresh, and doesn't destroy
+	for _, extra := range []string{"-refresh=false", "-destroy"} {
+		ui := new(cli.MockUi)
+		c := &PlanCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+		args := []string{"-refresh-only", extra, testFixturePath("plan")}
+		if code := c.Run(args); code != 1 {
+			t.Fatalf("%s: bad: %d\n\n%s", extra, code, ui.OutputWriter.String())
+		}
+	}
+}
+
+func TestPlan_stateOverride(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	remotePath := filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)
+	testRemoteConfigCache(t, remotePath, cache)
+
+	// A prior version of the remote state, with a resource that has since
+	// been removed.
+	override := s.DeepCopy()
+	override.Serial++
+	override.RootModule().Resources["test_instance.orphan"] = &terraform.ResourceState{
+		Type:    "test_instance",
+		Primary: &terraform.InstanceState{ID: "baz"},
+	}
+	statePath := testStateFile(t, override)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-no-color",
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := fmt.Sprintf(
+		"Using state: %s (serial %d), overriding remote state http at %s",
+		statePath, override.Serial, srv.URL)
+	if !strings.HasPrefix(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "- test_instance.orphan") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// Neither state was written
+	if actual := testStateRead(t, statePath); !actual.Equal(override) {
+		t.Fatalf("bad: %s", actual)
+	}
+	if actual := testStateRead(t, remotePath); !actual.Equal(cache) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestPlan_stateOverrideOut(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	remotePath := filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)
+	testRemoteConfigCache(t, remotePath, cache)
+	statePath := testStateFile(t, s)
+	outPath := filepath.Join(tmp, "plan")
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-out", outPath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "-out flag can't be used") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	if _, err := os.Stat(outPath); !os.IsNotExist(err) {
+		t.Fatalf("plan should not be saved: %s", err)
+	}
+}
+
+func TestPlan_stateOverrideLineage(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	conf, srv := testRemoteState(t, s, 200)
+	defer srv.Close()
+
+	cache := s.DeepCopy()
+	cache.Remote = conf
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), cache)
+
+	// A state file for some other state altogether
+	override := testState()
+	statePath := testStateFile(t, override)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if errOut := ui.ErrorWriter.String(); !strings.Contains(errOut, override.Lineage) {
+		t.Fatalf("bad: %s", errOut)
+	}
+
+	// With -force the state file is used anyways
+	ui = new(cli.MockUi)
+	c = &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args = []string{
+		"-force",
+		"-state", statePath,
+		testFixturePath("plan"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+}
+
+func TestPlan_typeSummary(t *testing.T) {
+	s := testState()
+	s.RootModule().Resources = map[string]*terraform.ResourceState{
+		"test_volume.orphan": &terraform.ResourceState{
+			Type:    "test_volume",
+			Primary: &terraform.InstanceState{ID: "baz"},
+		},
+	}
+	statePath := testStateFile(t, s)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-no-color",
+		"-quiet",
+		"-state", statePath,
+		testFixturePath("plan-type-summary"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	idx := strings.Index(output, "Plan:")
+	if idx < 0 {
+		t.Fatalf("bad: %s", output)
+	}
+
+	golden := filepath.Join(testFixturePath("plan-type-summary"), "summary.txt")
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := output[idx:]; actual != string(expected) {
+		t.Fatalf("expected:\n%s\n\nactual:\n%s", expected, actual)
+	}
+}
+
+func TestPlan_applyEstimate(t *testing.T) {
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-no-color",
+		"-state", testTempFile(t),
+		testFixturePath("plan-apply-estimate"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// Six instances are created, and c waits for b, which waits for a
+	expected := &terraform.ApplyEstimate{Operations: 6, CriticalPath: 3}
+	if !reflect.DeepEqual(c.Result.ApplyEstimate, expected) {
+		t.Fatalf("bad: %#v", c.Result.ApplyEstimate)
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "Estimated apply: 6 operations, critical path 3") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestPlan_outputs(t *testing.T) {
+	s := terraform.NewState()
+	s.RootModule().Outputs = map[string]*terraform.OutputState{
+		"changed": &terraform.OutputState{Type: "string", Value: "foo"},
+		"removed": &terraform.OutputState{Type: "string", Value: "qux"},
+	}
+	statePath := testStateFile(t, s)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("plan-outputs"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := `Outputs:
+
+~ changed: "foo" => "baz"
++ new:     <computed>
+- removed`
+	if !strings.Contains(output, expected) {
+		t.Fatalf("bad: %s", output)
+	}
+}
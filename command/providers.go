@@ -0,0 +1,145 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// ProvidersCommand is a Command implementation that shows the providers
+// required by the configuration and the state, and which modules require
+// them.
+type ProvidersCommand struct {
+	Meta
+}
+
+func (c *ProvidersCommand) Run(args []string) int {
+	args = c.Meta.process(args, false)
+
+	cmdFlags := c.Meta.flagSet("providers")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	var path string
+	args = cmdFlags.Args()
+	if len(args) > 1 {
+		c.Ui.Error("The providers command expects at most one argument.")
+		cmdFlags.Usage()
+		return 1
+	} else if len(args) == 1 {
+		path = args[0]
+	} else {
+		var err error
+		path, err = c.pwd()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
+			return 1
+		}
+	}
+
+	ctx, _, err := c.Context(contextOpts{
+		Path:        path,
+		PathEmptyOk: true,
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error loading Terraform: %s", err))
+		return 1
+	}
+
+	var s *terraform.State
+	if st, err := c.State(); err == nil && st != nil {
+		s = st.State()
+	}
+
+	deps := terraform.ModuleTreeDependencies(ctx.Module(), s)
+	c.Ui.Output(formatModuleDependencies(deps))
+	return 0
+}
+
+// formatModuleDependencies formats the providers required by each module
+// as a tree, with the root module as ".".
+func formatModuleDependencies(deps *terraform.ModuleDependencies) string {
+	var buf bytes.Buffer
+	buf.WriteString(".\n")
+	formatModuleDependenciesChildren(&buf, deps, "")
+	return strings.TrimSpace(buf.String())
+}
+
+func formatModuleDependenciesChildren(buf *bytes.Buffer, deps *terraform.ModuleDependencies, indent string) {
+	names := make([]string, 0, len(deps.Providers))
+	for name := range deps.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The providers of the module come before its child modules
+	total := len(names) + len(deps.Children)
+	i := 0
+	branch := func() (string, string) {
+		i++
+		if i == total {
+			return indent + "└── ", indent + "    "
+		}
+
+		return indent + "├── ", indent + "│   "
+	}
+
+	for _, name := range names {
+		prefix, _ := branch()
+		buf.WriteString(prefix + "provider." + name)
+
+		dep := deps.Providers[name]
+		if dep.Constraint != "" {
+			buf.WriteString(" " + dep.Constraint)
+		}
+		switch dep.Reason {
+		case terraform.ProviderDependencyInherited:
+			buf.WriteString(" (inherited)")
+		case terraform.ProviderDependencyFromState:
+			buf.WriteString(" (from state)")
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, child := range deps.Children {
+		prefix, childIndent := branch()
+		buf.WriteString(prefix + "module." + child.Name + "\n")
+		formatModuleDependenciesChildren(buf, child, childIndent)
+	}
+}
+
+func (c *ProvidersCommand) Help() string {
+	helpText := `
+Usage: terraform providers [options] [DIR]
+
+  Shows the providers required by the configuration in DIR (or the current
+  directory if omitted) and by the resources in the state, as a tree of
+  the modules that require them.
+
+  Each provider is shown with the version constraints given in the
+  provider blocks of the module, if any. Providers that a module uses
+  without configuring them are required by the module too, and are marked
+  as inherited if one of its parent modules configures them. Providers
+  only used by resources in the state are marked as from state: they are
+  needed to destroy those resources.
+
+Options:
+
+  -no-color           If specified, output won't contain any color.
+
+  -state=path         Path to the state file to read. Defaults to
+                      "terraform.tfstate". Ignored when remote state is used.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *ProvidersCommand) Synopsis() string {
+	return "Show the providers required by the configuration"
+}
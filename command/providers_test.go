@@ -0,0 +1,80 @@
+package command
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestProviders(t *testing.T) {
+	dataDir := tempDir(t)
+	ui := new(cli.MockUi)
+	get := &GetCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+			dataDir:     dataDir,
+		},
+	}
+	if code := get.Run([]string{testFixturePath("providers")}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The provider of a resource that is only in the state is required too
+	state := terraform.NewState()
+	state.RootModule().Resources["test_instance.gone"] = &terraform.ResourceState{
+		Type: "test_instance",
+		Primary: &terraform.InstanceState{
+			ID: "bar",
+		},
+	}
+	statePath := testStateFile(t, state)
+
+	ui = new(cli.MockUi)
+	c := &ProvidersCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+			dataDir:     dataDir,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("providers"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	golden := filepath.Join(testFixturePath("providers"), "output.txt")
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := ui.OutputWriter.String(); actual != string(expected) {
+		t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+	}
+}
+
+func TestProviders_modulesMissing(t *testing.T) {
+	ui := new(cli.MockUi)
+	c := &ProvidersCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+			dataDir:     tempDir(t),
+		},
+	}
+
+	if code := c.Run([]string{testFixturePath("providers")}); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "module.child") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
@@ -64,7 +64,7 @@ func (c *PushCommand) Run(args []string) int {
 	}
 
 	// The pwd is used for the configuration path if one is not given
-	pwd, err := os.Getwd()
+	pwd, err := c.pwd()
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		return 1
@@ -351,8 +351,10 @@ Options:
                        flag can be set multiple times.
 
   -var-file=foo        Set variables in the Terraform configuration from
-                       a file. If "terraform.tfvars" is present, it will be
-                       automatically loaded if this flag is not specified.
+                       a file. If "terraform.tfvars" or any ".auto.tfvars"
+                       files are present, they will be automatically loaded.
+                       A directory or glob pattern loads all the matching
+                       files, in lexical order.
 
   -vcs=true            If true (default), push will upload only files
                        committed to your VCS, if detected.
@@ -0,0 +1,179 @@
+package command
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// redactedValue replaces the values hidden by a Redactor.
+const redactedValue = "(sensitive)"
+
+// redactMinLength is the length a value must have to be redacted. Very
+// short values, such as "1" or "ab", would make the output unreadable if
+// every occurrence was replaced, and aren't meaningful secrets anyways.
+const redactMinLength = 4
+
+// Redactor hides the values of variables marked as sensitive in text by
+// replacing every occurrence with "(sensitive)". Values can be added at
+// any time, such as once the variables are known, and are redacted from
+// all the text after that. It is safe for concurrent use.
+type Redactor struct {
+	l        sync.RWMutex
+	values   []string
+	replacer *strings.Replacer
+}
+
+// Add adds the given value to be redacted. Lists and maps add all the
+// values in them.
+func (r *Redactor) Add(v interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	added := false
+	var add func(interface{})
+	add = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if len(v) < redactMinLength {
+				return
+			}
+			for _, existing := range r.values {
+				if existing == v {
+					return
+				}
+			}
+
+			r.values = append(r.values, v)
+			added = true
+		case []interface{}:
+			for _, e := range v {
+				add(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				add(e)
+			}
+		case []map[string]interface{}:
+			for _, m := range v {
+				add(m)
+			}
+		}
+	}
+	add(v)
+
+	if !added {
+		return
+	}
+
+	// Replace longer values first so that a value that contains another
+	// one is redacted completely.
+	sort.Sort(redactValues(r.values))
+	pairs := make([]string, 0, len(r.values)*2)
+	for _, v := range r.values {
+		pairs = append(pairs, v, redactedValue)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with the sensitive values replaced.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+
+	return r.replacer.Replace(s)
+}
+
+// Writer returns an io.Writer that writes to w with the sensitive values
+// redacted. Each write is redacted on its own, so values are only found
+// when a write contains all of them, such as the lines written by the
+// standard logger.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &redactWriter{Redactor: r, Writer: w}
+}
+
+// redactValues sorts the values to redact with the longest first.
+type redactValues []string
+
+func (v redactValues) Len() int           { return len(v) }
+func (v redactValues) Less(i, j int) bool { return len(v[i]) > len(v[j]) }
+func (v redactValues) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+type redactWriter struct {
+	Redactor *Redactor
+	Writer   io.Writer
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.Writer, w.Redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// redactVariables adds the values of the root module variables that are
+// marked as sensitive to the redactor, including their defaults.
+func redactVariables(r *Redactor, ctx *terraform.Context) {
+	mod := ctx.Module()
+	if mod == nil || mod.Config() == nil {
+		return
+	}
+
+	vars := ctx.Variables()
+	for _, v := range mod.Config().Variables {
+		if !v.Sensitive {
+			continue
+		}
+
+		r.Add(v.Default)
+		r.Add(vars[v.Name])
+	}
+}
+
+// RedactUi is a Ui implementation that hides the values of sensitive
+// variables in everything that is output.
+type RedactUi struct {
+	Redactor *Redactor
+	Ui       cli.Ui
+}
+
+func (u *RedactUi) Ask(query string) (string, error) {
+	return u.Ui.Ask(u.Redactor.Redact(query))
+}
+
+func (u *RedactUi) AskSecret(query string) (string, error) {
+	return u.Ui.AskSecret(u.Redactor.Redact(query))
+}
+
+func (u *RedactUi) Output(message string) {
+	u.Ui.Output(u.Redactor.Redact(message))
+}
+
+func (u *RedactUi) Info(message string) {
+	u.Ui.Info(u.Redactor.Redact(message))
+}
+
+func (u *RedactUi) Error(message string) {
+	u.Ui.Error(u.Redactor.Redact(message))
+}
+
+func (u *RedactUi) Warn(message string) {
+	u.Ui.Warn(u.Redactor.Redact(message))
+}
@@ -0,0 +1,142 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestRedactUi_impl(t *testing.T) {
+	var _ cli.Ui = new(RedactUi)
+}
+
+func TestRedactor(t *testing.T) {
+	r := new(Redactor)
+	if actual := r.Redact("hunter2"); actual != "hunter2" {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	r.Add("hunter2")
+	r.Add("hunter2-longer")
+	r.Add([]interface{}{"listvalue", "ab"})
+	r.Add(map[string]interface{}{"key": "mapvalue"})
+	r.Add(nil)
+
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		{"foo", "foo"},
+		{"password: hunter2", "password: (sensitive)"},
+		{"hunter2-longer", "(sensitive)"},
+		{"listvalue and mapvalue", "(sensitive) and (sensitive)"},
+		{"ab is too short", "ab is too short"},
+	}
+	for _, tc := range cases {
+		if actual := r.Redact(tc.Input); actual != tc.Expected {
+			t.Fatalf("%s: bad: %s", tc.Input, actual)
+		}
+	}
+}
+
+func TestRedactor_nil(t *testing.T) {
+	var r *Redactor
+	r.Add("hunter2")
+	if actual := r.Redact("hunter2"); actual != "hunter2" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestRedactor_writer(t *testing.T) {
+	r := new(Redactor)
+	r.Add("hunter2")
+
+	var buf bytes.Buffer
+	fmt.Fprintln(r.Writer(&buf), "[DEBUG] password is hunter2")
+	if actual := buf.String(); actual != "[DEBUG] password is (sensitive)\n" {
+		t.Fatalf("bad: %q", actual)
+	}
+}
+
+func TestPlan_sensitiveVarError(t *testing.T) {
+	const password = "hunter2-s3cr3t"
+
+	var logs bytes.Buffer
+	p := testProvider()
+	p.ValidateResourceFn = func(
+		t string, c *terraform.ResourceConfig) ([]string, []error) {
+		v, _ := c.Get("ami")
+		return nil, []error{fmt.Errorf("invalid value for ami: %s", v)}
+	}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			LogWriter:   &logs,
+		},
+	}
+
+	args := []string{
+		"-var", "password=" + password,
+		testFixturePath("plan-sensitive-var"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	errStr := ui.ErrorWriter.String()
+	if !strings.Contains(errStr, "invalid value for ami: (sensitive)") {
+		t.Fatalf("bad: %s", errStr)
+	}
+	for name, output := range map[string]string{
+		"output": ui.OutputWriter.String(),
+		"error":  errStr,
+		"logs":   logs.String(),
+	} {
+		if strings.Contains(output, password) {
+			t.Fatalf("%s has the sensitive value:\n\n%s", name, output)
+		}
+	}
+}
+
+func TestPlan_sensitiveVarDiff(t *testing.T) {
+	const password = "hunter2-s3cr3t"
+
+	p := testProvider()
+	p.DiffReturn = &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"ami": &terraform.ResourceAttrDiff{
+				New:         password,
+				RequiresNew: true,
+			},
+		},
+	}
+	ui := new(cli.MockUi)
+	c := &PlanCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-var", "password=" + password,
+		testFixturePath("plan-sensitive-var"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if strings.Contains(output, password) {
+		t.Fatalf("bad:\n\n%s", output)
+	}
+	if !strings.Contains(output, `ami: "(sensitive)"`) {
+		t.Fatalf("bad:\n\n%s", output)
+	}
+}
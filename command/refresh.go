@@ -1,12 +1,17 @@
 package command
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/terraform/config/module"
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -14,32 +19,66 @@ import (
 // file.
 type RefreshCommand struct {
 	Meta
+
+	// Result is set to the result of the refresh when Run returns.
+	Result *RefreshResult
 }
 
-func (c *RefreshCommand) Run(args []string) int {
+func (c *RefreshCommand) Run(args []string) (code int) {
+	var get bool
+	var refreshSkip []string
+	var lockTimeout, timeout time.Duration
+	c.Result = new(RefreshResult)
 	args = c.Meta.process(args, true)
 
+	// Call the hook once everything else, such as unlocking, is done
+	defer func() { c.postOperation(code) }()
+
+	// Output any warnings collected during the operation once it is done
+	defer c.showWarnings()
+
+	// Release the state lock acquired by Context once we're done
+	defer c.unlockState()
+
+	// Capture the logs of the operation if requested
+	defer c.captureLogs("refresh")()
+
+	// Persist the state as resources are refreshed, so that a refresh that
+	// crashes partway through doesn't lose everything refreshed until then
+	checkpointHook := new(StateCheckpointHook)
+	c.Meta.extraHooks = []terraform.Hook{checkpointHook}
+
 	cmdFlags := c.Meta.flagSet("refresh")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.IntVar(&c.Meta.parallelism, "parallelism", 0, "parallelism")
+	cmdFlags.BoolVar(&get, "get", false, "get")
+	cmdFlags.BoolVar(&c.Meta.quiet, "quiet", false, "quiet")
+	cmdFlags.Var((*FlagStringSlice)(&refreshSkip), "refresh-skip", "resource to skip refreshing")
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
+	c.addCompactWarningsFlag(cmdFlags)
+	cmdFlags.DurationVar(&timeout, "timeout", 0, "timeout")
+	cmdFlags.IntVar(&checkpointHook.Count, "checkpoint-resources", 100, "checkpoint-resources")
+	cmdFlags.DurationVar(&checkpointHook.Interval, "checkpoint-interval", time.Minute, "checkpoint-interval")
 	cmdFlags.StringVar(&c.Meta.stateOutPath, "state-out", "", "path")
 	cmdFlags.StringVar(&c.Meta.backupPath, "backup", "", "path")
+	cmdFlags.StringVar(&c.Meta.backupDir, "backup-dir", "", "path")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
+		c.Result.Err = err
 		return 1
 	}
 
 	var configPath string
 	args = cmdFlags.Args()
 	if len(args) > 1 {
-		c.Ui.Error("The refresh command expects at most one argument.")
+		c.fail(errors.New("The refresh command expects at most one argument."))
 		cmdFlags.Usage()
 		return 1
 	} else if len(args) == 1 {
 		configPath = args[0]
 	} else {
 		var err error
-		configPath, err = os.Getwd()
+		configPath, err = c.pwd()
 		if err != nil {
 			c.Ui.Error(fmt.Sprintf("Error getting pwd: %s", err))
 		}
@@ -48,55 +87,51 @@ func (c *RefreshCommand) Run(args []string) int {
 	// Check if remote state is enabled
 	state, err := c.State()
 	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Failed to load state: %s", err))
-		return 1
+		return c.fail(errwrap.Wrapf("Failed to load state: {{err}}", err))
 	}
 
 	// Verify that the state path exists. The "ContextArg" function below
 	// will actually do this, but we want to provide a richer error message
-	// if possible.
-	if !state.State().IsRemote() {
-		if _, err := os.Stat(c.Meta.statePath); err != nil {
-			if os.IsNotExist(err) {
-				c.Ui.Error(fmt.Sprintf(
-					"The Terraform state file for your infrastructure does not\n"+
-						"exist. The 'refresh' command only works and only makes sense\n"+
-						"when there is existing state that Terraform is managing. Please\n"+
-						"double-check the value given below and try again. If you\n"+
-						"haven't created infrastructure with Terraform yet, use the\n"+
-						"'terraform apply' command.\n\n"+
-						"Path: %s",
-					c.Meta.statePath))
-				return 1
-			}
-
-			c.Ui.Error(fmt.Sprintf(
-				"There was an error reading the Terraform state that is needed\n"+
-					"for refreshing. The path and error are shown below.\n\n"+
-					"Path: %s\n\nError: %s",
-				c.Meta.statePath,
-				err))
-			return 1
+	// if possible. A state that isn't in a file has nothing to check.
+	if c.Meta.stateResult.Local != nil && !state.State().IsRemote() {
+		if err := refreshStateExists(c.Meta.statePath); err != nil {
+			return c.fail(err)
 		}
 	}
 
 	// This is going to keep track of shadow errors
 	var shadowErr error
 
+	getMode := module.GetModeNone
+	if get {
+		getMode = module.GetModeGet
+	}
+
 	// Build the context based on the arguments given
 	ctx, _, err := c.Context(contextOpts{
 		Path:        configPath,
 		StatePath:   c.Meta.statePath,
+		GetMode:     getMode,
 		Parallelism: c.Meta.parallelism,
+		RefreshSkip: refreshSkip,
+		Lock:        true,
+		LockTimeout: lockTimeout,
+		Operation:   "refresh",
+		WritesState: true,
 	})
 	if err != nil {
-		c.Ui.Error(err.Error())
-		return 1
+		return c.fail(err)
 	}
 
+	// A state that is read-only for this operation is never checkpointed
+	if _, ok := c.Meta.state.(*readOnlyState); !ok {
+		checkpointHook.State = c.Meta.state
+	}
+
+	c.outputOperationHeader()
+
 	if err := ctx.Input(c.InputMode()); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error configuring: %s", err))
-		return 1
+		return c.fail(errwrap.Wrapf("Error configuring: {{err}}", err))
 	}
 
 	// Record any shadow errors for later
@@ -105,20 +140,49 @@ func (c *RefreshCommand) Run(args []string) int {
 			err, "input operation:"))
 	}
 
-	if !validateContext(ctx, c.Ui) {
+	if err := c.validateContext(ctx); err != nil {
+		c.Result.Err = err
 		return 1
 	}
 
-	newState, err := ctx.Refresh()
-	if err != nil {
-		c.Ui.Error(fmt.Sprintf("Error refreshing state: %s", err))
-		return 1
+	// Keep the state from before the refresh to find what changed
+	prior := state.State().DeepCopy()
+
+	var newState *terraform.State
+	var refreshErr error
+	finished, err := c.runWithDeadline(ctx, operationDeadline(timeout), func() {
+		newState, refreshErr = ctx.Refresh()
+	})
+	if !finished {
+		// The refresh is still running, so its state can't be trusted.
+		checkpointHook.Stop()
+		return c.fail(errwrap.Wrapf(fmt.Sprintf(
+			"Error refreshing state: {{err}} after %s. The refresh didn't stop in\n"+
+				"time, so the state wasn't updated.", timeout), err))
+	}
+	if refreshErr != nil {
+		return c.fail(errwrap.Wrapf("Error refreshing state: {{err}}", refreshErr))
 	}
 
+	// A refresh that was stopped at the deadline has still refreshed some
+	// resources, and the others keep their prior state, so it is saved.
 	log.Printf("[INFO] Writing state output to: %s", c.Meta.StateOutPath())
 	if err := c.Meta.PersistState(newState); err != nil {
-		c.Ui.Error(fmt.Sprintf("Error writing state file: %s", err))
-		return 1
+		return c.fail(errwrap.Wrapf("Error writing state file: {{err}}", err))
+	}
+	c.Result.State = newState
+	c.Result.StatePath = c.Meta.StateOutPath()
+	if err == ErrTimeout {
+		return c.fail(errwrap.Wrapf(fmt.Sprintf(
+			"Error refreshing state: {{err}} after %s. The resources refreshed\n"+
+				"before it was stopped were saved to the state.", timeout), err))
+	}
+
+	changed, removed := terraform.StateDrift(prior, newState)
+	c.Result.Changed = changed
+	c.Result.Removed = removed
+	if len(changed)+len(removed) > 0 {
+		c.Ui.Output(c.Colorize().Color(formatStateDrift(changed, removed)))
 	}
 
 	if outputs := outputsAsString(newState, terraform.RootModulePath, ctx.Module().Config().Outputs, true); outputs != "" {
@@ -137,6 +201,61 @@ func (c *RefreshCommand) Run(args []string) int {
 	return 0
 }
 
+// fail records err as the error the command failed with and outputs it,
+// returning the exit status for failing.
+func (c *RefreshCommand) fail(err error) int {
+	c.Result.Err = err
+	c.Ui.Error(FormatDiagnostic(&FormatDiagnosticOpts{
+		Err:   err,
+		Color: c.Colorize(),
+	}))
+	return 1
+}
+
+// refreshStateExists returns an error if there is no state file to refresh
+// at the given path. If it doesn't exist, the error contains an
+// *ErrStateNotFound.
+func refreshStateExists(path string) error {
+	_, err := os.Stat(path)
+	if err == nil {
+		return nil
+	}
+
+	if os.IsNotExist(err) {
+		return errwrap.Wrap(fmt.Errorf(
+			"The Terraform state file for your infrastructure does not\n"+
+				"exist. The 'refresh' command only works and only makes sense\n"+
+				"when there is existing state that Terraform is managing. Please\n"+
+				"double-check the value given below and try again. If you\n"+
+				"haven't created infrastructure with Terraform yet, use the\n"+
+				"'terraform apply' command.\n\n"+
+				"Path: %s",
+			path), &ErrStateNotFound{Path: path})
+	}
+
+	return fmt.Errorf(
+		"There was an error reading the Terraform state that is needed\n"+
+			"for refreshing. The path and error are shown below.\n\n"+
+			"Path: %s\n\nError: %s",
+		path, err)
+}
+
+// formatStateDrift returns a summary of the resources that were found to
+// be changed or removed outside of Terraform when refreshing, to be
+// colorized by Meta.Colorize.
+func formatStateDrift(changed, removed []string) string {
+	var buf bytes.Buffer
+	buf.WriteString("[reset][bold]Refreshing found changes made outside of Terraform:[reset]\n\n")
+	for _, addr := range changed {
+		buf.WriteString(fmt.Sprintf("  [update]~ %s[reset]\n", addr))
+	}
+	for _, addr := range removed {
+		buf.WriteString(fmt.Sprintf("  [delete]- %s[reset] (no longer exists)\n", addr))
+	}
+
+	return buf.String()
+}
+
 func (c *RefreshCommand) Help() string {
 	helpText := `
 Usage: terraform refresh [options] [dir]
@@ -154,13 +273,61 @@ Options:
                       modifying. Defaults to the "-state-out" path with
                       ".backup" extension. Set to "-" to disable backup.
 
+  -backup-dir=path    Directory to write the backup to, keeping its name.
+                      By default, it is written next to the state, or to
+                      within .terraform with a warning if that fails.
+
+  -checkpoint-interval=1m
+                      Save the state while refreshing once this long has
+                      passed since it was last saved, so that a refresh that
+                      fails doesn't lose all the resources refreshed until
+                      then. Zero disables this.
+
+  -checkpoint-resources=100
+                      Save the state while refreshing after this many
+                      resources were refreshed since it was last saved.
+                      Zero disables this.
+
+  -compact-warnings=true
+                      Output warnings that differ only in what they're for,
+                      such as the same deprecated argument in many resources,
+                      as one warning followed by how many more there are.
+
+  -get=false          Download any modules for this configuration that haven't
+                      been downloaded yet. Modules that were already
+                      downloaded are not updated.
+
   -input=true         Ask for input for variables if not directly set.
 
+  -input-cache=true   Reuse the answers given for provider configuration
+                      by earlier runs, saved in .terraform/input.json.
+                      Credentials are never saved.
+
+  -lock-takeover=0s   Take over a state lock older than this that was
+                      acquired by a process on this host that is no longer
+                      running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s    Duration to retry a state lock held by another
+                      operation before giving up.
+
   -no-color           If specified, output won't contain any color.
 
+  -quiet              Don't output which state file or remote state the
+                      refresh uses.
+
+  -refresh-skip=res   Resource address to leave out of the refresh, keeping
+                      its state as it is. This flag can be used multiple
+                      times.
+
   -state=path         Path to read and save state (unless state-out
                       is specified). Defaults to "terraform.tfstate".
 
+  -state-lock-file=path
+                      Path of the lock file of the local state. Defaults to
+                      a file next to the state, such as
+                      ".terraform.tfstate.lock.info". Every operation on
+                      the state must use the same lock file.
+
   -state-out=path     Path to write updated state file. By default, the
                       "-state" path will be used.
 
@@ -168,12 +335,23 @@ Options:
                       resource and its dependencies. This flag can be used
                       multiple times.
 
+  -target-file=path   File with a resource to target on each line, in addition
+                      to those given with -target. Blank lines and comments
+                      starting with # are ignored. This flag can be used
+                      multiple times.
+
+  -timeout=0s         Duration the refresh can run before it is stopped.
+                      The refreshed resources are still saved. Zero means
+                      no limit.
+
   -var 'foo=bar'      Set a variable in the Terraform configuration. This
                       flag can be set multiple times.
 
   -var-file=foo       Set variables in the Terraform configuration from
-                      a file. If "terraform.tfvars" is present, it will be
-                      automatically loaded if this flag is not specified.
+                      a file. If "terraform.tfvars" or any ".auto.tfvars"
+                      files are present, they will be automatically loaded.
+                      A directory or glob pattern loads all the matching
+                      files, in lexical order.
 
 `
 	return strings.TrimSpace(helpText)
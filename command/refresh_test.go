@@ -2,60 +2,70 @@ package command
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
 
 func TestRefresh(t *testing.T) {
-	state := testState()
-	statePath := testStateFile(t, state)
-
 	p := testProvider()
-	ui := new(cli.MockUi)
-	c := &RefreshCommand{
-		Meta: Meta{
-			ContextOpts: testCtxConfig(p),
-			Ui:          ui,
-		},
-	}
-
 	p.RefreshFn = nil
 	p.RefreshReturn = &terraform.InstanceState{ID: "yes"}
 
-	args := []string{
-		"-state", statePath,
-		testFixturePath("refresh"),
-	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
-	}
+	h := testCommandHarness(t, p, testState())
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh").
+		ExpectCode(0).
+		ExpectState(testRefreshStr)
 
 	if !p.RefreshCalled {
 		t.Fatal("refresh should be called")
 	}
+}
 
-	f, err := os.Open(statePath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
+func TestRefresh_drift(t *testing.T) {
+	p := testProvider()
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{
+		ID:         "bar",
+		Attributes: map[string]string{"ami": "changed"},
 	}
 
-	newState, err := terraform.ReadState(f)
-	f.Close()
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
+	h := testCommandHarness(t, p, testState())
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh").
+		ExpectCode(0).
+		ExpectOutput("changes made outside of Terraform", "~ test_instance.foo")
+}
 
-	actual := strings.TrimSpace(newState.String())
-	expected := strings.TrimSpace(testRefreshStr)
-	if actual != expected {
-		t.Fatalf("bad:\n\n%s", actual)
+func TestRefresh_driftRemoved(t *testing.T) {
+	p := testProvider()
+	p.RefreshFn = nil
+	p.RefreshReturn = nil
+
+	h := testCommandHarness(t, p, testState())
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh").
+		ExpectCode(0).
+		ExpectOutput("- test_instance.foo (no longer exists)")
+}
+
+func TestRefresh_noDrift(t *testing.T) {
+	p := testProvider()
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{ID: "bar"}
+
+	h := testCommandHarness(t, p, testState())
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh").ExpectCode(0)
+
+	if output := h.Ui.OutputWriter.String(); strings.Contains(output, "outside of Terraform") {
+		t.Fatalf("bad: %s", output)
 	}
 }
 
@@ -76,6 +86,13 @@ func TestRefresh_badState(t *testing.T) {
 	if code := c.Run(args); code != 1 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
+
+	output := ui.ErrorWriter.String()
+	for _, v := range []string{"Error: ", "Path: i-should-not-exist-ever", "Suggestion: "} {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
+	}
 }
 
 func TestRefresh_cwd(t *testing.T) {
@@ -410,26 +427,10 @@ func TestRefresh_outPath(t *testing.T) {
 }
 
 func TestRefresh_var(t *testing.T) {
-	state := testState()
-	statePath := testStateFile(t, state)
-
 	p := testProvider()
-	ui := new(cli.MockUi)
-	c := &RefreshCommand{
-		Meta: Meta{
-			ContextOpts: testCtxConfig(p),
-			Ui:          ui,
-		},
-	}
-
-	args := []string{
-		"-var", "foo=bar",
-		"-state", statePath,
-		testFixturePath("refresh-var"),
-	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
-	}
+	h := testCommandHarness(t, p, testState())
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh-var", "-var", "foo=bar").
+		ExpectCode(0)
 
 	if !p.ConfigureCalled {
 		t.Fatal("configure should be called")
@@ -439,6 +440,32 @@ func TestRefresh_var(t *testing.T) {
 	}
 }
 
+func TestRefresh_targets(t *testing.T) {
+	s := testState()
+	s.RootModule().Resources["test_instance.bar"] = &terraform.ResourceState{
+		Type:    "test_instance",
+		Primary: &terraform.InstanceState{ID: "baz"},
+	}
+
+	var refreshed []string
+	p := testProvider()
+	p.RefreshFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState) (*terraform.InstanceState, error) {
+		refreshed = append(refreshed, info.Id)
+		return s, nil
+	}
+
+	h := testCommandHarness(t, p, s)
+	h.Run(&RefreshCommand{Meta: h.Meta}, "refresh-targeted",
+		"-target", "test_instance.foo").
+		ExpectCode(0)
+
+	if !reflect.DeepEqual(refreshed, []string{"test_instance.foo"}) {
+		t.Fatalf("bad: %#v", refreshed)
+	}
+}
+
 func TestRefresh_varFile(t *testing.T) {
 	state := testState()
 	statePath := testStateFile(t, state)
@@ -546,6 +573,77 @@ func TestRefresh_varsUnset(t *testing.T) {
 	}
 }
 
+func TestRefresh_varsUnsetContextInput(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	input := &terraform.MockUIInput{
+		InputReturnMap: map[string]string{"var.should_ask": "bar"},
+	}
+	opts := testCtxConfig(p)
+	opts.UIInput = input
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: opts,
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh-unset-var"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if !input.InputCalled {
+		t.Fatal("input on the context options should be used")
+	}
+}
+
+func TestRefresh_varsUnsetFailOnInput(t *testing.T) {
+	// Disable test mode so input would be asked
+	test = false
+	defer func() { test = true }()
+
+	defaultInputReader = bytes.NewBufferString("bar\n")
+
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			FailOnInput: true,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh-unset-var"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "var.should_ask") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
+	}
+}
+
 func TestRefresh_backup(t *testing.T) {
 	state := testState()
 	statePath := testStateFile(t, state)
@@ -640,18 +738,49 @@ func TestRefresh_backup(t *testing.T) {
 	}
 }
 
-func TestRefresh_disableBackup(t *testing.T) {
-	state := testState()
-	statePath := testStateFile(t, state)
+func TestRefresh_backupDir(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	backupDir := testTempDir(t)
+	defer os.RemoveAll(backupDir)
 
-	// Output path
-	outf, err := ioutil.TempFile("", "tf")
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
 	}
-	outPath := outf.Name()
-	outf.Close()
-	os.Remove(outPath)
+
+	p.RefreshFn = nil
+	p.RefreshReturn = newInstanceState("yes")
+
+	args := []string{
+		"-state", statePath,
+		"-backup-dir", backupDir,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The backup is in the backup dir, with the name it has by default
+	backupPath := filepath.Join(backupDir, filepath.Base(statePath)+DefaultBackupExtension)
+	backupState := testStateRead(t, backupPath)
+	actual := backupState.RootModule().Resources["test_instance.foo"].Primary
+	if actual.ID != "bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+	if _, err := os.Stat(statePath + DefaultBackupExtension); !os.IsNotExist(err) {
+		t.Fatalf("backup should not be next to the state: %v", err)
+	}
+}
+
+func TestRefresh_backupDirExplicitBackup(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	backupDir := testTempDir(t)
+	defer os.RemoveAll(backupDir)
+	backupPath := filepath.Join(testTempDir(t), "explicit.backup")
 
 	p := testProvider()
 	ui := new(cli.MockUi)
@@ -667,58 +796,86 @@ func TestRefresh_disableBackup(t *testing.T) {
 
 	args := []string{
 		"-state", statePath,
-		"-state-out", outPath,
-		"-backup", "-",
+		"-backup", backupPath,
+		"-backup-dir", backupDir,
 		testFixturePath("refresh"),
 	}
 	if code := c.Run(args); code != 0 {
 		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	f, err := os.Open(statePath)
+	// The path given with -backup wins over the backup dir
+	testStateRead(t, backupPath)
+	matches, err := filepath.Glob(filepath.Join(backupDir, "*"))
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
+	if len(matches) != 0 {
+		t.Fatalf("bad: %v", matches)
+	}
+}
 
-	newState, err := terraform.ReadState(f)
-	f.Close()
-	if err != nil {
+func TestRefresh_backupFallback(t *testing.T) {
+	statePath := testStateFile(t, testState())
+	dataDir := testTempDir(t)
+	defer os.RemoveAll(dataDir)
+
+	// The backup can't be written next to the state, since there's a
+	// directory in its place
+	if err := os.Mkdir(statePath+DefaultBackupExtension, 0755); err != nil {
 		t.Fatalf("err: %s", err)
 	}
+	defer os.RemoveAll(statePath + DefaultBackupExtension)
 
-	if !reflect.DeepEqual(newState, state) {
-		t.Fatalf("bad: %#v", newState)
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			dataDir:     dataDir,
+		},
 	}
 
-	f, err = os.Open(outPath)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
+	p.RefreshFn = nil
+	p.RefreshReturn = newInstanceState("yes")
 
-	newState, err = terraform.ReadState(f)
-	f.Close()
-	if err != nil {
-		t.Fatalf("err: %s", err)
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
 	}
 
-	actual := newState.RootModule().Resources["test_instance.foo"].Primary
-	expected := p.RefreshReturn
-	if !reflect.DeepEqual(actual, expected) {
+	// The backup is in the data dir of the state instead, with a warning
+	backupPath := filepath.Join(
+		c.stateDataDirFor(statePath), DefaultBackupsDir,
+		filepath.Base(statePath)+DefaultBackupExtension)
+	if !strings.HasPrefix(backupPath, dataDir) {
+		t.Fatalf("bad: %s", backupPath)
+	}
+	backupState := testStateRead(t, backupPath)
+	actual := backupState.RootModule().Resources["test_instance.foo"].Primary
+	if actual.ID != "bar" {
 		t.Fatalf("bad: %#v", actual)
 	}
 
-	// Ensure there is no backup
-	_, err = os.Stat(outPath + DefaultBackupExtension)
-	if err == nil || !os.IsNotExist(err) {
-		t.Fatalf("backup should not exist")
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "written to "+backupPath+" instead") {
+		t.Fatalf("bad: %s", output)
 	}
-}
 
-func TestRefresh_displaysOutputs(t *testing.T) {
-	state := testState()
-	statePath := testStateFile(t, state)
+	// The refreshed state was still written
+	newState := testStateRead(t, statePath)
+	if newState.RootModule().Resources["test_instance.foo"].Primary.ID != "yes" {
+		t.Fatalf("bad: %s", newState)
+	}
+}
+func TestRefresh_checkpoint(t *testing.T) {
+	s, p := testRefreshCheckpoint(t)
+	statePath := testStateFile(t, s)
 
-	p := testProvider()
 	ui := new(cli.MockUi)
 	c := &RefreshCommand{
 		Meta: Meta{
@@ -729,43 +886,658 @@ func TestRefresh_displaysOutputs(t *testing.T) {
 
 	args := []string{
 		"-state", statePath,
-		testFixturePath("refresh-output"),
+		"-checkpoint-resources", "1",
+		testFixturePath("apply-continue"),
 	}
-	if code := c.Run(args); code != 0 {
-		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "failed to refresh d") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
 	}
 
-	// Test that outputs were displayed
-	outputValue := "foo.example.com"
-	actual := ui.OutputWriter.String()
-	if !strings.Contains(actual, outputValue) {
-		t.Fatalf("Expected:\n%s\n\nTo include: %q", actual, outputValue)
+	// The resources refreshed before the failure were saved
+	actual := testStateRead(t, statePath).RootModule().Resources
+	for name, refreshed := range map[string]bool{"a": true, "b": true, "c": true, "d": false} {
+		rs := actual["test_instance."+name]
+		if rs == nil || rs.Primary.ID != name {
+			t.Fatalf("%s: bad: %#v", name, rs)
+		}
+		if (rs.Primary.Attributes["refreshed"] == "true") != refreshed {
+			t.Fatalf("%s: bad: %#v", name, rs.Primary)
+		}
+	}
+
+	// Checkpoints are backed up like any other write of the state
+	backup := testStateRead(t, statePath+DefaultBackupExtension)
+	if !backup.Equal(s) {
+		t.Fatalf("bad: %s", backup)
 	}
 }
 
-// When creating an InstaneState for direct comparison to one contained in
-// terraform.State, all fields must be initialized (duplicating the
-// InstanceState.init() method)
-func newInstanceState(id string) *terraform.InstanceState {
-	return &terraform.InstanceState{
-		ID:         id,
-		Attributes: make(map[string]string),
-		Ephemeral: terraform.EphemeralState{
-			ConnInfo: make(map[string]string),
+func TestRefresh_checkpointDisabled(t *testing.T) {
+	s, p := testRefreshCheckpoint(t)
+	statePath := testStateFile(t, s)
+	original := testStateRead(t, statePath)
+
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
 		},
-		Meta: make(map[string]string),
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-checkpoint-resources", "0",
+		"-checkpoint-interval", "0",
+		testFixturePath("apply-continue"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	// Nothing was saved, since the refresh failed
+	if actual := testStateRead(t, statePath); !actual.Equal(original) {
+		t.Fatalf("bad: %s", actual)
 	}
 }
 
-const refreshVarFile = `
-foo = "bar"
-`
+// testRefreshCheckpoint returns a state with the resources of the
+// apply-continue fixture, which are refreshed one after the other, and a
+// provider that fails to refresh the last one.
+func testRefreshCheckpoint(t *testing.T) (*terraform.State, *terraform.MockResourceProvider) {
+	s := terraform.NewState()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		s.RootModule().Resources["test_instance."+name] = &terraform.ResourceState{
+			Type:    "test_instance",
+			Primary: &terraform.InstanceState{ID: name},
+		}
+	}
 
-const testRefreshStr = `
-test_instance.foo:
+	p := testProvider()
+	p.RefreshFn = func(
+		info *terraform.InstanceInfo,
+		is *terraform.InstanceState) (*terraform.InstanceState, error) {
+		if is.ID == "d" {
+			return nil, fmt.Errorf("failed to refresh %s", is.ID)
+		}
+
+		is = is.DeepCopy()
+		is.Attributes = map[string]string{"refreshed": "true"}
+		return is, nil
+	}
+
+	return s, p
+}
+
+func TestRefresh_disableBackup(t *testing.T) {
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	// Output path
+	outf, err := ioutil.TempFile("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	outPath := outf.Name()
+	outf.Close()
+	os.Remove(outPath)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	p.RefreshFn = nil
+	p.RefreshReturn = newInstanceState("yes")
+
+	args := []string{
+		"-state", statePath,
+		"-state-out", outPath,
+		"-backup", "-",
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	f, err := os.Open(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	newState, err := terraform.ReadState(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(newState, state) {
+		t.Fatalf("bad: %#v", newState)
+	}
+
+	f, err = os.Open(outPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	newState, err = terraform.ReadState(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := newState.RootModule().Resources["test_instance.foo"].Primary
+	expected := p.RefreshReturn
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	// Ensure there is no backup
+	_, err = os.Stat(outPath + DefaultBackupExtension)
+	if err == nil || !os.IsNotExist(err) {
+		t.Fatalf("backup should not exist")
+	}
+}
+
+func TestRefresh_displaysOutputs(t *testing.T) {
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh-output"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// Test that outputs were displayed
+	outputValue := "foo.example.com"
+	actual := ui.OutputWriter.String()
+	if !strings.Contains(actual, outputValue) {
+		t.Fatalf("Expected:\n%s\n\nTo include: %q", actual, outputValue)
+	}
+}
+
+func TestRefresh_statePersistHook(t *testing.T) {
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	var calls int
+	var hookState *terraform.State
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			StatePersistHook: func(s *terraform.State) error {
+				calls++
+				hookState = s
+				return nil
+			},
+		},
+	}
+
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{ID: "yes"}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if calls != 1 {
+		t.Fatalf("hook should be called once, got: %d", calls)
+	}
+
+	actual := strings.TrimSpace(hookState.String())
+	expected := strings.TrimSpace(testRefreshStr)
+	if actual != expected {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestRefresh_statePersistHookError(t *testing.T) {
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+			StatePersistHook: func(s *terraform.State) error {
+				return fmt.Errorf("hook failed")
+			},
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if actual := ui.ErrorWriter.String(); !strings.Contains(actual, "hook failed") {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+// When creating an InstaneState for direct comparison to one contained in
+// terraform.State, all fields must be initialized (duplicating the
+// InstanceState.init() method)
+func newInstanceState(id string) *terraform.InstanceState {
+	return &terraform.InstanceState{
+		ID:         id,
+		Attributes: make(map[string]string),
+		Ephemeral: terraform.EphemeralState{
+			ConnInfo: make(map[string]string),
+		},
+		Meta: make(map[string]string),
+	}
+}
+
+const refreshVarFile = `
+foo = "bar"
+`
+
+const testRefreshStr = `
+test_instance.foo:
   ID = yes
 `
 const testRefreshCwdStr = `
 test_instance.foo:
   ID = yes
 `
+
+func TestRefresh_stateDir(t *testing.T) {
+	td := tempDir(t)
+	if err := os.MkdirAll(td, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	statePath := filepath.Join(td, DefaultStateFilename)
+	f, err := os.Create(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err = terraform.WriteState(testState(), f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	p.RefreshFn = nil
+	p.RefreshReturn = &terraform.InstanceState{ID: "yes"}
+
+	args := []string{
+		"-state", td + string(os.PathSeparator),
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	if !p.RefreshCalled {
+		t.Fatal("refresh should be called")
+	}
+
+	actual := testStateRead(t, statePath)
+	if actual.RootModule().Resources["test_instance.foo"].Primary.ID != "yes" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestRefresh_stateDirMissing(t *testing.T) {
+	statePath := filepath.Join(tempDir(t), "missing", DefaultStateFilename)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "doesn't exist") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
+	}
+}
+
+func TestRefresh_timeout(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type:    "test_instance",
+						Primary: &terraform.InstanceState{ID: "foo"},
+					},
+					"test_instance.bar": &terraform.ResourceState{
+						Type:    "test_instance",
+						Primary: &terraform.InstanceState{ID: "bar"},
+					},
+				},
+			},
+		},
+	}
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// test_instance.bar depends on test_instance.foo, so foo is refreshed
+	// before bar, which is still being refreshed at the timeout.
+	p.RefreshFn = func(
+		info *terraform.InstanceInfo,
+		s *terraform.InstanceState) (*terraform.InstanceState, error) {
+		if info.Id == "test_instance.bar" {
+			time.Sleep(500 * time.Millisecond)
+		}
+
+		return &terraform.InstanceState{
+			ID:         s.ID,
+			Attributes: map[string]string{"ami": "refreshed"},
+		}, nil
+	}
+
+	start := time.Now()
+	args := []string{
+		"-state", statePath,
+		"-timeout", "250ms",
+		testFixturePath("apply-shutdown"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("took too long: %s", d)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), ErrTimeout.Error()) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The resource refreshed before the timeout is saved
+	actual := testStateRead(t, statePath).RootModule().Resources
+	if v := actual["test_instance.foo"].Primary.Attributes["ami"]; v != "refreshed" {
+		t.Fatalf("bad: %#v", actual["test_instance.foo"].Primary)
+	}
+	if v := actual["test_instance.bar"].Primary.Attributes["ami"]; v != "" {
+		t.Fatalf("bad: %#v", actual["test_instance.bar"].Primary)
+	}
+}
+
+func TestRefresh_timeoutAbandoned(t *testing.T) {
+	defer func(d time.Duration) { operationTimeoutGrace = d }(operationTimeoutGrace)
+	operationTimeoutGrace = 50 * time.Millisecond
+
+	state := testState()
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	// The provider ignores being stopped
+	releaseCh := make(chan struct{})
+	defer close(releaseCh)
+	p.RefreshFn = func(
+		*terraform.InstanceInfo,
+		*terraform.InstanceState) (*terraform.InstanceState, error) {
+		<-releaseCh
+		return &terraform.InstanceState{ID: "yes"}, nil
+	}
+
+	args := []string{
+		"-state", statePath,
+		"-timeout", "50ms",
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "the state wasn't updated") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The state is unchanged
+	actual := testStateRead(t, statePath)
+	if !actual.Equal(state) {
+		t.Fatalf("bad:\n\n%s", actual)
+	}
+}
+
+func TestRefresh_stateUpgrade(t *testing.T) {
+	original, err := ioutil.ReadFile(filepath.Join(
+		testFixturePath("state-v1"), DefaultStateFilename))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	statePath := testTempFile(t)
+	if err := ioutil.WriteFile(statePath, original, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The original state is backed up before it is upgraded
+	backup, err := ioutil.ReadFile(statePath + ".v1.backup")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Fatalf("bad: %s", backup)
+	}
+
+	actual := testStateRead(t, statePath)
+	if actual.Version != terraform.StateVersion {
+		t.Fatalf("bad: %d", actual.Version)
+	}
+	rs := actual.RootModule().Resources["test_instance.foo"]
+	if rs == nil || rs.Primary.ID != "bar" || rs.Primary.Attributes["ami"] != "bar" {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestRefresh_remoteStateChanged(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	conf, srv, written := testRemoteStateRecorder(t)
+	defer srv.Close()
+
+	post := func(s *terraform.State) error {
+		var buf bytes.Buffer
+		if err := terraform.WriteState(s, &buf); err != nil {
+			return err
+		}
+		resp, err := http.Post(srv.URL, "application/json", &buf)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	s := testState()
+	s.Serial = 1
+	s.Remote = conf
+	if err := post(s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	remotePath := filepath.Join(tmp, DefaultDataDir, DefaultStateFilename)
+	testRemoteConfigCache(t, remotePath, s)
+
+	// Someone else saves the state while we're refreshing
+	theirs := s.DeepCopy()
+	theirs.Serial = 5
+	p := testProvider()
+	p.RefreshFn = func(
+		info *terraform.InstanceInfo, s *terraform.InstanceState) (*terraform.InstanceState, error) {
+		if err := post(theirs); err != nil {
+			t.Errorf("err: %s", err)
+		}
+		return &terraform.InstanceState{ID: "yes"}, nil
+	}
+
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	output := ui.ErrorWriter.String()
+	if !strings.Contains(output, "changed by someone else") {
+		t.Fatalf("bad: %s", output)
+	}
+	if !strings.Contains(output, "Suggestion:") || !strings.Contains(output, "terraform refresh") {
+		t.Fatalf("bad: %s", output)
+	}
+
+	// Their state is kept
+	written.Lock()
+	defer written.Unlock()
+	if written.State.Serial != theirs.Serial {
+		t.Fatalf("bad: %d", written.State.Serial)
+	}
+}
+
+func TestRefresh_stateUpgradeDisabled(t *testing.T) {
+	// Run in a temporary working directory, where errored.tfstate would be
+	// written
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	original, err := ioutil.ReadFile(filepath.Join(
+		testFixturePath("state-v1"), DefaultStateFilename))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	statePath := testTempFile(t)
+	if err := ioutil.WriteFile(statePath, original, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &RefreshCommand{
+		Meta: Meta{
+			ContextOpts:             testCtxConfig(p),
+			Ui:                      ui,
+			DisableStateAutoUpgrade: true,
+		},
+	}
+
+	args := []string{
+		"-backup", "-",
+		"-state", statePath,
+		testFixturePath("refresh"),
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "upgrading") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// Nothing is refreshed, since the result couldn't be written
+	if p.RefreshCalled {
+		t.Fatal("refresh should not be called")
+	}
+
+	// The state isn't saved to be put in place of the state file later
+	if _, err := os.Stat(DefaultErroredStateFilename); !os.IsNotExist(err) {
+		t.Fatalf("bad: %s", err)
+	}
+	if strings.Contains(ui.ErrorWriter.String(), DefaultErroredStateFilename) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The state file is unchanged
+	actual, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(actual, original) {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -30,6 +30,9 @@ func (c *RemoteCommand) Run(argsRaw []string) int {
 	case "push":
 		cmd := &RemotePushCommand{Meta: c.Meta}
 		return cmd.Run(args[1:])
+	case "show":
+		cmd := &RemoteShowCommand{Meta: c.Meta}
+		return cmd.Run(args[1:])
 	default:
 		c.Ui.Error(c.Help())
 		return 1
@@ -51,6 +54,7 @@ Available subcommands:
   config      Configure the remote storage settings.
   pull        Sync the remote storage by downloading to local storage.
   push        Sync the remote storage by uploading the local storage.
+  show        Show the state and remote state configuration in use.
 
 `
 	return strings.TrimSpace(helpText)
@@ -3,10 +3,13 @@ package command
 import (
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"regexp"
 	"strings"
 
+	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/state/remote"
 	"github.com/hashicorp/terraform/terraform"
@@ -19,6 +22,10 @@ type remoteCommandConfig struct {
 
 	statePath  string
 	backupPath string
+
+	// configFile is a file of configuration for the backend, which is
+	// merged under the -backend-config pairs.
+	configFile string
 }
 
 // RemoteConfigCommand is a Command implementation that is used to
@@ -40,16 +47,32 @@ func (c *RemoteConfigCommand) Run(args []string) int {
 	cmdFlags := flag.NewFlagSet("remote", flag.ContinueOnError)
 	cmdFlags.BoolVar(&c.conf.disableRemote, "disable", false, "")
 	cmdFlags.BoolVar(&c.conf.pullOnDisable, "pull", true, "")
+	cmdFlags.BoolVar(&c.Meta.input, "input", true, "input")
 	cmdFlags.StringVar(&c.conf.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.StringVar(&c.conf.backupPath, "backup", "", "path")
 	cmdFlags.StringVar(&c.remoteConf.Type, "backend", "atlas", "")
 	cmdFlags.Var((*FlagStringKV)(&config), "backend-config", "config")
+	cmdFlags.StringVar(&c.conf.configFile, "backend-config-file", "", "path")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
 		c.Ui.Error(fmt.Sprintf("\nError parsing CLI flags: %s", err))
 		return 1
 	}
 
+	// The values given on the command line override the ones in the file
+	if c.conf.configFile != "" {
+		fileConfig, err := loadBackendConfigFile(c.conf.configFile)
+		if err != nil {
+			c.Ui.Error(err.Error())
+			return 1
+		}
+
+		for k, v := range config {
+			fileConfig[k] = v
+		}
+		config = fileConfig
+	}
+
 	// Lowercase the type
 	c.remoteConf.Type = strings.ToLower(c.remoteConf.Type)
 
@@ -209,10 +232,43 @@ func (c *RemoteConfigCommand) disableRemoteState() int {
 }
 
 // validateRemoteConfig is used to verify that the remote configuration
-// we have is valid
+// we have is valid. If input is enabled, the user is asked for any
+// required configuration that is missing.
 func (c *RemoteConfigCommand) validateRemoteConfig() error {
 	conf := c.remoteConf
+	asked := make(map[string]bool)
 	_, err := remote.NewClient(conf.Type, conf.Config)
+	for err != nil && c.Input() {
+		// Never ask for the same key twice, since the backend may not
+		// accept the value the user gave for it.
+		key := remoteConfigMissingKey(err)
+		if key == "" || asked[key] {
+			break
+		}
+		asked[key] = true
+
+		v, inputErr := c.UIInput().Input(&terraform.InputOpts{
+			Id:    "backend-config-" + key,
+			Query: fmt.Sprintf("backend-config.%s", key),
+			Description: fmt.Sprintf(
+				"The %q backend requires the %q configuration, which wasn't\n"+
+					"set with -backend-config or -backend-config-file.",
+				conf.Type, key),
+		})
+		if inputErr != nil {
+			c.Ui.Error(fmt.Sprintf("Error asking for %s: %s", key, inputErr))
+			return inputErr
+		}
+		if v == "" {
+			break
+		}
+
+		if conf.Config == nil {
+			conf.Config = make(map[string]string)
+		}
+		conf.Config[key] = v
+		_, err = remote.NewClient(conf.Type, conf.Config)
+	}
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(
 			"%s\n\n"+
@@ -224,6 +280,56 @@ func (c *RemoteConfigCommand) validateRemoteConfig() error {
 	return err
 }
 
+// remoteConfigMissingRegexp matches the error the remote state clients
+// return when a required configuration key isn't set.
+var remoteConfigMissingRegexp = regexp.MustCompile(`missing '([^']+)' configuration`)
+
+// remoteConfigMissingKey returns the required configuration key that the
+// given error from creating a remote client says is missing, or "" if the
+// error is about something else.
+func remoteConfigMissingKey(err error) string {
+	m := remoteConfigMissingRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+// loadBackendConfigFile reads the backend configuration from the HCL or
+// JSON file at path. The file has the format of `key = "value"`, and
+// every value must be a primitive.
+func loadBackendConfigFile(path string) (map[string]string, error) {
+	d, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading backend config file: %s", err)
+	}
+
+	obj, err := hcl.Parse(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing backend config file %s: %s", path, err)
+	}
+
+	var raw map[string]interface{}
+	if err := hcl.DecodeObject(&raw, obj); err != nil {
+		return nil, fmt.Errorf("Error decoding backend config file %s: %s", path, err)
+	}
+
+	result := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v.(type) {
+		case string, int, int64, float64, bool:
+			result[k] = fmt.Sprintf("%v", v)
+		default:
+			return nil, fmt.Errorf(
+				"Error in backend config file %s: the value of %q must be "+
+					"a string, number or bool", path, k)
+		}
+	}
+
+	return result, nil
+}
+
 // initBlank state is used to initialize a blank state that is
 // remote enabled
 func (c *RemoteConfigCommand) initBlankState() int {
@@ -262,9 +368,20 @@ func (c *RemoteConfigCommand) updateRemoteConfig() int {
 
 	// Read in the local state, which is just the cache of the remote state
 	remote := c.stateResult.Remote.Cache
+	state := remote.State()
+
+	// If the state is moving to a different location, it must be copied
+	// there or Terraform would start out with no state at the new location.
+	if state.Remote != nil && !state.Remote.Equals(c.remoteConf) {
+		migrated, code := c.migrateRemoteState(state)
+		if code != 0 {
+			return code
+		}
+
+		state = migrated
+	}
 
 	// Update the configuration
-	state := remote.State()
 	state.Remote = c.remoteConf
 	if err := remote.WriteState(state); err != nil {
 		c.Ui.Error(fmt.Sprintf("%s", err))
@@ -280,6 +397,130 @@ func (c *RemoteConfigCommand) updateRemoteConfig() int {
 	return 0
 }
 
+// migrateRemoteState is used when the remote state configuration changes
+// to ask whether the existing state should be copied to the new location,
+// and to copy it if so. The state to cache for the new location is
+// returned. If the user doesn't want to copy the state, this is the state
+// already at the new location, keeping its lineage, or a blank state if
+// there's none.
+//
+// If we can't ask for input, nothing is copied and the cached state is
+// kept, which is what Terraform always did.
+func (c *RemoteConfigCommand) migrateRemoteState(cached *terraform.State) (*terraform.State, int) {
+	if !c.Input() {
+		log.Printf("[INFO] Input disabled, not copying state to the new remote")
+		return cached, 0
+	}
+
+	v, err := c.UIInput().Input(&terraform.InputOpts{
+		Id:    "remote-migrate",
+		Query: "Do you want to copy existing state to the new backend?",
+		Description: fmt.Sprintf(
+			"The remote state configuration is changing from the %q backend to\n"+
+				"the %q backend. Terraform can copy the existing state to the new\n"+
+				"location. If you don't copy the state, Terraform will use the state\n"+
+				"at the new location, which may be empty. Only 'yes' will be accepted\n"+
+				"to copy the state.",
+			cached.Remote.Type, c.remoteConf.Type),
+	})
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error asking to copy state: %s", err))
+		return nil, 1
+	}
+	if v != "yes" {
+		target, code := c.refreshRemoteTarget()
+		if code != 0 {
+			return nil, code
+		}
+
+		result := target.State()
+		if result == nil {
+			result = terraform.NewState()
+		}
+
+		c.Ui.Output("The existing state was not copied to the new backend.")
+		return result, 0
+	}
+
+	// Get the latest state from the current location to copy
+	current := cached
+	durable := c.stateResult.Remote.Durable
+	if err := durable.RefreshState(); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read the state from the current backend: %s", err))
+		return nil, 1
+	}
+	if s := durable.State(); s != nil {
+		current = s
+	}
+
+	// Backup the state before we copy it
+	backupPath := c.conf.backupPath
+	if backupPath != "-" {
+		if backupPath == "" {
+			backupPath = c.stateResult.RemotePath + DefaultBackupExtension
+		}
+
+		log.Printf("[INFO] Writing backup state to: %s", backupPath)
+		backup := &state.LocalState{Path: backupPath}
+		if err := backup.WriteState(current); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error writing backup state file: %s", err))
+			return nil, 1
+		}
+	}
+
+	// Never overwrite a different state that already exists at the new
+	// location.
+	target, code := c.refreshRemoteTarget()
+	if code != 0 {
+		return nil, code
+	}
+	if existing := target.State(); existing.HasResources() &&
+		existing.Lineage != current.Lineage {
+		c.Ui.Error(fmt.Sprintf(
+			"The new backend already has a state with different resources\n"+
+				"(lineage %q) than the state being copied (lineage %q). Terraform\n"+
+				"won't overwrite it. Please move one of the states manually.",
+			existing.Lineage, current.Lineage))
+		return nil, 1
+	}
+
+	// Copy the state as-is, keeping its lineage, so that it is still known
+	// as the same state at the new location.
+	migrated := current.DeepCopy()
+	migrated.Remote = c.remoteConf
+	if err := target.WriteState(migrated); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to copy state to the new backend: %s", err))
+		return nil, 1
+	}
+	if err := target.PersistState(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to copy state to the new backend: %s", err))
+		return nil, 1
+	}
+
+	c.Ui.Output("Copied the existing state to the new backend.")
+	return migrated, 0
+}
+
+// refreshRemoteTarget returns the remote state at the new location, with
+// the state there read.
+func (c *RemoteConfigCommand) refreshRemoteTarget() (*remote.State, int) {
+	client, err := remote.NewClient(c.remoteConf.Type, c.remoteConf.Config)
+	if err != nil {
+		c.Ui.Error(err.Error())
+		return nil, 1
+	}
+
+	target := &remote.State{Client: client}
+	if err := target.RefreshState(); err != nil {
+		c.Ui.Error(fmt.Sprintf(
+			"Failed to read the state from the new backend: %s", err))
+		return nil, 1
+	}
+
+	return target, 0
+}
+
 // enableRemoteState is used to enable remote state management
 // and to move a state file into place
 func (c *RemoteConfigCommand) enableRemoteState() int {
@@ -358,11 +599,20 @@ Options:
 
   -backend-config="k=v"  Specifies configuration for the remote storage
                          backend. This can be specified multiple times.
+                         These override the values from -backend-config-file.
+
+  -backend-config-file=path  Path to an HCL or JSON file of configuration
+                         for the remote storage backend, in the format of
+                         'key = "value"'.
 
   -backup=path           Path to backup the existing state file before
                          modifying. Defaults to the "-state" path with
                          ".backup" extension. Set to "-" to disable backup.
 
+  -input=true            Ask whether to copy the existing state when the
+                         remote state configuration changes. If false, the
+                         state isn't copied.
+
   -disable               Disables remote state management and migrates the state
                          to the -state path.
 
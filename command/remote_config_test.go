@@ -3,8 +3,13 @@ package command
 import (
 	"bytes"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/hashicorp/terraform/state"
@@ -270,6 +275,181 @@ func TestRemoteConfig_initBlank(t *testing.T) {
 }
 
 // Test initializing without remote settings
+func TestRemoteConfig_initBlankConfigFile(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	configPath := filepath.Join(tmp, "backend.hcl")
+	err := ioutil.WriteFile(configPath, []byte(`
+address = "http://file.example.com"
+access_token = "file"
+retries = 3
+`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-backend-config-file", configPath,
+		"-backend-config", "address=http://example.com",
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	// The command line overrides the file
+	local := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	expected := map[string]string{
+		"address":      "http://example.com",
+		"access_token": "file",
+		"retries":      "3",
+	}
+	if !reflect.DeepEqual(local.Remote.Config, expected) {
+		t.Fatalf("bad: %#v", local.Remote.Config)
+	}
+}
+
+func TestRemoteConfig_initBlankConfigFileJSON(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	configPath := filepath.Join(tmp, "backend.json")
+	err := ioutil.WriteFile(configPath, []byte(
+		`{"address": "http://example.com", "skip_cert_verification": true}`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-backend-config-file", configPath,
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	local := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	expected := map[string]string{
+		"address":                "http://example.com",
+		"skip_cert_verification": "true",
+	}
+	if !reflect.DeepEqual(local.Remote.Config, expected) {
+		t.Fatalf("bad: %#v", local.Remote.Config)
+	}
+}
+
+func TestRemoteConfig_initBlankConfigFileInvalid(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// Only primitive values can be given
+	configPath := filepath.Join(tmp, "backend.hcl")
+	err := ioutil.WriteFile(configPath, []byte(`address = ["a", "b"]`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-backend-config-file", configPath,
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), `"address"`) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
+// A required configuration that isn't set is asked for
+func TestRemoteConfig_initBlankInput(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	test = false
+	defer func() { test = true }()
+	defaultInputReader = bytes.NewBufferString("http://example.com\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	local := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	if local.Remote.Config["address"] != "http://example.com" {
+		t.Fatalf("bad: %#v", local.Remote)
+	}
+}
+
+// Without input, a missing configuration is an error naming it
+func TestRemoteConfig_initBlankMissingConfig(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "missing 'address' configuration") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+}
+
 func TestRemoteConfig_initBlank_missingRemote(t *testing.T) {
 	tmp, cwd := testCwd(t)
 	defer testFixCwd(t, tmp, cwd)
@@ -447,3 +627,241 @@ func testRemoteLocalCache(t *testing.T, exists bool) {
 
 	t.Fatalf("bad: %#v", err)
 }
+
+// Test changing the remote state configuration and copying the state
+func TestRemoteConfig_updateRemoteMigrate(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// The state at the current remote location
+	s := testState()
+	s.Serial = 5
+	oldConf, oldSrv := testRemoteState(t, s, 200)
+	defer oldSrv.Close()
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), s)
+
+	newConf, newSrv, written := testRemoteStateRecorder(t)
+	defer newSrv.Close()
+
+	test = false
+	defer func() { test = true }()
+	defaultInputReader = bytes.NewBufferString("yes\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-backend-config", "address=" + newConf.Config["address"],
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	// The state must have been copied to the new location as the same
+	// state.
+	if written.State == nil {
+		t.Fatal("state should be copied")
+	}
+	if written.State.Lineage != s.Lineage {
+		t.Fatalf("bad: %#v", written.State)
+	}
+	if !written.State.HasResources() {
+		t.Fatalf("bad: %#v", written.State)
+	}
+	if !written.State.Remote.Equals(newConf) {
+		t.Fatalf("bad: %#v", written.State.Remote)
+	}
+
+	// The state from before the copy is backed up
+	backupPath := filepath.Join(DefaultDataDir, DefaultStateFilename) + DefaultBackupExtension
+	backup := testStateRead(t, backupPath)
+	if !backup.Remote.Equals(oldConf) || backup.Lineage != s.Lineage {
+		t.Fatalf("bad: %#v", backup)
+	}
+
+	cache := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	if !cache.Remote.Equals(newConf) || !cache.HasResources() {
+		t.Fatalf("bad: %#v", cache)
+	}
+}
+
+// Test changing the remote state configuration without copying the state
+func TestRemoteConfig_updateRemoteMigrateDecline(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	s.Serial = 5
+	_, oldSrv := testRemoteState(t, s, 200)
+	defer oldSrv.Close()
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), s)
+
+	newConf, newSrv, written := testRemoteStateRecorder(t)
+	defer newSrv.Close()
+
+	test = false
+	defer func() { test = true }()
+	defaultInputReader = bytes.NewBufferString("no\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-backend=http",
+		"-backend-config", "address=" + newConf.Config["address"],
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	// The blank cache may be pushed to the new location, but none of the
+	// existing resources may end up there.
+	if written.State != nil && written.State.HasResources() {
+		t.Fatalf("state should not be copied: %#v", written.State)
+	}
+
+	// The cache must not carry the old state over to the new location
+	cache := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	if !cache.Remote.Equals(newConf) || cache.HasResources() {
+		t.Fatalf("bad: %#v", cache)
+	}
+}
+
+// Test not copying the state to a new location that already has a state
+func TestRemoteConfig_updateRemoteMigrateDeclineExisting(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	_, oldSrv := testRemoteState(t, s, 200)
+	defer oldSrv.Close()
+	testRemoteConfigCache(t, filepath.Join(tmp, DefaultDataDir, DefaultStateFilename), s)
+
+	// The state at the new location is a different one
+	existing := terraform.NewState()
+	existing.Serial = 3
+	newConf, newSrv := testRemoteState(t, existing, 200)
+	defer newSrv.Close()
+
+	test = false
+	defer func() { test = true }()
+	defaultInputReader = bytes.NewBufferString("no\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &RemoteConfigCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	// Without pulling, the cache is what was written when the
+	// configuration changed
+	args := []string{
+		"-backend=http",
+		"-backend-config", "address=" + newConf.Config["address"],
+		"-pull=false",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	// The state at the new location is cached, so that it can be pushed
+	// to without conflicting
+	cache := testStateRead(t, filepath.Join(DefaultDataDir, DefaultStateFilename))
+	if cache.Lineage != existing.Lineage || cache.HasResources() {
+		t.Fatalf("bad: %#v", cache)
+	}
+	if !cache.Remote.Equals(newConf) {
+		t.Fatalf("bad: %#v", cache.Remote)
+	}
+}
+
+// testRemoteConfigCache writes the given state as the local cache of the
+// remote state at path.
+func testRemoteConfigCache(t *testing.T, path string, s *terraform.State) {
+	ls := &state.LocalState{Path: path}
+	if err := ls.WriteState(s); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ls.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testRemoteStateWritten records the state written to the server made by
+// testRemoteStateRecorder.
+type testRemoteStateWritten struct {
+	sync.Mutex
+	State *terraform.State
+}
+
+// testRemoteStateRecorder makes a test HTTP server for remote state that
+// starts out empty and records the state written to it.
+func testRemoteStateRecorder(t *testing.T) (*terraform.RemoteState, *httptest.Server, *testRemoteStateWritten) {
+	written := new(testRemoteStateWritten)
+	var data []byte
+
+	cb := func(resp http.ResponseWriter, req *http.Request) {
+		written.Lock()
+		defer written.Unlock()
+
+		if req.Method == "POST" {
+			var err error
+			data, err = ioutil.ReadAll(req.Body)
+			if err != nil {
+				resp.WriteHeader(500)
+				return
+			}
+
+			written.State, err = terraform.ReadState(bytes.NewReader(data))
+			if err != nil {
+				resp.WriteHeader(500)
+				return
+			}
+
+			return
+		}
+
+		if data == nil {
+			resp.WriteHeader(404)
+			return
+		}
+
+		resp.Write(data)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(cb))
+	remote := &terraform.RemoteState{
+		Type:   "http",
+		Config: map[string]string{"address": srv.URL},
+	}
+
+	return remote, srv, written
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"crypto/md5"
 	"encoding/base64"
-	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -104,8 +103,7 @@ func testRemoteState(t *testing.T, s *terraform.State, c int) (*terraform.Remote
 		// Set the remote data
 		s.Remote = remote
 
-		enc := json.NewEncoder(buf)
-		if err := enc.Encode(s); err != nil {
+		if err := terraform.WriteState(s, buf); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 		md5 := md5.Sum(buf.Bytes())
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/state/remote"
 )
 
 type RemotePushCommand struct {
@@ -57,13 +58,17 @@ func (c *RemotePushCommand) Run(args []string) int {
 		return 1
 	}
 
-	// Write it to the real storage
-	remote := cache.Durable
-	if err := remote.WriteState(cache.Cache.State()); err != nil {
+	// Write it to the real storage, overwriting any changes made to the
+	// remote state since we read it if forced.
+	durable := cache.Durable
+	if rs, ok := durable.(*remote.State); ok {
+		rs.Force = force
+	}
+	if err := durable.WriteState(cache.Cache.State()); err != nil {
 		c.Ui.Error(fmt.Sprintf("Error writing state: %s", err))
 		return 1
 	}
-	if err := remote.PersistState(); err != nil {
+	if err := durable.PersistState(); err != nil {
 		c.Ui.Error(fmt.Sprintf("Error saving state: %s", err))
 		return 1
 	}
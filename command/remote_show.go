@@ -0,0 +1,132 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/state/remote"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// RemoteShowCommand is a Command implementation that shows which state
+// operations use, and how the remote state is configured if there is one.
+type RemoteShowCommand struct {
+	Meta
+}
+
+func (c *RemoteShowCommand) Run(args []string) int {
+	args = c.Meta.process(args, false)
+	cmdFlags := c.Meta.flagSet("remote show")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if len(cmdFlags.Args()) > 0 {
+		c.Ui.Error("The remote show command expects no arguments.")
+		cmdFlags.Usage()
+		return 1
+	}
+
+	// Only the cache of the remote state is read, so that this works
+	// without access to the remote storage.
+	stateOpts := c.StateOpts()
+	stateOpts.RemoteCacheOnly = true
+	result, err := State(stateOpts)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error reading state: %s", err))
+		return 1
+	}
+
+	var fields [][2]string
+	add := func(name, value string) {
+		fields = append(fields, [2]string{name, value})
+	}
+
+	// Operations use the remote state if one is configured. The local state
+	// file can only override it for operations given -state explicitly.
+	var rs *terraform.State
+	if result.Remote != nil {
+		rs = result.Remote.State()
+	}
+	if rs.IsRemote() {
+		add("State", "remote")
+	} else {
+		rs = nil
+		add("State", "local")
+		if result.Local != nil {
+			path := result.Local.Describe()
+			if abs, err := filepath.Abs(result.Local.Path); err == nil {
+				path = strings.Replace(path, result.Local.Path, abs, 1)
+			}
+			add("Path", path)
+		}
+	}
+
+	if rs != nil {
+		location := "unknown"
+		client, err := remote.NewClient(strings.ToLower(rs.Remote.Type), rs.Remote.Config)
+		if err != nil {
+			location = fmt.Sprintf("unknown (%s)", err)
+		} else if d := (&remote.State{Client: client}).Describe(); d != "" {
+			location = d
+		}
+
+		add("Remote type", rs.Remote.Type)
+		add("Remote location", location)
+		add("Remote cache", result.RemotePath)
+	}
+
+	for _, f := range fields {
+		c.Ui.Output(fmt.Sprintf("%-17s %s", f[0]+":", f[1]))
+	}
+
+	if rs != nil && len(rs.Remote.Config) > 0 {
+		conf := remote.MaskConfig(strings.ToLower(rs.Remote.Type), rs.Remote.Config)
+		keys := make([]string, 0, len(conf))
+		width := 0
+		for k := range conf {
+			keys = append(keys, k)
+			if len(k) > width {
+				width = len(k)
+			}
+		}
+		sort.Strings(keys)
+
+		c.Ui.Output("\nRemote configuration:")
+		for _, k := range keys {
+			c.Ui.Output(fmt.Sprintf("  %-*s = %s", width, k, conf[k]))
+		}
+	}
+
+	return 0
+}
+
+func (c *RemoteShowCommand) Help() string {
+	helpText := `
+Usage: terraform remote show [options]
+
+  Show which state operations in this working directory use: the local
+  state file, or the remote state along with where it's stored and the
+  local file it's cached in.
+
+  The configuration of the remote state is shown too, with the values of
+  sensitive options such as passwords masked. Only the cached remote
+  state is read, so the remote storage isn't accessed.
+
+Options:
+
+  -no-color           If specified, output won't contain any color.
+
+  -state=path         Path to the local state file. Defaults to
+                      "terraform.tfstate".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *RemoteShowCommand) Synopsis() string {
+	return "Show the state and remote state configuration in use"
+}
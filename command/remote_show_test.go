@@ -0,0 +1,139 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+func TestRemoteShow_local(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	ui := new(cli.MockUi)
+	c := &RemoteShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run(nil); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := []string{
+		"State:            local\n",
+		"Path:             " + filepath.Join(tmp, DefaultStateFilename) + "\n",
+	}
+	for _, v := range expected {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
+	}
+	if strings.Contains(output, "Remote") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestRemoteShow_remote(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	testRemoteShowCache(t, &terraform.RemoteState{
+		Type: "http",
+		Config: map[string]string{
+			"address":  "http://example.com/state",
+			"username": "foo",
+			"password": "secret",
+		},
+	})
+
+	ui := new(cli.MockUi)
+	c := &RemoteShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run(nil); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := []string{
+		"State:            remote\n",
+		"Remote type:      http\n",
+		"Remote location:  http://example.com/state\n",
+		"Remote cache:     " + filepath.Join(DefaultDataDir, DefaultStateFilename) + "\n",
+		"  address  = http://example.com/state\n",
+		"  password = (sensitive)\n",
+		"  username = foo\n",
+	}
+	for _, v := range expected {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
+	}
+	if strings.Contains(output, "secret") || strings.Contains(output, "Path:") {
+		t.Fatalf("bad: %s", output)
+	}
+}
+
+func TestRemoteShow_remoteUnknownLocation(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	// The inmem client can't describe where it stores the state
+	testRemoteShowCache(t, &terraform.RemoteState{
+		Type:   "inmem",
+		Config: map[string]string{"name": "foo"},
+	})
+
+	ui := new(cli.MockUi)
+	c := &RemoteShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+	if code := c.Run(nil); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	expected := []string{
+		"Remote type:      inmem\n",
+		"Remote location:  unknown\n",
+		"  name = foo\n",
+	}
+	for _, v := range expected {
+		if !strings.Contains(output, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, output)
+		}
+	}
+}
+
+// testRemoteShowCache writes the cache of a remote state with the given
+// configuration to the data directory.
+func testRemoteShowCache(t *testing.T, conf *terraform.RemoteState) {
+	s := terraform.NewState()
+	s.Remote = conf
+
+	path := filepath.Join(DefaultDataDir, DefaultStateFilename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+	if err := terraform.WriteState(s, f); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"strings"
 
@@ -49,7 +50,11 @@ func (c *ShowCommand) Run(args []string) int {
 		}
 		defer f.Close()
 
-		plan, err = terraform.ReadPlan(f)
+		plan, err = readPlan(f, c.planEncryptionKey())
+		if _, ok := err.(*planDecryptError); ok {
+			c.Ui.Error(fmt.Sprintf("Error reading plan: %s", err))
+			return 1
+		}
 		if err != nil {
 			if _, err := f.Seek(0, 0); err != nil {
 				c.Ui.Error(fmt.Sprintf("Error reading file: %s", err))
@@ -92,11 +97,40 @@ func (c *ShowCommand) Run(args []string) int {
 	}
 
 	if plan != nil {
-		c.Ui.Output(FormatPlan(&FormatPlanOpts{
+		// Older plans don't record the version that created them
+		if plan.TerraformVersion != "" {
+			c.Ui.Output(fmt.Sprintf(
+				"Generated by Terraform v%s\n", plan.TerraformVersion))
+		}
+
+		if msg := c.planStale(plan); msg != "" {
+			c.Ui.Output(c.Colorize().Color(fmt.Sprintf(
+				"[reset][bold][yellow]STALE:[reset][yellow] %s\n", msg)))
+		}
+
+		// Stream the plan to the UI since it can be very large
+		planOut := &UiWriter{Ui: c.Ui}
+		err := FormatPlanWrite(planOut, &FormatPlanOpts{
 			Plan:        plan,
 			Color:       c.Colorize(),
 			ModuleDepth: moduleDepth,
-		}))
+		})
+		planOut.Close()
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error formatting plan: %s", err))
+			return 1
+		}
+
+		// Count the changes the same way plan does, from the saved diff
+		// so that no provider is needed.
+		if plan.Diff != nil && !plan.Diff.Empty() {
+			countHook := new(CountHook)
+			countHook.CountDiff(plan.Diff)
+			c.Ui.Output(c.Colorize().Color(formatPlanSummary(countHook)))
+			if summary := formatPlanTypeSummary(countHook); summary != "" {
+				c.Ui.Output(c.Colorize().Color("\n" + summary))
+			}
+		}
 		return 0
 	}
 
@@ -108,6 +142,28 @@ func (c *ShowCommand) Run(args []string) int {
 	return 0
 }
 
+// planStale returns a message explaining why the plan is stale if the
+// state it was created from is behind the current state, or an empty
+// string if it isn't. Applying a stale plan would undo the changes made
+// to the state since the plan was created.
+func (c *ShowCommand) planStale(plan *terraform.Plan) string {
+	if plan.State == nil {
+		return ""
+	}
+
+	result, err := State(c.StateOpts())
+	if err != nil {
+		// The plan can still be shown, we just can't tell if it's stale
+		log.Printf("[WARN] Error reading state to check the plan: %s", err)
+		return ""
+	}
+	if err := planStaleError(plan.State, result.State.State()); err != nil {
+		return err.Error()
+	}
+
+	return ""
+}
+
 func (c *ShowCommand) Help() string {
 	helpText := `
 Usage: terraform show [options] [path]
@@ -115,6 +171,9 @@ Usage: terraform show [options] [path]
   Reads and outputs a Terraform state or plan file in a human-readable
   form. If no path is specified, the current state will be shown.
 
+  Plans are shown with the number of resources they change, and are
+  marked as stale if the current state changed since they were created.
+
 Options:
 
   -module-depth=n     Specifies the depth of modules to show in the output.
@@ -125,6 +125,141 @@ func TestShow_plan(t *testing.T) {
 	}
 }
 
+func TestShow_planStats(t *testing.T) {
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module: new(module.Tree),
+		Diff: &terraform.Diff{
+			Modules: []*terraform.ModuleDiff{
+				&terraform.ModuleDiff{
+					Path: []string{"root"},
+					Resources: map[string]*terraform.InstanceDiff{
+						"test_instance.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"ami": &terraform.ResourceAttrDiff{
+									New: "bar",
+								},
+							},
+						},
+						"test_instance.bar": &terraform.InstanceDiff{
+							Destroy: true,
+						},
+						"data.test_data_source.foo": &terraform.InstanceDiff{
+							Attributes: map[string]*terraform.ResourceAttrDiff{
+								"id": &terraform.ResourceAttrDiff{
+									NewComputed: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &ShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	output := ui.OutputWriter.String()
+	if !strings.Contains(output, "Plan: 0 to add, 1 to change, 1 to destroy.") {
+		t.Fatalf("bad:\n\n%s", output)
+	}
+	if p.DiffCalled || p.RefreshCalled {
+		t.Fatal("the provider should not be used")
+	}
+}
+
+func TestShow_planStale(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	current := testState()
+	current.Serial = 5
+	testStateFileDefault(t, current)
+
+	cases := []struct {
+		Serial  int64
+		Lineage string
+		Stale   string
+	}{
+		{5, current.Lineage, ""},
+		{7, current.Lineage, ""},
+		{3, current.Lineage, "created from the state at serial 3"},
+		{5, "other", "created for a different state"},
+	}
+
+	for _, tc := range cases {
+		planState := current.DeepCopy()
+		planState.Serial = tc.Serial
+		planState.Lineage = tc.Lineage
+		planPath := testPlanFile(t, &terraform.Plan{
+			Module: new(module.Tree),
+			State:  planState,
+		})
+
+		ui := new(cli.MockUi)
+		c := &ShowCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(testProvider()),
+				Ui:          ui,
+			},
+		}
+
+		if code := c.Run([]string{planPath}); code != 0 {
+			t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+		}
+
+		output := ui.OutputWriter.String()
+		if tc.Stale == "" {
+			if strings.Contains(output, "STALE") {
+				t.Fatalf("%d: plan should not be stale:\n\n%s", tc.Serial, output)
+			}
+			continue
+		}
+		if !strings.Contains(output, "STALE") || !strings.Contains(output, tc.Stale) {
+			t.Fatalf("%d: expected %q in:\n\n%s", tc.Serial, tc.Stale, output)
+		}
+	}
+}
+
+func TestShow_planVersion(t *testing.T) {
+	planPath := testPlanFile(t, &terraform.Plan{
+		Module:           new(module.Tree),
+		TerraformVersion: "0.1.0",
+	})
+
+	ui := new(cli.MockUi)
+	c := &ShowCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(testProvider()),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		planPath,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: \n%s", ui.ErrorWriter.String())
+	}
+
+	if !strings.Contains(ui.OutputWriter.String(), "Generated by Terraform v0.1.0") {
+		t.Fatalf("bad: \n%s", ui.OutputWriter.String())
+	}
+}
+
 func TestShow_noArgsRemoteState(t *testing.T) {
 	tmp, cwd := testCwd(t)
 	defer testFixCwd(t, tmp, cwd)
@@ -1,9 +1,13 @@
 package command
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/terraform/state"
@@ -33,9 +37,43 @@ type StateOpts struct {
 	// plus the DefaultBackupExtension.
 	BackupPath string
 
+	// BackupDir, if set, is the directory the backup is written to instead
+	// of the directory of the state, keeping the name of the backup. It is
+	// ignored if BackupPath is set.
+	BackupDir string
+
+	// BackupFallbackDir, if set, is the directory the backup is written to
+	// if it can't be written next to the state, keeping the name of the
+	// backup. OnBackupFallback is then called with the path of the backup
+	// and the error from writing it next to the state. It is ignored if
+	// BackupPath or BackupDir is set, since those are given explicitly.
+	BackupFallbackDir string
+	OnBackupFallback  func(path string, err error)
+
 	// ForceState is a state structure to force the value to be. This
 	// is used by Terraform plans (which contain their state).
 	ForceState *terraform.State
+
+	// DisableAutoUpgrade makes writing a local state file in an older
+	// format an error instead of upgrading it. See
+	// state.LocalState.DisableAutoUpgrade.
+	DisableAutoUpgrade bool
+
+	// LocalOverride, if true, uses the state file at LocalPath even if
+	// remote state is configured, instead of failing because both exist.
+	LocalOverride bool
+
+	// StaleLockTimeout is the state.LocalState.StaleLockTimeout of the
+	// local state.
+	StaleLockTimeout time.Duration
+
+	// LockPath is the state.LocalState.LockPath of the local state.
+	LockPath string
+
+	// Store, if set, is used as the state instead of the local or remote
+	// state, such as a state.InmemState in tests. ForceState is written
+	// to it, and it isn't backed up.
+	Store state.State
 }
 
 // StateResult is the result of calling State and holds various different
@@ -56,6 +94,11 @@ type StateResult struct {
 	LocalPath  string
 	Remote     *state.CacheState
 	RemotePath string
+
+	// Override is true if the local state file is used instead of the
+	// remote state because of StateOpts.LocalOverride. Remote is still
+	// the remote state then.
+	Override bool
 }
 
 // State returns the proper state.State implementation to represent the
@@ -65,6 +108,16 @@ type StateResult struct {
 // dataDir is the path to the local data directory where the remote state
 // cache would be stored.
 func State(opts *StateOpts) (*StateResult, error) {
+	if opts.Store != nil {
+		if opts.ForceState != nil {
+			if err := opts.Store.WriteState(opts.ForceState); err != nil {
+				return nil, err
+			}
+		}
+
+		return &StateResult{State: opts.Store}, nil
+	}
+
 	result := new(StateResult)
 
 	// Get the remote state cache path
@@ -142,9 +195,20 @@ func State(opts *StateOpts) (*StateResult, error) {
 
 	// Do we have a local state?
 	if opts.LocalPath != "" {
+		var err error
+		if opts.LocalPath, err = localStatePath(opts.LocalPath); err != nil {
+			return nil, err
+		}
+		if opts.LocalPathOut != "" {
+			opts.LocalPathOut = localStateFilePath(opts.LocalPathOut)
+		}
+
 		local := &state.LocalState{
-			Path:    opts.LocalPath,
-			PathOut: opts.LocalPathOut,
+			Path:               opts.LocalPath,
+			PathOut:            opts.LocalPathOut,
+			DisableAutoUpgrade: opts.DisableAutoUpgrade,
+			StaleLockTimeout:   opts.StaleLockTimeout,
+			LockPath:           opts.LockPath,
 		}
 
 		// Always store it in the result even if we're not using it
@@ -163,15 +227,16 @@ func State(opts *StateOpts) (*StateResult, error) {
 			err := local.RefreshState()
 			if err == nil {
 				if result.State != nil && !result.State.State().Empty() {
-					if !local.State().Empty() {
+					if local.State().Empty() {
+						local = nil
+					} else if opts.LocalOverride {
+						result.Override = true
+					} else {
 						// We already have a remote state... that is an error.
 						return nil, fmt.Errorf(
 							"Remote state found, but state file '%s' also present.",
 							opts.LocalPath)
 					}
-
-					// Empty state
-					local = nil
 				}
 			}
 			if err != nil {
@@ -192,15 +257,29 @@ func State(opts *StateOpts) (*StateResult, error) {
 	// If we have a result, make sure to back it up
 	if result.State != nil {
 		backupPath := result.StatePath + DefaultBackupExtension
-		if opts.BackupPath != "" {
+		var fallbackPath string
+		switch {
+		case opts.BackupPath != "":
 			backupPath = opts.BackupPath
+		case opts.BackupDir != "":
+			backupPath = filepath.Join(opts.BackupDir, filepath.Base(backupPath))
+		case opts.BackupFallbackDir != "":
+			fallbackPath = filepath.Join(
+				opts.BackupFallbackDir, filepath.Base(backupPath))
 		}
 
 		if backupPath != "-" {
-			result.State = &state.BackupState{
-				Real: result.State,
-				Path: backupPath,
+			backup := &state.BackupState{
+				Real:         result.State,
+				Path:         backupPath,
+				FallbackPath: fallbackPath,
+			}
+			if fallbackPath != "" && opts.OnBackupFallback != nil {
+				backup.OnFallback = func(err error) {
+					opts.OnBackupFallback(fallbackPath, err)
+				}
 			}
+			result.State = backup
 		}
 	}
 
@@ -208,6 +287,144 @@ func State(opts *StateOpts) (*StateResult, error) {
 	return result, nil
 }
 
+// Description returns a one line description of the state in the result,
+// such as the path of the state file and its serial, which is output
+// before the plan so that it's clear which state an operation used.
+func (r *StateResult) Description() string {
+	var s *terraform.State
+	if r.State != nil {
+		s = r.State.State()
+	}
+
+	serial := "no state yet"
+	if s != nil {
+		serial = fmt.Sprintf("serial %d", s.Serial)
+	}
+
+	if r.Remote != nil && s.IsRemote() && !r.Override {
+		return fmt.Sprintf("remote state %s (%s), cached in %s",
+			remoteStateWhere(s.Remote), serial, r.RemotePath)
+	}
+
+	if r.Local == nil {
+		return serial
+	}
+
+	path := r.Local.Path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	result := fmt.Sprintf("%s (%s)", path, serial)
+	if out := r.Local.PathOut; out != "" && out != r.Local.Path {
+		result = fmt.Sprintf("%s, saved to %s", result, out)
+	}
+	if r.Override {
+		result = fmt.Sprintf("%s, overriding remote state %s", result,
+			remoteStateWhere(r.Remote.State().Remote))
+	}
+
+	return result
+}
+
+// remoteStateWhere returns where the remote state is kept, for describing
+// it: its type and its address if it has one. Credentials in the address
+// are masked like in the output of 'terraform remote config'.
+func remoteStateWhere(rs *terraform.RemoteState) string {
+	if rs == nil {
+		return "unknown"
+	}
+
+	where := rs.Type
+	if rs.Config["address"] != "" {
+		addr := remote.MaskConfig(strings.ToLower(rs.Type), rs.Config)["address"]
+		where = fmt.Sprintf("%s at %s", where, addr)
+	}
+
+	return where
+}
+
+// errStateReadOnly is returned when writing a readOnlyState.
+var errStateReadOnly = errors.New(
+	"the state is read-only for this operation, because the -state file\n" +
+		"is used instead of the configured remote state")
+
+// readOnlyState is a state.State that can't be written or locked, for
+// operations that use a state file in place of the state of record.
+type readOnlyState struct {
+	state.StateReader
+	state.StateRefresher
+}
+
+func (s *readOnlyState) WriteState(*terraform.State) error {
+	return errStateReadOnly
+}
+
+func (s *readOnlyState) PersistState() error {
+	return errStateReadOnly
+}
+
+// checkOverrideLineage returns an error if the local state file that
+// overrides the remote state in the result is for a different state than
+// the remote state, going by their lineages, unless force is set.
+func checkOverrideLineage(r *StateResult, force bool) error {
+	local, remote := r.Local.State(), r.Remote.State()
+	if force || local == nil || remote == nil {
+		return nil
+	}
+	if local.Lineage == "" || remote.Lineage == "" || local.Lineage == remote.Lineage {
+		return nil
+	}
+
+	return fmt.Errorf(
+		"The state file %s has lineage %s, but the remote state has lineage\n"+
+			"%s, so it isn't a version of the same state. Use -force to use the\n"+
+			"state file anyways.",
+		r.Local.Path, local.Lineage, remote.Lineage)
+}
+
+// localStatePath resolves the path given for the local state file that is
+// read, like localStateFilePath. The directory the state file is in must
+// already exist, so that a mistyped path is reported before any work is
+// done instead of when the state is written.
+func localStatePath(path string) (string, error) {
+	path = localStateFilePath(path)
+
+	dir := filepath.Dir(path)
+	fi, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf(
+				"The directory %q for the state file %q doesn't exist. Please\n"+
+					"create it or use a different state path.", dir, path)
+		}
+
+		return "", errwrap.Wrapf(fmt.Sprintf(
+			"Error checking state path %q: {{err}}", path), err)
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf(
+			"The state path %q is invalid: %q is not a directory.", path, dir)
+	}
+
+	return path, nil
+}
+
+// localStateFilePath resolves the path given for a local state file. A path
+// to a directory, or a path ending in a separator, refers to the state file
+// with the default name in that directory. The directory doesn't need to
+// exist, such as for -state-out, since it is created when the state is
+// written.
+func localStateFilePath(path string) string {
+	fi, err := os.Stat(path)
+	if (err == nil && fi.IsDir()) || os.IsPathSeparator(path[len(path)-1]) {
+		resolved := filepath.Join(path, DefaultStateFilename)
+		log.Printf("[INFO] State path %q is a directory, using %q", path, resolved)
+		path = resolved
+	}
+
+	return path
+}
+
 func remoteState(
 	local *terraform.State,
 	localPath string, refresh bool) (*state.CacheState, error) {
@@ -2,6 +2,7 @@ package command
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/hashicorp/terraform/terraform"
@@ -17,7 +18,9 @@ type StateListCommand struct {
 func (c *StateListCommand) Run(args []string) int {
 	args = c.Meta.process(args, true)
 
+	var module string
 	cmdFlags := c.Meta.flagSet("state list")
+	cmdFlags.StringVar(&module, "module", "", "module")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	if err := cmdFlags.Parse(args); err != nil {
 		return cli.RunResultHelp
@@ -36,22 +39,105 @@ func (c *StateListCommand) Run(args []string) int {
 		return 1
 	}
 
-	filter := &terraform.StateFilter{State: stateReal}
-	results, err := filter.Filter(args...)
+	results, err := stateListFilter(stateReal, args)
 	if err != nil {
 		c.Ui.Error(fmt.Sprintf(errStateFilter, err))
 		return cli.RunResultHelp
 	}
 
+	modPath := outputModulePath(module)[1:]
 	for _, result := range results {
-		if _, ok := result.Value.(*terraform.InstanceState); ok {
-			c.Ui.Output(result.Address)
+		if _, ok := result.Value.(*terraform.InstanceState); !ok {
+			continue
 		}
+		if !stateListInModule(result.Path, modPath) {
+			continue
+		}
+
+		c.Ui.Output(result.Address)
 	}
 
 	return 0
 }
 
+// stateListFilter returns the results in the state matching any of the
+// given patterns, sorted by address. Patterns with a "*" or "?", such as
+// "aws_instance.*", are globs matched against the addresses of the results,
+// where a "*" also matches the periods between address parts.
+// Anything else is a resource address, which also matches everything in
+// the resource or module it refers to.
+func stateListFilter(
+	s *terraform.State, patterns []string) ([]*terraform.StateFilterResult, error) {
+	filter := &terraform.StateFilter{State: s}
+
+	var addrs, globs []string
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?") {
+			// Check the pattern now so a bad one isn't silently ignored
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("Error parsing pattern '%s': %s", p, err)
+			}
+
+			globs = append(globs, p)
+		} else {
+			addrs = append(addrs, p)
+		}
+	}
+
+	if len(globs) == 0 {
+		return filter.Filter(addrs...)
+	}
+
+	matched := make(map[string]bool)
+	if len(addrs) > 0 {
+		results, err := filter.Filter(addrs...)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			matched[r.String()] = true
+		}
+	}
+
+	all, err := filter.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*terraform.StateFilterResult, 0, len(all))
+	for _, r := range all {
+		ok := matched[r.String()]
+		for _, g := range globs {
+			if m, _ := path.Match(g, r.Address); m {
+				ok = true
+			}
+		}
+
+		if ok {
+			results = append(results, r)
+		}
+	}
+
+	return results, nil
+}
+
+// stateListInModule returns true if the result at the given module path
+// is in the module at modPath or one of its children. Both paths don't
+// include the root module.
+func stateListInModule(resultPath, modPath []string) bool {
+	if len(resultPath) < len(modPath) {
+		return false
+	}
+
+	for i, name := range modPath {
+		if resultPath[i] != name {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (c *StateListCommand) Help() string {
 	helpText := `
 Usage: terraform state list [options] [pattern...]
@@ -68,10 +154,15 @@ Usage: terraform state list [options] [pattern...]
 
   The pattern argument accepts any resource targeting syntax. Please
   refer to the documentation on resource targeting syntax for more
-  information.
+  information. Patterns with glob characters, such as "aws_instance.*"
+  or "module.foo.*", are matched against the resource addresses instead.
 
 Options:
 
+  -module=path        Only list the resources in the given module and its
+                      child modules, given as "foo.bar" or
+                      "module.foo.module.bar".
+
   -state=statefile    Path to a Terraform state file to use to look
                       up Terraform-managed resources. By default it will
                       use the state "terraform.tfstate" if it exists.
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
 
@@ -54,6 +55,161 @@ func TestStateList_noState(t *testing.T) {
 	}
 }
 
+func TestStateList_filter(t *testing.T) {
+	statePath := testStateFile(t, testStateListMultiState())
+
+	cases := []struct {
+		Args     []string
+		Expected []string
+	}{
+		{
+			[]string{"test_instance.foo"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"module.child.test_instance.foo",
+				"test_instance.foo[0]",
+				"test_instance.foo[1]",
+			},
+		},
+		{
+			[]string{"test_instance.foo[1]"},
+			[]string{"test_instance.foo[1]"},
+		},
+		{
+			[]string{"module.child"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"module.child.test_instance.foo",
+			},
+		},
+		{
+			[]string{"test_instance.*"},
+			[]string{"test_instance.bar", "test_instance.foo[0]", "test_instance.foo[1]"},
+		},
+		{
+			[]string{"-module=child", "test_instance.foo"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"module.child.test_instance.foo",
+			},
+		},
+		{
+			[]string{"module.*.test_instance.foo"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"module.child.test_instance.foo",
+			},
+		},
+		{
+			[]string{"test_instance.bar", "module.child.module.*"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"test_instance.bar",
+			},
+		},
+		{
+			[]string{"-module=child"},
+			[]string{
+				"module.child.module.grandchild.test_instance.foo",
+				"module.child.test_instance.foo",
+			},
+		},
+		{
+			[]string{"-module=module.child.module.grandchild"},
+			[]string{"module.child.module.grandchild.test_instance.foo"},
+		},
+		{
+			[]string{"-module=child", "test_instance.bar"},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		p := testProvider()
+		ui := new(cli.MockUi)
+		c := &StateListCommand{
+			Meta: Meta{
+				ContextOpts: testCtxConfig(p),
+				Ui:          ui,
+			},
+		}
+
+		args := append([]string{"-state", statePath}, tc.Args...)
+		if code := c.Run(args); code != 0 {
+			t.Fatalf("%v: bad: %d\n\n%s", tc.Args, code, ui.ErrorWriter.String())
+		}
+
+		var expected string
+		if len(tc.Expected) > 0 {
+			expected = strings.Join(tc.Expected, "\n") + "\n"
+		}
+		var actual string
+		if ui.OutputWriter != nil {
+			actual = ui.OutputWriter.String()
+		}
+		if actual != expected {
+			t.Fatalf("%v: expected:\n%s\n\ngot:\n%s", tc.Args, expected, actual)
+		}
+	}
+}
+
+func TestStateList_badPattern(t *testing.T) {
+	statePath := testStateFile(t, testStateListMultiState())
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateListCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.*[",
+	}
+	if code := c.Run(args); code == 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+}
+
+// testStateListMultiState returns a state with resources in the root
+// module, a counted resource, and nested modules.
+func testStateListMultiState() *terraform.State {
+	instance := func(id string) *terraform.ResourceState {
+		return &terraform.ResourceState{
+			Type:    "test_instance",
+			Primary: &terraform.InstanceState{ID: id},
+		}
+	}
+
+	return &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo.0": instance("foo0"),
+					"test_instance.foo.1": instance("foo1"),
+					"test_instance.bar":   instance("bar"),
+				},
+			},
+			&terraform.ModuleState{
+				Path: []string{"root", "child"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": instance("child"),
+				},
+			},
+			&terraform.ModuleState{
+				Path: []string{"root", "child", "grandchild"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": instance("grandchild"),
+				},
+			},
+		},
+	}
+}
+
 const testStateListOutput = `
 test_instance.foo
 `
@@ -3,8 +3,10 @@ package command
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -42,6 +44,20 @@ func (c *StateMeta) State(m *Meta) (state.State, error) {
 	return s, nil
 }
 
+// lockState locks the state returned by State for the given operation if
+// it supports locking, and reads it again once the lock is held so that
+// changes made by the previous holder aren't lost. The lock is released
+// with m.unlockState.
+func (c *StateMeta) lockState(
+	m *Meta, s state.State, operation string, timeout time.Duration) error {
+	copts := contextOpts{Operation: operation, LockTimeout: timeout}
+	if err := m.lockState(s, copts); err != nil {
+		return err
+	}
+
+	return s.RefreshState()
+}
+
 // filterInstance filters a single instance out of filter results.
 func (c *StateMeta) filterInstance(rs []*terraform.StateFilterResult) (*terraform.StateFilterResult, error) {
 	var result *terraform.StateFilterResult
@@ -60,6 +76,92 @@ func (c *StateMeta) filterInstance(rs []*terraform.StateFilterResult) (*terrafor
 	return result, nil
 }
 
+// stateDependencyRef returns the path of the module where the resources
+// that depend on the item at the given address are, and the name they use
+// to refer to it. Only whole resources and modules can be referred to, so
+// false is returned for anything else.
+func stateDependencyRef(addr *terraform.ResourceAddress) ([]string, string, bool) {
+	if addr.Index != -1 || addr.InstanceTypeSet {
+		return nil, "", false
+	}
+
+	path := append([]string{"root"}, addr.Path...)
+	if addr.Type == "" && addr.Name == "" {
+		// A module is referred to by its parent
+		if len(addr.Path) == 0 {
+			return nil, "", false
+		}
+
+		return path[:len(path)-1], "module." + addr.Path[len(addr.Path)-1], true
+	}
+
+	name := fmt.Sprintf("%s.%s", addr.Type, addr.Name)
+	if addr.Mode == config.DataResourceMode {
+		name = "data." + name
+	}
+
+	return path, name, true
+}
+
+// stateUpdateDependencies updates the dependencies of the resources in the
+// state on the item at the from address, which has been moved to the to
+// address or removed if to is nil. Dependencies only refer to items in the
+// same module, so they are only renamed when the item stays in the same
+// module; otherwise they're removed like they are for removed items.
+func stateUpdateDependencies(s *terraform.State, from, to *terraform.ResourceAddress) {
+	if from == nil {
+		return
+	}
+
+	path, fromName, ok := stateDependencyRef(from)
+	if !ok {
+		return
+	}
+
+	var toName string
+	if to != nil {
+		toPath, name, ok := stateDependencyRef(to)
+		if ok && strings.Join(toPath, ".") == strings.Join(path, ".") {
+			toName = name
+		}
+	}
+
+	mod := s.ModuleByPath(path)
+	if mod == nil {
+		return
+	}
+
+	for _, rs := range mod.Resources {
+		changed := false
+		deps := make([]string, 0, len(rs.Dependencies))
+		for _, dep := range rs.Dependencies {
+			// A dependency can have several names separated by "/", such
+			// as "aws_instance.foo.0/aws_instance.foo.N".
+			parts := strings.Split(dep, "/")
+			matched := false
+			for i, part := range parts {
+				if part == fromName || strings.HasPrefix(part, fromName+".") {
+					matched = true
+					parts[i] = toName + part[len(fromName):]
+				}
+			}
+
+			switch {
+			case !matched:
+				deps = append(deps, dep)
+				continue
+			case toName != "":
+				deps = append(deps, strings.Join(parts, "/"))
+			}
+			changed = true
+		}
+
+		if changed {
+			rs.Dependencies = deps
+		}
+	}
+}
+
 const errStateMultiple = `Multiple instances found for the given pattern!
 
 This command requires that the pattern match exactly one instance
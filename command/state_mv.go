@@ -3,6 +3,7 @@ package command
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
@@ -17,9 +18,18 @@ type StateMvCommand struct {
 func (c *StateMvCommand) Run(args []string) int {
 	args = c.Meta.process(args, true)
 
-	// We create two metas to track the two states
+	// We create two metas to track the two states, and the locks on them
 	var meta1, meta2 Meta
+	meta1.Ui = c.Ui
+	meta2.Ui = c.Ui
+	defer meta1.unlockState()
+	defer meta2.unlockState()
+
+	var force bool
+	var lockTimeout time.Duration
 	cmdFlags := c.Meta.flagSet("state mv")
+	cmdFlags.BoolVar(&force, "force", false, "force")
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
 	cmdFlags.StringVar(&meta1.stateOutPath, "backup", "", "backup")
 	cmdFlags.StringVar(&meta1.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.StringVar(&meta2.stateOutPath, "backup-out", "", "backup")
@@ -44,6 +54,10 @@ func (c *StateMvCommand) Run(args []string) int {
 		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
 		return cli.RunResultHelp
 	}
+	if err := c.StateMeta.lockState(&meta1, stateFrom, "state mv", lockTimeout); err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+		return 1
+	}
 
 	stateFromReal := stateFrom.State()
 	if stateFromReal == nil {
@@ -60,6 +74,10 @@ func (c *StateMvCommand) Run(args []string) int {
 			c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
 			return cli.RunResultHelp
 		}
+		if err := c.StateMeta.lockState(&meta2, stateTo, "state mv", lockTimeout); err != nil {
+			c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+			return 1
+		}
 
 		stateToReal = stateTo.State()
 		if stateToReal == nil {
@@ -79,6 +97,25 @@ func (c *StateMvCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Refuse to replace what is already at the destination unless forced
+	destFilter := &terraform.StateFilter{State: stateToReal}
+	existing, err := destFilter.Filter(args[1])
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateMv, err))
+		return cli.RunResultHelp
+	}
+	if len(existing) > 0 {
+		if !force {
+			c.Ui.Error(fmt.Sprintf(errStateMvExists, args[1]))
+			return 1
+		}
+
+		if err := stateToReal.Remove(args[1]); err != nil {
+			c.Ui.Error(fmt.Sprintf(errStateMv, err))
+			return 1
+		}
+	}
+
 	// Get the item to add to the state
 	add := c.addableResult(results)
 
@@ -93,6 +130,16 @@ func (c *StateMvCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Update what depends on the moved item. The addresses were already
+	// parsed by Add, so they are valid.
+	fromAddr, _ := terraform.ParseResourceAddress(args[0])
+	toAddr, _ := terraform.ParseResourceAddress(args[1])
+	if stateTo == stateFrom {
+		stateUpdateDependencies(stateToReal, fromAddr, toAddr)
+	} else {
+		stateUpdateDependencies(stateFromReal, fromAddr, nil)
+	}
+
 	// Write the new state
 	if err := stateTo.WriteState(stateToReal); err != nil {
 		c.Ui.Error(fmt.Sprintf(errStateMvPersist, err))
@@ -188,6 +235,11 @@ Usage: terraform state mv [options] ADDRESS ADDRESS
   If you're moving from one state file to a different state file, a backup
   will be created for each state file.
 
+  The item is moved along with everything in it: all the instances of a
+  resource with a count, or a module and its child modules. The moved
+  instances keep their data, and what depends on the item in the same
+  module is updated to refer to the new address.
+
 Options:
 
   -backup=PATH        Path where Terraform should write the backup for the original
@@ -202,10 +254,26 @@ Options:
                       to be specified if -state-out is set to a different path
                       than -state.
 
+  -force              Replace the item at the destination address if there
+                      already is one. Without this, the move is refused.
+
+  -lock-takeover=0s   Take over a state lock older than this that was
+                      acquired by a process on this host that is no longer
+                      running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s    Duration to retry a state lock held by another
+                      operation before giving up.
+
   -state=PATH         Path to a Terraform state file to use to look
                       up Terraform-managed resources. By default it will
                       use the state "terraform.tfstate" if it exists.
 
+  -state-lock-file=path
+                      Path of the lock file of the local state. Defaults to
+                      a file next to the state, such as
+                      ".terraform.tfstate.lock.info". Every operation on
+                      the state must use the same lock file.
+
   -state-out=PATH     Path to the destination state file to move the item
                       to. This defaults to the same statefile. This will
                       overwrite the destination state file.
@@ -223,6 +291,12 @@ const errStateMv = `Error moving state: %[1]s
 Please ensure your addresses and state paths are valid. No
 state was persisted. Your existing states are untouched.`
 
+const errStateMvExists = `Error moving state: %s already exists.
+
+Moving to an address that's already in the state would replace what's
+there. Please choose a different address, or use -force to replace it.
+No state was persisted. Your existing states are untouched.`
+
 const errStateMvPersist = `Error saving the state: %s
 
 The state wasn't saved properly. If the error happening after a partial
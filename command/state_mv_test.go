@@ -2,8 +2,10 @@ package command
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
@@ -381,6 +383,290 @@ func TestStateMv_stateOutNew_nestedModule(t *testing.T) {
 	testStateOutput(t, backups[0], testStateMvNestedModule_stateOutOriginal)
 }
 
+func TestStateMv_existing(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+							Attributes: map[string]string{
+								"foo": "value",
+								"bar": "value",
+							},
+						},
+					},
+
+					"test_instance.baz": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "foo",
+							Attributes: map[string]string{
+								"foo": "value",
+								"bar": "value",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateMvCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo",
+		"test_instance.baz",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "test_instance.baz already exists") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The state is untouched
+	testStateOutput(t, statePath, testStateMvOutputOriginal)
+
+	// With -force the existing resource is replaced
+	ui = new(cli.MockUi)
+	c.Meta.Ui = ui
+	args = append([]string{"-force"}, args...)
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testStateMvExisting_forced)
+}
+
+func TestStateMv_dependencies(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+						},
+					},
+
+					"test_instance.baz": &terraform.ResourceState{
+						Type: "test_instance",
+						Dependencies: []string{
+							"test_instance.foo",
+							"test_instance.foobar",
+							"module.child",
+						},
+						Primary: &terraform.InstanceState{
+							ID: "foo",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, state)
+	serial := testStateRead(t, statePath).Serial
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateMvCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo",
+		"test_instance.bar",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testStateMvDependencies)
+
+	if actual := testStateRead(t, statePath).Serial; actual != serial+1 {
+		t.Fatalf("bad serial: %d, expected %d", actual, serial+1)
+	}
+}
+
+func TestStateMv_count(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo.0": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "foo",
+						},
+					},
+
+					"test_instance.foo.1": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+						},
+					},
+
+					"test_instance.baz": &terraform.ResourceState{
+						Type: "test_instance",
+						Dependencies: []string{
+							"test_instance.foo.*",
+						},
+						Primary: &terraform.InstanceState{
+							ID: "baz",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateMvCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo",
+		"test_instance.bar",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testStateMvCount)
+}
+
+func TestStateMv_moduleToModule(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Dependencies: []string{
+							"module.foo.output.id",
+						},
+						Primary: &terraform.InstanceState{
+							ID: "foo",
+						},
+					},
+				},
+			},
+
+			&terraform.ModuleState{
+				Path: []string{"root", "foo"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+						},
+					},
+				},
+			},
+
+			&terraform.ModuleState{
+				Path: []string{"root", "foo", "child"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "baz",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	statePath := testStateFile(t, state)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateMvCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"module.foo",
+		"module.bar",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testStateMvModuleToModule)
+}
+
+func TestStateMv_lockedState(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	p := testProvider()
+	ui := new(cli.MockUi)
+	c := &StateMvCommand{
+		Meta: Meta{
+			ContextOpts: testCtxConfig(p),
+			Ui:          ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo",
+		"test_instance.bar",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "Error locking state") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testState().String())
+}
+
 const testStateMvOutputOriginal = `
 test_instance.baz:
   ID = foo
@@ -514,3 +800,49 @@ const testStateMvExisting_stateDstOriginal = `
 test_instance.qux:
   ID = bar
 `
+
+const testStateMvExisting_forced = `
+test_instance.baz:
+  ID = bar
+  bar = value
+  foo = value
+`
+
+const testStateMvDependencies = `
+test_instance.bar:
+  ID = bar
+test_instance.baz:
+  ID = foo
+
+  Dependencies:
+    module.child
+    test_instance.bar
+    test_instance.foobar
+`
+
+const testStateMvCount = `
+test_instance.bar.0:
+  ID = foo
+test_instance.bar.1:
+  ID = bar
+test_instance.baz:
+  ID = baz
+
+  Dependencies:
+    test_instance.bar.*
+`
+
+const testStateMvModuleToModule = `
+test_instance.foo:
+  ID = foo
+
+  Dependencies:
+    module.bar.output.id
+
+module.bar:
+  test_instance.foo:
+    ID = bar
+module.bar.child:
+  test_instance.foo:
+    ID = baz
+`
@@ -0,0 +1,181 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/mitchellh/cli"
+)
+
+// StateNewCommand is a Command implementation that creates a new state
+// file, optionally seeded with a copy of another state file.
+type StateNewCommand struct {
+	Meta
+}
+
+func (c *StateNewCommand) Run(args []string) int {
+	args = c.Meta.process(args, false)
+
+	var sourcePath string
+	var force, preserveLineage bool
+	cmdFlags := c.Meta.flagSet("state new")
+	cmdFlags.BoolVar(&force, "force", false, "force")
+	cmdFlags.BoolVar(&preserveLineage, "preserve-lineage", false, "preserve-lineage")
+	cmdFlags.StringVar(&sourcePath, "state", "", "path")
+	cmdFlags.StringVar(&c.Meta.StateLockPath, "state-lock-file", c.Meta.StateLockPath, "path")
+	if err := cmdFlags.Parse(args); err != nil {
+		return cli.RunResultHelp
+	}
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("Exactly one argument expected: the path of the new state.\n")
+		return cli.RunResultHelp
+	}
+	path := args[0]
+
+	// Read the state to seed the new state with, if any
+	newState := terraform.NewState()
+	if sourcePath != "" {
+		source := &state.LocalState{Path: sourcePath}
+		if err := source.RefreshState(); err != nil {
+			c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+			return 1
+		}
+		if source.State() == nil {
+			c.Ui.Error(fmt.Sprintf("State file %s doesn't exist.", sourcePath))
+			return 1
+		}
+
+		newState = source.State().DeepCopy()
+		if !preserveLineage {
+			newState.Lineage = ""
+		}
+	}
+	newState.EnsureHasLineage()
+
+	// Lock the new state so that nothing else creates it at the same time
+	dest := &state.LocalState{Path: path, LockPath: c.StateLockPath}
+	info := state.NewLockInfo()
+	info.Operation = "state new"
+	id, err := dest.Lock(info)
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Error locking the new state: %s", err))
+		return 1
+	}
+	defer func() {
+		if err := dest.Unlock(id); err != nil {
+			c.Ui.Error(fmt.Sprintf("Error unlocking the new state: %s", err))
+		}
+	}()
+
+	if err := dest.RefreshState(); err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+		return 1
+	}
+	if dest.State() != nil {
+		c.Ui.Error(fmt.Sprintf(errStateNewExists, path))
+		return 1
+	}
+
+	if sourcePath != "" {
+		c.Ui.Output(c.Colorize().Color(formatStateNewPreview(
+			sourcePath, path, newState, preserveLineage)))
+
+		if !force {
+			v, err := c.UIInput().Input(&terraform.InputOpts{
+				Id:    "state-new",
+				Query: "Do you want to copy this state?",
+				Description: fmt.Sprintf(
+					"Terraform will create %s with a copy of the state above.\n"+
+						"Only 'yes' will be accepted to confirm.", path),
+			})
+			if err != nil {
+				c.Ui.Error(fmt.Sprintf("Error asking for confirmation: %s", err))
+				return 1
+			}
+			if v != "yes" {
+				c.Ui.Output("State copy cancelled.")
+				return 1
+			}
+		}
+	}
+
+	if err := dest.WriteState(newState); err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateNewPersist, err))
+		return 1
+	}
+	if err := dest.PersistState(); err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateNewPersist, err))
+		return 1
+	}
+
+	c.Ui.Output(fmt.Sprintf("Created the new state %s.", path))
+	return 0
+}
+
+// formatStateNewPreview returns what will be copied from the state file
+// at sourcePath to the new state at path.
+func formatStateNewPreview(sourcePath, path string, s *terraform.State, preserveLineage bool) string {
+	resources := 0
+	for _, m := range s.Modules {
+		resources += len(m.Resources)
+	}
+
+	lineage := s.Lineage
+	if !preserveLineage {
+		lineage += " (new)"
+	}
+
+	return fmt.Sprintf(
+		"[reset][bold]The state %s will be copied to %s:[reset]\n\n"+
+			"  Resources: %d\n"+
+			"  Lineage:   %s\n"+
+			"  Serial:    %d\n",
+		sourcePath, path, resources, lineage, s.Serial)
+}
+
+func (c *StateNewCommand) Help() string {
+	helpText := `
+Usage: terraform state new [options] PATH
+
+  Create a new state file at PATH.
+
+  The new state is empty, unless the -state flag is given a state file to
+  copy to it. The number of resources, the lineage and the serial of the
+  copy are shown before it's created, and you're asked to confirm.
+
+  The copy is given a new lineage by default, since it's a new state that
+  is kept apart from the state it was copied from. Its serial is kept.
+
+Options:
+
+  -force              Don't ask for confirmation before copying the state.
+
+  -preserve-lineage   Keep the lineage of the state that is copied, such as
+                      when the new state is meant to replace it.
+
+  -state=path         Path of a state file to copy to the new state.
+
+  -state-lock-file=path
+                      Path of the lock file of the new state. Defaults to
+                      a file next to the new state.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *StateNewCommand) Synopsis() string {
+	return "Create a new state, optionally copied from another"
+}
+
+const errStateNewExists = `The state %s already exists.
+
+A new state can only be created where there's no state yet, so that no
+state is overwritten. Choose another path, or remove the state first.`
+
+const errStateNewPersist = `Error saving the new state: %s
+
+The new state was not created. Please resolve the issue above and try
+again.`
@@ -0,0 +1,193 @@
+package command
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestStateNew(t *testing.T) {
+	source := testState()
+	source.Serial = 4
+	sourcePath := testStateFile(t, source)
+	path := filepath.Join(testTempDir(t), "new.tfstate")
+
+	defaultInputReader = bytes.NewBufferString("yes\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &StateNewCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-state", sourcePath,
+		path,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The copy has the resources and serial of the source, with a new
+	// lineage
+	actual := testStateRead(t, path)
+	if actual.Lineage == "" || actual.Lineage == source.Lineage {
+		t.Fatalf("bad: %q", actual.Lineage)
+	}
+	if actual.Serial != source.Serial {
+		t.Fatalf("bad: %d", actual.Serial)
+	}
+	if len(actual.RootModule().Resources) != 1 ||
+		actual.RootModule().Resources["test_instance.foo"] == nil {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// The preview shows what was copied
+	output := ui.OutputWriter.String()
+	for _, s := range []string{
+		"Resources: 1",
+		"Lineage:   " + actual.Lineage + " (new)",
+		"Serial:    4",
+	} {
+		if !strings.Contains(output, s) {
+			t.Fatalf("bad: missing %q\n\n%s", s, output)
+		}
+	}
+}
+
+func TestStateNew_decline(t *testing.T) {
+	sourcePath := testStateFile(t, testState())
+	path := filepath.Join(testTempDir(t), "new.tfstate")
+
+	defaultInputReader = bytes.NewBufferString("no\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &StateNewCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-state", sourcePath,
+		path,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "State copy cancelled.") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+
+	// Nothing is created, not even the lock
+	matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("bad: %v", matches)
+	}
+}
+
+func TestStateNew_preserveLineage(t *testing.T) {
+	source := testState()
+	sourcePath := testStateFile(t, source)
+	path := filepath.Join(testTempDir(t), "new.tfstate")
+
+	ui := new(cli.MockUi)
+	c := &StateNewCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-preserve-lineage",
+		"-state", sourcePath,
+		path,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, path)
+	if actual.Lineage != source.Lineage {
+		t.Fatalf("bad: %q", actual.Lineage)
+	}
+	if strings.Contains(ui.OutputWriter.String(), "(new)") {
+		t.Fatalf("bad: %s", ui.OutputWriter.String())
+	}
+}
+
+func TestStateNew_empty(t *testing.T) {
+	path := filepath.Join(testTempDir(t), "new.tfstate")
+
+	ui := new(cli.MockUi)
+	c := &StateNewCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	// Nothing is copied, so there's nothing to confirm
+	if code := c.Run([]string{path}); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	actual := testStateRead(t, path)
+	if actual.Lineage == "" || actual.HasResources() {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestStateNew_exists(t *testing.T) {
+	sourcePath := testStateFile(t, testState())
+	path := testStateFile(t, testState())
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ui := new(cli.MockUi)
+	c := &StateNewCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-state", sourcePath,
+		path,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "already exists") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() {
+		t.Fatal("state should not be overwritten")
+	}
+}
@@ -3,7 +3,9 @@ package command
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
 
@@ -16,9 +18,13 @@ type StateRmCommand struct {
 func (c *StateRmCommand) Run(args []string) int {
 	args = c.Meta.process(args, true)
 
+	defer c.unlockState()
+
 	var backupPath string
+	var lockTimeout time.Duration
 	cmdFlags := c.Meta.flagSet("state show")
 	cmdFlags.StringVar(&backupPath, "backup", "", "backup")
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	if err := cmdFlags.Parse(args); err != nil {
 		return cli.RunResultHelp
@@ -30,6 +36,10 @@ func (c *StateRmCommand) Run(args []string) int {
 		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
 		return cli.RunResultHelp
 	}
+	if err := c.StateMeta.lockState(&c.Meta, state, "state rm", lockTimeout); err != nil {
+		c.Ui.Error(fmt.Sprintf(errStateLoadingState, err))
+		return 1
+	}
 
 	stateReal := state.State()
 	if stateReal == nil {
@@ -42,6 +52,13 @@ func (c *StateRmCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Remove the dependencies on the removed items. The addresses were
+	// already parsed by Remove, so they are valid.
+	for _, arg := range args {
+		addr, _ := terraform.ParseResourceAddress(arg)
+		stateUpdateDependencies(stateReal, addr, nil)
+	}
+
 	if err := state.WriteState(stateReal); err != nil {
 		c.Ui.Error(fmt.Sprintf(errStateRmPersist, err))
 		return 1
@@ -77,10 +94,23 @@ Options:
                       will write it to the same path as the statefile with
                       a backup extension.
 
+  -lock-takeover=0s   Take over a state lock older than this that was
+                      acquired by a process on this host that is no longer
+                      running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s    Duration to retry a state lock held by another
+                      operation before giving up.
+
   -state=statefile    Path to a Terraform state file to use to look
                       up Terraform-managed resources. By default it will
                       use the state "terraform.tfstate" if it exists.
 
+  -state-lock-file=path
+                      Path of the lock file of the local state. Defaults to
+                      a file next to the state, such as
+                      ".terraform.tfstate.lock.info". Every operation on
+                      the state must use the same lock file.
+
 `
 	return strings.TrimSpace(helpText)
 }
@@ -1,6 +1,7 @@
 package command
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -19,7 +20,9 @@ type StateShowCommand struct {
 func (c *StateShowCommand) Run(args []string) int {
 	args = c.Meta.process(args, true)
 
+	var jsonOutput bool
 	cmdFlags := c.Meta.flagSet("state show")
+	cmdFlags.BoolVar(&jsonOutput, "json", false, "json")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	if err := cmdFlags.Parse(args); err != nil {
 		return cli.RunResultHelp
@@ -61,6 +64,22 @@ func (c *StateShowCommand) Run(args []string) int {
 
 	is := instance.Value.(*terraform.InstanceState)
 
+	if jsonOutput {
+		data, err := json.MarshalIndent(&stateShowJSON{
+			Address:    instance.Address,
+			ID:         is.ID,
+			Tainted:    is.Tainted,
+			Attributes: is.Attributes,
+		}, "", "    ")
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error encoding the resource as JSON: %s", err))
+			return 1
+		}
+
+		c.Ui.Output(string(data))
+		return 0
+	}
+
 	// Sort the keys
 	var keys []string
 	for k, _ := range is.Attributes {
@@ -96,6 +115,9 @@ Usage: terraform state show [options] ADDRESS
 
 Options:
 
+  -json               If specified, the resource is printed as a JSON
+                      object with its address, ID and attributes.
+
   -state=statefile    Path to a Terraform state file to use to look
                       up Terraform-managed resources. By default it will
                       use the state "terraform.tfstate" if it exists.
@@ -107,3 +129,11 @@ Options:
 func (c *StateShowCommand) Synopsis() string {
 	return "Show a resource in the state"
 }
+
+// stateShowJSON is the JSON output of the state show command.
+type stateShowJSON struct {
+	Address    string            `json:"address"`
+	ID         string            `json:"id"`
+	Tainted    bool              `json:"tainted"`
+	Attributes map[string]string `json:"attributes"`
+}
@@ -1,9 +1,14 @@
 package command
 
 import (
+	"bytes"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
 	"github.com/mitchellh/cli"
 )
@@ -298,6 +303,33 @@ func TestTaint_stateOut(t *testing.T) {
 	testStateOutput(t, "foo", testTaintStr)
 }
 
+func TestTaint_stateOutNewDir(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	path := testStateFileDefault(t, testState())
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	// The directories of the state written are created
+	outPath := filepath.Join("new", "dir", DefaultStateFilename)
+	args := []string{
+		"-state-out", outPath,
+		"test_instance.foo",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, path, testTaintDefaultStr)
+	testStateOutput(t, outPath, testTaintStr)
+}
+
 func TestTaint_module(t *testing.T) {
 	state := &terraform.State{
 		Modules: []*terraform.ModuleState{
@@ -346,6 +378,198 @@ func TestTaint_module(t *testing.T) {
 	testStateOutput(t, statePath, testTaintModuleStr)
 }
 
+func TestTaint_countIndex(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo.0": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar0",
+						},
+					},
+					"test_instance.foo.1": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar1",
+						},
+					},
+				},
+			},
+		},
+	}
+	statePath := testStateFile(t, state)
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo.1",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testTaintCountIndexStr)
+}
+
+func TestTaint_countOneIndex(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	// A resource with a count of one is stored without its index
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo.0",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testTaintStr)
+}
+
+func TestTaint_lockedState(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		"test_instance.foo",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+
+	if !strings.Contains(ui.ErrorWriter.String(), "Error locking state") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testTaintDefaultStr)
+}
+
+func TestTaint_remoteState(t *testing.T) {
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	conf, srv, written := testRemoteStateRecorder(t)
+	defer srv.Close()
+
+	s := testState()
+	s.Remote = conf
+	testStateFileRemote(t, s)
+
+	// Store the state on the server as well, so that it is what the
+	// command reads when it refreshes the state.
+	var buf bytes.Buffer
+	if err := terraform.WriteState(s, &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp, err := http.Post(srv.URL, "application/json", &buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	resp.Body.Close()
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{"test_instance.foo"}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	written.Lock()
+	defer written.Unlock()
+	rs := written.State.RootModule().Resources["test_instance.foo"]
+	if rs == nil || !rs.Primary.Tainted {
+		t.Fatalf("remote state should be tainted: %s", written.State)
+	}
+
+	// The local state file should not be created
+	if _, err := os.Stat(DefaultStateFilename); err == nil {
+		t.Fatal("state path should not exist")
+	}
+}
+
+func TestTaint_moduleAddress(t *testing.T) {
+	state := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path: []string{"root"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.foo": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "bar",
+						},
+					},
+				},
+			},
+			&terraform.ModuleState{
+				Path: []string{"root", "child"},
+				Resources: map[string]*terraform.ResourceState{
+					"test_instance.blah": &terraform.ResourceState{
+						Type: "test_instance",
+						Primary: &terraform.InstanceState{
+							ID: "blah",
+						},
+					},
+				},
+			},
+		},
+	}
+	statePath := testStateFile(t, state)
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-module=module.child",
+		"-state", statePath,
+		"test_instance.blah",
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	testStateOutput(t, statePath, testTaintModuleStr)
+}
+
 const testTaintStr = `
 test_instance.foo: (tainted)
   ID = bar
@@ -364,3 +588,54 @@ module.child:
   test_instance.blah: (tainted)
     ID = blah
 `
+
+const testTaintCountIndexStr = `
+test_instance.foo.0:
+  ID = bar0
+test_instance.foo.1: (tainted)
+  ID = bar1
+`
+
+func TestTaint_futureState(t *testing.T) {
+	// Run in a temporary working directory, where errored.tfstate would be
+	// written
+	tmp, cwd := testCwd(t)
+	defer testFixCwd(t, tmp, cwd)
+
+	s := testState()
+	s.TFVersion = "99.99.99"
+	statePath := testStateFile(t, s)
+
+	ui := new(cli.MockUi)
+	c := &TaintCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-backup", "-",
+		"-state", statePath,
+		"test_instance.foo",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "written by Terraform 99.99.99") {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The state isn't saved to be put in place of the state file later
+	if _, err := os.Stat(DefaultErroredStateFilename); !os.IsNotExist(err) {
+		t.Fatalf("bad: %s", err)
+	}
+	if strings.Contains(ui.ErrorWriter.String(), DefaultErroredStateFilename) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The resource isn't tainted
+	actual := testStateRead(t, statePath)
+	if actual.RootModule().Resources["test_instance.foo"].Primary.Tainted {
+		t.Fatalf("bad: %s", actual)
+	}
+}
@@ -0,0 +1,18 @@
+resource "test_instance" "a" {
+    ami = "bar"
+}
+
+resource "test_instance" "b" {
+    ami = "bar"
+    depends_on = ["test_instance.a"]
+}
+
+resource "test_instance" "c" {
+    ami = "bar"
+    depends_on = ["test_instance.b"]
+}
+
+resource "test_instance" "d" {
+    ami = "bar"
+    depends_on = ["test_instance.c"]
+}
@@ -0,0 +1,13 @@
+resource "test_instance" "foo" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "test_instance" "bar" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "test_instance" "baz" {}
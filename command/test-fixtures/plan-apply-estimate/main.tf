@@ -0,0 +1,16 @@
+resource "test_instance" "a" {
+    ami = "a"
+}
+
+resource "test_instance" "b" {
+    ami = "${test_instance.a.id}"
+}
+
+resource "test_instance" "c" {
+    ami = "${test_instance.b.id}"
+}
+
+resource "test_instance" "d" {
+    count = 3
+    ami = "d"
+}
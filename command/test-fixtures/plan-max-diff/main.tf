@@ -0,0 +1,4 @@
+resource "test_instance" "foo" {
+    count = 10
+    ami = "bar"
+}
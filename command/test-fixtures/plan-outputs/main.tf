@@ -0,0 +1,11 @@
+resource "test_instance" "foo" {
+    ami = "bar"
+}
+
+output "new" {
+    value = "${test_instance.foo.id}"
+}
+
+output "changed" {
+    value = "baz"
+}
@@ -0,0 +1,3 @@
+resource "test_instance" "foo" {
+    provider = "test.west"
+}
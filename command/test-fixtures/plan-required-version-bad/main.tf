@@ -0,0 +1,7 @@
+module "child" {
+    source = "./child"
+}
+
+resource "test_instance" "foo" {
+    ami = "bar"
+}
@@ -0,0 +1,7 @@
+terraform {
+    required_version = ">= 0.1.0"
+}
+
+resource "test_instance" "foo" {
+    ami = "bar"
+}
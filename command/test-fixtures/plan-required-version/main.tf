@@ -0,0 +1,11 @@
+terraform {
+    required_version = ">= 0.1.0"
+}
+
+module "child" {
+    source = "./child"
+}
+
+resource "test_instance" "foo" {
+    ami = "bar"
+}
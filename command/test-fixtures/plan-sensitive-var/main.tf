@@ -0,0 +1,7 @@
+variable "password" {
+    sensitive = true
+}
+
+resource "test_instance" "foo" {
+    ami = "${var.password}"
+}
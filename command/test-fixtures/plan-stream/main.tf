@@ -0,0 +1,12 @@
+resource "test_instance" "foo" {
+    ami = "bar"
+    count = 3
+}
+
+resource "test_instance" "bar" {
+    ami = "bar"
+}
+
+resource "test_instance" "baz" {
+    ami = "bar"
+}
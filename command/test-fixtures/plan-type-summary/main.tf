@@ -0,0 +1,9 @@
+resource "test_instance" "foo" {
+    ami = "bar"
+    count = 4
+}
+
+resource "test_volume" "bar" {
+    size = 10
+    count = 2
+}
@@ -0,0 +1,15 @@
+variable "subnets" {
+    type = "list"
+}
+
+variable "tags" {
+    type = "map"
+}
+
+variable "name" {}
+
+resource "test_instance" "foo" {
+    subnets = "${join(",", var.subnets)}"
+    tag     = "${lookup(var.tags["nested"], "key")}"
+    name    = "${var.name}"
+}
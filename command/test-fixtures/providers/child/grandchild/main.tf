@@ -0,0 +1,3 @@
+data "template_file" "foo" {}
+
+resource "consul_key" "foo" {}
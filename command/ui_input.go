@@ -134,3 +134,17 @@ func (i *UIInput) init() {
 		}
 	}
 }
+
+// FailUIInput is an implementation of terraform.UIInput that never asks
+// for input and returns an error naming what was asked for instead.
+type FailUIInput struct{}
+
+func (FailUIInput) Input(opts *terraform.InputOpts) (string, error) {
+	name := opts.Id
+	if name == "" {
+		name = opts.Query
+	}
+
+	return "", fmt.Errorf(
+		"input is required for %s, but asking for input is disabled", name)
+}
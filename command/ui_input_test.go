@@ -2,6 +2,7 @@ package command
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/terraform"
@@ -42,3 +43,18 @@ func TestUIInputInput_spaces(t *testing.T) {
 		t.Fatalf("bad: %#v", v)
 	}
 }
+
+func TestFailUIInput_impl(t *testing.T) {
+	var _ terraform.UIInput = new(FailUIInput)
+}
+
+func TestFailUIInputInput(t *testing.T) {
+	i := new(FailUIInput)
+	_, err := i.Input(&terraform.InputOpts{Id: "var.foo", Query: "var.foo"})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "var.foo") {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -0,0 +1,113 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// UnlockCommand is a cli.Command implementation that manually removes a
+// lock that was left on the state, such as by a crashed process.
+type UnlockCommand struct {
+	Meta
+}
+
+func (c *UnlockCommand) Run(args []string) int {
+	args = c.Meta.process(args, false)
+
+	var force bool
+	cmdFlags := c.Meta.flagSet("force-unlock")
+	cmdFlags.BoolVar(&force, "force", false, "force")
+	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
+	cmdFlags.StringVar(&c.Meta.StateLockPath, "state-lock-file", c.Meta.StateLockPath, "path")
+	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	// Require the lock ID so that we only ever remove the lock that the
+	// user actually looked at.
+	args = cmdFlags.Args()
+	if len(args) != 1 {
+		c.Ui.Error("The force-unlock command expects exactly one argument\n" +
+			"with the ID of the lock to remove.")
+		cmdFlags.Usage()
+		return 1
+	}
+	id := args[0]
+
+	st, err := c.State()
+	if err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to load state: %s", err))
+		return 1
+	}
+
+	l, ok := st.(state.Locker)
+	if !ok {
+		c.Ui.Error("The configured state doesn't support locking.")
+		return 1
+	}
+
+	if !force {
+		v, err := c.UIInput().Input(&terraform.InputOpts{
+			Id:    "force-unlock",
+			Query: "Do you really want to force-unlock?",
+			Description: "Terraform will remove the lock on the state.\n" +
+				"This will allow local Terraform commands to modify this state, even\n" +
+				"though it may still be in use. Only 'yes' will be accepted to confirm.",
+		})
+		if err != nil {
+			c.Ui.Error(fmt.Sprintf("Error asking for confirmation: %s", err))
+			return 1
+		}
+		if v != "yes" {
+			c.Ui.Output("force-unlock cancelled.")
+			return 1
+		}
+	}
+
+	if err := l.Unlock(id); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to unlock state: %s", err))
+		return 1
+	}
+
+	c.Ui.Output(c.Colorize().Color(strings.TrimSpace(outputUnlockSuccess)))
+	return 0
+}
+
+func (c *UnlockCommand) Help() string {
+	helpText := `
+Usage: terraform force-unlock [options] LOCK_ID
+
+  Manually unlock the state for the defined configuration.
+
+  This will not modify your infrastructure. This command removes the lock on
+  the state. The lock ID, which is shown when a command fails to acquire the
+  lock, must be given so that only that lock is removed.
+
+Options:
+
+  -force              Don't ask for input for unlock confirmation.
+
+  -state=path         Path to the state file. Defaults to "terraform.tfstate".
+
+  -state-lock-file=path
+                      Path of the lock file of the local state, if it was
+                      locked with -state-lock-file.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (c *UnlockCommand) Synopsis() string {
+	return "Manually unlock the terraform state"
+}
+
+const outputUnlockSuccess = `
+[reset][bold][green]Terraform state has been successfully unlocked![reset][green]
+
+The state has been unlocked, and Terraform commands should now be able to
+obtain a new lock on the state.
+`
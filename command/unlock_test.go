@@ -0,0 +1,106 @@
+package command
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/state"
+	"github.com/mitchellh/cli"
+)
+
+func TestUnlock(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	// Leave a lock behind, as a crashed process would
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	defaultInputReader = bytes.NewBufferString("yes\n")
+	defaultInputWriter = new(bytes.Buffer)
+	defer func() {
+		defaultInputReader = nil
+		defaultInputWriter = nil
+	}()
+
+	ui := new(cli.MockUi)
+	c := &UnlockCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-state", statePath,
+		id,
+	}
+	if code := c.Run(args); code != 0 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.ErrorWriter.String())
+	}
+
+	// The state can be locked again
+	other := &state.LocalState{Path: statePath}
+	id, err = other.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other.Unlock(id)
+}
+
+func TestUnlock_idMismatch(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ls := &state.LocalState{Path: statePath}
+	id, err := ls.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	ui := new(cli.MockUi)
+	c := &UnlockCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-state", statePath,
+		"not-the-lock-id",
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), id) {
+		t.Fatalf("bad: %s", ui.ErrorWriter.String())
+	}
+
+	// The lock must still be held
+	other := &state.LocalState{Path: statePath}
+	if _, err := other.Lock(state.NewLockInfo()); err == nil {
+		t.Fatal("should still be locked")
+	}
+}
+
+func TestUnlock_noId(t *testing.T) {
+	statePath := testStateFile(t, testState())
+
+	ui := new(cli.MockUi)
+	c := &UnlockCommand{
+		Meta: Meta{
+			Ui: ui,
+		},
+	}
+
+	args := []string{
+		"-force",
+		"-state", statePath,
+	}
+	if code := c.Run(args); code != 1 {
+		t.Fatalf("bad: %d\n\n%s", code, ui.OutputWriter.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 )
 
 // UntaintCommand is a cli.Command implementation that manually untaints
@@ -14,15 +15,19 @@ type UntaintCommand struct {
 
 func (c *UntaintCommand) Run(args []string) int {
 	args = c.Meta.process(args, false)
+	defer c.unlockState()
 
 	var allowMissing bool
+	var lockTimeout time.Duration
 	var module string
 	cmdFlags := c.Meta.flagSet("untaint")
 	cmdFlags.BoolVar(&allowMissing, "allow-missing", false, "module")
+	c.addLockTimeoutFlag(cmdFlags, &lockTimeout)
 	cmdFlags.StringVar(&module, "module", "", "module")
 	cmdFlags.StringVar(&c.Meta.statePath, "state", DefaultStateFilename, "path")
 	cmdFlags.StringVar(&c.Meta.stateOutPath, "state-out", "", "path")
 	cmdFlags.StringVar(&c.Meta.backupPath, "backup", "", "path")
+	cmdFlags.StringVar(&c.Meta.backupDir, "backup-dir", "", "path")
 	cmdFlags.Usage = func() { c.Ui.Error(c.Help()) }
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
@@ -37,11 +42,8 @@ func (c *UntaintCommand) Run(args []string) int {
 	}
 
 	name := args[0]
-	if module == "" {
-		module = "root"
-	} else {
-		module = "root." + module
-	}
+	modPath := outputModulePath(module)
+	module = strings.Join(modPath, ".")
 
 	// Get the state that we'll be modifying
 	state, err := c.State()
@@ -50,6 +52,18 @@ func (c *UntaintCommand) Run(args []string) int {
 		return 1
 	}
 
+	// Lock the state and read it again once we hold the lock, so that
+	// changes made by whoever held the lock before us aren't lost.
+	copts := contextOpts{Operation: "untaint", LockTimeout: lockTimeout}
+	if err := c.lockState(state, copts); err != nil {
+		c.Ui.Error(err.Error())
+		return 1
+	}
+	if err := state.RefreshState(); err != nil {
+		c.Ui.Error(fmt.Sprintf("Failed to load state: %s", err))
+		return 1
+	}
+
 	// Get the actual state structure
 	s := state.State()
 	if s.Empty() {
@@ -66,7 +80,6 @@ func (c *UntaintCommand) Run(args []string) int {
 	}
 
 	// Get the proper module holding the resource we want to untaint
-	modPath := strings.Split(module, ".")
 	mod := s.ModuleByPath(modPath)
 	if mod == nil {
 		if allowMissing {
@@ -92,7 +105,7 @@ func (c *UntaintCommand) Run(args []string) int {
 	}
 
 	// Get the resource we're looking for
-	rs, ok := mod.Resources[name]
+	rs, ok := taintStateResource(mod, name)
 	if !ok {
 		if allowMissing {
 			return c.allowMissingExit(name, module)
@@ -126,7 +139,9 @@ Usage: terraform untaint [options] name
 
   Manually unmark a resource as tainted, restoring it as the primary
   instance in the state.  This reverses either a manual 'terraform taint'
-  or the result of provisioners failing on a resource.
+  or the result of provisioners failing on a resource. A single instance
+  of a resource with a count can be given with its index, such as
+  "aws_instance.web.2".
 
   This will not modify your infrastructure. This command changes your
   state to unmark a resource as tainted.  This command can be undone by
@@ -142,15 +157,33 @@ Options:
                       modifying. Defaults to the "-state-out" path with
                       ".backup" extension. Set to "-" to disable backup.
 
+  -backup-dir=path    Directory to write the backup to, keeping its name.
+                      By default, it is written next to the state, or to
+                      within .terraform with a warning if that fails.
+
+  -lock-takeover=0s   Take over a state lock older than this that was
+                      acquired by a process on this host that is no longer
+                      running. Defaults to TF_LOCK_TAKEOVER, or never.
+
+  -lock-timeout=0s    Duration to retry a state lock held by another
+                      operation before giving up.
+
   -module=path        The module path where the resource lives. By
                       default this will be root. Child modules can be specified
-                      by names. Ex. "consul" or "consul.vpc" (nested modules).
+                      by names. Ex. "consul" or "consul.vpc" (nested modules),
+                      or as "module.consul.module.vpc".
 
   -no-color           If specified, output won't contain any color.
 
   -state=path         Path to read and save state (unless state-out
                       is specified). Defaults to "terraform.tfstate".
 
+  -state-lock-file=path
+                      Path of the lock file of the local state. Defaults to
+                      a file next to the state, such as
+                      ".terraform.tfstate.lock.info". Every operation on
+                      the state must use the same lock file.
+
   -state-out=path     Path to write updated state file. By default, the
                       "-state" path will be used.
 
@@ -0,0 +1,52 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// compactWarnings collapses the warnings that are similar into the first
+// of them, followed by how many more there are. Warnings are similar if
+// their messages are the same apart from the name of what they're for,
+// such as the same deprecated attribute used by many resources. The
+// order of the first occurrences is kept.
+func compactWarnings(warnings []string) []string {
+	var keys []string
+	first := make(map[string]string)
+	counts := make(map[string]int)
+	for _, w := range warnings {
+		key := warningMessage(w)
+		if _, ok := first[key]; !ok {
+			keys = append(keys, key)
+			first[key] = w
+		}
+		counts[key]++
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		w := first[key]
+		switch n := counts[key] - 1; n {
+		case 0:
+		case 1:
+			w = fmt.Sprintf("%s (and 1 more similar warning)", w)
+		default:
+			w = fmt.Sprintf("%s (and %d more similar warnings)", w, n)
+		}
+		result = append(result, w)
+	}
+
+	return result
+}
+
+// warningMessage returns the message of a warning without the name of
+// what it's for. Warnings from validation are prefixed with the name of
+// the resource or provider, such as "aws_instance.foo: ".
+func warningMessage(w string) string {
+	idx := strings.Index(w, ": ")
+	if idx == -1 || strings.ContainsAny(w[:idx], " \t\n") {
+		return w
+	}
+
+	return w[idx+2:]
+}
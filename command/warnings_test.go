@@ -0,0 +1,50 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompactWarnings(t *testing.T) {
+	cases := []struct {
+		Input    []string
+		Expected []string
+	}{
+		{nil, []string{}},
+		{
+			[]string{"foo"},
+			[]string{"foo"},
+		},
+		{
+			[]string{
+				"aws_instance.a: \"ami\": [DEPRECATED] use image",
+				"provider.aws: region is ignored",
+				"aws_instance.b: \"ami\": [DEPRECATED] use image",
+				"aws_instance.c: \"ami\": [DEPRECATED] use image",
+				"module.foo.provider.aws: region is ignored",
+			},
+			[]string{
+				"aws_instance.a: \"ami\": [DEPRECATED] use image (and 2 more similar warnings)",
+				"provider.aws: region is ignored (and 1 more similar warning)",
+			},
+		},
+		{
+			// Only a name is left out when comparing
+			[]string{
+				"The plan was created by: v0.7.0",
+				"The plan was created by: v0.8.0",
+			},
+			[]string{
+				"The plan was created by: v0.7.0",
+				"The plan was created by: v0.8.0",
+			},
+		},
+	}
+
+	for i, tc := range cases {
+		actual := compactWarnings(tc.Input)
+		if !reflect.DeepEqual(actual, tc.Expected) {
+			t.Fatalf("%d: bad: %#v", i, actual)
+		}
+	}
+}
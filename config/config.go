@@ -10,6 +10,7 @@ import (
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/hcl/hcl/token"
 	"github.com/hashicorp/hil"
 	"github.com/hashicorp/hil/ast"
 	"github.com/hashicorp/terraform/helper/hilmapstructure"
@@ -45,6 +46,9 @@ type Config struct {
 // in configuration files for configuring Terraform itself.
 type Terraform struct {
 	RequiredVersion string `hcl:"required_version"` // Required Terraform version (constraint)
+
+	// Path is the file the terraform block was loaded from, if known.
+	Path string `hcl:"-"`
 }
 
 // AtlasConfig is the configuration for building in HashiCorp's Atlas.
@@ -71,6 +75,7 @@ type Module struct {
 type ProviderConfig struct {
 	Name      string
 	Alias     string
+	Version   string // Required provider version (constraint)
 	RawConfig *RawConfig
 }
 
@@ -88,6 +93,11 @@ type Resource struct {
 	Provider     string
 	DependsOn    []string
 	Lifecycle    ResourceLifecycle
+
+	// Pos is where the resource is declared in the configuration, used to
+	// point to it in errors. It isn't valid if the resource wasn't loaded
+	// from a file.
+	Pos token.Pos
 }
 
 // Copy returns a copy of this Resource. Helpful for avoiding shared
@@ -104,6 +114,7 @@ func (r *Resource) Copy() *Resource {
 		Provider:     r.Provider,
 		DependsOn:    make([]string, len(r.DependsOn)),
 		Lifecycle:    *r.Lifecycle.Copy(),
+		Pos:          r.Pos,
 	}
 	for _, p := range r.Provisioners {
 		n.Provisioners = append(n.Provisioners, p.Copy())
@@ -153,6 +164,10 @@ type Variable struct {
 	DeclaredType string `mapstructure:"type"`
 	Default      interface{}
 	Description  string
+
+	// Sensitive, if true, hides the value of the variable in the output
+	// and logs of commands, such as for variables with credentials.
+	Sensitive bool
 }
 
 // Output is an output defined within the configuration. An output is
@@ -368,6 +383,14 @@ func (c *Config) Validate() error {
 		}
 
 		providerSet[name] = struct{}{}
+
+		if p.Version != "" {
+			if _, err := version.NewConstraint(p.Version); err != nil {
+				errs = append(errs, fmt.Errorf(
+					"provider.%s: invalid version constraint %q: %s",
+					name, p.Version, err))
+			}
+		}
 	}
 
 	// Check that all references to modules are valid
@@ -940,6 +963,9 @@ func (c *ProviderConfig) mergerMerge(m merger) merger {
 	if c2.Alias != "" {
 		result.Alias = c2.Alias
 	}
+	if c2.Version != "" {
+		result.Version = c2.Version
+	}
 
 	return &result
 }
@@ -985,6 +1011,9 @@ func (v *Variable) Merge(v2 *Variable) *Variable {
 	if v2.Description != "" {
 		result.Description = v2.Description
 	}
+	if v2.Sensitive {
+		result.Sensitive = true
+	}
 
 	return &result
 }
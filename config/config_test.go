@@ -442,6 +442,14 @@ func TestConfigValidate_providerMulti(t *testing.T) {
 	}
 }
 
+func TestConfigValidate_providerVersionBad(t *testing.T) {
+	c := testConfig(t, "validate-provider-version-bad")
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "invalid version constraint") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
 func TestConfigValidate_providerMultiGood(t *testing.T) {
 	c := testConfig(t, "validate-provider-multi-good")
 	if err := c.Validate(); err != nil {
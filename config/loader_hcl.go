@@ -45,6 +45,7 @@ func (t *hclConfigurable) Config() (*Config, error) {
 		if err != nil {
 			return nil, err
 		}
+		config.Terraform.Path = t.File
 	}
 
 	// Build the variables
@@ -105,6 +106,11 @@ func (t *hclConfigurable) Config() (*Config, error) {
 
 		config.Resources = append(config.Resources, dataResources...)
 		config.Resources = append(config.Resources, managedResources...)
+
+		// The positions are within this file
+		for _, r := range config.Resources {
+			r.Pos.Filename = t.File
+		}
 	}
 
 	// Build the outputs
@@ -387,6 +393,7 @@ func loadVariablesHcl(list *ast.ObjectList) ([]*Variable, error) {
 		DeclaredType string `hcl:"type"`
 		Default      interface{}
 		Description  string
+		Sensitive    bool
 		Fields       []string `hcl:",decodedFields"`
 	}
 
@@ -411,7 +418,7 @@ func loadVariablesHcl(list *ast.ObjectList) ([]*Variable, error) {
 		}
 
 		// Check for invalid keys
-		valid := []string{"type", "default", "description"}
+		valid := []string{"type", "default", "description", "sensitive"}
 		if err := checkHCLKeys(item.Val, valid); err != nil {
 			return nil, multierror.Prefix(err, fmt.Sprintf(
 				"variable[%s]:", n))
@@ -443,6 +450,7 @@ func loadVariablesHcl(list *ast.ObjectList) ([]*Variable, error) {
 			DeclaredType: hclVar.DeclaredType,
 			Default:      hclVar.Default,
 			Description:  hclVar.Description,
+			Sensitive:    hclVar.Sensitive,
 		}
 		if err := newVar.ValidateTypeAndDefault(); err != nil {
 			return nil, err
@@ -480,6 +488,7 @@ func loadProvidersHcl(list *ast.ObjectList) ([]*ProviderConfig, error) {
 		}
 
 		delete(config, "alias")
+		delete(config, "version")
 
 		rawConfig, err := NewRawConfig(config)
 		if err != nil {
@@ -501,9 +510,22 @@ func loadProvidersHcl(list *ast.ObjectList) ([]*ProviderConfig, error) {
 			}
 		}
 
+		// The version constraint isn't configuration for the provider
+		var version string
+		if v := listVal.Filter("version"); len(v.Items) > 0 {
+			err := hcl.DecodeObject(&version, v.Items[0].Val)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Error reading version for provider[%s]: %s",
+					n,
+					err)
+			}
+		}
+
 		result = append(result, &ProviderConfig{
 			Name:      n,
 			Alias:     alias,
+			Version:   version,
 			RawConfig: rawConfig,
 		})
 	}
@@ -624,6 +646,7 @@ func loadDataResourcesHcl(list *ast.ObjectList) ([]*Resource, error) {
 			Provisioners: []*Provisioner{},
 			DependsOn:    dependsOn,
 			Lifecycle:    ResourceLifecycle{},
+			Pos:          item.Pos(),
 		})
 	}
 
@@ -820,6 +843,7 @@ func loadManagedResourcesHcl(list *ast.ObjectList) ([]*Resource, error) {
 			Provider:     provider,
 			DependsOn:    dependsOn,
 			Lifecycle:    lifecycle,
+			Pos:          item.Pos(),
 		})
 	}
 
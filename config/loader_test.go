@@ -183,7 +183,10 @@ func TestLoadFileBasic(t *testing.T) {
 		t.Fatalf("bad: %#v", c.Dir)
 	}
 
-	expectedTF := &Terraform{RequiredVersion: "foo"}
+	expectedTF := &Terraform{
+		RequiredVersion: "foo",
+		Path:            filepath.Join(fixtureDir, "basic.tf"),
+	}
 	if !reflect.DeepEqual(c.Terraform, expectedTF) {
 		t.Fatalf("bad: %#v", c.Terraform)
 	}
@@ -214,6 +217,29 @@ func TestLoadFileBasic(t *testing.T) {
 	}
 }
 
+func TestLoadFileBasic_resourcePos(t *testing.T) {
+	path := filepath.Join(fixtureDir, "basic.tf")
+	c, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]int{
+		"data.do.simple":              31,
+		"aws_security_group.firewall": 39,
+		"aws_instance.db":             61,
+	}
+	for _, r := range c.Resources {
+		line, ok := expected[r.Id()]
+		if !ok {
+			continue
+		}
+		if r.Pos.Filename != path || r.Pos.Line != line {
+			t.Fatalf("%s: bad: %s", r.Id(), r.Pos)
+		}
+	}
+}
+
 func TestLoadFileBasic_empty(t *testing.T) {
 	c, err := LoadFile(filepath.Join(fixtureDir, "empty.tf"))
 	if err != nil {
@@ -294,6 +320,26 @@ func TestLoadFileBasic_json(t *testing.T) {
 	}
 }
 
+func TestLoadFile_providerVersion(t *testing.T) {
+	c, err := LoadFile(filepath.Join(fixtureDir, "provider-version.tf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(c.ProviderConfigs) != 1 {
+		t.Fatalf("bad: %#v", c.ProviderConfigs)
+	}
+
+	// The version isn't part of the configuration given to the provider
+	pc := c.ProviderConfigs[0]
+	if pc.Version != "~> 1.0" {
+		t.Fatalf("bad: %#v", pc)
+	}
+	if _, ok := pc.RawConfig.Raw["version"]; ok {
+		t.Fatalf("bad: %#v", pc.RawConfig.Raw)
+	}
+}
+
 func TestLoadFileBasic_modules(t *testing.T) {
 	c, err := LoadFile(filepath.Join(fixtureDir, "modules.tf"))
 	if err != nil {
@@ -461,6 +507,23 @@ func TestLoadFile_variables(t *testing.T) {
 	}
 }
 
+func TestLoadFile_variableSensitive(t *testing.T) {
+	c, err := LoadFile(filepath.Join(fixtureDir, "variable-sensitive.tf"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sensitive := make(map[string]bool)
+	for _, v := range c.Variables {
+		sensitive[v.Name] = v.Sensitive
+	}
+
+	expected := map[string]bool{"foo": false, "password": true}
+	if !reflect.DeepEqual(sensitive, expected) {
+		t.Fatalf("bad: %#v", sensitive)
+	}
+}
+
 func TestLoadDir_basic(t *testing.T) {
 	dir := filepath.Join(fixtureDir, "dir-basic")
 	c, err := LoadDir(dir)
@@ -577,6 +640,21 @@ func TestLoadDir_overrideVar(t *testing.T) {
 	}
 }
 
+func TestLoadDir_overrideVarSensitive(t *testing.T) {
+	c, err := LoadDir(filepath.Join(fixtureDir, "dir-override-var-sensitive"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(c.Variables) != 1 {
+		t.Fatalf("bad: %#v", c.Variables)
+	}
+	v := c.Variables[0]
+	if !v.Sensitive || v.Default != "secret" || v.Description != "bar" {
+		t.Fatalf("bad: %#v", v)
+	}
+}
+
 func TestLoadFile_mismatchedVariableTypes(t *testing.T) {
 	_, err := LoadFile(filepath.Join(fixtureDir, "variable-mismatched-type.tf"))
 	if err == nil {
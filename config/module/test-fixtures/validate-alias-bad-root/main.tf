@@ -0,0 +1,11 @@
+provider "aws" {
+    alias = "east"
+}
+
+resource "aws_instance" "foo" {
+    provider = "aws.east"
+}
+
+resource "aws_instance" "bar" {
+    provider = "aws.west"
+}
@@ -5,10 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform/config"
 )
 
@@ -136,7 +139,58 @@ func (t *Tree) Name() string {
 // module trees inherently require the configuration to be in a reasonably
 // sane state: no circular dependencies, proper module sources, etc. A full
 // suite of validations can be done by running Validate (after loading).
+//
+// The modules are loaded in parallel, but the storage is only ever used
+// by one module at a time, so it doesn't have to be safe for concurrent
+// use. If loading several modules fails, the errors are sorted by the
+// path of the module so that they are the same from one load to the next.
 func (t *Tree) Load(s getter.Storage, mode GetMode) error {
+	// Without room for at least one module, nothing would ever load
+	n := LoadParallelism
+	if n < 1 {
+		n = 1
+	}
+
+	return t.load(&treeLoader{
+		storage: s,
+		mode:    mode,
+		sem:     make(chan struct{}, n),
+	})
+}
+
+// LoadParallelism is the number of modules that Tree.Load gets and parses
+// at the same time. Values less than 1 are treated as 1.
+var LoadParallelism = runtime.NumCPU()
+
+// treeLoader is what the loading of all the modules of a tree shares.
+type treeLoader struct {
+	storage getter.Storage
+	mode    GetMode
+
+	// sem bounds the number of modules that are got and parsed at once.
+	sem chan struct{}
+
+	// storageLock serializes the access to the storage, since storages
+	// such as one writing what it gets to the UI aren't safe for
+	// concurrent use.
+	storageLock sync.Mutex
+}
+
+// treeChild is a child module of a tree to load, and the result of
+// loading it.
+type treeChild struct {
+	name   string
+	path   []string
+	key    string
+	source string
+	subDir string
+
+	tree    *Tree
+	missing []string
+	err     error
+}
+
+func (t *Tree) load(l *treeLoader) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
@@ -144,14 +198,16 @@ func (t *Tree) Load(s getter.Storage, mode GetMode) error {
 	t.children = nil
 
 	modules := t.Modules()
-	children := make(map[string]*Tree)
+	children := make([]*treeChild, 0, len(modules))
+	names := make(map[string]struct{})
 
-	// Go through all the modules and get the directory for them.
+	// Go through all the modules and find where to get them from.
 	for _, m := range modules {
-		if _, ok := children[m.Name]; ok {
+		if _, ok := names[m.Name]; ok {
 			return fmt.Errorf(
 				"module %s: duplicated. module names must be unique", m.Name)
 		}
+		names[m.Name] = struct{}{}
 
 		// Determine the path to this child
 		path := make([]string, len(t.path), len(t.path)+1)
@@ -172,45 +228,129 @@ func (t *Tree) Load(s getter.Storage, mode GetMode) error {
 			subDir = filepath.Join(subDir2, subDir)
 		}
 
-		// Get the directory where this module is so we can load it
 		key := strings.Join(path, ".")
 		key = fmt.Sprintf("root.%s-%s", key, m.Source)
-		dir, ok, err := getStorage(s, key, source, mode)
-		if err != nil {
-			return err
-		}
-		if !ok {
-			return fmt.Errorf(
-				"module %s: not found, may need to be downloaded using 'terraform get'", m.Name)
+		children = append(children, &treeChild{
+			name:   m.Name,
+			path:   path,
+			key:    key,
+			source: source,
+			subDir: subDir,
+		})
+	}
+
+	// Get and load all the children, along with their own children.
+	var wg sync.WaitGroup
+	for _, c := range children {
+		wg.Add(1)
+		go func(c *treeChild) {
+			defer wg.Done()
+			c.load(l)
+		}(c)
+	}
+	wg.Wait()
+
+	// Keep going past missing modules so that all of them can be reported
+	// at once rather than one at a time, but any other error wins.
+	var missing []string
+	var errs []*treeChild
+	for _, c := range children {
+		missing = append(missing, c.missing...)
+		if c.err != nil {
+			errs = append(errs, c)
 		}
+	}
 
-		// If we have a subdirectory, then merge that in
-		if subDir != "" {
-			dir = filepath.Join(dir, subDir)
+	if len(errs) > 0 {
+		sort.Sort(treeChildrenByPath(errs))
+		if len(errs) == 1 {
+			return errs[0].err
 		}
 
-		// Load the configurations.Dir(source)
-		children[m.Name], err = NewTreeModule(m.Name, dir)
-		if err != nil {
-			return fmt.Errorf(
-				"module %s: %s", m.Name, err)
+		var err error
+		for _, c := range errs {
+			err = multierror.Append(err, c.err)
 		}
+		return err
+	}
 
-		// Set the path of this child
-		children[m.Name].path = path
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return &ErrModulesNotFound{Modules: missing}
 	}
 
-	// Go through all the children and load them.
+	// Set our tree up
+	t.children = make(map[string]*Tree, len(children))
 	for _, c := range children {
-		if err := c.Load(s, mode); err != nil {
-			return err
+		t.children[c.name] = c.tree
+	}
+
+	return nil
+}
+
+// treeChildrenByPath sorts tree children by their path.
+type treeChildrenByPath []*treeChild
+
+func (s treeChildrenByPath) Len() int      { return len(s) }
+func (s treeChildrenByPath) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s treeChildrenByPath) Less(i, j int) bool {
+	return strings.Join(s[i].path, ".") < strings.Join(s[j].path, ".")
+}
+
+// load gets the child module and loads it, recording the result in c.
+func (c *treeChild) load(l *treeLoader) {
+	l.sem <- struct{}{}
+	dir, ok := c.get(l)
+	if !ok {
+		<-l.sem
+		return
+	}
+
+	// If we have a subdirectory, then merge that in
+	if c.subDir != "" {
+		dir = filepath.Join(dir, c.subDir)
+	}
+
+	// Load the configurations.Dir(source)
+	tree, err := NewTreeModule(c.name, dir)
+	<-l.sem
+	if err != nil {
+		c.err = fmt.Errorf("module %s: %s", c.name, err)
+		return
+	}
+
+	// Set the path of this child
+	tree.path = c.path
+	c.tree = tree
+
+	// The children of the child are loaded without holding on to the
+	// semaphore, since they need it themselves.
+	if err := tree.load(l); err != nil {
+		if notFound, ok := err.(*ErrModulesNotFound); ok {
+			c.missing = notFound.Modules
+		} else {
+			c.err = err
 		}
 	}
+}
 
-	// Set our tree up
-	t.children = children
+// get returns the directory where the child module is, getting it first if
+// the mode allows, and false if it wasn't found.
+func (c *treeChild) get(l *treeLoader) (string, bool) {
+	l.storageLock.Lock()
+	defer l.storageLock.Unlock()
 
-	return nil
+	dir, ok, err := getStorage(l.storage, c.key, c.source, l.mode)
+	if err != nil {
+		c.err = err
+		return "", false
+	}
+	if !ok {
+		c.missing = []string{strings.Join(c.path, ".")}
+		return "", false
+	}
+
+	return dir, true
 }
 
 // Path is the full path to this tree.
@@ -372,6 +512,26 @@ func (t *Tree) Validate() error {
 	return nil
 }
 
+// ErrModulesNotFound is the error returned by Tree.Load if modules haven't
+// been downloaded yet and the GetMode doesn't allow downloading them.
+type ErrModulesNotFound struct {
+	// Modules are the paths of the modules that weren't found, with the
+	// names of the modules in each path joined by dots.
+	Modules []string
+}
+
+func (e *ErrModulesNotFound) Error() string {
+	if len(e.Modules) == 1 {
+		return fmt.Sprintf(
+			"module %s: not found, may need to be downloaded using 'terraform get'",
+			e.Modules[0])
+	}
+
+	return fmt.Sprintf(
+		"modules not found, may need to be downloaded using 'terraform get': %s",
+		strings.Join(e.Modules, ", "))
+}
+
 // TreeError is an error returned by Tree.Validate if an error occurs
 // with validation.
 type TreeError struct {
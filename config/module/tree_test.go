@@ -1,8 +1,11 @@
 package module
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -99,6 +102,47 @@ func TestTreeLoad(t *testing.T) {
 	}
 }
 
+func TestTreeLoad_parallelismZero(t *testing.T) {
+	defer func(old int) { LoadParallelism = old }(LoadParallelism)
+	LoadParallelism = 0
+
+	storage := testStorage(t)
+	tree := NewTree("", testConfig(t, "basic"))
+	if err := tree.Load(storage, GetModeGet); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual := strings.TrimSpace(tree.String())
+	expected := strings.TrimSpace(treeLoadStr)
+	if actual != expected {
+		t.Fatalf("bad: \n\n%s", actual)
+	}
+}
+
+func TestTreeLoad_missing(t *testing.T) {
+	storage := testStorage(t)
+	tree := NewTree("", testConfig(t, "load-missing"))
+
+	err := tree.Load(storage, GetModeNone)
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	notFound, ok := err.(*ErrModulesNotFound)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	expected := []string{"bar", "foo"}
+	if !reflect.DeepEqual(notFound.Modules, expected) {
+		t.Fatalf("bad: %#v", notFound.Modules)
+	}
+
+	if tree.Loaded() {
+		t.Fatal("should not be loaded")
+	}
+}
+
 func TestTreeLoad_duplicate(t *testing.T) {
 	storage := testStorage(t)
 	tree := NewTree("", testConfig(t, "dup"))
@@ -113,6 +157,38 @@ func TestTreeLoad_duplicate(t *testing.T) {
 	}
 }
 
+func TestTreeLoad_errors(t *testing.T) {
+	storage := testStorage(t)
+	tree := NewTree("", testConfig(t, "load-errors"))
+
+	// The modules are loaded in parallel, so the errors of both are
+	// reported, in the same order every time.
+	var expected string
+	for i := 0; i < 20; i++ {
+		err := tree.Load(storage, GetModeGet)
+		if err == nil {
+			t.Fatal("should error")
+		}
+
+		actual := err.Error()
+		if i == 0 {
+			expected = actual
+		}
+		if actual != expected {
+			t.Fatalf("expected:\n\n%s\n\ngot:\n\n%s", expected, actual)
+		}
+	}
+
+	a, b := strings.Index(expected, "module a:"), strings.Index(expected, "module b:")
+	if a < 0 || b < 0 || a > b {
+		t.Fatalf("bad: %s", expected)
+	}
+
+	if tree.Loaded() {
+		t.Fatal("should not be loaded")
+	}
+}
+
 func TestTreeLoad_copyable(t *testing.T) {
 	dir := tempDir(t)
 	storage := &getter.FolderStorage{StorageDir: dir}
@@ -288,6 +364,12 @@ func TestTreeValidate_table(t *testing.T) {
 			"validate-alias-bad",
 			"alias must be defined",
 		},
+
+		{
+			"undefined provider alias in root names the resource",
+			"validate-alias-bad-root",
+			"module root: provider alias must be defined by the module or a parent: aws.west (used by aws_instance.bar)",
+		},
 	}
 
 	for i, tc := range cases {
@@ -403,6 +485,59 @@ func TestTreeValidate_requiredChildVar(t *testing.T) {
 	}
 }
 
+func BenchmarkTreeLoad(b *testing.B) {
+	dir, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Generate a root module with 100 child modules of 20 resources each
+	var root bytes.Buffer
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("child%d", i)
+		fmt.Fprintf(&root, "module %q {\n  source = \"./%s\"\n}\n\n", name, name)
+
+		var child bytes.Buffer
+		for j := 0; j < 20; j++ {
+			fmt.Fprintf(&child,
+				"resource \"aws_instance\" \"foo%d\" {\n  ami = \"ami-%d\"\n  count = 2\n}\n\n", j, j)
+		}
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		path := filepath.Join(dir, name, "main.tf")
+		if err := ioutil.WriteFile(path, child.Bytes(), 0644); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.tf"), root.Bytes(), 0644); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	c, err := config.LoadDir(dir)
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	storageDir := filepath.Join(dir, ".terraform")
+	storage := &getter.FolderStorage{StorageDir: storageDir}
+	if err := NewTree("", c).Load(storage, GetModeGet); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	defer func(old int) { LoadParallelism = old }(LoadParallelism)
+	for _, n := range []int{1, 8} {
+		b.Run(fmt.Sprintf("parallelism-%d", n), func(b *testing.B) {
+			LoadParallelism = n
+			for i := 0; i < b.N; i++ {
+				if err := NewTree("", c).Load(storage, GetModeNone); err != nil {
+					b.Fatalf("err: %s", err)
+				}
+			}
+		})
+	}
+}
+
 const treeLoadStr = `
 root
   foo (path: foo)
@@ -2,6 +2,7 @@ package module
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -47,7 +48,7 @@ func (t *Tree) validateProviderAlias() error {
 				parents = append(parents, pv)
 			}
 		}
-		for k, _ := range pv.Used {
+		for k, resources := range pv.Used {
 			// Check if we define this
 			if _, ok := pv.Defined[k]; ok {
 				continue
@@ -65,10 +66,16 @@ func (t *Tree) validateProviderAlias() error {
 				continue
 			}
 
-			// We didn't find the alias, error!
+			// We didn't find the alias, error! Report every resource that
+			// references it so the typo can be found quickly.
+			name := RootName
+			if len(pv.Path) > 0 {
+				name = strings.Join(pv.Path, ".")
+			}
+			sort.Strings(resources)
 			err = multierror.Append(err, fmt.Errorf(
-				"module %s: provider alias must be defined by the module or a parent: %s",
-				strings.Join(pv.Path, "."), k))
+				"module %s: provider alias must be defined by the module or a parent: %s (used by %s)",
+				name, k, strings.Join(resources, ", ")))
 		}
 	}
 
@@ -82,11 +89,11 @@ func (t *Tree) buildProviderAliasGraph(g *dag.AcyclicGraph, parent dag.Vertex) {
 		defined[p.FullName()] = struct{}{}
 	}
 
-	// Add all our used aliases
-	used := make(map[string]struct{})
+	// Add all our used aliases along with the resources that use them
+	used := make(map[string][]string)
 	for _, r := range t.config.Resources {
 		if r.Provider != "" {
-			used[r.Provider] = struct{}{}
+			used[r.Provider] = append(used[r.Provider], r.Id())
 		}
 	}
 
@@ -114,5 +121,5 @@ func (t *Tree) buildProviderAliasGraph(g *dag.AcyclicGraph, parent dag.Vertex) {
 type providerAliasVertex struct {
 	Path    []string
 	Defined map[string]struct{}
-	Used    map[string]struct{}
+	Used    map[string][]string
 }
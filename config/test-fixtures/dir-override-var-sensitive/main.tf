@@ -0,0 +1,4 @@
+variable "password" {
+    default = "secret"
+    description = "bar"
+}
@@ -0,0 +1,5 @@
+variable "foo" {}
+
+variable "password" {
+    sensitive = true
+}
@@ -50,11 +50,31 @@ func TestGraphDot_attrs(t *testing.T) {
 	}
 }
 
+func TestGraphDot_opts(t *testing.T) {
+	var g Graph
+	n := &testGraphNodeDotterOpts{}
+	g.Add(n)
+
+	opts := &DotOpts{MaxDepth: -1}
+	g.Dot(opts)
+	if n.Opts != opts {
+		t.Fatalf("bad: %#v", n.Opts)
+	}
+}
+
 type testGraphNodeDotter struct{ Result *DotNode }
 
 func (n *testGraphNodeDotter) Name() string                      { return n.Result.Name }
 func (n *testGraphNodeDotter) DotNode(string, *DotOpts) *DotNode { return n.Result }
 
+type testGraphNodeDotterOpts struct{ Opts *DotOpts }
+
+func (n *testGraphNodeDotterOpts) Name() string { return "foo" }
+func (n *testGraphNodeDotterOpts) DotNode(name string, opts *DotOpts) *DotNode {
+	n.Opts = opts
+	return &DotNode{Name: name}
+}
+
 const testGraphDotBasicStr = `digraph {
 	compound = "true"
 	newrank = "true"
@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The standard logger doesn't let us read back where it is writing to,
+// so the output is tracked here in order to be able to restore it once
+// all the captures are done.
+var (
+	captureLock   sync.Mutex
+	captureOutput io.Writer = os.Stderr
+	captures                = make(map[string]io.Writer)
+)
+
+// SetWriter sets the output of the standard logger to w. This should be
+// used instead of log.SetOutput so that Capture knows where the logs
+// were going before they were captured.
+func SetWriter(w io.Writer) {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+
+	captureOutput = w
+	if len(captures) > 0 {
+		// Captures are active, so the router is still the output. It
+		// will pick up the new writer.
+		return
+	}
+
+	log.SetOutput(w)
+}
+
+// Capture sends the output of the standard logger to w in addition to
+// its current output, until the returned function is called. This allows
+// capturing the logs of a single operation.
+//
+// The id identifies the capture and must be unique among the active
+// captures. Since the standard logger is global, lines can't be attributed
+// to one of several operations running at the same time. While more than
+// one capture is active, every capture receives all the lines, tagged with
+// the IDs of the captures that were active when they were logged.
+func Capture(w io.Writer, id string) func() {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+
+	if _, ok := captures[id]; ok {
+		panic(fmt.Sprintf("log capture %q is already active", id))
+	}
+
+	captures[id] = w
+	if len(captures) == 1 {
+		log.SetOutput(captureRouter{})
+	}
+
+	return func() {
+		captureLock.Lock()
+		defer captureLock.Unlock()
+
+		delete(captures, id)
+		if len(captures) == 0 {
+			log.SetOutput(captureOutput)
+		}
+	}
+}
+
+// captureRouter is the output of the standard logger while captures are
+// active. The logger writes a full line with every call to Write.
+type captureRouter struct{}
+
+func (captureRouter) Write(p []byte) (int, error) {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+
+	// Always write to the regular output so that nothing is lost from
+	// it, such as the logs used for crash reports.
+	n, err := captureOutput.Write(p)
+
+	line := p
+	if len(captures) > 1 {
+		ids := make([]string, 0, len(captures))
+		for id := range captures {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		line = []byte(fmt.Sprintf("[%s] %s", strings.Join(ids, ","), p))
+	}
+
+	for id, w := range captures {
+		// A capture failing shouldn't stop the logs from going to
+		// the regular output or the other captures.
+		if _, err := w.Write(line); err != nil {
+			fmt.Fprintf(captureOutput, "[WARN] Error writing capture %q: %s\n", id, err)
+		}
+	}
+
+	return n, err
+}
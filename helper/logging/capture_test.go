@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	var out bytes.Buffer
+	SetWriter(&out)
+	defer SetWriter(ioutil.Discard)
+
+	var a, b bytes.Buffer
+	stopA := Capture(&a, "a")
+	log.Printf("[INFO] one")
+
+	stopB := Capture(&b, "b")
+	log.Printf("[INFO] two")
+	stopA()
+
+	log.Printf("[INFO] three")
+	stopB()
+	log.Printf("[INFO] four")
+
+	// The regular output gets every line untagged
+	for _, v := range []string{"one", "two", "three", "four"} {
+		if !strings.Contains(out.String(), "[INFO] "+v) {
+			t.Fatalf("bad: %q missing in:\n\n%s", v, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "[a,b]") {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	if !strings.Contains(a.String(), "[INFO] one") ||
+		!strings.Contains(a.String(), "[a,b] ") ||
+		strings.Contains(a.String(), "three") {
+		t.Fatalf("bad: %s", a.String())
+	}
+	if strings.Contains(b.String(), "one") ||
+		!strings.Contains(b.String(), "[a,b] ") ||
+		!strings.Contains(b.String(), "[INFO] three") ||
+		strings.Contains(b.String(), "four") {
+		t.Fatalf("bad: %s", b.String())
+	}
+}
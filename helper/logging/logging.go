@@ -39,17 +39,29 @@ func LogOutput() (logOutput io.Writer, err error) {
 	}
 
 	// This was the default since the beginning
-	logOutput = &logutils.LevelFilter{
+	logOutput = NewLevelFilter(logOutput)
+
+	return
+}
+
+// NewLevelFilter returns a writer that writes the log lines at the log
+// level set by the environment or higher to w. If no log level is set,
+// all the lines are written.
+func NewLevelFilter(w io.Writer) *logutils.LevelFilter {
+	logLevel := LogLevel()
+	if logLevel == "" {
+		logLevel = "TRACE"
+	}
+
+	return &logutils.LevelFilter{
 		Levels:   validLevels,
 		MinLevel: logutils.LogLevel(logLevel),
-		Writer:   logOutput,
+		Writer:   w,
 	}
-
-	return
 }
 
 // SetOutput checks for a log destination with LogOutput, and calls
-// log.SetOutput with the result. If LogOutput returns nil, SetOutput uses
+// SetWriter with the result. If LogOutput returns nil, SetOutput uses
 // ioutil.Discard. Any error from LogOutout is fatal.
 func SetOutput() {
 	out, err := LogOutput()
@@ -61,7 +73,7 @@ func SetOutput() {
 		out = ioutil.Discard
 	}
 
-	log.SetOutput(out)
+	SetWriter(out)
 }
 
 // LogLevel returns the current log level string based the environment vars
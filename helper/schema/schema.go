@@ -1068,6 +1068,7 @@ func (m schemaMap) inputString(
 		Query:       k,
 		Description: schema.Description,
 		Default:     schema.InputDefault,
+		Sensitive:   schema.Sensitive,
 	})
 
 	return result, err
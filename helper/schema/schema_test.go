@@ -2905,6 +2905,39 @@ func TestSchemaMap_InputDeprecated(t *testing.T) {
 	}
 }
 
+func TestSchemaMap_InputSensitive(t *testing.T) {
+	emptyConfig := make(map[string]interface{})
+	c, err := config.NewRawConfig(emptyConfig)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	rc := terraform.NewResourceConfig(c)
+	rc.Config = make(map[string]interface{})
+
+	input := new(terraform.MockUIInput)
+	input.InputFn = func(opts *terraform.InputOpts) (string, error) {
+		if opts.Sensitive != (opts.Id == "password") {
+			t.Fatalf("bad: %#v", opts)
+		}
+		return "foo", nil
+	}
+
+	schema := map[string]*Schema{
+		"password": &Schema{
+			Type:      TypeString,
+			Required:  true,
+			Sensitive: true,
+		},
+		"username": &Schema{
+			Type:     TypeString,
+			Required: true,
+		},
+	}
+	if _, err := schemaMap(schema).Input(input, rc); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestSchemaMap_InternalValidate(t *testing.T) {
 	cases := map[string]struct {
 		In  map[string]*Schema
@@ -0,0 +1,123 @@
+// Package textfile has helpers for reading the text files that users edit
+// by hand, such as state and variable files, which are often mangled by
+// editors on Windows.
+package textfile
+
+import (
+	"bytes"
+	"strings"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// StripBOM returns the contents without the UTF-8 byte order mark at the
+// start, if there is one. Editors on Windows often add it, but it isn't
+// valid JSON or HCL.
+func StripBOM(b []byte) []byte {
+	return bytes.TrimPrefix(b, bomUTF8)
+}
+
+// Hint returns a hint about why the contents of a file couldn't be
+// parsed, for the common ways files are mangled: a different encoding
+// than UTF-8, a line ending within a quoted string, or a file that ends
+// before all its braces and brackets are closed. It returns an empty
+// string if none of these are found. The hint starts with blank lines so
+// that it can be added to the end of the parse error.
+func Hint(b []byte) string {
+	hint := hint(b)
+	if hint == "" {
+		return ""
+	}
+
+	return "\n\nHint: " + hint
+}
+
+func hint(b []byte) string {
+	if bytes.HasPrefix(b, bomUTF16BE) || bytes.HasPrefix(b, bomUTF16LE) ||
+		bytes.IndexByte(b, 0) != -1 {
+		return strings.TrimSpace(hintUTF16)
+	}
+
+	if bytes.Contains(StripBOM(b), bomUTF8) {
+		return strings.TrimSpace(hintBOM)
+	}
+
+	var inString, inComment, escaped, crInString bool
+	var depth int
+	var prev byte
+	for _, c := range b {
+		last := prev
+		prev = c
+
+		if inComment {
+			inComment = c != '\n'
+			continue
+		}
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			case c == '\r':
+				crInString = true
+			case c == '\n':
+				// A string can't span lines, so this is as far as it goes
+				inString = false
+			}
+
+			continue
+		}
+
+		switch {
+		case c == '#' || (c == '/' && last == '/'):
+			// Comments in HCL can have anything in them
+			inComment = true
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+
+	if crInString {
+		return strings.TrimSpace(hintCRLF)
+	}
+	if inString || depth > 0 {
+		return strings.TrimSpace(hintTruncated)
+	}
+
+	return ""
+}
+
+const hintUTF16 = `
+The file seems to be encoded as UTF-16, but it must be UTF-8. This is
+common for files saved by editors or PowerShell on Windows. Save the
+file as UTF-8 and try again.
+`
+
+const hintBOM = `
+The file contains a UTF-8 byte order mark other than at its start, such
+as from concatenating files saved on Windows. Remove it and try again.
+`
+
+const hintCRLF = `
+A quoted string in the file contains a carriage return, so it was likely
+split across lines with Windows line endings (CRLF). Keep each string on
+a single line, using "\n" for a newline within it, and try again.
+`
+
+const hintTruncated = `
+The file ends before all its quotes, braces and brackets are closed, so
+it may have been cut short, such as by a full disk or an interrupted
+write. If it's a state file, restore it from its backup.
+`
@@ -0,0 +1,55 @@
+package textfile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripBOM(t *testing.T) {
+	cases := map[string]string{
+		"\xef\xbb\xbf{}": "{}",
+		"{}":             "{}",
+		"":               "",
+	}
+
+	for input, expected := range cases {
+		if actual := string(StripBOM([]byte(input))); actual != expected {
+			t.Fatalf("%q: bad: %q", input, actual)
+		}
+	}
+}
+
+func TestHint(t *testing.T) {
+	cases := []struct {
+		Input    string
+		Expected string
+	}{
+		// Nothing wrong that we can spot
+		{`{"foo": "bar"}`, ""},
+		{"{\r\n  \"foo\": \"bar\"\r\n}\r\n", ""},
+		{"{\"foo\": bar}", ""},
+		{"foo = \"bar\" # a \"comment\r\n", ""},
+		{"foo = \"{\\\"\"\n", ""},
+
+		{"\xff\xfe{\x00}\x00", "UTF-16"},
+		{"{\x00}\x00", "UTF-16"},
+		{"{}\xef\xbb\xbf{}", "byte order mark"},
+		{"{\"foo\": \"bar\r\nbaz\"}", "carriage return"},
+		{"{\"foo\": [\"bar\"", "cut short"},
+		{"{\"foo\": \"ba", "cut short"},
+	}
+
+	for _, tc := range cases {
+		actual := Hint([]byte(tc.Input))
+		if tc.Expected == "" {
+			if actual != "" {
+				t.Fatalf("%q: bad: %s", tc.Input, actual)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(actual, "\n\nHint: ") || !strings.Contains(actual, tc.Expected) {
+			t.Fatalf("%q: bad: %s", tc.Input, actual)
+		}
+	}
+}
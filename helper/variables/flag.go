@@ -30,7 +30,7 @@ func (v *Flag) Set(raw string) error {
 
 	value, err := ParseInput(input)
 	if err != nil {
-		return err
+		return fmt.Errorf("Invalid value for variable %q: %s", key, err)
 	}
 
 	*v = Merge(*v, map[string]interface{}{key: value})
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 
 	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/terraform/helper/textfile"
 	"github.com/mitchellh/go-homedir"
 )
 
@@ -41,10 +42,11 @@ func loadKVFile(rawPath string) (map[string]interface{}, error) {
 	}
 
 	// Parse it
+	d = textfile.StripBOM(d)
 	obj, err := hcl.Parse(string(d))
 	if err != nil {
 		return nil, fmt.Errorf(
-			"Error parsing %s: %s", path, err)
+			"Error parsing %s: %s%s", path, err, textfile.Hint(d))
 	}
 
 	var result map[string]interface{}
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -105,3 +107,38 @@ foo = {
 		})
 	}
 }
+
+func TestFlagFile_encoding(t *testing.T) {
+	cases := []struct {
+		File string
+		Hint string
+	}{
+		// A byte order mark is read past
+		{"bom.tfvars", ""},
+
+		{"crlf-string.tfvars", "carriage return"},
+		{"truncated.tfvars", "cut short"},
+	}
+
+	for _, tc := range cases {
+		f := new(FlagFile)
+		err := f.Set(filepath.Join("test-fixtures", "encoding", tc.File))
+
+		if tc.Hint == "" {
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.File, err)
+			}
+			if !reflect.DeepEqual(*f, FlagFile{"foo": "bar"}) {
+				t.Fatalf("%s: bad: %#v", tc.File, *f)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("%s: should error", tc.File)
+		}
+		if !strings.Contains(err.Error(), "Hint: ") || !strings.Contains(err.Error(), tc.Hint) {
+			t.Fatalf("%s: bad: %s", tc.File, err)
+		}
+	}
+}
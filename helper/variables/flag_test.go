@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -143,6 +144,16 @@ func TestFlag(t *testing.T) {
 			false,
 		},
 
+		{
+			`key={ nested = { foo = "bar" } }`,
+			map[string]interface{}{
+				"key": map[string]interface{}{
+					"nested": map[string]interface{}{"foo": "bar"},
+				},
+			},
+			false,
+		},
+
 		// Test setting multiple times
 		{
 			[]string{
@@ -202,3 +213,14 @@ func TestFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestFlag_errorName(t *testing.T) {
+	f := new(Flag)
+	err := f.Set(`subnets=["a", "b"`)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), `variable "subnets"`) {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -64,7 +64,7 @@ func ParseInput(value string) (interface{}, error) {
 	}
 
 	var decoded map[string]interface{}
-	if hcl.DecodeObject(&decoded, parsed); err != nil {
+	if err := hcl.DecodeObject(&decoded, parsed); err != nil {
 		return nil, fmt.Errorf(
 			"Cannot parse value for variable (%q) as valid HCL: %s",
 			value, err)
@@ -101,18 +101,47 @@ var (
 // Variables don't support any type that can be configured via multiple
 // declarations of the same HCL map, so any instances of
 // []map[string]interface{} are either a single map that can be flattened, or
-// are invalid config.
+// are invalid config. Maps nested in the values are flattened too, so that
+// a value such as `{ a = { b = "c" } }` is a map of maps.
 func flattenMultiMaps(m map[string]interface{}) error {
 	for k, v := range m {
-		switch v := v.(type) {
-		case []map[string]interface{}:
-			switch {
-			case len(v) > 1:
-				return fmt.Errorf("multiple map declarations not supported for variables")
-			case len(v) == 1:
-				m[k] = v[0]
-			}
+		flat, err := flattenMultiMapsValue(v)
+		if err != nil {
+			return err
 		}
+
+		m[k] = flat
 	}
 	return nil
 }
+
+func flattenMultiMapsValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []map[string]interface{}:
+		switch {
+		case len(v) > 1:
+			return nil, fmt.Errorf("multiple map declarations not supported for variables")
+		case len(v) == 1:
+			if err := flattenMultiMaps(v[0]); err != nil {
+				return nil, err
+			}
+
+			return v[0], nil
+		}
+	case map[string]interface{}:
+		if err := flattenMultiMaps(v); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		for i, e := range v {
+			flat, err := flattenMultiMapsValue(e)
+			if err != nil {
+				return nil, err
+			}
+
+			v[i] = flat
+		}
+	}
+
+	return v, nil
+}
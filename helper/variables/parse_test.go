@@ -61,6 +61,39 @@ func TestParseInput(t *testing.T) {
 			map[string]interface{}{"foo": "bar"},
 			false,
 		},
+
+		{
+			"nested map",
+			`{ foo = { bar = "baz" } }`,
+			map[string]interface{}{
+				"foo": map[string]interface{}{"bar": "baz"},
+			},
+			false,
+		},
+
+		{
+			"list of maps",
+			`[{ foo = "bar" }, { foo = "baz" }]`,
+			[]interface{}{
+				map[string]interface{}{"foo": "bar"},
+				map[string]interface{}{"foo": "baz"},
+			},
+			false,
+		},
+
+		{
+			"string with brackets",
+			"foo[0]",
+			"foo[0]",
+			false,
+		},
+
+		{
+			"malformed list",
+			`["foo"`,
+			nil,
+			true,
+		},
 	}
 
 	for i, tc := range cases {
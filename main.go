@@ -92,7 +92,7 @@ func wrappedMain() int {
 	// We always need to close the DebugInfo before we exit.
 	defer terraform.CloseDebugInfo()
 
-	log.SetOutput(os.Stderr)
+	logging.SetWriter(os.Stderr)
 	log.Printf(
 		"[INFO] Terraform version: %s %s %s",
 		Version, VersionPrerelease, GitCommit)
@@ -1,6 +1,8 @@
 package state
 
 import (
+	"log"
+
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -12,6 +14,15 @@ type BackupState struct {
 	Real State
 	Path string
 
+	// FallbackPath, if set, is where the backup is written if it can't be
+	// written to Path, such as when Path is in a shared directory that we
+	// can't write to. A warning is logged, and OnFallback is called with
+	// the error from writing to Path if it is set. If the backup can't be
+	// written to FallbackPath either, the error from writing to Path is
+	// returned.
+	FallbackPath string
+	OnFallback   func(error)
+
 	done bool
 }
 
@@ -43,21 +54,74 @@ func (s *BackupState) PersistState() error {
 	return s.Real.PersistState()
 }
 
+// Lock locks the real state if it supports locking.
+//
+// Locker impl.
+func (s *BackupState) Lock(info *LockInfo) (string, error) {
+	if l, ok := s.Real.(Locker); ok {
+		return l.Lock(info)
+	}
+
+	return "", nil
+}
+
+// Unlock unlocks the real state if it supports locking.
+//
+// Locker impl.
+func (s *BackupState) Unlock(id string) error {
+	if l, ok := s.Real.(Locker); ok {
+		return l.Unlock(id)
+	}
+
+	return nil
+}
+
 func (s *BackupState) backup() error {
-	state := s.Real.State()
+	state := s.realState()
 	if state == nil {
 		if err := s.Real.RefreshState(); err != nil {
 			return err
 		}
 
-		state = s.Real.State()
+		state = s.realState()
 	}
 
-	ls := &LocalState{Path: s.Path}
-	if err := ls.WriteState(state); err != nil {
-		return err
+	if err := writeBackup(s.Path, state); err != nil {
+		if s.FallbackPath == "" {
+			return err
+		}
+
+		log.Printf(
+			"[WARN] Error writing the state backup %s, writing it to %s instead: %s",
+			s.Path, s.FallbackPath, err)
+		if fallbackErr := writeBackup(s.FallbackPath, state); fallbackErr != nil {
+			log.Printf("[ERROR] Error writing the state backup %s: %s",
+				s.FallbackPath, fallbackErr)
+			return err
+		}
+		if s.OnFallback != nil {
+			s.OnFallback(err)
+		}
 	}
 
 	s.done = true
 	return nil
 }
+
+// writeBackup writes the backup of the given state to path.
+func writeBackup(path string, state *terraform.State) error {
+	ls := &LocalState{Path: path}
+	return ls.WriteState(state)
+}
+
+// realState returns the state of Real to back up. The state held by a
+// LocalState is backed up as it is rather than a copy of it, since a copy
+// of a very large state is a lot of memory for nothing: writing the state
+// only sorts it, which reading it already did.
+func (s *BackupState) realState() *terraform.State {
+	if ls, ok := s.Real.(*LocalState); ok {
+		return ls.state
+	}
+
+	return s.Real.State()
+}
@@ -3,6 +3,7 @@ package state
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -27,3 +28,43 @@ func TestBackupState(t *testing.T) {
 		t.Fatalf("bad: %d", fi.Size())
 	}
 }
+
+func TestBackupState_fallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The backup can't be written to a path that is a directory
+	path := filepath.Join(dir, "backup")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	var fallbackErr error
+	bs := &BackupState{
+		Real:         ls,
+		Path:         path,
+		FallbackPath: filepath.Join(dir, "fallback", "backup"),
+		OnFallback:   func(err error) { fallbackErr = err },
+	}
+	if err := bs.WriteState(ls.State()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fallbackErr == nil {
+		t.Fatal("OnFallback should be called")
+	}
+	if _, err := os.Stat(bs.FallbackPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Without a fallback, the backup fails
+	bs = &BackupState{Real: ls, Path: path}
+	if err := bs.WriteState(ls.State()); err == nil {
+		t.Fatal("should error")
+	}
+}
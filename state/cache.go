@@ -14,6 +14,10 @@ type CacheState struct {
 
 	refreshResult CacheRefreshResult
 	state         *terraform.State
+
+	// cacheLocked is true if Lock locked the cache, since the durable
+	// state couldn't be locked.
+	cacheLocked bool
 }
 
 // StateReader impl.
@@ -136,6 +140,56 @@ func (s *CacheState) PersistState() error {
 	return s.Durable.PersistState()
 }
 
+// Lock locks the durable state if it supports locking. If it doesn't, or
+// it returns no lock ID because it can't be locked, such as a remote state
+// whose client has no locking, the cache is locked instead if it supports
+// locking.
+//
+// Locker impl.
+func (s *CacheState) Lock(info *LockInfo) (string, error) {
+	if l, ok := s.Durable.(Locker); ok {
+		id, err := l.Lock(info)
+		if err != nil || id != "" {
+			return id, err
+		}
+	}
+
+	if l, ok := s.Cache.(Locker); ok {
+		id, err := l.Lock(info)
+		if err == nil {
+			s.cacheLocked = true
+		}
+		return id, err
+	}
+
+	return "", nil
+}
+
+// Unlock unlocks the state locked by Lock.
+//
+// Locker impl.
+func (s *CacheState) Unlock(id string) error {
+	if s.cacheLocked {
+		s.cacheLocked = false
+		return s.Cache.(Locker).Unlock(id)
+	}
+
+	if l, ok := s.Durable.(Locker); ok {
+		if err := l.Unlock(id); err != nil {
+			return err
+		}
+	}
+
+	// The lock may be on the cache if it was taken by another process,
+	// such as when it is force-unlocked. It's only removed if it has the
+	// given ID, and otherwise there's nothing to unlock there.
+	if l, ok := s.Cache.(Locker); ok {
+		l.Unlock(id)
+	}
+
+	return nil
+}
+
 // CacheStateCache is the meta-interface that must be implemented for
 // the cache for the CacheState.
 type CacheStateCache interface {
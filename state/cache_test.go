@@ -110,9 +110,66 @@ func TestCacheState_RefreshState(t *testing.T) {
 	}
 }
 
+func TestCacheState_lockNonLockingDurable(t *testing.T) {
+	cache := testLocalState(t)
+	defer os.Remove(cache.Path)
+
+	cs := &CacheState{
+		Cache:   cache,
+		Durable: &nonLockingState{InmemState: new(InmemState)},
+	}
+
+	// The durable state can't be locked, so the cache must be
+	id, err := cs.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id == "" {
+		t.Fatal("should have a lock ID")
+	}
+	defer os.Remove(cache.lockInfoPath())
+
+	other := &CacheState{
+		Cache:   &LocalState{Path: cache.Path},
+		Durable: &nonLockingState{InmemState: new(InmemState)},
+	}
+	if _, err := other.Lock(NewLockInfo()); err == nil {
+		t.Fatal("should error")
+	} else if _, ok := err.(*LockError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	if err := cs.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Now the other state can lock
+	id, err = other.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := other.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestCacheState_impl(t *testing.T) {
 	var _ StateReader = new(CacheState)
 	var _ StateWriter = new(CacheState)
 	var _ StatePersister = new(CacheState)
 	var _ StateRefresher = new(CacheState)
 }
+
+// nonLockingState is a durable state that is a Locker but can't actually
+// be locked, like a remote state whose client has no locking.
+type nonLockingState struct {
+	*InmemState
+}
+
+func (s *nonLockingState) Lock(info *LockInfo) (string, error) {
+	return "", nil
+}
+
+func (s *nonLockingState) Unlock(id string) error {
+	return nil
+}
@@ -1,12 +1,19 @@
 package state
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/hashicorp/terraform/terraform"
 )
 
-// InmemState is an in-memory state storage.
+// InmemState is an in-memory state storage. It can be locked so that it
+// can stand in for any other state in tests.
 type InmemState struct {
 	state *terraform.State
+
+	lockLock sync.Mutex
+	lockInfo *LockInfo
 }
 
 func (s *InmemState) State() *terraform.State {
@@ -26,3 +33,35 @@ func (s *InmemState) WriteState(state *terraform.State) error {
 func (s *InmemState) PersistState() error {
 	return nil
 }
+
+// Locker impl.
+func (s *InmemState) Lock(info *LockInfo) (string, error) {
+	s.lockLock.Lock()
+	defer s.lockLock.Unlock()
+
+	if s.lockInfo != nil {
+		return "", &LockError{Info: s.lockInfo}
+	}
+
+	info.Path = "(in memory)"
+	s.lockInfo = info
+	return info.ID, nil
+}
+
+// Locker impl.
+func (s *InmemState) Unlock(id string) error {
+	s.lockLock.Lock()
+	defer s.lockLock.Unlock()
+
+	if s.lockInfo == nil {
+		return fmt.Errorf("state is not locked")
+	}
+	if s.lockInfo.ID != id {
+		return fmt.Errorf(
+			"lock ID %q does not match the ID of the existing lock %q",
+			id, s.lockInfo.ID)
+	}
+
+	s.lockInfo = nil
+	return nil
+}
@@ -13,4 +13,10 @@ func TestInmemState_impl(t *testing.T) {
 	var _ StateWriter = new(InmemState)
 	var _ StatePersister = new(InmemState)
 	var _ StateRefresher = new(InmemState)
+	var _ Locker = new(InmemState)
+}
+
+func TestInmemState_lock(t *testing.T) {
+	s := &InmemState{state: TestStateInitial()}
+	TestLocker(t, s, s)
 }
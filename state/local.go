@@ -1,12 +1,32 @@
 package state
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/hashicorp/terraform/helper/textfile"
 	"github.com/hashicorp/terraform/terraform"
 )
 
+const (
+	// DefaultWriteRetries is the number of times writing the state file
+	// is retried when it is temporarily in use by another program.
+	DefaultWriteRetries = 5
+
+	// DefaultWriteRetryInterval is how long to wait before the first
+	// retry. The wait doubles with each retry after that.
+	DefaultWriteRetryInterval = 200 * time.Millisecond
+)
+
 // LocalState manages a state storage that is local to the filesystem.
 type LocalState struct {
 	// Path is the path to read the state from. PathOut is the path to
@@ -15,9 +35,66 @@ type LocalState struct {
 	Path    string
 	PathOut string
 
+	// WriteRetries and WriteRetryInterval configure retrying to write the
+	// state when the file is temporarily in use by another program, such
+	// as an antivirus scanner or an editor on Windows. If WriteRetries is
+	// zero, DefaultWriteRetries is used, and if it is negative, writing
+	// is never retried.
+	WriteRetries       int
+	WriteRetryInterval time.Duration
+
+	// DisableAutoUpgrade makes writing a state that was read from a file
+	// in an older format version an error. By default, the file is
+	// upgraded to the current format when it is first written, after its
+	// original contents are saved to a backup next to it, such as
+	// "terraform.tfstate.v1.backup".
+	DisableAutoUpgrade bool
+
+	// StaleLockTimeout, if set, lets Lock take over a lock that is older
+	// than this and was acquired by a process on this host that no longer
+	// runs, such as one that crashed. A warning is logged, and the lock
+	// info of the stale lock is kept in TookOverLock. Locks acquired on
+	// other hosts are never taken over, since we can't tell whether their
+	// process still runs.
+	//
+	// Only the pid of the process and the time the lock was acquired are
+	// recorded, not the start time of the process. So if the pid was
+	// reused by another process since, the lock isn't taken over even
+	// though its process no longer runs.
+	StaleLockTimeout time.Duration
+	TookOverLock     *LockInfo
+
+	// LockPath is the path of the lock info file. If empty, the lock info
+	// is kept next to the state file, such as in
+	// ".terraform.tfstate.lock.info". While the state is locked, the path
+	// of the lock info file is recorded in a lock path file next to the
+	// state file, so that processes that lock the same state with another
+	// lock path can tell: Lock logs a warning and sets LockPathMismatch
+	// to the lock path of the other process, since the locks don't keep
+	// them from changing the state at the same time.
+	LockPath         string
+	LockPathMismatch string
+
+	// createFile creates the file to write the state to. This is only
+	// set by tests to simulate errors.
+	createFile func(string) (io.WriteCloser, error)
+
 	state     *terraform.State
 	readState *terraform.State
 	written   bool
+	lockID    string
+
+	// futureVersion is the version of Terraform that wrote the state file
+	// if it is newer than this one. Such a state can be read, but it is
+	// never overwritten since this version may not keep everything the
+	// newer version recorded in it.
+	futureVersion string
+
+	// upgradeFrom and upgradeData are the format version and contents of
+	// the state file if it was read in an older format. They're written to
+	// a backup before the file is first written in the current format.
+	upgradeFrom int
+	upgradeData []byte
 }
 
 // SetState will force a specific state in-memory for this local state.
@@ -26,6 +103,18 @@ func (s *LocalState) SetState(state *terraform.State) {
 	s.readState = state
 }
 
+// Describe returns the path of the state file, and the path it's written
+// to if that's different.
+//
+// Describer impl.
+func (s *LocalState) Describe() string {
+	if s.PathOut != "" && s.PathOut != s.Path {
+		return fmt.Sprintf("%s, written to %s", s.Path, s.PathOut)
+	}
+
+	return s.Path
+}
+
 // StateReader impl.
 func (s *LocalState) State() *terraform.State {
 	return s.state.DeepCopy()
@@ -35,13 +124,23 @@ func (s *LocalState) State() *terraform.State {
 //
 // StateWriter impl.
 func (s *LocalState) WriteState(state *terraform.State) error {
-	s.state = state
-
 	path := s.PathOut
 	if path == "" {
 		path = s.Path
 	}
 
+	if err := s.CheckWrite(); err != nil {
+		return err
+	}
+
+	if s.upgradeFrom > 0 {
+		if err := s.backupBeforeUpgrade(); err != nil {
+			return err
+		}
+	}
+
+	s.state = state
+
 	// If we don't have any state, we actually delete the file if it exists
 	if state == nil {
 		err := os.Remove(path)
@@ -57,23 +156,92 @@ func (s *LocalState) WriteState(state *terraform.State) error {
 		return err
 	}
 
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	s.state.IncrementSerialMaybe(s.readState)
 	s.readState = s.state
 
-	if err := terraform.WriteState(s.state, f); err != nil {
-		return err
+	retries := s.WriteRetries
+	if retries == 0 {
+		retries = DefaultWriteRetries
+	}
+	interval := s.WriteRetryInterval
+	if interval == 0 {
+		interval = DefaultWriteRetryInterval
+	}
+
+	for i := 0; ; i++ {
+		err := s.writeFile(path)
+		if err == nil {
+			break
+		}
+		if i >= retries || !stateFileBusy(err) {
+			return err
+		}
+
+		log.Printf("[WARN] state file %s is in use, retrying in %s: %s", path, interval, err)
+		time.Sleep(interval)
+		interval *= 2
 	}
 
 	s.written = true
 	return nil
 }
 
+// writeFile writes the state to the file at the given path. The state is
+// encoded into a temporary file next to it, which is then renamed over the
+// state file, so that the state file is never left half written. Paths
+// that aren't regular files, such as devices, are written directly.
+func (s *LocalState) writeFile(path string) error {
+	create := s.createFile
+	if create == nil {
+		create = func(path string) (io.WriteCloser, error) {
+			return os.Create(path)
+		}
+	}
+
+	// Write the file a symlink points to instead of replacing the symlink
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	fi, err := os.Stat(path)
+	if err == nil && !fi.Mode().IsRegular() {
+		return writeStateFile(s.state, path, create)
+	}
+
+	dir, file := filepath.Split(path)
+	tmpPath := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", file, os.Getpid()))
+	defer os.Remove(tmpPath)
+	if err := writeStateFile(s.state, tmpPath, create); err != nil {
+		return err
+	}
+
+	// Keep the permissions of the file being replaced
+	if fi != nil {
+		if err := os.Chmod(tmpPath, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+// writeStateFile writes the state to the file at path created with create.
+func writeStateFile(
+	state *terraform.State, path string, create func(string) (io.WriteCloser, error)) error {
+	f, err := create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := terraform.WriteState(state, f); err != nil {
+		f.Close()
+		return err
+	}
+
+	// Errors closing the file can mean that the state wasn't written
+	return f.Close()
+}
+
 // PersistState for LocalState is a no-op since WriteState always persists.
 //
 // StatePersister impl.
@@ -101,15 +269,386 @@ func (s *LocalState) RefreshState() error {
 	}
 
 	var state *terraform.State
+	var data []byte
 	if f != nil {
 		defer f.Close()
-		state, err = terraform.ReadState(f)
+
+		// Size the buffer for the whole file up front instead of growing
+		// it, which copies it over and over for large states.
+		var buf bytes.Buffer
+		if fi, err := f.Stat(); err == nil {
+			buf.Grow(int(fi.Size()) + bytes.MinRead)
+		}
+		if _, err := buf.ReadFrom(f); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+
+		state, err = terraform.ReadState(bytes.NewReader(data))
 		if err != nil {
 			return err
 		}
 	}
 
+	// ReadState upgrades states in older formats in memory. The original
+	// is kept so that it can be backed up before the file is upgraded.
+	s.upgradeFrom = 0
+	s.upgradeData = nil
+	if state != nil {
+		var v struct {
+			Version int `json:"version"`
+		}
+		if err := json.Unmarshal(textfile.StripBOM(data), &v); err == nil && v.Version < terraform.StateVersion {
+			log.Printf(
+				"[INFO] State %s is in format version %d, it will be upgraded to %d when written",
+				path, v.Version, terraform.StateVersion)
+			s.upgradeFrom = v.Version
+			s.upgradeData = data
+		}
+	}
+
+	s.futureVersion = ""
+	if state != nil && state.FromFutureTerraform() {
+		log.Printf(
+			"[WARN] State %s was written by the newer Terraform %s, it won't be written",
+			path, state.TFVersion)
+		s.futureVersion = state.TFVersion
+	}
+
 	s.state = state
 	s.readState = state
 	return nil
 }
+
+// WriteRefusedError is the error returned by LocalState.WriteState when it
+// refuses to write over the state file, such as one written by a newer
+// version of Terraform. The file must be kept as it is, so it shouldn't be
+// replaced with the state that couldn't be written either.
+type WriteRefusedError struct {
+	// Path is the path of the state file, and Reason explains why it
+	// can't be written.
+	Path   string
+	Reason string
+}
+
+func (e *WriteRefusedError) Error() string {
+	return e.Reason
+}
+
+// CheckWrite returns the *WriteRefusedError that WriteState would return
+// for the state file as it was last read, or nil if it can be written.
+// Operations that change infrastructure should check it before they start,
+// since the changes can't be recorded once they're made.
+func (s *LocalState) CheckWrite() error {
+	if s.futureVersion != "" {
+		return &WriteRefusedError{
+			Path: s.Path,
+			Reason: fmt.Sprintf(
+				"The state %s was written by Terraform %s, which is newer than\n"+
+					"this version of Terraform (%s). To not lose anything the newer\n"+
+					"version recorded, it can't be written by this version. Please use\n"+
+					"at least Terraform %s with this state.",
+				s.Path, s.futureVersion, terraform.SemVersion, s.futureVersion),
+		}
+	}
+
+	if s.upgradeFrom > 0 && s.DisableAutoUpgrade {
+		return &WriteRefusedError{
+			Path: s.Path,
+			Reason: fmt.Sprintf(
+				"The state %s is in the older format version %d, and upgrading\n"+
+					"it to the current version %d is disabled.",
+				s.Path, s.upgradeFrom, terraform.StateVersion),
+		}
+	}
+
+	return nil
+}
+
+// backupBeforeUpgrade writes the original contents of a state file read in
+// an older format to a backup named after its format version, before the
+// file is written in the current format for the first time.
+func (s *LocalState) backupBeforeUpgrade() error {
+	path := fmt.Sprintf("%s.v%d.backup", s.Path, s.upgradeFrom)
+	if err := ioutil.WriteFile(path, s.upgradeData, 0644); err != nil {
+		return fmt.Errorf("Error backing up state before upgrading its format: %s", err)
+	}
+	log.Printf(
+		"[INFO] Upgrading state %s from format version %d to %d, the original is backed up to %s",
+		s.Path, s.upgradeFrom, terraform.StateVersion, path)
+
+	s.upgradeFrom = 0
+	s.upgradeData = nil
+	return nil
+}
+
+// Lock acquires the lock for the state by creating a lock info file next
+// to the state file, or at LockPath if it is set. If the lock info file
+// already exists, a *LockError is returned with the information it
+// contains.
+//
+// Locker impl.
+func (s *LocalState) Lock(info *LockInfo) (string, error) {
+	if s.lockID != "" {
+		return "", fmt.Errorf("state %s is already locked by this process", s.Path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return "", err
+	}
+
+	path := s.lockInfoPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	s.checkLockPath()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return "", err
+		}
+
+		current, err := s.lockInfo()
+		if err != nil {
+			return "", &LockError{Err: err}
+		}
+		if !s.lockStale(current) {
+			return "", &LockError{Info: current}
+		}
+
+		if f, err = s.takeOverLock(current); err != nil {
+			return "", err
+		}
+	}
+	info.Path = s.Path
+	err = json.NewEncoder(f).Encode(info)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("Error writing lock info %s: %s", path, err)
+	}
+	if err := s.writeLockPath(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	s.lockID = info.ID
+	return info.ID, nil
+}
+
+// Unlock releases the lock with the given ID. The ID must match the ID in
+// the lock info file.
+//
+// Locker impl.
+func (s *LocalState) Unlock(id string) error {
+	info, err := s.lockInfo()
+	if err != nil {
+		return err
+	}
+
+	if info.ID != id {
+		return fmt.Errorf(
+			"lock ID %q does not match the ID of the existing lock %q",
+			id, info.ID)
+	}
+
+	if err := os.Remove(s.lockInfoPath()); err != nil {
+		return err
+	}
+	s.removeLockPath()
+
+	s.lockID = ""
+	return nil
+}
+
+// lockStale returns true if the lock can be taken over because it's older
+// than StaleLockTimeout and its process on this host no longer runs. If the
+// pid now belongs to a process that started at another time, the process
+// that held the lock is gone as well.
+func (s *LocalState) lockStale(info *LockInfo) bool {
+	if s.StaleLockTimeout <= 0 || info.Pid <= 0 || info.Created.IsZero() {
+		return false
+	}
+	if time.Since(info.Created) < s.StaleLockTimeout {
+		return false
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || info.Hostname == "" || info.Hostname != hostname {
+		return false
+	}
+
+	if !processExists(info.Pid) {
+		return true
+	}
+	if !info.ProcessStarted.IsZero() {
+		if started, ok := processStartTime(info.Pid); ok {
+			return !started.Equal(info.ProcessStarted)
+		}
+	}
+
+	return false
+}
+
+// takeOverLock replaces the stale lock with the given info with a new
+// lock info file, which is returned for writing the new lock to. Only the
+// process that creates the takeover marker of the stale lock may replace
+// it, so that two processes can't both take it over.
+func (s *LocalState) takeOverLock(stale *LockInfo) (*os.File, error) {
+	path := s.lockInfoPath()
+	marker := fmt.Sprintf("%s.%s.takeover", path, stale.ID)
+	m, err := os.OpenFile(marker, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, &LockError{
+				Info: stale,
+				Err: fmt.Errorf(
+					"another process is taking over the stale lock. If none is,\n"+
+						"remove %s and try again", marker),
+			}
+		}
+
+		return nil, err
+	}
+	m.Close()
+	defer os.Remove(marker)
+
+	// Another process may have taken over the lock before we created the
+	// marker, in which case the lock is theirs now.
+	current, err := s.lockInfo()
+	if err != nil || current.ID != stale.ID {
+		return nil, &LockError{Info: current, Err: err}
+	}
+
+	log.Printf(
+		"[WARN] Taking over the stale lock %s on %s, held by pid %d on %s since %s",
+		stale.ID, s.Path, stale.Pid, stale.Hostname, stale.Created)
+	if err := os.Remove(path); err != nil {
+		return nil, &LockError{Info: stale, Err: err}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			current, _ := s.lockInfo()
+			return nil, &LockError{Info: current}
+		}
+
+		return nil, err
+	}
+
+	s.TookOverLock = stale
+	return f, nil
+}
+
+// lockInfoPath returns the path of the lock info file for the state.
+func (s *LocalState) lockInfoPath() string {
+	if s.LockPath != "" {
+		return s.LockPath
+	}
+
+	dir, file := filepath.Split(s.Path)
+	return filepath.Join(dir, fmt.Sprintf(".%s.lock.info", file))
+}
+
+// lockPathPath returns the path of the file next to the state file that
+// records the path of the lock info file while the state is locked.
+func (s *LocalState) lockPathPath() string {
+	dir, file := filepath.Split(s.Path)
+	return filepath.Join(dir, fmt.Sprintf(".%s.lock.path", file))
+}
+
+// absLockInfoPath returns the absolute path of the lock info file, which
+// is what the lock path file records.
+func (s *LocalState) absLockInfoPath() string {
+	path := s.lockInfoPath()
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	return path
+}
+
+// checkLockPath sets LockPathMismatch if the lock path file records that
+// another process locked the state with a different lock info file.
+func (s *LocalState) checkLockPath() {
+	s.LockPathMismatch = ""
+
+	data, err := ioutil.ReadFile(s.lockPathPath())
+	if err != nil {
+		return
+	}
+	other := strings.TrimSpace(string(data))
+	if other == "" || other == s.absLockInfoPath() {
+		return
+	}
+
+	log.Printf(
+		"[WARN] State %s is locked with the lock info file %s, not %s. "+
+			"Processes using different lock files aren't kept from changing "+
+			"the state at the same time.",
+		s.Path, other, s.absLockInfoPath())
+	s.LockPathMismatch = other
+}
+
+// writeLockPath records the path of our lock info file in the lock path
+// file.
+func (s *LocalState) writeLockPath() error {
+	path := s.lockPathPath()
+	if err := ioutil.WriteFile(path, []byte(s.absLockInfoPath()+"\n"), 0644); err != nil {
+		return fmt.Errorf("Error writing lock path %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// removeLockPath removes the lock path file, unless it was since written
+// by another process using a different lock info file.
+func (s *LocalState) removeLockPath() {
+	path := s.lockPathPath()
+	data, err := ioutil.ReadFile(path)
+	if err != nil || strings.TrimSpace(string(data)) != s.absLockInfoPath() {
+		return
+	}
+
+	os.Remove(path)
+}
+
+// lockInfo reads the lock info file for the state.
+func (s *LocalState) lockInfo() (*LockInfo, error) {
+	return readLockInfo(s.lockInfoPath())
+}
+
+// readLockInfo reads the lock info file at the given path.
+func readLockInfo(path string) (*LockInfo, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var info LockInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("Error reading lock info %s: %s", path, err)
+	}
+
+	return &info, nil
+}
+
+// stateFileErrno returns the system error number of an error from writing
+// a state file, if it has one.
+func stateFileErrno(err error) (syscall.Errno, bool) {
+	switch e := err.(type) {
+	case *os.PathError:
+		err = e.Err
+	case *os.LinkError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+
+	errno, ok := err.(syscall.Errno)
+	return errno, ok
+}
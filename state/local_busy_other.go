@@ -0,0 +1,18 @@
+// +build !windows
+
+package state
+
+import (
+	"syscall"
+)
+
+// stateFileBusyErrno is the error for a file that is temporarily in use
+// by another program and can't be written.
+const stateFileBusyErrno = syscall.EBUSY
+
+// stateFileBusy returns whether the error from writing a state file means
+// that the file is temporarily in use by another program.
+func stateFileBusy(err error) bool {
+	errno, ok := stateFileErrno(err)
+	return ok && (errno == stateFileBusyErrno || errno == syscall.ETXTBSY)
+}
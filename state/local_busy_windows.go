@@ -0,0 +1,23 @@
+// +build windows
+
+package state
+
+import (
+	"syscall"
+)
+
+// stateFileBusyErrno is the error for a file that is open in another
+// program, such as an antivirus scanner or an editor, that doesn't allow
+// it to be written.
+const stateFileBusyErrno = syscall.Errno(32) // ERROR_SHARING_VIOLATION
+
+// stateFileLockErrno is the error for a file that is partially locked by
+// another program.
+const stateFileLockErrno = syscall.Errno(33) // ERROR_LOCK_VIOLATION
+
+// stateFileBusy returns whether the error from writing a state file means
+// that the file is temporarily in use by another program.
+func stateFileBusy(err error) bool {
+	errno, ok := stateFileErrno(err)
+	return ok && (errno == stateFileBusyErrno || errno == stateFileLockErrno)
+}
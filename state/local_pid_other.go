@@ -0,0 +1,15 @@
+// +build !windows
+
+package state
+
+import (
+	"syscall"
+)
+
+// processExists returns whether a process with the given pid runs on this
+// host. Signal 0 only checks whether the process can be signalled, and a
+// process owned by another user still exists.
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
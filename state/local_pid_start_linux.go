@@ -0,0 +1,75 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is the number of clock ticks per second that the start times
+// of processes are counted in, USER_HZ, which is 100 on the architectures
+// Go supports.
+const clockTicks = 100
+
+// processStartTime returns the time the process with the given pid
+// started, and false if it isn't known. It's computed from the boot time
+// and the start time in /proc, so it's the same every time it's read.
+func processStartTime(pid int) (time.Time, bool) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// The command name is in parentheses and may contain spaces, so the
+	// fields are counted from after it. The start time is the 22nd field,
+	// and the state following the name is the 3rd.
+	idx := bytes.LastIndexByte(data, ')')
+	if idx < 0 {
+		return time.Time{}, false
+	}
+	fields := strings.Fields(string(data[idx+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, false
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	boot, ok := bootTime()
+	if !ok {
+		return time.Time{}, false
+	}
+
+	started := boot.Add(time.Duration(ticks) * time.Second / clockTicks)
+	return started.UTC(), true
+}
+
+// bootTime returns the time the system booted, from /proc/stat.
+func bootTime() (time.Time, bool) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "btime" {
+			secs, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+
+			return time.Unix(secs, 0), true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,57 @@
+// +build windows
+
+package state
+
+import (
+	"syscall"
+	"time"
+)
+
+const (
+	// processQueryLimitedInformation is the access right needed to get the
+	// exit code of a process, which is granted for more processes than
+	// PROCESS_QUERY_INFORMATION.
+	processQueryLimitedInformation = 0x1000
+
+	// errorInvalidParameter is the error opening a process that doesn't
+	// exist, and stillActive the exit code of a process that still runs.
+	errorInvalidParameter syscall.Errno = 87
+	stillActive           uint32        = 259
+)
+
+// processExists returns whether a process with the given pid runs on this
+// host. Only opening a process with an invalid pid means that it doesn't
+// exist: opening a process that runs as another user or elevated fails
+// with ERROR_ACCESS_DENIED, but the process still runs.
+func processExists(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return err != errorInvalidParameter
+	}
+	defer syscall.CloseHandle(h)
+
+	// A process that exited can still be opened while others have it open
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+
+	return code == stillActive
+}
+
+// processStartTime returns the time the process with the given pid
+// started, and false if it isn't known.
+func processStartTime(pid int) (time.Time, bool) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer syscall.CloseHandle(h)
+
+	var created, exited, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &created, &exited, &kernel, &user); err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, created.Nanoseconds()).UTC(), true
+}
@@ -1,9 +1,16 @@
 package state
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/terraform"
 )
@@ -45,6 +52,338 @@ func TestLocalState_impl(t *testing.T) {
 	var _ StateWriter = new(LocalState)
 	var _ StatePersister = new(LocalState)
 	var _ StateRefresher = new(LocalState)
+	var _ Locker = new(LocalState)
+}
+
+func TestLocalState_lock(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	info := NewLockInfo()
+	info.Operation = "test"
+	id, err := ls.Lock(info)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(ls.lockInfoPath())
+
+	// A second lock on the same path must fail with the lock info
+	other := &LocalState{Path: ls.Path}
+	_, err = other.Lock(NewLockInfo())
+	lockErr, ok := err.(*LockError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if lockErr.Info == nil || lockErr.Info.ID != id {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+	if lockErr.Info.Operation != "test" || lockErr.Info.Path != ls.Path {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+
+	if err := ls.Unlock("wrong"); err == nil {
+		t.Fatal("should error")
+	}
+	if err := ls.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Now the other state can lock
+	id, err = other.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := other.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestLocalState_lockPath(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	dir, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ls.LockPath = filepath.Join(dir, "locks", "state.lock")
+
+	id, err := ls.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ls.LockPathMismatch != "" {
+		t.Fatalf("bad: %s", ls.LockPathMismatch)
+	}
+
+	// The lock is at the lock path, not next to the state
+	if _, err := readLockInfo(ls.LockPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat((&LocalState{Path: ls.Path}).lockInfoPath()); !os.IsNotExist(err) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Another process with the same lock path can't lock it
+	other := &LocalState{Path: ls.Path, LockPath: ls.LockPath}
+	if _, err := other.Lock(NewLockInfo()); err == nil {
+		t.Fatal("should error")
+	}
+
+	// The lock path is recorded next to the state while it's locked
+	data, err := ioutil.ReadFile(ls.lockPathPath())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if strings.TrimSpace(string(data)) != ls.LockPath {
+		t.Fatalf("bad: %s", data)
+	}
+
+	if err := ls.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, path := range []string{ls.LockPath, ls.lockPathPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s: bad: %v", path, err)
+		}
+	}
+}
+
+func TestLocalState_lockPathMismatch(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	dir, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ls.LockPath = filepath.Join(dir, "state.lock")
+
+	id, err := ls.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Locking with the default lock path succeeds, but with a warning
+	other := &LocalState{Path: ls.Path}
+	otherID, err := other.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if other.LockPathMismatch != ls.LockPath {
+		t.Fatalf("bad: %q", other.LockPathMismatch)
+	}
+
+	// Unlocking doesn't remove the lock path recorded by the other
+	if err := ls.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(ls.lockPathPath()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := other.Unlock(otherID); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(ls.lockPathPath()); !os.IsNotExist(err) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// Without another lock path in use, there's no warning
+	otherID, err = other.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer other.Unlock(otherID)
+	if other.LockPathMismatch != "" {
+		t.Fatalf("bad: %q", other.LockPathMismatch)
+	}
+}
+
+// testDeadPid returns the pid of a process that no longer runs.
+func testDeadPid(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return cmd.Process.Pid
+}
+
+// testStaleLock writes a lock for the state that was acquired an hour ago
+// by the given process.
+func testStaleLock(t *testing.T, ls *LocalState, pid int, hostname string) *LockInfo {
+	info := NewLockInfo()
+	info.Pid = pid
+	info.Hostname = hostname
+	info.Created = time.Now().Add(-time.Hour)
+
+	id, err := (&LocalState{Path: ls.Path}).Lock(info)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != info.ID {
+		t.Fatalf("bad: %s", id)
+	}
+
+	return info
+}
+
+func TestLocalState_lockStale(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	deadPid := testDeadPid(t)
+
+	cases := []struct {
+		Name     string
+		Pid      int
+		Hostname string
+		Timeout  time.Duration
+		Takeover bool
+	}{
+		{"dead process", deadPid, hostname, time.Minute, true},
+		{"no takeover", deadPid, hostname, 0, false},
+		{"not old enough", deadPid, hostname, 2 * time.Hour, false},
+		{"running process", os.Getpid(), hostname, time.Minute, false},
+		{"other host", deadPid, hostname + "-other", time.Minute, false},
+		{"unknown host", deadPid, "", time.Minute, false},
+	}
+
+	for _, tc := range cases {
+		ls := testLocalState(t)
+		stale := testStaleLock(t, ls, tc.Pid, tc.Hostname)
+
+		ls.StaleLockTimeout = tc.Timeout
+		id, err := ls.Lock(NewLockInfo())
+		if !tc.Takeover {
+			lockErr, ok := err.(*LockError)
+			if !ok || lockErr.Info == nil || lockErr.Info.ID != stale.ID {
+				t.Fatalf("%s: bad: %#v", tc.Name, err)
+			}
+			if ls.TookOverLock != nil {
+				t.Fatalf("%s: bad: %#v", tc.Name, ls.TookOverLock)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.Name, err)
+			}
+			if ls.TookOverLock == nil || ls.TookOverLock.ID != stale.ID {
+				t.Fatalf("%s: bad: %#v", tc.Name, ls.TookOverLock)
+			}
+
+			// The lock is ours now
+			if err := ls.Unlock(id); err != nil {
+				t.Fatalf("%s: err: %s", tc.Name, err)
+			}
+		}
+
+		// Nothing is left behind from moving the stale lock
+		matches, err := filepath.Glob(ls.lockInfoPath() + ".*")
+		if err != nil || len(matches) != 0 {
+			t.Fatalf("%s: bad: %#v %v", tc.Name, matches, err)
+		}
+
+		os.Remove(ls.lockInfoPath())
+		os.Remove(ls.Path)
+	}
+}
+
+func TestLocalState_lockStalePidReused(t *testing.T) {
+	started, ok := processStartTime(os.Getpid())
+	if !ok {
+		t.Skip("process start times aren't known on this platform")
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The lock of a process that started at another time than the one
+	// that has its pid now is stale
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+	info := &LockInfo{
+		Pid:            os.Getpid(),
+		Hostname:       hostname,
+		ProcessStarted: started.Add(-time.Minute),
+		Created:        time.Now().Add(-time.Hour),
+	}
+	ls.StaleLockTimeout = time.Minute
+	if !ls.lockStale(info) {
+		t.Fatal("should be stale")
+	}
+
+	info.ProcessStarted = started
+	if ls.lockStale(info) {
+		t.Fatal("should not be stale")
+	}
+}
+
+func TestLocalState_lockStaleTakeover(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+	stale := testStaleLock(t, ls, testDeadPid(t), hostname)
+	defer os.Remove(ls.lockInfoPath())
+
+	// Another process is taking over the stale lock
+	marker := fmt.Sprintf("%s.%s.takeover", ls.lockInfoPath(), stale.ID)
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	ls.StaleLockTimeout = time.Minute
+	_, err = ls.Lock(NewLockInfo())
+	if _, ok := err.(*LockError); !ok || !strings.Contains(err.Error(), "taking over") {
+		t.Fatalf("bad: %#v", err)
+	}
+	os.Remove(marker)
+
+	// Once it has taken over the lock, the stale lock is gone and can't
+	// be taken over again by another process that read it before.
+	id, err := ls.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other := &LocalState{Path: ls.Path, StaleLockTimeout: time.Minute}
+	if _, err := other.takeOverLock(stale); err == nil {
+		t.Fatal("should error")
+	}
+	if err := ls.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestLocalState_deterministic(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	state := TestStateInitial()
+	var files [][]byte
+	for i := 0; i < 2; i++ {
+		if err := ls.WriteState(state.DeepCopy()); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := ls.PersistState(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadFile(ls.Path)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		files = append(files, data)
+	}
+
+	if !bytes.Equal(files[0], files[1]) {
+		t.Fatalf("writes differ:\n%s\n\n%s", files[0], files[1])
+	}
 }
 
 func testLocalState(t *testing.T) *LocalState {
@@ -66,3 +405,292 @@ func testLocalState(t *testing.T) *LocalState {
 
 	return ls
 }
+
+func TestLocalState_writeRetry(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	// The file is busy for the first two attempts
+	attempts := 0
+	ls.WriteRetryInterval = time.Millisecond
+	ls.createFile = func(path string) (io.WriteCloser, error) {
+		attempts++
+		if attempts <= 2 {
+			return nil, &os.PathError{Op: "open", Path: path, Err: stateFileBusyErrno}
+		}
+
+		return os.Create(path)
+	}
+
+	state := TestStateInitial()
+	state.Serial++
+	if err := ls.WriteState(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+
+	if err := ls.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if actual := ls.State(); actual.Serial != state.Serial {
+		t.Fatalf("bad: %s", actual)
+	}
+}
+
+func TestLocalState_writeRetryFail(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	// The file stays busy
+	attempts := 0
+	ls.WriteRetries = 2
+	ls.WriteRetryInterval = time.Millisecond
+	ls.createFile = func(path string) (io.WriteCloser, error) {
+		attempts++
+		return nil, &os.PathError{Op: "open", Path: path, Err: stateFileBusyErrno}
+	}
+
+	if err := ls.WriteState(TestStateInitial()); err == nil {
+		t.Fatal("should error")
+	}
+	if attempts != 3 {
+		t.Fatalf("bad: %d", attempts)
+	}
+
+	// Other errors aren't retried
+	attempts = 0
+	ls.createFile = func(path string) (io.WriteCloser, error) {
+		attempts++
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrPermission}
+	}
+	if err := ls.WriteState(TestStateInitial()); err == nil {
+		t.Fatal("should error")
+	}
+	if attempts != 1 {
+		t.Fatalf("bad: %d", attempts)
+	}
+}
+
+func TestLocalState_writeReplace(t *testing.T) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// The state file is written through a symlink and keeps its mode
+	path := filepath.Join(td, "terraform.tfstate")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	link := filepath.Join(td, "link.tfstate")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skipf("symlinks not supported: %s", err)
+	}
+
+	state := TestStateInitial()
+	ls := &LocalState{Path: link}
+	if err := ls.WriteState(state); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("bad: %#v %s", fi, err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("bad: %s", fi.Mode())
+	}
+
+	// The bytes are those of terraform.WriteState
+	var expected bytes.Buffer
+	if err := terraform.WriteState(state, &expected); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(actual, expected.Bytes()) {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	// No temporary files are left behind
+	list, err := ioutil.ReadDir(td)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("bad: %#v", list)
+	}
+}
+
+func TestLocalState_futureVersion(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	future := TestStateInitial()
+	future.TFVersion = "99.99.99"
+	if err := ls.WriteState(future); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	before, err := ioutil.ReadFile(ls.Path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The state can be read
+	if err := ls.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ls.State().TFVersion != "99.99.99" {
+		t.Fatalf("bad: %s", ls.State())
+	}
+
+	// It isn't overwritten
+	err = ls.WriteState(TestStateInitial())
+	if _, ok := err.(*WriteRefusedError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	for _, v := range []string{"99.99.99", terraform.SemVersion.String()} {
+		if !strings.Contains(err.Error(), v) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+
+	after, err := ioutil.ReadFile(ls.Path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatalf("state was overwritten:\n%s", after)
+	}
+}
+
+func TestLocalState_upgrade(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	original := []byte(`{"version": 2, "serial": 1, "modules": [{"path": ["root"]}]}`)
+	if err := ioutil.WriteFile(ls.Path, original, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ls.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	backupPath := ls.Path + ".v2.backup"
+	defer os.Remove(backupPath)
+	if err := ls.WriteState(ls.State()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	backup, err := ioutil.ReadFile(backupPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Fatalf("bad: %s", backup)
+	}
+
+	// The backup is only written for the first write
+	if err := os.Remove(backupPath); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ls.WriteState(ls.State()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := os.Stat(backupPath); err == nil {
+		t.Fatal("backup should not be written again")
+	}
+}
+
+// testLargeState returns a state with n resources in the root module, for
+// benchmarking states the size of big infrastructures.
+func testLargeState(n int) *terraform.State {
+	resources := make(map[string]*terraform.ResourceState, n)
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("i-%08d", i)
+		resources[fmt.Sprintf("test_instance.foo.%d", i)] = &terraform.ResourceState{
+			Type: "test_instance",
+			Primary: &terraform.InstanceState{
+				ID: id,
+				Attributes: map[string]string{
+					"id":            id,
+					"ami":           "ami-abcd1234",
+					"instance_type": "t2.micro",
+					"tags.%":        "1",
+					"tags.Name":     fmt.Sprintf("foo-%d", i),
+				},
+			},
+		}
+	}
+
+	s := &terraform.State{
+		Modules: []*terraform.ModuleState{
+			&terraform.ModuleState{
+				Path:      []string{"root"},
+				Resources: resources,
+			},
+		},
+	}
+	s.Init()
+
+	return s
+}
+
+func BenchmarkLocalState_writeState(b *testing.B) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	ls := &LocalState{Path: filepath.Join(td, "terraform.tfstate")}
+	s := testLargeState(50000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Serial++
+		if err := ls.WriteState(s); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func BenchmarkLocalState_backup(b *testing.B) {
+	td, err := ioutil.TempDir("", "tf")
+	if err != nil {
+		b.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	path := filepath.Join(td, "terraform.tfstate")
+	if err := (&LocalState{Path: path}).WriteState(testLargeState(50000)); err != nil {
+		b.Fatalf("err: %s", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Read the state and write it back, which backs it up first
+		bs := &BackupState{
+			Real: &LocalState{Path: path},
+			Path: path + ".backup",
+		}
+		if err := bs.RefreshState(); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+		s := bs.State()
+		s.Serial++
+		if err := bs.WriteState(s); err != nil {
+			b.Fatalf("err: %s", err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// Locker is the interface that is implemented by states that can be locked
+// so that only one operation can modify them at a time.
+//
+// Lock returns the ID of the lock that was acquired, which must be given to
+// Unlock to release it. If the state is already locked, Lock returns a
+// *LockError with the information of the current lock holder.
+type Locker interface {
+	Lock(info *LockInfo) (string, error)
+	Unlock(id string) error
+}
+
+// LockInfo stores metadata about a lock, so that the holder of a lock can
+// be identified when acquiring the lock fails.
+type LockInfo struct {
+	// ID is the unique ID of the lock.
+	ID string
+
+	// Operation is the operation the lock was acquired for, such as the
+	// name of the command that is running.
+	Operation string
+
+	// OperationID is the unique ID of the operation holding the lock. This
+	// is different from the lock ID since it can be given by whoever runs
+	// the operation, to correlate the lock with the operation's logs.
+	OperationID string
+
+	// Source describes who or what started the operation holding the
+	// lock, such as "CI job 1234", if that is known.
+	Source string
+
+	// Annotations are extra key/value information about the operation
+	// holding the lock, such as the user that triggered it.
+	Annotations map[string]string
+
+	// Info is extra information about the lock.
+	Info string
+
+	// Pid and Hostname identify the process holding the lock.
+	Pid      int
+	Hostname string
+
+	// ProcessStarted is the time the process holding the lock started, if
+	// it's known, so that another process that reused its pid can be told
+	// apart from it.
+	ProcessStarted time.Time
+
+	// Version is the version of Terraform that acquired the lock.
+	Version string
+
+	// Created is the time the lock was acquired.
+	Created time.Time
+
+	// Path is the path of the state that is locked. This is set by
+	// the Locker.
+	Path string
+}
+
+// NewLockInfo returns a LockInfo with a new ID and the information about
+// the current process filled in.
+func NewLockInfo() *LockInfo {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		// This only happens if reading from the random source fails,
+		// in which case nothing else will work either.
+		panic(err)
+	}
+
+	hostname, _ := os.Hostname()
+	started, _ := processStartTime(os.Getpid())
+	return &LockInfo{
+		ID:             id,
+		Pid:            os.Getpid(),
+		Hostname:       hostname,
+		ProcessStarted: started,
+		Version:        terraform.VersionString(),
+		Created:        time.Now().UTC(),
+	}
+}
+
+// String returns a human-friendly description of the lock.
+func (i *LockInfo) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "  ID:        %s\n", i.ID)
+	fmt.Fprintf(&buf, "  Path:      %s\n", i.Path)
+	fmt.Fprintf(&buf, "  Operation: %s\n", i.Operation)
+	if i.OperationID != "" {
+		fmt.Fprintf(&buf, "  Op ID:     %s\n", i.OperationID)
+	}
+	if i.Source != "" {
+		fmt.Fprintf(&buf, "  Source:    %s\n", i.Source)
+	}
+	fmt.Fprintf(&buf, "  Who:       pid %d on %s\n", i.Pid, i.Hostname)
+	fmt.Fprintf(&buf, "  Version:   %s\n", i.Version)
+	fmt.Fprintf(&buf, "  Created:   %s\n", i.Created)
+	if i.Info != "" {
+		fmt.Fprintf(&buf, "  Info:      %s\n", i.Info)
+	}
+	if len(i.Annotations) > 0 {
+		keys := make([]string, 0, len(i.Annotations))
+		for k := range i.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteString("  Annotations:\n")
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "    %s = %s\n", k, i.Annotations[k])
+		}
+	}
+
+	return buf.String()
+}
+
+// LockError is the error returned by a Locker when the state is already
+// locked.
+type LockError struct {
+	// Info is the information of the current lock holder, if known.
+	Info *LockInfo
+
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *LockError) Error() string {
+	msg := "state is locked"
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+	if e.Info != nil {
+		msg = fmt.Sprintf("%s\n\nLock Info:\n%s", msg, e.Info)
+	}
+
+	return msg
+}
+
+// LockWithTimeout acquires a lock with the given Locker. If the state is
+// locked by someone else, acquiring the lock is retried with backoff until
+// the timeout has passed, after which the last *LockError is returned. A
+// timeout of zero tries to acquire the lock only once.
+//
+// While waiting, the waiting function, if given, is called every few
+// seconds with the information of the current lock holder.
+func LockWithTimeout(
+	l Locker,
+	info *LockInfo,
+	timeout time.Duration,
+	waiting func(*LockInfo)) (string, error) {
+	const maxDelay = time.Second
+	const notifyInterval = 3 * time.Second
+
+	deadline := time.Now().Add(timeout)
+	delay := 50 * time.Millisecond
+	var lastNotify time.Time
+	for {
+		id, err := l.Lock(info)
+		if err == nil {
+			return id, nil
+		}
+
+		lockErr, ok := err.(*LockError)
+		if !ok {
+			return "", err
+		}
+
+		now := time.Now()
+		if !now.Before(deadline) {
+			return "", err
+		}
+
+		if waiting != nil && now.Sub(lastNotify) >= notifyInterval {
+			waiting(lockErr.Info)
+			lastNotify = now
+		}
+
+		// Never sleep past the deadline so we try once more right at it
+		sleep := delay
+		if remaining := deadline.Sub(now); sleep > remaining {
+			sleep = remaining
+		}
+		time.Sleep(sleep)
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
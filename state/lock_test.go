@@ -0,0 +1,112 @@
+package state
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockWithTimeout(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	id, err := ls.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(ls.lockInfoPath())
+
+	// Release the lock while the other state is waiting for it
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		if err := ls.Unlock(id); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
+
+	waited := false
+	other := &LocalState{Path: ls.Path}
+	otherId, err := LockWithTimeout(
+		other, NewLockInfo(), 5*time.Second, func(*LockInfo) { waited = true })
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !waited {
+		t.Fatal("should call waiting function")
+	}
+	if err := other.Unlock(otherId); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestLockWithTimeout_timeout(t *testing.T) {
+	ls := testLocalState(t)
+	defer os.Remove(ls.Path)
+
+	info := NewLockInfo()
+	id, err := ls.Lock(info)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer ls.Unlock(id)
+
+	start := time.Now()
+	other := &LocalState{Path: ls.Path}
+	_, err = LockWithTimeout(other, NewLockInfo(), 200*time.Millisecond, nil)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Fatal("should wait for the timeout")
+	}
+
+	lockErr, ok := err.(*LockError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if lockErr.Info == nil || lockErr.Info.ID != id {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+
+	msg := err.Error()
+	expected := []string{
+		info.ID,
+		info.Hostname,
+		info.Created.String(),
+		"pid " + strconv.Itoa(info.Pid),
+	}
+	for _, v := range expected {
+		if !strings.Contains(msg, v) {
+			t.Fatalf("bad: expected %q in:\n\n%s", v, msg)
+		}
+	}
+}
+
+func TestLockInfoString(t *testing.T) {
+	info := NewLockInfo()
+	info.Operation = "apply"
+	if strings.Contains(info.String(), "Op ID") {
+		t.Fatalf("bad: %s", info)
+	}
+
+	info.OperationID = "foo-123"
+	if !strings.Contains(info.String(), "Op ID:     foo-123") {
+		t.Fatalf("bad: %s", info)
+	}
+	if strings.Contains(info.String(), "Source") || strings.Contains(info.String(), "Annotations") {
+		t.Fatalf("bad: %s", info)
+	}
+
+	info.Source = "CI job 1234"
+	info.Annotations = map[string]string{"user": "alice", "job": "1234"}
+	expected := "  Source:    CI job 1234\n"
+	if !strings.Contains(info.String(), expected) {
+		t.Fatalf("bad: %s", info)
+	}
+	expected = "  Annotations:\n    job = 1234\n    user = alice\n"
+	if !strings.HasSuffix(info.String(), expected) {
+		t.Fatalf("bad: %s", info)
+	}
+}
@@ -2,15 +2,59 @@ package remote
 
 import (
 	"crypto/md5"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/terraform/state"
+)
+
+// inmemClients are the clients of the "inmem" remote state by name, so
+// that every client created with the same name in this process shares the
+// same state.
+var (
+	inmemClientsLock sync.Mutex
+	inmemClients     = make(map[string]*InmemClient)
 )
 
+// inmemFactory returns the client for the state with the configured name,
+// which defaults to "default". The state only exists in the memory of this
+// process, which is useful for tests and for runs where the state should
+// never be written anywhere.
+func inmemFactory(conf map[string]string) (Client, error) {
+	name := conf["name"]
+	if name == "" {
+		name = "default"
+	}
+
+	inmemClientsLock.Lock()
+	defer inmemClientsLock.Unlock()
+
+	client, ok := inmemClients[name]
+	if !ok {
+		client = new(InmemClient)
+		inmemClients[name] = client
+	}
+
+	return client, nil
+}
+
 // InmemClient is a Client implementation that stores data in memory.
 type InmemClient struct {
 	Data []byte
 	MD5  []byte
+
+	mu       sync.Mutex
+	lockInfo *state.LockInfo
 }
 
 func (c *InmemClient) Get() (*Payload, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Data == nil {
+		return nil, nil
+	}
+
 	return &Payload{
 		Data: c.Data,
 		MD5:  c.MD5,
@@ -18,6 +62,9 @@ func (c *InmemClient) Get() (*Payload, error) {
 }
 
 func (c *InmemClient) Put(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	md5 := md5.Sum(data)
 
 	c.Data = data
@@ -26,7 +73,42 @@ func (c *InmemClient) Put(data []byte) error {
 }
 
 func (c *InmemClient) Delete() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.Data = nil
 	c.MD5 = nil
 	return nil
 }
+
+// state.Locker impl.
+func (c *InmemClient) Lock(info *state.LockInfo) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lockInfo != nil {
+		return "", &state.LockError{Info: c.lockInfo}
+	}
+
+	info.Path = "(in memory)"
+	c.lockInfo = info
+	return info.ID, nil
+}
+
+// state.Locker impl.
+func (c *InmemClient) Unlock(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lockInfo == nil {
+		return fmt.Errorf("state is not locked")
+	}
+	if c.lockInfo.ID != id {
+		return fmt.Errorf(
+			"lock ID %q does not match the ID of the existing lock %q",
+			id, c.lockInfo.ID)
+	}
+
+	c.lockInfo = nil
+	return nil
+}
@@ -0,0 +1,64 @@
+package remote
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/state"
+)
+
+func TestInmemClient_impl(t *testing.T) {
+	var _ Client = new(InmemClient)
+	var _ state.Locker = new(InmemClient)
+}
+
+func TestInmemClient(t *testing.T) {
+	client, err := inmemFactory(map[string]string{"name": "client"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	testClient(t, client)
+}
+
+func TestInmemClient_shared(t *testing.T) {
+	a, err := inmemFactory(map[string]string{"name": "shared"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	b, err := inmemFactory(map[string]string{"name": "shared"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	other, err := inmemFactory(map[string]string{"name": "shared-other"})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := a.Put([]byte("data")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Clients with the same name share the state
+	p, err := b.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p == nil || string(p.Data) != "data" {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	// Clients with other names don't
+	p, err = other.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p != nil {
+		t.Fatalf("bad: %#v", p)
+	}
+}
+
+func TestInmemClient_lock(t *testing.T) {
+	a, _ := inmemFactory(map[string]string{"name": "lock"})
+	b, _ := inmemFactory(map[string]string{"name": "lock"})
+	state.TestLocker(t, a.(state.Locker), b.(state.Locker))
+}
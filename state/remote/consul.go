@@ -1,11 +1,18 @@
 package remote
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"strconv"
 	"strings"
+	"sync"
 
 	consulapi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/terraform/state"
 )
 
 func consulFactory(conf map[string]string) (Client, error) {
@@ -42,6 +49,15 @@ func consulFactory(conf map[string]string) (Client, error) {
 		}
 	}
 
+	var compress bool
+	if raw, ok := conf["gzip"]; ok && raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("'gzip' must be a boolean: %s", err)
+		}
+		compress = v
+	}
+
 	client, err := consulapi.NewClient(config)
 	if err != nil {
 		return nil, err
@@ -50,38 +66,126 @@ func consulFactory(conf map[string]string) (Client, error) {
 	return &ConsulClient{
 		Client: client,
 		Path:   path,
+		GZip:   compress,
 	}, nil
 }
 
 // ConsulClient is a remote client that stores data in Consul.
+//
+// Once the state has been read, it is only written if it wasn't modified
+// in Consul since, using a check-and-set. The state can be locked with a
+// Consul session.
 type ConsulClient struct {
 	Client *consulapi.Client
 	Path   string
+
+	// GZip compresses the state written to Consul. Compressed states are
+	// always read, whether this is set or not.
+	GZip bool
+
+	mu sync.Mutex
+
+	// modifyIndex is the index of the state in Consul when it was last
+	// read or written. It is only used for writing the state once haveIndex
+	// is set.
+	modifyIndex uint64
+	haveIndex   bool
 }
 
 func (c *ConsulClient) Get() (*Payload, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	pair, _, err := c.Client.KV().Get(c.Path, nil)
 	if err != nil {
 		return nil, err
 	}
+
+	c.haveIndex = true
 	if pair == nil {
+		c.modifyIndex = 0
 		return nil, nil
 	}
+	c.modifyIndex = pair.ModifyIndex
+
+	data, err := consulDecompress(pair.Value)
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing state from Consul: %s", err)
+	}
 
-	md5 := md5.Sum(pair.Value)
+	md5 := md5.Sum(data)
 	return &Payload{
-		Data: pair.Value,
+		Data: data,
 		MD5:  md5[:],
 	}, nil
 }
 
+// CheckAndSet returns true, since Put only writes the state if its index
+// in Consul didn't change since it was read.
+//
+// CheckAndSetClient impl.
+func (c *ConsulClient) CheckAndSet() bool {
+	return true
+}
+
 func (c *ConsulClient) Put(data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.GZip {
+		var err error
+		data, err = consulCompress(data)
+		if err != nil {
+			return err
+		}
+	}
+
 	kv := c.Client.KV()
-	_, err := kv.Put(&consulapi.KVPair{
+	pair := &consulapi.KVPair{
 		Key:   c.Path,
 		Value: data,
-	}, nil)
-	return err
+	}
+
+	// If the state was never read there is nothing to check against.
+	if !c.haveIndex {
+		if _, err := kv.Put(pair, nil); err != nil {
+			return err
+		}
+
+		return c.updateIndex(data)
+	}
+
+	pair.ModifyIndex = c.modifyIndex
+	ok, _, err := kv.CAS(pair, nil)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf(
+			"The state at %q in Consul was modified since it was read, so it\n"+
+				"wasn't written in order not to lose those changes. Refresh the\n"+
+				"state and try again.", c.Path)
+	}
+
+	return c.updateIndex(data)
+}
+
+// updateIndex reads the index of the state that was just written with the
+// given data so the next write can check against it. If someone else
+// wrote the state in the meantime, the old index is kept so that the next
+// write fails.
+func (c *ConsulClient) updateIndex(data []byte) error {
+	pair, _, err := c.Client.KV().Get(c.Path, nil)
+	if err != nil {
+		return err
+	}
+
+	if pair != nil && bytes.Equal(pair.Value, data) {
+		c.modifyIndex = pair.ModifyIndex
+		c.haveIndex = true
+	}
+
+	return nil
 }
 
 func (c *ConsulClient) Delete() error {
@@ -89,3 +193,129 @@ func (c *ConsulClient) Delete() error {
 	_, err := kv.Delete(c.Path, nil)
 	return err
 }
+
+// Lock locks the state by acquiring the lock key next to the state with a
+// new Consul session. The lock info is stored as the value of the key.
+//
+// state.Locker impl.
+func (c *ConsulClient) Lock(info *state.LockInfo) (string, error) {
+	info.Path = c.Path
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	// The lock is released if the session is invalidated, such as when
+	// the Consul agent we're talking to goes away.
+	session, _, err := c.Client.Session().Create(&consulapi.SessionEntry{
+		Name:     fmt.Sprintf("terraform-lock:%s", c.Path),
+		Behavior: consulapi.SessionBehaviorDelete,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("Error creating Consul session: %s", err)
+	}
+
+	ok, _, err := c.Client.KV().Acquire(&consulapi.KVPair{
+		Key:     c.lockPath(),
+		Value:   data,
+		Session: session,
+	}, nil)
+	if err != nil || !ok {
+		c.Client.Session().Destroy(session, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error acquiring lock in Consul: %s", err)
+	}
+	if !ok {
+		current, _, err := c.lockInfo()
+		return "", &state.LockError{Info: current, Err: err}
+	}
+
+	return info.ID, nil
+}
+
+// Unlock unlocks the state by destroying the session that holds the lock,
+// which also removes the lock key. This works from any process, so that a
+// stuck lock can be removed with force-unlock.
+//
+// state.Locker impl.
+func (c *ConsulClient) Unlock(id string) error {
+	info, session, err := c.lockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("the state at %q in Consul is not locked", c.Path)
+	}
+
+	if info.ID != id {
+		return fmt.Errorf(
+			"lock ID %q does not match the ID of the existing lock %q",
+			id, info.ID)
+	}
+
+	if session != "" {
+		if _, err := c.Client.Session().Destroy(session, nil); err != nil {
+			return fmt.Errorf("Error destroying Consul session: %s", err)
+		}
+	}
+
+	// Make sure the key is gone even if the session was already invalid
+	_, err = c.Client.KV().Delete(c.lockPath(), nil)
+	return err
+}
+
+// lockPath returns the key of the lock of the state.
+func (c *ConsulClient) lockPath() string {
+	return strings.TrimRight(c.Path, "/") + "/.lock"
+}
+
+// lockInfo reads the current lock info and the session holding the lock.
+// If the state isn't locked, the info is nil.
+func (c *ConsulClient) lockInfo() (*state.LockInfo, string, error) {
+	pair, _, err := c.Client.KV().Get(c.lockPath(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if pair == nil {
+		return nil, "", nil
+	}
+
+	var info state.LockInfo
+	if err := json.Unmarshal(pair.Value, &info); err != nil {
+		return nil, "", fmt.Errorf("Error reading lock info from Consul: %s", err)
+	}
+
+	return &info, pair.Session, nil
+}
+
+// consulCompress compresses the given data with gzip.
+func consulCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// consulDecompress decompresses the given data if it is compressed with
+// gzip, and returns it as-is otherwise. A JSON state can never start with
+// the gzip magic number, so there is no ambiguity.
+func consulDecompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return ioutil.ReadAll(r)
+}
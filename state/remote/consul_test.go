@@ -1,15 +1,27 @@
 package remote
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/terraform/helper/acctest"
+	"github.com/hashicorp/terraform/state"
 )
 
 func TestConsulClient_impl(t *testing.T) {
 	var _ Client = new(ConsulClient)
+	var _ state.Locker = new(ConsulClient)
+	var _ CheckAndSetClient = new(ConsulClient)
 }
 
 func TestConsulClient(t *testing.T) {
@@ -25,3 +37,320 @@ func TestConsulClient(t *testing.T) {
 
 	testClient(t, client)
 }
+
+func TestConsulClient_gzip(t *testing.T) {
+	srv := newTestConsulServer()
+	defer srv.Close()
+
+	client := testConsulClient(t, srv, map[string]string{"gzip": "true"})
+	testClient(t, client)
+
+	// The state must be stored compressed
+	data := []byte(`{"version": 3}`)
+	if err := client.Put(data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	stored := srv.Value("tf/state")
+	if bytes.Equal(stored, data) {
+		t.Fatal("state should be compressed")
+	}
+	if actual, err := consulDecompress(stored); err != nil || !bytes.Equal(actual, data) {
+		t.Fatalf("bad: %q %s", actual, err)
+	}
+
+	// A client without gzip can still read it
+	plain := testConsulClient(t, srv, nil)
+	p, err := plain.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(p.Data, data) {
+		t.Fatalf("bad: %q", p.Data)
+	}
+}
+
+func TestConsulClient_gzipInvalid(t *testing.T) {
+	_, err := consulFactory(map[string]string{
+		"path": "tf/state",
+		"gzip": "maybe",
+	})
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestConsulClient_cas(t *testing.T) {
+	srv := newTestConsulServer()
+	defer srv.Close()
+
+	a := testConsulClient(t, srv, nil)
+	b := testConsulClient(t, srv, nil)
+
+	// Both read the same state
+	if _, err := a.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := b.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The first write wins, and can be followed by more writes
+	if err := a.Put([]byte("a1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := a.Put([]byte("a2")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The second one would overwrite the changes it doesn't know about
+	err := b.Put([]byte("b1"))
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "modified since it was read") {
+		t.Fatalf("bad: %s", err)
+	}
+	if actual := srv.Value("tf/state"); string(actual) != "a2" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// After reading the state again, it can be written
+	if _, err := b.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.Put([]byte("b1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestConsulClient_casState(t *testing.T) {
+	srv := newTestConsulServer()
+	defer srv.Close()
+
+	initial := &State{Client: testConsulClient(t, srv, nil)}
+	if err := initial.WriteState(state.TestStateInitial()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := initial.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ours := &State{Client: testConsulClient(t, srv, nil)}
+	if err := ours.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Someone writes the state without changing its serial
+	theirs := testConsulClient(t, srv, nil)
+	if _, err := theirs.Get(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := theirs.Put(srv.Value("tf/state")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Checking the remote state before writing it must not make the
+	// write succeed anyway.
+	err := ours.PersistState()
+	if err == nil || !strings.Contains(err.Error(), "modified since it was read") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestConsulClient_lock(t *testing.T) {
+	srv := newTestConsulServer()
+	defer srv.Close()
+
+	a := testConsulClient(t, srv, nil)
+	b := testConsulClient(t, srv, nil)
+
+	info := state.NewLockInfo()
+	info.Operation = "test"
+	id, err := a.Lock(info)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != info.ID {
+		t.Fatalf("bad: %s", id)
+	}
+
+	_, err = b.Lock(state.NewLockInfo())
+	lockErr, ok := err.(*state.LockError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if lockErr.Info == nil || lockErr.Info.ID != id || lockErr.Info.Path != "tf/state" {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+
+	if err := b.Unlock("wrong"); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Any client can unlock with the right ID
+	if err := b.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if srv.Value("tf/state/.lock") != nil {
+		t.Fatal("lock should be removed")
+	}
+
+	id, err = b.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := b.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// Tests locking against a real Consul server
+func TestConsulClient_lockServer(t *testing.T) {
+	addr := os.Getenv("CONSUL_HTTP_ADDR")
+	if addr == "" {
+		t.Skip("CONSUL_HTTP_ADDR must be set to test against Consul")
+	}
+
+	client, err := consulFactory(map[string]string{
+		"address": addr,
+		"path":    fmt.Sprintf("tf-unit/%d", time.Now().UnixNano()),
+		"gzip":    "true",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	testClient(t, client)
+
+	locker := client.(state.Locker)
+	id, err := locker.Lock(state.NewLockInfo())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := locker.Lock(state.NewLockInfo()); err == nil {
+		t.Fatal("should be locked")
+	}
+	if err := locker.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func testConsulClient(t *testing.T, srv *testConsulServer, conf map[string]string) *ConsulClient {
+	config := map[string]string{
+		"address": strings.TrimPrefix(srv.URL, "http://"),
+		"path":    "tf/state",
+	}
+	for k, v := range conf {
+		config[k] = v
+	}
+
+	client, err := consulFactory(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return client.(*ConsulClient)
+}
+
+// testConsulServer is a fake of the parts of the Consul HTTP API used by
+// ConsulClient: the KV store with check-and-set and sessions.
+type testConsulServer struct {
+	*httptest.Server
+
+	sync.Mutex
+	index uint64
+	kv    map[string]*consulapi.KVPair
+}
+
+func newTestConsulServer() *testConsulServer {
+	s := &testConsulServer{kv: make(map[string]*consulapi.KVPair)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Value returns the value stored for the given key, or nil.
+func (s *testConsulServer) Value(key string) []byte {
+	s.Lock()
+	defer s.Unlock()
+
+	if pair, ok := s.kv[key]; ok {
+		return pair.Value
+	}
+
+	return nil
+}
+
+func (s *testConsulServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/session/create":
+		s.index++
+		json.NewEncoder(w).Encode(map[string]string{
+			"ID": fmt.Sprintf("session-%d", s.index),
+		})
+
+	case strings.HasPrefix(r.URL.Path, "/v1/session/destroy/"):
+		// Sessions are created with the delete behavior
+		session := strings.TrimPrefix(r.URL.Path, "/v1/session/destroy/")
+		for k, pair := range s.kv {
+			if pair.Session == session {
+				delete(s.kv, k)
+			}
+		}
+		w.Write([]byte("true"))
+
+	case strings.HasPrefix(r.URL.Path, "/v1/kv/"):
+		s.handleKV(w, r, strings.TrimPrefix(r.URL.Path, "/v1/kv/"))
+
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func (s *testConsulServer) handleKV(w http.ResponseWriter, r *http.Request, key string) {
+	pair, exists := s.kv[key]
+	query := r.URL.Query()
+
+	switch r.Method {
+	case "GET":
+		if !exists {
+			w.WriteHeader(404)
+			return
+		}
+
+		json.NewEncoder(w).Encode([]*consulapi.KVPair{pair})
+
+	case "PUT":
+		if cas := query.Get("cas"); cas != "" {
+			var current uint64
+			if exists {
+				current = pair.ModifyIndex
+			}
+			if cas != fmt.Sprintf("%d", current) {
+				w.Write([]byte("false"))
+				return
+			}
+		}
+
+		session := query.Get("acquire")
+		if session != "" && exists && pair.Session != "" {
+			w.Write([]byte("false"))
+			return
+		}
+
+		value, _ := ioutil.ReadAll(r.Body)
+		s.index++
+		s.kv[key] = &consulapi.KVPair{
+			Key:         key,
+			Value:       value,
+			ModifyIndex: s.index,
+			Session:     session,
+		}
+		w.Write([]byte("true"))
+
+	case "DELETE":
+		delete(s.kv, key)
+		w.Write([]byte("true"))
+	}
+}
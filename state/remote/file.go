@@ -25,6 +25,11 @@ type FileClient struct {
 	Path string
 }
 
+// Describe returns the path of the state file.
+func (c *FileClient) Describe() string {
+	return c.Path
+}
+
 func (c *FileClient) Get() (*Payload, error) {
 	var buf bytes.Buffer
 	f, err := os.Open(c.Path)
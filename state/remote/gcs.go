@@ -118,6 +118,11 @@ func gcsFactory(conf map[string]string) (Client, error) {
 
 }
 
+// Describe returns the Cloud Storage URL of the state.
+func (c *GCSClient) Describe() string {
+	return fmt.Sprintf("gs://%s/%s", c.bucket, c.path)
+}
+
 func (c *GCSClient) Get() (*Payload, error) {
 	// Read the object from bucket.
 	log.Printf("[INFO] Reading %s/%s", c.bucket, c.path)
@@ -5,11 +5,17 @@ import (
 	"crypto/md5"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform/state"
 )
 
 func httpFactory(conf map[string]string) (Client, error) {
@@ -18,12 +24,57 @@ func httpFactory(conf map[string]string) (Client, error) {
 		return nil, fmt.Errorf("missing 'address' configuration")
 	}
 
-	url, err := url.Parse(address)
+	stateURL, err := httpParseURL(address)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse HTTP URL: %s", err)
+		return nil, err
 	}
-	if url.Scheme != "http" && url.Scheme != "https" {
-		return nil, fmt.Errorf("address must be HTTP or HTTPS")
+
+	var lockURL, unlockURL *url.URL
+	if addr, ok := conf["lock_address"]; ok && addr != "" {
+		if lockURL, err = httpParseURL(addr); err != nil {
+			return nil, fmt.Errorf("lock_address: %s", err)
+		}
+
+		// Unlocking happens at the same address unless told otherwise
+		unlockURL = lockURL
+	}
+	if addr, ok := conf["unlock_address"]; ok && addr != "" {
+		if unlockURL, err = httpParseURL(addr); err != nil {
+			return nil, fmt.Errorf("unlock_address: %s", err)
+		}
+	}
+
+	lockMethod := "LOCK"
+	if v, ok := conf["lock_method"]; ok && v != "" {
+		lockMethod = v
+	}
+	unlockMethod := "UNLOCK"
+	if v, ok := conf["unlock_method"]; ok && v != "" {
+		unlockMethod = v
+	}
+
+	retryMax := 2
+	if raw, ok := conf["retry_max"]; ok && raw != "" {
+		retryMax, err = strconv.Atoi(raw)
+		if err != nil || retryMax < 0 {
+			return nil, fmt.Errorf("retry_max must be a number that is 0 or more")
+		}
+	}
+	retryWaitMin := time.Second
+	if raw, ok := conf["retry_wait_min"]; ok && raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("retry_wait_min must be a number of seconds")
+		}
+		retryWaitMin = time.Duration(v) * time.Second
+	}
+	retryWaitMax := 30 * time.Second
+	if raw, ok := conf["retry_wait_max"]; ok && raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("retry_wait_max must be a number of seconds")
+		}
+		retryWaitMax = time.Duration(v) * time.Second
 	}
 
 	client := &http.Client{}
@@ -45,19 +96,77 @@ func httpFactory(conf map[string]string) (Client, error) {
 	}
 
 	return &HTTPClient{
-		URL:    url,
-		Client: client,
+		URL:          stateURL,
+		Client:       client,
+		LockURL:      lockURL,
+		LockMethod:   lockMethod,
+		UnlockURL:    unlockURL,
+		UnlockMethod: unlockMethod,
+		Username:     conf["username"],
+		Password:     conf["password"],
+		RetryMax:     retryMax,
+		RetryWaitMin: retryWaitMin,
+		RetryWaitMax: retryWaitMax,
 	}, nil
 }
 
+// httpParseURL parses an address for the HTTP client, which must be HTTP
+// or HTTPS.
+func httpParseURL(address string) (*url.URL, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTTP URL: %s", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("address must be HTTP or HTTPS")
+	}
+
+	return u, nil
+}
+
 // HTTPClient is a remote client that stores data in Consul or HTTP REST.
+//
+// The state is read with GET, written with POST and purged with DELETE.
+// If LockURL is set, the state is locked by sending the lock info as JSON
+// with LockMethod to LockURL, and unlocked the same way with UnlockMethod
+// and UnlockURL. A server that can't lock the state because it is already
+// locked responds with 423 Locked or 409 Conflict, optionally with the
+// info of the current lock as the body.
 type HTTPClient struct {
 	URL    *url.URL
 	Client *http.Client
+
+	LockURL      *url.URL
+	LockMethod   string
+	UnlockURL    *url.URL
+	UnlockMethod string
+
+	// Username and Password are sent with basic auth if Username is set.
+	Username string
+	Password string
+
+	// RetryMax is the number of times a request is retried when it fails
+	// with a server error, waiting from RetryWaitMin up to RetryWaitMax
+	// between attempts.
+	RetryMax     int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+
+	// lockInfo is the info of the lock held by this client, if any
+	lockInfo *state.LockInfo
+}
+
+// Describe returns the URL of the state, without any user credentials or
+// query in it, since the query may hold a token.
+func (c *HTTPClient) Describe() string {
+	u := *c.URL
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
 }
 
 func (c *HTTPClient) Get() (*Payload, error) {
-	resp, err := c.Client.Get(c.URL.String())
+	resp, err := c.do("GET", c.URL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -97,7 +206,9 @@ func (c *HTTPClient) Get() (*Payload, error) {
 		return nil, nil
 	}
 
-	// Check for the MD5
+	// Generate the MD5, and check it against the one from the server
+	hash := md5.Sum(payload.Data)
+	payload.MD5 = hash[:]
 	if raw := resp.Header.Get("Content-MD5"); raw != "" {
 		md5, err := base64.StdEncoding.DecodeString(raw)
 		if err != nil {
@@ -105,47 +216,39 @@ func (c *HTTPClient) Get() (*Payload, error) {
 				"Failed to decode Content-MD5 '%s': %s", raw, err)
 		}
 
-		payload.MD5 = md5
-	} else {
-		// Generate the MD5
-		hash := md5.Sum(payload.Data)
-		payload.MD5 = hash[:]
+		if !bytes.Equal(md5, payload.MD5) {
+			return nil, fmt.Errorf(
+				"The remote state doesn't match the Content-MD5 sent by the server.\n"+
+					"It may have been corrupted on the way. Expected %x, got %x.",
+				md5, payload.MD5)
+		}
 	}
 
 	return payload, nil
 }
 
 func (c *HTTPClient) Put(data []byte) error {
-	// Copy the target URL
-	base := *c.URL
-
-	// Generate the MD5
-	hash := md5.Sum(data)
-	b64 := base64.StdEncoding.EncodeToString(hash[:])
-
-	/*
-		// Set the force query parameter if needed
-		if force {
-			values := base.Query()
-			values.Set("force", "true")
-			base.RawQuery = values.Encode()
-		}
-	*/
-
-	req, err := http.NewRequest("POST", base.String(), bytes.NewReader(data))
+	// Make the request
+	resp, err := c.do("POST", c.URL, data)
 	if err != nil {
-		return fmt.Errorf("Failed to make HTTP request: %s", err)
+		return fmt.Errorf("Failed to upload state: %v", err)
 	}
+	defer resp.Body.Close()
 
-	// Prepare the request
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-MD5", b64)
-	req.ContentLength = int64(len(data))
+	// Handle the error codes
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	default:
+		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+	}
+}
 
+func (c *HTTPClient) Delete() error {
 	// Make the request
-	resp, err := c.Client.Do(req)
+	resp, err := c.do("DELETE", c.URL, nil)
 	if err != nil {
-		return fmt.Errorf("Failed to upload state: %v", err)
+		return fmt.Errorf("Failed to delete state: %s", err)
 	}
 	defer resp.Body.Close()
 
@@ -158,24 +261,127 @@ func (c *HTTPClient) Put(data []byte) error {
 	}
 }
 
-func (c *HTTPClient) Delete() error {
-	req, err := http.NewRequest("DELETE", c.URL.String(), nil)
+// Lock locks the state at LockURL. If LockURL isn't set, the state isn't
+// locked.
+//
+// state.Locker impl.
+func (c *HTTPClient) Lock(info *state.LockInfo) (string, error) {
+	if c.LockURL == nil {
+		return "", nil
+	}
+
+	info.Path = c.URL.String()
+	data, err := json.Marshal(info)
 	if err != nil {
-		return fmt.Errorf("Failed to make HTTP request: %s", err)
+		return "", err
 	}
 
-	// Make the request
-	resp, err := c.Client.Do(req)
+	resp, err := c.do(c.LockMethod, c.LockURL, data)
 	if err != nil {
-		return fmt.Errorf("Failed to delete state: %s", err)
+		return "", fmt.Errorf("Failed to lock state: %s", err)
 	}
 	defer resp.Body.Close()
 
-	// Handle the error codes
 	switch resp.StatusCode {
 	case http.StatusOK:
+		c.lockInfo = info
+		return info.ID, nil
+	case http.StatusLocked, http.StatusConflict:
+		// The server may tell us who holds the lock
+		var current *state.LockInfo
+		body, err := ioutil.ReadAll(resp.Body)
+		if err == nil && len(body) > 0 {
+			current = new(state.LockInfo)
+			if err := json.Unmarshal(body, current); err != nil {
+				current = nil
+			}
+		}
+
+		return "", &state.LockError{Info: current}
+	default:
+		return "", fmt.Errorf("Failed to lock state, HTTP error: %d", resp.StatusCode)
+	}
+}
+
+// Unlock unlocks the state at UnlockURL. The server is sent the info of
+// the lock, or only its ID if the lock isn't held by this client.
+//
+// state.Locker impl.
+func (c *HTTPClient) Unlock(id string) error {
+	if c.UnlockURL == nil {
+		return nil
+	}
+
+	info := &state.LockInfo{ID: id}
+	if c.lockInfo != nil && c.lockInfo.ID == id {
+		info = c.lockInfo
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(c.UnlockMethod, c.UnlockURL, data)
+	if err != nil {
+		return fmt.Errorf("Failed to unlock state: %s", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		c.lockInfo = nil
 		return nil
 	default:
-		return fmt.Errorf("HTTP error: %d", resp.StatusCode)
+		return fmt.Errorf("Failed to unlock state, HTTP error: %d", resp.StatusCode)
+	}
+}
+
+// do makes a request with the given method and body, retrying it if it
+// fails with a server error.
+func (c *HTTPClient) do(method string, u *url.URL, data []byte) (*http.Response, error) {
+	wait := c.RetryWaitMin
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+
+		req, err := http.NewRequest(method, u.String(), body)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to make HTTP request: %s", err)
+		}
+
+		if data != nil {
+			hash := md5.Sum(data)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(hash[:]))
+			req.ContentLength = int64(len(data))
+		}
+		if c.Username != "" {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+
+		resp, err := c.Client.Do(req)
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		if attempt >= c.RetryMax {
+			return resp, err
+		}
+
+		if err != nil {
+			log.Printf("[WARN] HTTP remote state %s failed, retrying: %s", method, err)
+		} else {
+			log.Printf("[WARN] HTTP remote state %s failed with %d, retrying",
+				method, resp.StatusCode)
+			resp.Body.Close()
+		}
+
+		time.Sleep(wait)
+		wait *= 2
+		if wait > c.RetryWaitMax {
+			wait = c.RetryWaitMax
+		}
 	}
 }
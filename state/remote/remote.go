@@ -19,6 +19,15 @@ type Payload struct {
 	Data []byte
 }
 
+// CheckAndSetClient is implemented by clients whose Put only writes the
+// state if it wasn't changed since it was last read with Get, such as
+// Consul. State doesn't read such a state again to check it before writing
+// it, since Put would then write over any changes made before that read.
+type CheckAndSetClient interface {
+	Client
+	CheckAndSet() bool
+}
+
 // Factory is the factory function to create a remote client.
 type Factory func(map[string]string) (Client, error)
 
@@ -43,6 +52,7 @@ var BuiltinClients = map[string]Factory{
 	"etcd":        etcdFactory,
 	"gcs":         gcsFactory,
 	"http":        httpFactory,
+	"inmem":       inmemFactory,
 	"local":       fileFactory,
 	"s3":          s3Factory,
 	"swift":       swiftFactory,
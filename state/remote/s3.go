@@ -2,6 +2,7 @@ package remote
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -11,10 +12,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/go-multierror"
 	terraformAws "github.com/hashicorp/terraform/builtin/providers/aws"
+	"github.com/hashicorp/terraform/state"
 )
 
 func s3Factory(conf map[string]string) (Client, error) {
@@ -90,23 +93,58 @@ providing credentials for the AWS S3 remote`))
 	sess := session.New(awsConfig)
 	nativeClient := s3.New(sess)
 
-	return &S3Client{
+	client := &S3Client{
 		nativeClient:         nativeClient,
 		bucketName:           bucketName,
 		keyName:              keyName,
 		serverSideEncryption: serverSideEncryption,
 		acl:                  acl,
 		kmsKeyID:             kmsKeyID,
-	}, nil
+		lockTable:            conf["lock_table"],
+	}
+	if client.lockTable != "" {
+		client.dynClient = dynamodb.New(sess)
+	}
+
+	return client, nil
+}
+
+// s3API is the part of the S3 API used by S3Client, so that it can be
+// faked in tests.
+type s3API interface {
+	GetObject(*s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	PutObject(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
+	DeleteObject(*s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
+}
+
+// dynamoDBAPI is the part of the DynamoDB API used by S3Client for
+// locking, so that it can be faked in tests.
+type dynamoDBAPI interface {
+	GetItem(*dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	DeleteItem(*dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error)
 }
 
+// S3Client is a remote client that stores the state as an object in S3.
+//
+// If a lock table is configured, the state can be locked with an item in
+// that DynamoDB table. The table must have a string hash key named
+// "LockID".
 type S3Client struct {
-	nativeClient         *s3.S3
+	nativeClient         s3API
 	bucketName           string
 	keyName              string
 	serverSideEncryption bool
 	acl                  string
 	kmsKeyID             string
+
+	dynClient dynamoDBAPI
+	lockTable string
+}
+
+// Describe returns the S3 URL of the state.
+func (c *S3Client) Describe() string {
+	return fmt.Sprintf("s3://%s/%s", c.bucketName, c.keyName)
 }
 
 func (c *S3Client) Get() (*Payload, error) {
@@ -129,6 +167,12 @@ func (c *S3Client) Get() (*Payload, error) {
 
 	defer output.Body.Close()
 
+	// In a versioned bucket, this helps to find the version to restore
+	// if the state is ever lost.
+	if output.VersionId != nil {
+		log.Printf("[DEBUG] Read remote state version %s from S3", *output.VersionId)
+	}
+
 	buf := bytes.NewBuffer(nil)
 	if _, err := io.Copy(buf, output.Body); err != nil {
 		return nil, fmt.Errorf("Failed to read remote state: %s", err)
@@ -173,11 +217,15 @@ func (c *S3Client) Put(data []byte) error {
 
 	log.Printf("[DEBUG] Uploading remote state to S3: %#v", i)
 
-	if _, err := c.nativeClient.PutObject(i); err == nil {
-		return nil
-	} else {
+	output, err := c.nativeClient.PutObject(i)
+	if err != nil {
 		return fmt.Errorf("Failed to upload state: %v", err)
 	}
+	if output.VersionId != nil {
+		log.Printf("[DEBUG] Uploaded remote state version %s to S3", *output.VersionId)
+	}
+
+	return nil
 }
 
 func (c *S3Client) Delete() error {
@@ -188,3 +236,125 @@ func (c *S3Client) Delete() error {
 
 	return err
 }
+
+// Lock locks the state by creating an item for it in the lock table, with
+// the lock info as its value. The item is created with a conditional put,
+// so only one client can hold the lock. If there is no lock table, the
+// state isn't locked.
+//
+// state.Locker impl.
+func (c *S3Client) Lock(info *state.LockInfo) (string, error) {
+	if c.lockTable == "" {
+		return "", nil
+	}
+
+	info.Path = c.lockPath()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = c.dynClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(c.lockTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(c.lockPath())},
+			"Info":   {S: aws.String(string(data))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(LockID)"),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ConditionalCheckFailedException" {
+			lockErr := &state.LockError{}
+			current, err := c.lockInfo()
+			if current != nil {
+				lockErr.Info = current.LockInfo
+			}
+			lockErr.Err = err
+			return "", lockErr
+		}
+
+		return "", fmt.Errorf("Error acquiring lock in DynamoDB: %s", err)
+	}
+
+	return info.ID, nil
+}
+
+// Unlock unlocks the state by deleting its item from the lock table, if
+// the lock has the given ID.
+//
+// state.Locker impl.
+func (c *S3Client) Unlock(id string) error {
+	if c.lockTable == "" {
+		return nil
+	}
+
+	info, err := c.lockInfo()
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return fmt.Errorf("the state at %q is not locked", c.lockPath())
+	}
+
+	if info.ID != id {
+		return fmt.Errorf(
+			"lock ID %q does not match the ID of the existing lock %q",
+			id, info.ID)
+	}
+
+	// Only delete the lock we checked, not one acquired in the meantime
+	_, err = c.dynClient.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(c.lockTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(c.lockPath())},
+		},
+		ConditionExpression: aws.String("Info = :info"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":info": {S: aws.String(info.raw)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Error releasing lock in DynamoDB: %s", err)
+	}
+
+	return nil
+}
+
+// lockPath returns the ID of the lock item of the state.
+func (c *S3Client) lockPath() string {
+	return fmt.Sprintf("%s/%s", c.bucketName, c.keyName)
+}
+
+// s3LockInfo is the lock info read from the lock table, along with the
+// raw value it was read from.
+type s3LockInfo struct {
+	*state.LockInfo
+	raw string
+}
+
+// lockInfo reads the current lock info from the lock table. If the state
+// isn't locked, nil is returned.
+func (c *S3Client) lockInfo() (*s3LockInfo, error) {
+	output, err := c.dynClient.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(c.lockTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(c.lockPath())},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error reading lock from DynamoDB: %s", err)
+	}
+
+	v, ok := output.Item["Info"]
+	if !ok || v.S == nil {
+		return nil, nil
+	}
+
+	var info state.LockInfo
+	if err := json.Unmarshal([]byte(*v.S), &info); err != nil {
+		return nil, fmt.Errorf("Error reading lock info from DynamoDB: %s", err)
+	}
+
+	return &s3LockInfo{LockInfo: &info, raw: *v.S}, nil
+}
@@ -1,16 +1,23 @@
 package remote
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/hashicorp/terraform/state"
 )
 
 func TestS3Client_impl(t *testing.T) {
 	var _ Client = new(S3Client)
+	var _ state.Locker = new(S3Client)
 }
 
 func TestS3Factory(t *testing.T) {
@@ -42,8 +49,9 @@ func TestS3Factory(t *testing.T) {
 	}
 
 	s3Client := client.(*S3Client)
+	nativeClient := s3Client.nativeClient.(*s3.S3)
 
-	if *s3Client.nativeClient.Config.Region != "us-west-1" {
+	if *nativeClient.Config.Region != "us-west-1" {
 		t.Fatalf("Incorrect region was populated")
 	}
 	if s3Client.bucketName != "foo" {
@@ -53,7 +61,7 @@ func TestS3Factory(t *testing.T) {
 		t.Fatalf("Incorrect keyName was populated")
 	}
 
-	credentials, err := s3Client.nativeClient.Config.Credentials.Get()
+	credentials, err := nativeClient.Config.Credentials.Get()
 	if err != nil {
 		t.Fatalf("Error when requesting credentials")
 	}
@@ -96,7 +104,7 @@ func TestS3Client(t *testing.T) {
 	}
 
 	s3Client := client.(*S3Client)
-	nativeClient := s3Client.nativeClient
+	nativeClient := s3Client.nativeClient.(*s3.S3)
 
 	createBucketReq := &s3.CreateBucketInput{
 		Bucket: &bucketName,
@@ -129,3 +137,202 @@ func TestS3Client(t *testing.T) {
 
 	testClient(t, client)
 }
+
+func TestS3Factory_lockTable(t *testing.T) {
+	client, err := s3Factory(map[string]string{
+		"region":     "us-west-1",
+		"bucket":     "foo",
+		"key":        "bar",
+		"lock_table": "locks",
+		"access_key": "bazkey",
+		"secret_key": "bazsecret",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s3Client := client.(*S3Client)
+	if s3Client.lockTable != "locks" || s3Client.dynClient == nil {
+		t.Fatalf("bad: %#v", s3Client)
+	}
+}
+
+func TestS3Client_putGet(t *testing.T) {
+	fake := newTestS3()
+	client := &S3Client{
+		nativeClient:         fake,
+		bucketName:           "foo",
+		keyName:              "bar",
+		serverSideEncryption: true,
+		kmsKeyID:             "key",
+	}
+
+	// A missing object is no state
+	p, err := client.Get()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p != nil {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	testClient(t, client)
+
+	if err := client.Put([]byte("data")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	input := fake.puts["foo/bar"]
+	if *input.ServerSideEncryption != "aws:kms" || *input.SSEKMSKeyId != "key" {
+		t.Fatalf("bad: %#v", input)
+	}
+}
+
+func TestS3Client_lock(t *testing.T) {
+	dyn := newTestDynamoDB()
+	a := &S3Client{
+		nativeClient: newTestS3(),
+		bucketName:   "foo",
+		keyName:      "bar",
+		dynClient:    dyn,
+		lockTable:    "locks",
+	}
+	b := &S3Client{
+		nativeClient: a.nativeClient,
+		bucketName:   "foo",
+		keyName:      "bar",
+		dynClient:    dyn,
+		lockTable:    "locks",
+	}
+
+	info := state.NewLockInfo()
+	info.Operation = "test"
+	id, err := a.Lock(info)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != info.ID {
+		t.Fatalf("bad: %s", id)
+	}
+
+	// The lock is contended
+	_, err = b.Lock(state.NewLockInfo())
+	lockErr, ok := err.(*state.LockError)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if lockErr.Info == nil || lockErr.Info.ID != id || lockErr.Info.Path != "foo/bar" {
+		t.Fatalf("bad: %#v", lockErr.Info)
+	}
+
+	if err := b.Unlock("wrong"); err == nil {
+		t.Fatal("should error")
+	}
+	if err := b.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(dyn.items) != 0 {
+		t.Fatalf("bad: %#v", dyn.items)
+	}
+
+	if err := b.Unlock(id); err == nil {
+		t.Fatal("should error when not locked")
+	}
+}
+
+func TestS3Client_lockNoTable(t *testing.T) {
+	client := &S3Client{
+		nativeClient: newTestS3(),
+		bucketName:   "foo",
+		keyName:      "bar",
+	}
+
+	// Without a lock table, locking does nothing
+	id, err := client.Lock(state.NewLockInfo())
+	if err != nil || id != "" {
+		t.Fatalf("bad: %q %s", id, err)
+	}
+	if err := client.Unlock(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+// testS3 is a fake of the S3 API that stores objects in memory.
+type testS3 struct {
+	objects map[string][]byte
+	puts    map[string]*s3.PutObjectInput
+}
+
+func newTestS3() *testS3 {
+	return &testS3{
+		objects: make(map[string][]byte),
+		puts:    make(map[string]*s3.PutObjectInput),
+	}
+}
+
+func (s *testS3) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	data, ok := s.objects[*input.Bucket+"/"+*input.Key]
+	if !ok {
+		return nil, awserr.New("NoSuchKey", "The specified key does not exist.", nil)
+	}
+
+	return &s3.GetObjectOutput{
+		Body:      ioutil.NopCloser(bytes.NewReader(data)),
+		VersionId: aws.String("v1"),
+	}, nil
+}
+
+func (s *testS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	key := *input.Bucket + "/" + *input.Key
+	s.objects[key] = data
+	s.puts[key] = input
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (s *testS3) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	delete(s.objects, *input.Bucket+"/"+*input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// testDynamoDB is a fake of the DynamoDB API that supports the conditions
+// used for locking.
+type testDynamoDB struct {
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newTestDynamoDB() *testDynamoDB {
+	return &testDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func (d *testDynamoDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: d.items[*input.Key["LockID"].S],
+	}, nil
+}
+
+func (d *testDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	id := *input.Item["LockID"].S
+	if _, ok := d.items[id]; ok && input.ConditionExpression != nil {
+		return nil, awserr.New(
+			"ConditionalCheckFailedException", "The conditional request failed", nil)
+	}
+
+	d.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (d *testDynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	id := *input.Key["LockID"].S
+	item, ok := d.items[id]
+	if !ok || *item["Info"].S != *input.ExpressionAttributeValues[":info"].S {
+		return nil, awserr.New(
+			"ConditionalCheckFailedException", "The conditional request failed", nil)
+	}
+
+	delete(d.items, id)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
@@ -2,7 +2,9 @@ package remote
 
 import (
 	"bytes"
+	"fmt"
 
+	"github.com/hashicorp/terraform/state"
 	"github.com/hashicorp/terraform/terraform"
 )
 
@@ -13,7 +15,32 @@ import (
 type State struct {
 	Client Client
 
+	// Force, if true, persists the state even if the remote state was
+	// changed by someone else since it was read. Otherwise PersistState
+	// fails instead of overwriting their changes.
+	Force bool
+
 	state, readState *terraform.State
+
+	// readSerial and readLineage are of the remote state as it was last
+	// read or persisted, which the remote state is checked against before
+	// it's persisted. haveRead is false until then, in which case there's
+	// nothing to check against.
+	readSerial  int64
+	readLineage string
+	haveRead    bool
+}
+
+// Describe returns where the client stores the state, if the client
+// implements state.Describer.
+//
+// state.Describer impl.
+func (s *State) Describe() string {
+	if d, ok := s.Client.(state.Describer); ok {
+		return d.Describe()
+	}
+
+	return ""
 }
 
 // StateReader impl.
@@ -29,21 +56,16 @@ func (s *State) WriteState(state *terraform.State) error {
 
 // StateRefresher impl.
 func (s *State) RefreshState() error {
-	payload, err := s.Client.Get()
+	state, err := s.get()
 	if err != nil {
 		return err
 	}
 
 	// no remote state is OK
-	if payload == nil {
+	if state == nil {
 		return nil
 	}
 
-	state, err := terraform.ReadState(bytes.NewReader(payload.Data))
-	if err != nil {
-		return err
-	}
-
 	s.state = state
 	s.readState = state
 	return nil
@@ -53,10 +75,178 @@ func (s *State) RefreshState() error {
 func (s *State) PersistState() error {
 	s.state.IncrementSerialMaybe(s.readState)
 
+	if !s.Force {
+		if err := s.checkRemote(); err != nil {
+			return err
+		}
+	}
+
 	var buf bytes.Buffer
 	if err := terraform.WriteState(s.state, &buf); err != nil {
 		return err
 	}
 
-	return s.Client.Put(buf.Bytes())
+	if err := s.Client.Put(buf.Bytes()); err != nil {
+		return err
+	}
+
+	if s.state != nil {
+		s.readSerial = s.state.Serial
+		s.readLineage = s.state.Lineage
+		s.haveRead = true
+	}
+	return nil
+}
+
+// CheckRemote reads the remote state and returns a *ConflictError if it
+// moved on from base, the state an operation is about to start from, such
+// as the state a saved plan was made with. It's the remote state read here
+// that is checked against again when the state is persisted.
+func (s *State) CheckRemote(base *terraform.State) error {
+	current, err := s.get()
+	if err != nil {
+		return fmt.Errorf("Error reading remote state to check for changes: %s", err)
+	}
+	if current == nil || base == nil {
+		return nil
+	}
+	s.readState = current
+
+	conflict := &ConflictError{
+		Serial:      current.Serial,
+		Lineage:     current.Lineage,
+		ReadSerial:  base.Serial,
+		ReadLineage: base.Lineage,
+	}
+	if conflict.lineageDiffers() || conflict.Serial > conflict.ReadSerial {
+		return conflict
+	}
+
+	return nil
+}
+
+// get reads the remote state from the client and records its serial and
+// lineage for checkRemote. It returns nil if there is no remote state.
+func (s *State) get() (*terraform.State, error) {
+	payload, err := s.Client.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	s.readSerial = 0
+	s.readLineage = ""
+	s.haveRead = true
+	if payload == nil {
+		return nil, nil
+	}
+
+	state, err := terraform.ReadState(bytes.NewReader(payload.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	s.readSerial = state.Serial
+	s.readLineage = state.Lineage
+	return state, nil
+}
+
+// checkRemote verifies that the remote state wasn't changed by someone
+// else since we read it, such as by a teammate applying while we were
+// planning. Persisting our state would otherwise silently replace theirs.
+//
+// If the state was never read there's nothing to check against, and a
+// CheckAndSetClient checks for changes itself when the state is written.
+func (s *State) checkRemote() error {
+	if !s.haveRead || s.state == nil {
+		return nil
+	}
+	if c, ok := s.Client.(CheckAndSetClient); ok && c.CheckAndSet() {
+		return nil
+	}
+
+	payload, err := s.Client.Get()
+	if err != nil {
+		return fmt.Errorf("Error reading remote state to check for changes: %s", err)
+	}
+	if payload == nil {
+		return nil
+	}
+
+	current, err := terraform.ReadState(bytes.NewReader(payload.Data))
+	if err != nil {
+		return fmt.Errorf("Error reading remote state to check for changes: %s", err)
+	}
+
+	conflict := &ConflictError{
+		Serial:      current.Serial,
+		Lineage:     current.Lineage,
+		ReadSerial:  s.readSerial,
+		ReadLineage: s.readLineage,
+	}
+	if conflict.lineageDiffers() || conflict.Serial > conflict.ReadSerial {
+		return conflict
+	}
+
+	return nil
+}
+
+// Lock locks the remote state if the client supports locking by
+// implementing state.Locker. Otherwise the state isn't locked.
+//
+// state.Locker impl.
+func (s *State) Lock(info *state.LockInfo) (string, error) {
+	if l, ok := s.Client.(state.Locker); ok {
+		return l.Lock(info)
+	}
+
+	return "", nil
+}
+
+// Unlock unlocks the state locked by Lock.
+//
+// state.Locker impl.
+func (s *State) Unlock(id string) error {
+	if l, ok := s.Client.(state.Locker); ok {
+		return l.Unlock(id)
+	}
+
+	return nil
+}
+
+// ConflictError is the error when the remote state was changed or replaced
+// by someone else since it was read, so persisting the state would
+// overwrite their changes.
+type ConflictError struct {
+	// Serial and Lineage are of the remote state, and ReadSerial and
+	// ReadLineage of the latest state we read or persisted.
+	Serial      int64
+	Lineage     string
+	ReadSerial  int64
+	ReadLineage string
+}
+
+func (e *ConflictError) Error() string {
+	// A different lineage means the remote state was replaced with a
+	// different state altogether since we read it.
+	if e.lineageDiffers() {
+		return fmt.Sprintf(
+			"The remote state was replaced by someone else while this operation\n"+
+				"was running: its lineage is now %q, but the state was read with\n"+
+				"lineage %q. Saving the state would overwrite it.\n\n"+
+				"Please run the command again to work with the latest state.",
+			e.Lineage, e.ReadLineage)
+	}
+
+	return fmt.Sprintf(
+		"The remote state was changed by someone else while this operation\n"+
+			"was running: its serial is now %d, but the state was read at\n"+
+			"serial %d. Saving the state would overwrite those changes.\n\n"+
+			"Please run the command again to work with the latest state.",
+		e.Serial, e.ReadSerial)
+}
+
+// lineageDiffers returns true if both states have a lineage and they
+// aren't the same.
+func (e *ConflictError) lineageDiffers() bool {
+	return e.ReadLineage != "" && e.Lineage != "" && e.Lineage != e.ReadLineage
 }
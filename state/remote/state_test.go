@@ -1,9 +1,12 @@
 package remote
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform/state"
+	"github.com/hashicorp/terraform/terraform"
 )
 
 func TestState(t *testing.T) {
@@ -25,3 +28,170 @@ func TestState_impl(t *testing.T) {
 	var _ state.StatePersister = new(State)
 	var _ state.StateRefresher = new(State)
 }
+
+func TestState_concurrentWriter(t *testing.T) {
+	client := new(InmemClient)
+	initial := &State{Client: client}
+	if err := initial.WriteState(state.TestStateInitial()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := initial.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Both read the same state
+	ours := &State{Client: client}
+	theirs := &State{Client: client}
+	for _, s := range []*State{ours, theirs} {
+		if err := s.RefreshState(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	// They save a change while we're still working
+	changed := theirs.State()
+	changed.RootModule().Outputs["theirs"] = &terraform.OutputState{
+		Type:  "string",
+		Value: "yes",
+	}
+	if err := theirs.WriteState(changed); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := theirs.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Saving our change must not overwrite theirs
+	changed = ours.State()
+	changed.RootModule().Outputs["ours"] = &terraform.OutputState{
+		Type:  "string",
+		Value: "yes",
+	}
+	if err := ours.WriteState(changed); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	err := ours.PersistState()
+	if _, ok := err.(*ConflictError); !ok || !strings.Contains(err.Error(), "changed by someone else") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	current := &State{Client: client}
+	if err := current.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := current.State().RootModule().Outputs["theirs"]; !ok {
+		t.Fatalf("bad: %s", current.State())
+	}
+
+	// Forcing overwrites their change
+	ours.Force = true
+	if err := ours.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := current.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := current.State().RootModule().Outputs["ours"]; !ok {
+		t.Fatalf("bad: %s", current.State())
+	}
+}
+
+func TestState_persistTwice(t *testing.T) {
+	client := new(InmemClient)
+	s := &State{Client: client}
+	if err := s.WriteState(state.TestStateInitial()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := s.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Persisting several times in the same operation, as apply does,
+	// only ever sees our own changes.
+	for i := 0; i < 3; i++ {
+		changed := s.State()
+		changed.RootModule().Outputs["count"] = &terraform.OutputState{
+			Type:  "string",
+			Value: fmt.Sprintf("%d", i),
+		}
+		if err := s.WriteState(changed); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := s.PersistState(); err != nil {
+			t.Fatalf("%d: err: %s", i, err)
+		}
+	}
+}
+
+func TestState_replaced(t *testing.T) {
+	client := new(InmemClient)
+	initial := &State{Client: client}
+	if err := initial.WriteState(state.TestStateInitial()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := initial.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ours := &State{Client: client}
+	if err := ours.RefreshState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Someone replaces the state with a different one
+	replaced := terraform.NewState()
+	replaced.Lineage = "other"
+	other := &State{Client: client, Force: true}
+	if err := other.WriteState(replaced); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := other.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := ours.PersistState()
+	if _, ok := err.(*ConflictError); !ok || !strings.Contains(err.Error(), "replaced by someone else") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestState_checkRemote(t *testing.T) {
+	client := new(InmemClient)
+	initial := &State{Client: client}
+	base := state.TestStateInitial()
+	base.Serial = 3
+	if err := initial.WriteState(base); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := initial.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A state that wasn't read, such as when applying a plan, is checked
+	// against the state it was made from before the operation.
+	ours := &State{Client: client}
+	if err := ours.CheckRemote(base); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	changed := base.DeepCopy()
+	changed.RootModule().Outputs["ours"] = &terraform.OutputState{
+		Type:  "string",
+		Value: "yes",
+	}
+	if err := ours.WriteState(changed); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ours.PersistState(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The check fails once the remote state moved on from the base
+	err := (&State{Client: client}).CheckRemote(base)
+	if _, ok := err.(*ConflictError); !ok || !strings.Contains(err.Error(), "changed by someone else") {
+		t.Fatalf("bad: %v", err)
+	}
+}
@@ -40,3 +40,12 @@ type StateRefresher interface {
 type StatePersister interface {
 	PersistState() error
 }
+
+// Describer is implemented by states that can describe where they're
+// stored, such as the path of a state file or the URL of a remote state,
+// for showing to users. Remote clients may implement it too.
+type Describer interface {
+	// Describe returns where the state is stored, without any secrets such
+	// as credentials. It is empty if that isn't known.
+	Describe() string
+}
@@ -126,6 +126,47 @@ func TestState(t *testing.T, s interface{}) {
 	}
 }
 
+// TestLocker is a helper for testing Locker implementations. The two
+// lockers must lock the same state, as if used by two separate operations.
+func TestLocker(t *testing.T, a, b Locker) {
+	info := NewLockInfo()
+	info.Operation = "test"
+	id, err := a.Lock(info)
+	if err != nil {
+		t.Fatalf("lock: %s", err)
+	}
+	if id != info.ID {
+		t.Fatalf("lock ID should be the ID of the lock info: %q", id)
+	}
+
+	// The state can't be locked again while it is locked
+	_, err = b.Lock(NewLockInfo())
+	lockErr, ok := err.(*LockError)
+	if !ok {
+		t.Fatalf("locking a locked state should return a *LockError: %#v", err)
+	}
+	if lockErr.Info != nil && lockErr.Info.ID != id {
+		t.Fatalf("lock error should have the current lock info: %#v", lockErr.Info)
+	}
+
+	// Only the right ID unlocks the state, from either locker
+	if err := b.Unlock("not-the-lock-id"); err == nil {
+		t.Fatal("unlocking with the wrong ID should fail")
+	}
+	if err := b.Unlock(id); err != nil {
+		t.Fatalf("unlock: %s", err)
+	}
+
+	// Once unlocked, the state can be locked again
+	id, err = b.Lock(NewLockInfo())
+	if err != nil {
+		t.Fatalf("lock after unlock: %s", err)
+	}
+	if err := b.Unlock(id); err != nil {
+		t.Fatalf("unlock: %s", err)
+	}
+}
+
 // TestStateInitial is the initial state that a State should have
 // for TestState.
 func TestStateInitial() *terraform.State {
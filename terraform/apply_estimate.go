@@ -0,0 +1,85 @@
+package terraform
+
+import (
+	"github.com/hashicorp/terraform/dag"
+)
+
+// ApplyEstimate is an estimate of how much work applying the diff of a
+// context is, and how much of it can run in parallel. It is found with
+// Context.ApplyEstimate.
+type ApplyEstimate struct {
+	// Operations is the number of resource operations the apply runs,
+	// such as creating, updating or destroying a resource instance. An
+	// instance that is replaced counts as two operations.
+	Operations int
+
+	// CriticalPath is the number of operations in the longest chain of
+	// operations that depend on each other. These run one after the other
+	// however high the parallelism is, so applying takes at least as long
+	// as this many operations.
+	CriticalPath int
+}
+
+// ApplyEstimate returns an estimate of the work of applying the diff of
+// the context, from the graph that Apply would walk. This should be called
+// after Plan, such as to show the estimate along with the plan.
+func (c *Context) ApplyEstimate() (*ApplyEstimate, error) {
+	graph, err := c.Graph(GraphTypeApply, &ContextGraphOpts{Validate: false})
+	if err != nil {
+		return nil, err
+	}
+
+	return graphApplyEstimate(graph), nil
+}
+
+// graphApplyEstimate returns the estimate of walking the given apply
+// graph, where only the resource nodes are operations.
+func graphApplyEstimate(g *Graph) *ApplyEstimate {
+	var result ApplyEstimate
+
+	// The length of the longest chain of operations starting at each
+	// vertex, following the edges to what the vertex depends on. The graph
+	// is acyclic, so the recursion ends.
+	chains := make(map[dag.Vertex]int)
+	var chain func(v dag.Vertex) int
+	chain = func(v dag.Vertex) int {
+		if n, ok := chains[v]; ok {
+			return n
+		}
+
+		n := 0
+		for _, raw := range g.DownEdges(v).List() {
+			if m := chain(raw.(dag.Vertex)); m > n {
+				n = m
+			}
+		}
+		if isApplyOperation(v) {
+			n++
+		}
+
+		chains[v] = n
+		return n
+	}
+
+	for _, v := range g.Vertices() {
+		if isApplyOperation(v) {
+			result.Operations++
+		}
+		if n := chain(v); n > result.CriticalPath {
+			result.CriticalPath = n
+		}
+	}
+
+	return &result
+}
+
+// isApplyOperation returns true if walking the vertex of an apply graph
+// runs a resource operation.
+func isApplyOperation(v dag.Vertex) bool {
+	switch v.(type) {
+	case *NodeApplyableResource, *NodeDestroyResource:
+		return true
+	}
+
+	return false
+}
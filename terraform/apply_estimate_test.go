@@ -0,0 +1,74 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContextApplyEstimate(t *testing.T) {
+	m := testModule(t, "apply-estimate")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+	})
+
+	if _, err := ctx.Plan(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := ctx.ApplyEstimate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Six instances are created, and c has to wait for b, which has to
+	// wait for a.
+	expected := &ApplyEstimate{Operations: 6, CriticalPath: 3}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestContextApplyEstimate_destroy(t *testing.T) {
+	m := testModule(t, "apply-estimate")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	s := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.a": resourceState("aws_instance", "a"),
+					"aws_instance.b": resourceState("aws_instance", "b"),
+				},
+			},
+		},
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:   s,
+		Destroy: true,
+	})
+
+	if _, err := ctx.Plan(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	actual, err := ctx.ApplyEstimate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The instances in the state are destroyed, b before a
+	expected := &ApplyEstimate{Operations: 2, CriticalPath: 2}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
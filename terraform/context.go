@@ -53,6 +53,7 @@ type ContextOpts struct {
 	Hooks              []Hook
 	Module             *module.Tree
 	Parallelism        int
+	RecoverPanics      bool
 	State              *State
 	StateFutureAllowed bool
 	Providers          map[string]ResourceProviderFactory
@@ -61,6 +62,32 @@ type ContextOpts struct {
 	Targets            []string
 	Variables          map[string]interface{}
 
+	// RefreshSkip is a list of addresses of managed resources that are not
+	// refreshed. Their state is kept as it is, and they are still planned.
+	RefreshSkip []string
+
+	// ForceReplace is a list of addresses of managed resources that are
+	// planned to be destroyed and created again, even if their
+	// configuration doesn't require it. They must be in the state.
+	ForceReplace []string
+
+	// PlanMode is the kind of plan to create. Destroy, if set, is the same
+	// as PlanModeDestroy.
+	PlanMode PlanMode
+
+	// OverridePreventDestroy allows a destroy plan to destroy resources
+	// that have lifecycle.prevent_destroy set. Other plans still fail if
+	// they would destroy them.
+	OverridePreventDestroy bool
+
+	// SkipProviderConfig defers configuring each provider until it's
+	// called for something that needs the configuration, such as a diff
+	// or a refresh. A plan that never calls a provider, such as a destroy
+	// plan without a refresh, then doesn't need a valid configuration for
+	// it. The error from configuring a provider is returned by the first
+	// call that needs it instead.
+	SkipProviderConfig bool
+
 	UIInput UIInput
 }
 
@@ -76,6 +103,7 @@ type Context struct {
 
 	components contextComponentFactory
 	destroy    bool
+	planMode   PlanMode
 	diff       *Diff
 	diffLock   sync.RWMutex
 	hooks      []Hook
@@ -88,9 +116,19 @@ type Context struct {
 	uiInput    UIInput
 	variables  map[string]interface{}
 
+	// priorOutputs are the root module outputs of the state the context
+	// was created with. They are kept since refreshing updates the
+	// outputs in the state, so that plans can show the changes to them.
+	priorOutputs map[string]*OutputState
+
 	l                   sync.Mutex // Lock acquired during any task
 	parallelSem         Semaphore
 	providerInputConfig map[string]map[string]interface{}
+	recoverPanics       bool
+	refreshSkip         []*ResourceAddress
+	forceReplace        []*ResourceAddress
+	overridePrevent     bool
+	skipProviderConfig  bool
 	runCh               <-chan struct{}
 	stopCh              chan struct{}
 	shadowErr           error
@@ -104,7 +142,7 @@ type Context struct {
 func NewContext(opts *ContextOpts) (*Context, error) {
 	// Validate the version requirement if it is given
 	if opts.Module != nil {
-		if err := checkRequiredVersion(opts.Module); err != nil {
+		if err := CheckRequiredVersion(opts.Module); err != nil {
 			return nil, err
 		}
 	}
@@ -167,12 +205,36 @@ func NewContext(opts *ContextOpts) (*Context, error) {
 		diff = &Diff{}
 	}
 
+	refreshSkip, err := parseRefreshSkip(opts.RefreshSkip)
+	if err != nil {
+		return nil, err
+	}
+
+	forceReplace, err := parseForceReplace(opts.ForceReplace)
+	if err != nil {
+		return nil, err
+	}
+
+	planMode := opts.PlanMode
+	if opts.Destroy {
+		planMode = PlanModeDestroy
+	}
+
+	var priorOutputs map[string]*OutputState
+	if mod := state.ModuleByPath(rootModulePath); mod != nil {
+		priorOutputs = make(map[string]*OutputState, len(mod.Outputs))
+		for k, v := range mod.Outputs {
+			priorOutputs[k] = v.deepcopy()
+		}
+	}
+
 	return &Context{
 		components: &basicComponentFactory{
 			providers:    opts.Providers,
 			provisioners: opts.Provisioners,
 		},
-		destroy:   opts.Destroy,
+		destroy:   planMode == PlanModeDestroy,
+		planMode:  planMode,
 		diff:      diff,
 		hooks:     hooks,
 		module:    opts.Module,
@@ -183,7 +245,13 @@ func NewContext(opts *ContextOpts) (*Context, error) {
 		variables: variables,
 
 		parallelSem:         NewSemaphore(par),
+		priorOutputs:        priorOutputs,
 		providerInputConfig: make(map[string]map[string]interface{}),
+		recoverPanics:       opts.RecoverPanics,
+		refreshSkip:         refreshSkip,
+		forceReplace:        forceReplace,
+		overridePrevent:     opts.OverridePreventDestroy,
+		skipProviderConfig:  opts.SkipProviderConfig,
 		sh:                  sh,
 	}, nil
 }
@@ -227,10 +295,11 @@ func (c *Context) Graph(typ GraphType, opts *ContextGraphOpts) (*Graph, error) {
 
 	case GraphTypePlanDestroy:
 		return (&DestroyPlanGraphBuilder{
-			Module:   c.module,
-			State:    c.state,
-			Targets:  c.targets,
-			Validate: opts.Validate,
+			Module:                 c.module,
+			State:                  c.state,
+			Targets:                c.targets,
+			OverridePreventDestroy: c.overridePrevent,
+			Validate:               opts.Validate,
 		}).Build(RootModulePath)
 
 	case GraphTypeLegacy:
@@ -428,6 +497,12 @@ func (c *Context) Apply() (*State, error) {
 	// Copy our own state
 	c.state = c.state.DeepCopy()
 
+	// A refresh-only plan changes nothing, applying it only saves the
+	// refreshed state it was created with.
+	if c.planMode == PlanModeRefreshOnly {
+		return c.state, nil
+	}
+
 	// Enable the new graph by default
 	X_legacyGraph := experiment.Enabled(experiment.X_legacyGraph)
 
@@ -475,6 +550,23 @@ func (c *Context) Plan() (*Plan, error) {
 		Vars:    c.variables,
 		State:   c.state,
 		Targets: c.targets,
+		Destroy: c.destroy,
+		Mode:    c.planMode,
+
+		TerraformVersion: VersionString(),
+	}
+
+	// A refresh-only plan has no changes, just the state as refreshed
+	if c.planMode == PlanModeRefreshOnly {
+		p.Diff = new(Diff)
+		p.Diff.init()
+		return p, nil
+	}
+
+	if !c.destroy {
+		if err := c.checkForceReplace(); err != nil {
+			return nil, err
+		}
 	}
 
 	var operation walkOperation
@@ -524,10 +616,17 @@ func (c *Context) Plan() (*Plan, error) {
 	// Do the walk
 	walker, err := c.walk(graph, graph, operation)
 	if err != nil {
-		return nil, err
+		return nil, mergePreventDestroyErrors(err)
 	}
 	p.Diff = c.diff
 
+	// The outputs were evaluated into the temporary state by the walk, so
+	// they have to be copied before the state is put back.
+	if !c.destroy {
+		p.Outputs = c.planOutputs()
+		p.PriorOutputs = c.priorOutputs
+	}
+
 	// If this is true, it means we're running unit tests. In this case,
 	// we perform a deep copy just to ensure that all context tests also
 	// test that a diff is copy-able. This will panic if it fails. This
@@ -556,6 +655,40 @@ func (c *Context) Plan() (*Plan, error) {
 	return p, errs
 }
 
+// planOutputs returns the root module outputs from the temporary state
+// that a plan walk evaluated them into.
+//
+// Outputs whose value isn't known yet are pruned from the state when it is
+// cleaned up, such as when comparing it with the shadow state, but every
+// output in the config is written by the walk. So outputs that are in the
+// config and missing from the state are computed.
+func (c *Context) planOutputs() map[string]*OutputState {
+	if c.module == nil || c.module.Config() == nil {
+		return nil
+	}
+
+	var written map[string]*OutputState
+	if mod := c.state.ModuleByPath(rootModulePath); mod != nil {
+		written = mod.Outputs
+	}
+
+	result := make(map[string]*OutputState)
+	for k, v := range written {
+		result[k] = v.deepcopy()
+	}
+	for _, o := range c.module.Config().Outputs {
+		if _, ok := result[o.Name]; !ok {
+			result[o.Name] = &OutputState{
+				Type:      "string",
+				Sensitive: o.Sensitive,
+				Value:     config.UnknownVariableValue,
+			}
+		}
+	}
+
+	return result
+}
+
 // Refresh goes through all the resources in the state and refreshes them
 // to their latest state. This will update the state that this context
 // works with, along with returning it.
@@ -755,8 +888,16 @@ func (c *Context) walk(
 	doneCh := make(chan struct{})
 	go c.watchStop(walker, c.stopCh, doneCh)
 
+	// If we're recovering panics, wrap the walker so that a panic while
+	// walking, such as in a hook or provider, becomes an error of the walk
+	// instead of crashing the whole process.
+	var realWalker GraphWalker = walker
+	if c.recoverPanics {
+		realWalker = GraphWalkerPanicwrap(walker)
+	}
+
 	// Walk the real graph, this will block until it completes
-	realErr := graph.Walk(walker)
+	realErr := graph.Walk(realWalker)
 
 	// Close the done channel so the watcher stops
 	close(doneCh)
@@ -929,3 +1070,131 @@ func parseVariableAsHCL(name string, input string, targetType config.VariableTyp
 		panic(fmt.Errorf("unknown type %s", targetType.Printable()))
 	}
 }
+
+// parseRefreshSkip parses the addresses of the resources not to refresh.
+// Only managed resources can be skipped: data sources are read during
+// refresh so that they can be used by the plan.
+func parseRefreshSkip(raw []string) ([]*ResourceAddress, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make([]*ResourceAddress, 0, len(raw))
+	for _, s := range raw {
+		addr, err := ParseResourceAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing refresh skip address %q: %s", s, err)
+		}
+		if addr.Type == "" || addr.Name == "" {
+			return nil, fmt.Errorf(
+				"Refresh skip address %q must be a resource, such as aws_instance.foo", s)
+		}
+		if addr.Mode == config.DataResourceMode {
+			return nil, fmt.Errorf(
+				"Refresh skip address %q is a data source. Data sources are always\n"+
+					"read during refresh, since the plan may depend on them.", s)
+		}
+
+		result = append(result, addr)
+	}
+
+	return result, nil
+}
+
+// parseForceReplace parses the addresses of the resources to replace.
+// Like those to skip refreshing, they must be managed resources.
+func parseForceReplace(raw []string) ([]*ResourceAddress, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	result := make([]*ResourceAddress, 0, len(raw))
+	for _, s := range raw {
+		addr, err := ParseResourceAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing replace address %q: %s", s, err)
+		}
+		if addr.Type == "" || addr.Name == "" {
+			return nil, fmt.Errorf(
+				"Replace address %q must be a resource, such as aws_instance.foo", s)
+		}
+		if addr.Mode == config.DataResourceMode {
+			return nil, fmt.Errorf(
+				"Replace address %q is a data source. Only managed resources\n"+
+					"can be replaced.", s)
+		}
+
+		result = append(result, addr)
+	}
+
+	return result, nil
+}
+
+// checkForceReplace verifies that each resource to replace has an
+// instance in the state, and is included in the targets if there are
+// any, since nothing would be replaced otherwise.
+func (c *Context) checkForceReplace() error {
+	if len(c.forceReplace) == 0 {
+		return nil
+	}
+
+	var targets []*ResourceAddress
+	for _, t := range c.targets {
+		addr, err := ParseResourceAddress(t)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, addr)
+	}
+
+	for _, addr := range c.forceReplace {
+		if !c.stateHasResource(addr) {
+			return fmt.Errorf(
+				"Can't replace %s: it isn't in the state, so there is\n"+
+					"nothing to replace.", addr)
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		targeted := false
+		for _, t := range targets {
+			if t.Equals(addr) {
+				targeted = true
+				break
+			}
+		}
+		if !targeted {
+			return fmt.Errorf(
+				"Can't replace %s: it isn't included in the targets.", addr)
+		}
+	}
+
+	return nil
+}
+
+// stateHasResource returns true if an instance in the state matches addr.
+func (c *Context) stateHasResource(addr *ResourceAddress) bool {
+	if c.state == nil {
+		return false
+	}
+
+	for _, mod := range c.state.Modules {
+		for k, rs := range mod.Resources {
+			if rs == nil || rs.Primary == nil {
+				continue
+			}
+
+			other, err := parseResourceAddressInternal(k)
+			if err != nil {
+				continue
+			}
+			other.Path = mod.Path[1:]
+			if addr.Equals(other) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
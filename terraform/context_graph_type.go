@@ -18,9 +18,13 @@ const (
 // GraphTypeMap is a mapping of human-readable string to GraphType. This
 // is useful to use as the mechanism for human input for configurable
 // graph types.
+//
+// "refresh" is the legacy graph, which is the graph that is walked to
+// refresh the state.
 var GraphTypeMap = map[string]GraphType{
 	"apply":        GraphTypeApply,
 	"plan":         GraphTypePlan,
 	"plan-destroy": GraphTypePlanDestroy,
+	"refresh":      GraphTypeLegacy,
 	"legacy":       GraphTypeLegacy,
 }
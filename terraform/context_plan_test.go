@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"sync"
 	"testing"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/terraform/config"
 )
 
 func TestContext2Plan_basic(t *testing.T) {
@@ -36,6 +40,287 @@ func TestContext2Plan_basic(t *testing.T) {
 	if actual != expected {
 		t.Fatalf("bad:\n%s", actual)
 	}
+
+	if plan.TerraformVersion != VersionString() {
+		t.Fatalf("bad: %q", plan.TerraformVersion)
+	}
+}
+
+func TestContext2Plan_outputs(t *testing.T) {
+	m := testModule(t, "plan-outputs")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	s := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path:      rootModulePath,
+				Resources: map[string]*ResourceState{},
+				Outputs: map[string]*OutputState{
+					"known": &OutputState{Type: "string", Value: "foo"},
+				},
+			},
+		},
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State: s,
+	})
+
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if v := plan.Outputs["known"]; v == nil || v.Value != "bar" {
+		t.Fatalf("bad: %#v", plan.Outputs)
+	}
+	if v := plan.Outputs["computed"]; v == nil || v.Value != config.UnknownVariableValue {
+		t.Fatalf("bad: %#v", plan.Outputs)
+	}
+
+	if v := plan.PriorOutputs["known"]; v == nil || v.Value != "foo" {
+		t.Fatalf("bad: %#v", plan.PriorOutputs)
+	}
+
+	// The state isn't changed by planning
+	if v := s.RootModule().Outputs["known"]; v.Value != "foo" {
+		t.Fatalf("bad: %#v", v)
+	}
+	if _, ok := s.RootModule().Outputs["computed"]; ok {
+		t.Fatalf("bad: %#v", s.RootModule().Outputs)
+	}
+}
+
+func TestContext2Plan_refreshOnly(t *testing.T) {
+	m := testModule(t, "refresh-basic")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	s := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.web": &ResourceState{
+						Type: "aws_instance",
+						Primary: &InstanceState{
+							ID:         "foo",
+							Attributes: map[string]string{"foo": "bar"},
+						},
+					},
+				},
+			},
+		},
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:    s,
+		PlanMode: PlanModeRefreshOnly,
+	})
+
+	// The resource was changed outside of Terraform, so the configuration
+	// would change it back.
+	p.RefreshFn = nil
+	p.RefreshReturn = &InstanceState{
+		ID:         "foo",
+		Attributes: map[string]string{"foo": "baz"},
+	}
+	if _, err := ctx.Refresh(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if plan.Mode != PlanModeRefreshOnly {
+		t.Fatalf("bad: %#v", plan.Mode)
+	}
+	if !plan.Diff.Empty() || p.DiffCalled {
+		t.Fatalf("bad: %s", plan.Diff)
+	}
+	if v := plan.State.RootModule().Resources["aws_instance.web"].Primary.Attributes["foo"]; v != "baz" {
+		t.Fatalf("bad: %s", plan.State)
+	}
+
+	// Applying the plan saves the refreshed state and changes nothing
+	ctx, err = plan.Context(&ContextOpts{
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state, err := ctx.Apply()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+	if v := state.RootModule().Resources["aws_instance.web"].Primary.Attributes["foo"]; v != "baz" {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
+func testForceReplaceState() *State {
+	return &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{
+						Type: "aws_instance",
+						Primary: &InstanceState{
+							ID:         "bar",
+							Attributes: map[string]string{"num": "2"},
+						},
+					},
+					"aws_instance.bar": &ResourceState{
+						Type: "aws_instance",
+						Primary: &InstanceState{
+							ID:         "baz",
+							Attributes: map[string]string{"foo": "2"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestContext2Plan_forceReplace(t *testing.T) {
+	m := testModule(t, "plan-taint")
+	p := testProvider("aws")
+	p.ApplyFn = testApplyFn
+	p.DiffFn = testDiffFn
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:        testForceReplaceState(),
+		ForceReplace: []string{"aws_instance.foo"},
+	})
+
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	resources := plan.Diff.RootModule().Resources
+	if rd := resources["aws_instance.foo"]; rd.ChangeType() != DiffDestroyCreate || !rd.Replace {
+		t.Fatalf("bad: %s", plan.Diff)
+	}
+	if rd := resources["aws_instance.bar"]; rd != nil && rd.ChangeType() != DiffUpdate {
+		t.Fatalf("bad: %s", plan.Diff)
+	}
+
+	// Applying the saved plan replaces the resource
+	ctx, err = plan.Context(&ContextOpts{
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	state, err := ctx.Apply()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id := state.RootModule().Resources["aws_instance.foo"].Primary.ID; id == "bar" {
+		t.Fatalf("bad: %s", state)
+	}
+	if id := state.RootModule().Resources["aws_instance.bar"].Primary.ID; id != "baz" {
+		t.Fatalf("bad: %s", state)
+	}
+}
+
+func TestContext2Plan_forceReplaceNotInState(t *testing.T) {
+	m := testModule(t, "plan-taint")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:        testForceReplaceState(),
+		ForceReplace: []string{"aws_instance.nope"},
+	})
+
+	_, err := ctx.Plan()
+	if err == nil || !strings.Contains(err.Error(), "isn't in the state") {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestContext2Plan_forceReplaceTargeted(t *testing.T) {
+	m := testModule(t, "plan-taint")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+
+	cases := []struct {
+		Target string
+		Err    bool
+	}{
+		{"aws_instance.foo", false},
+		{"aws_instance.bar", true},
+	}
+
+	for _, tc := range cases {
+		ctx := testContext2(t, &ContextOpts{
+			Module: m,
+			Providers: map[string]ResourceProviderFactory{
+				"aws": testProviderFuncFixed(p),
+			},
+			State:        testForceReplaceState(),
+			Targets:      []string{tc.Target},
+			ForceReplace: []string{"aws_instance.foo"},
+		})
+
+		plan, err := ctx.Plan()
+		if (err != nil) != tc.Err {
+			t.Fatalf("%s: err: %v", tc.Target, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		rd := plan.Diff.RootModule().Resources["aws_instance.foo"]
+		if rd.ChangeType() != DiffDestroyCreate {
+			t.Fatalf("%s: bad: %s", tc.Target, plan.Diff)
+		}
+	}
+}
+
+func TestContext2Plan_recoverPanics(t *testing.T) {
+	m := testModule(t, "plan-good")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		Hooks:         []Hook{new(testPanicHook)},
+		RecoverPanics: true,
+	})
+
+	_, err := ctx.Plan()
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), "captured panic: PostDiff panic") {
+		t.Fatalf("bad: %s", err)
+	}
 }
 
 func TestContext2Plan_createBefore_deposed(t *testing.T) {
@@ -840,7 +1125,7 @@ func TestContext2Plan_preventDestroy_bad(t *testing.T) {
 
 	plan, err := ctx.Plan()
 
-	expectedErr := "aws_instance.foo: the plan would destroy"
+	expectedErr := "aws_instance.foo ("
 	if !strings.Contains(fmt.Sprintf("%s", err), expectedErr) {
 		t.Fatalf("expected err would contain %q\nerr: %s\nplan: %s",
 			expectedErr, err, plan)
@@ -916,7 +1201,7 @@ func TestContext2Plan_preventDestroy_countBad(t *testing.T) {
 
 	plan, err := ctx.Plan()
 
-	expectedErr := "aws_instance.foo.1: the plan would destroy"
+	expectedErr := "aws_instance.foo.1 ("
 	if !strings.Contains(fmt.Sprintf("%s", err), expectedErr) {
 		t.Fatalf("expected err would contain %q\nerr: %s\nplan: %s",
 			expectedErr, err, plan)
@@ -1032,13 +1317,83 @@ func TestContext2Plan_preventDestroy_destroyPlan(t *testing.T) {
 
 	plan, err := ctx.Plan()
 
-	expectedErr := "aws_instance.foo: the plan would destroy"
+	expectedErr := "aws_instance.foo ("
 	if !strings.Contains(fmt.Sprintf("%s", err), expectedErr) {
 		t.Fatalf("expected err would contain %q\nerr: %s\nplan: %s",
 			expectedErr, err, plan)
 	}
 }
 
+func TestContext2Plan_preventDestroy_destroyPlanMulti(t *testing.T) {
+	m := testModule(t, "plan-prevent-destroy-multi")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	s := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "i-abc123"},
+					},
+					"aws_instance.bar": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "i-abc456"},
+					},
+					"aws_instance.baz": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "i-abc789"},
+					},
+				},
+			},
+		},
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:   s,
+		Destroy: true,
+	})
+
+	_, err := ctx.Plan()
+	if !errwrap.ContainsType(err, new(PreventDestroyError)) {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	// Both resources are listed together in one error, with their location
+	path := filepath.Join(fixtureDir, "plan-prevent-destroy-multi", "main.tf")
+	expected := fmt.Sprintf(
+		"\n  aws_instance.bar (%s:7)\n  aws_instance.foo (%s:1)\n", path, path)
+	if !strings.Contains(err.Error(), expected) {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// They can be destroyed if the check is overridden
+	ctx = testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:   s,
+		Destroy: true,
+
+		OverridePreventDestroy: true,
+	})
+
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, name := range []string{"aws_instance.foo", "aws_instance.bar", "aws_instance.baz"} {
+		if rd := plan.Diff.RootModule().Resources[name]; rd == nil || !rd.Destroy {
+			t.Fatalf("%s: bad: %s", name, plan.Diff)
+		}
+	}
+}
+
 func TestContext2Plan_provisionerCycle(t *testing.T) {
 	m := testModule(t, "plan-provisioner-cycle")
 	p := testProvider("aws")
@@ -1799,6 +2154,58 @@ func TestContext2Plan_destroy(t *testing.T) {
 	}
 }
 
+func TestContext2Plan_orphanSkipProviderConfig(t *testing.T) {
+	m := testModule(t, "empty")
+	p := testProvider("aws")
+	p.DiffFn = testDiffFn
+	p.ApplyFn = testApplyFn
+	p.ConfigureReturnError = fmt.Errorf("no credentials")
+	s := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.one": &ResourceState{
+						Type: "aws_instance",
+						Primary: &InstanceState{
+							ID: "bar",
+						},
+					},
+				},
+			},
+		},
+	}
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State:              s,
+		SkipProviderConfig: true,
+	})
+
+	// Planning to destroy the orphan doesn't need the provider
+	plan, err := ctx.Plan()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.ConfigureCalled {
+		t.Fatal("configure should not be called")
+	}
+	if len(plan.Diff.RootModule().Resources) != 1 {
+		t.Fatalf("bad: %#v", plan.Diff.RootModule().Resources)
+	}
+
+	// Applying it does, and fails with the error from configuring it
+	_, err = ctx.Apply()
+	if err == nil || !strings.Contains(err.Error(), "no credentials") {
+		t.Fatalf("bad: %v", err)
+	}
+	if !p.ConfigureCalled || p.ApplyCalled {
+		t.Fatalf("bad: %#v", p)
+	}
+}
+
 func TestContext2Plan_moduleDestroy(t *testing.T) {
 	m := testModule(t, "plan-module-destroy")
 	p := testProvider("aws")
@@ -2948,3 +3355,12 @@ func TestContext2Plan_listOrder(t *testing.T) {
 		t.Fatal("aws_instance.a and aws_instance.b diffs should match:\n", plan)
 	}
 }
+
+// testPanicHook is a Hook that panics in PostDiff.
+type testPanicHook struct {
+	NilHook
+}
+
+func (*testPanicHook) PostDiff(*InstanceInfo, *InstanceDiff) (HookAction, error) {
+	panic("PostDiff panic")
+}
@@ -101,6 +101,98 @@ func TestContext2Refresh_targeted(t *testing.T) {
 	}
 }
 
+func TestContext2Refresh_skip(t *testing.T) {
+	p := testProvider("aws")
+	m := testModule(t, "refresh-targeted")
+	ctx := testContext2(t, &ContextOpts{
+		Module: m,
+		Providers: map[string]ResourceProviderFactory{
+			"aws": testProviderFuncFixed(p),
+		},
+		State: &State{
+			Modules: []*ModuleState{
+				&ModuleState{
+					Path: rootModulePath,
+					Resources: map[string]*ResourceState{
+						"aws_vpc.metoo":      resourceState("aws_vpc", "vpc-abc123"),
+						"aws_instance.notme": resourceState("aws_instance", "i-bcd345"),
+						"aws_instance.me":    resourceState("aws_instance", "i-abc123"),
+						"aws_elb.meneither":  resourceState("aws_elb", "lb-abc123"),
+					},
+				},
+			},
+		},
+		RefreshSkip: []string{"aws_instance.notme", "aws_elb.meneither"},
+	})
+
+	var l sync.Mutex
+	var refreshed []string
+	p.RefreshFn = func(i *InstanceInfo, is *InstanceState) (*InstanceState, error) {
+		l.Lock()
+		defer l.Unlock()
+		refreshed = append(refreshed, i.Id)
+
+		is = is.DeepCopy()
+		is.Attributes = map[string]string{"id": is.ID, "refreshed": "true"}
+		return is, nil
+	}
+
+	s, err := ctx.Refresh()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Strings(refreshed)
+	expected := []string{"aws_instance.me", "aws_vpc.metoo"}
+	if !reflect.DeepEqual(refreshed, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, refreshed)
+	}
+
+	// The skipped resources keep their state
+	mod := s.RootModule()
+	for _, k := range []string{"aws_instance.notme", "aws_elb.meneither"} {
+		if v := mod.Resources[k].Primary.Attributes["refreshed"]; v != "" {
+			t.Fatalf("%s should not be refreshed", k)
+		}
+	}
+
+	// The skipped resources are still planned
+	var diffed []string
+	p.DiffFn = func(
+		info *InstanceInfo,
+		state *InstanceState,
+		c *ResourceConfig) (*InstanceDiff, error) {
+		l.Lock()
+		diffed = append(diffed, info.Id)
+		l.Unlock()
+		return testDiffFn(info, state, c)
+	}
+	if _, err := ctx.Plan(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	sort.Strings(diffed)
+	expected = []string{
+		"aws_elb.meneither", "aws_instance.me", "aws_instance.notme", "aws_vpc.metoo",
+	}
+	if !reflect.DeepEqual(diffed, expected) {
+		t.Fatalf("expected: %#v, got: %#v", expected, diffed)
+	}
+}
+
+func TestContext2Refresh_skipInvalid(t *testing.T) {
+	m := testModule(t, "refresh-targeted")
+	for _, addr := range []string{"data.aws_ami.foo", "module.foo", "aws_instance.foo[bar]"} {
+		_, err := NewContext(&ContextOpts{
+			Module:      m,
+			RefreshSkip: []string{addr},
+		})
+		if err == nil {
+			t.Fatalf("%s: should error", addr)
+		}
+	}
+}
+
 func TestContext2Refresh_targetedCount(t *testing.T) {
 	p := testProvider("aws")
 	m := testModule(t, "refresh-targeted-count")
@@ -279,6 +371,11 @@ func TestContext2Refresh_ignoreUncreated(t *testing.T) {
 func TestContext2Refresh_hook(t *testing.T) {
 	h := new(MockHook)
 	p := testProvider("aws")
+	p.RefreshFn = func(info *InstanceInfo, s *InstanceState) (*InstanceState, error) {
+		s = s.DeepCopy()
+		s.Attributes = map[string]string{"refreshed": "true"}
+		return s, nil
+	}
 	m := testModule(t, "refresh-basic")
 	ctx := testContext2(t, &ContextOpts{
 		Module: m,
@@ -312,6 +409,16 @@ func TestContext2Refresh_hook(t *testing.T) {
 	if !h.PostRefreshCalled {
 		t.Fatal("should be called")
 	}
+
+	// The state is updated after each resource is refreshed, so that it
+	// can be saved as the refresh goes
+	if !h.PostStateUpdateCalled {
+		t.Fatal("should be called")
+	}
+	rs := h.PostStateUpdateState.RootModule().Resources["aws_instance.web"]
+	if rs == nil || rs.Primary.ID != "foo" || rs.Primary.Attributes["refreshed"] != "true" {
+		t.Fatalf("bad: %#v", rs)
+	}
 }
 
 func TestContext2Refresh_modules(t *testing.T) {
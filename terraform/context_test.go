@@ -42,6 +42,14 @@ func TestNewContextRequiredVersion(t *testing.T) {
 			false,
 		},
 
+		{
+			"prerelease doesn't match",
+			"",
+			"0.7.0-beta1",
+			"> 0.8.0",
+			false,
+		},
+
 		{
 			"module matches",
 			"context-required-version-module",
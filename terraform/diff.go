@@ -364,6 +364,10 @@ type InstanceDiff struct {
 	Destroy        bool
 	DestroyDeposed bool
 	DestroyTainted bool
+
+	// Replace is set if the resource was requested to be replaced. Like a
+	// tainted resource, it is destroyed and created again.
+	Replace bool
 }
 
 func (d *InstanceDiff) Lock()   { d.mu.Lock() }
@@ -459,6 +463,7 @@ func (d *InstanceDiff) Empty() bool {
 	return !d.Destroy &&
 		!d.DestroyTainted &&
 		!d.DestroyDeposed &&
+		!d.Replace &&
 		len(d.Attributes) == 0
 }
 
@@ -493,6 +498,7 @@ func (d *InstanceDiff) GoString() string {
 		Destroy:        d.Destroy,
 		DestroyTainted: d.DestroyTainted,
 		DestroyDeposed: d.DestroyDeposed,
+		Replace:        d.Replace,
 	})
 }
 
@@ -514,7 +520,7 @@ func (d *InstanceDiff) requiresNew() bool {
 		return false
 	}
 
-	if d.DestroyTainted {
+	if d.DestroyTainted || d.Replace {
 		return true
 	}
 
@@ -558,6 +564,20 @@ func (d *InstanceDiff) GetDestroyTainted() bool {
 	return d.DestroyTainted
 }
 
+func (d *InstanceDiff) SetReplace(b bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.Replace = b
+}
+
+func (d *InstanceDiff) GetReplace() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.Replace
+}
+
 func (d *InstanceDiff) SetDestroy(b bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
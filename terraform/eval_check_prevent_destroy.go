@@ -2,7 +2,10 @@ package terraform
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/terraform/config"
 )
 
@@ -28,11 +31,69 @@ func (n *EvalCheckPreventDestroy) Eval(ctx EvalContext) (interface{}, error) {
 		if resourceId == "" {
 			resourceId = n.Resource.Id()
 		}
+		if n.Resource.Pos.IsValid() {
+			resourceId = fmt.Sprintf("%s (%s:%d)",
+				resourceId, n.Resource.Pos.Filename, n.Resource.Pos.Line)
+		}
 
-		return nil, fmt.Errorf(preventDestroyErrStr, resourceId)
+		return nil, &PreventDestroyError{Resources: []string{resourceId}}
 	}
 
 	return nil, nil
 }
 
-const preventDestroyErrStr = `%s: the plan would destroy this resource, but it currently has lifecycle.prevent_destroy set to true. To avoid this error and continue with the plan, either disable lifecycle.prevent_destroy or adjust the scope of the plan using the -target flag.`
+// PreventDestroyError is the error when a plan would destroy resources
+// that have lifecycle.prevent_destroy set. The errors of each resource
+// are merged into one when planning, so that all of them are listed
+// together.
+type PreventDestroyError struct {
+	// Resources are the addresses of the resources, each followed by
+	// where it is declared in the configuration if that is known.
+	Resources []string
+}
+
+func (e *PreventDestroyError) Error() string {
+	return fmt.Sprintf(preventDestroyErrStr, strings.Join(e.Resources, "\n  "))
+}
+
+// mergePreventDestroyErrors merges the PreventDestroyErrors among the
+// errors of a walk into one, returning it alone if there are no others.
+func mergePreventDestroyErrors(err error) error {
+	merr, ok := err.(*multierror.Error)
+	if !ok {
+		return err
+	}
+
+	var others []error
+	var result *PreventDestroyError
+	for _, e := range merr.Errors {
+		pe, ok := e.(*PreventDestroyError)
+		if !ok {
+			others = append(others, e)
+			continue
+		}
+
+		if result == nil {
+			result = new(PreventDestroyError)
+		}
+		result.Resources = append(result.Resources, pe.Resources...)
+	}
+	if result == nil {
+		return err
+	}
+
+	sort.Strings(result.Resources)
+	if len(others) == 0 {
+		return result
+	}
+
+	return multierror.Append(result, others...)
+}
+
+const preventDestroyErrStr = `The plan would destroy these resources, but they have lifecycle.prevent_destroy
+set to true:
+
+  %s
+
+To avoid this error and continue with the plan, either disable
+lifecycle.prevent_destroy or adjust the scope of the plan using the -target flag.`
@@ -77,4 +77,13 @@ type EvalContext interface {
 	// State returns the global state as well as the lock that should
 	// be used to modify that state.
 	State() (*State, *sync.RWMutex)
+
+	// SkipRefresh returns whether the given resource should not be
+	// refreshed, keeping its state as it is.
+	SkipRefresh(*InstanceInfo) bool
+
+	// ForceReplace returns whether the given resource should be planned
+	// to be destroyed and created again, even if its diff doesn't
+	// require it.
+	ForceReplace(*InstanceInfo) bool
 }
@@ -39,6 +39,9 @@ type BuiltinEvalContext struct {
 	DiffLock            *sync.RWMutex
 	StateValue          *State
 	StateLock           *sync.RWMutex
+	RefreshSkip         []*ResourceAddress
+	Replace             []*ResourceAddress
+	SkipProviderConfig  bool
 
 	once sync.Once
 }
@@ -91,6 +94,11 @@ func (ctx *BuiltinEvalContext) InitProvider(n string) (ResourceProvider, error)
 		return nil, err
 	}
 
+	// Providers are only configured when they're first needed
+	if ctx.SkipProviderConfig {
+		p = &lazyConfigResourceProvider{ResourceProvider: p}
+	}
+
 	ctx.ProviderCache[key] = p
 	return p, nil
 }
@@ -330,5 +338,37 @@ func (ctx *BuiltinEvalContext) State() (*State, *sync.RWMutex) {
 	return ctx.StateValue, ctx.StateLock
 }
 
+func (ctx *BuiltinEvalContext) SkipRefresh(info *InstanceInfo) bool {
+	return instanceMatches(info, ctx.RefreshSkip)
+}
+
+func (ctx *BuiltinEvalContext) ForceReplace(info *InstanceInfo) bool {
+	return instanceMatches(info, ctx.Replace)
+}
+
+// instanceMatches returns true if the resource of info matches any of
+// the given addresses.
+func instanceMatches(info *InstanceInfo, addrs []*ResourceAddress) bool {
+	if len(addrs) == 0 {
+		return false
+	}
+
+	addr, err := parseResourceAddressInternal(info.Id)
+	if err != nil {
+		return false
+	}
+	if len(info.ModulePath) > 1 {
+		addr.Path = info.ModulePath[1:]
+	}
+
+	for _, other := range addrs {
+		if other.Equals(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (ctx *BuiltinEvalContext) init() {
 }
@@ -83,6 +83,14 @@ type MockEvalContext struct {
 	StateCalled bool
 	StateState  *State
 	StateLock   *sync.RWMutex
+
+	SkipRefreshCalled bool
+	SkipRefreshInfo   *InstanceInfo
+	SkipRefreshResult bool
+
+	ForceReplaceCalled bool
+	ForceReplaceInfo   *InstanceInfo
+	ForceReplaceResult bool
 }
 
 func (c *MockEvalContext) Hook(fn func(Hook) (HookAction, error)) error {
@@ -198,3 +206,15 @@ func (c *MockEvalContext) State() (*State, *sync.RWMutex) {
 	c.StateCalled = true
 	return c.StateState, c.StateLock
 }
+
+func (c *MockEvalContext) SkipRefresh(info *InstanceInfo) bool {
+	c.SkipRefreshCalled = true
+	c.SkipRefreshInfo = info
+	return c.SkipRefreshResult
+}
+
+func (c *MockEvalContext) ForceReplace(info *InstanceInfo) bool {
+	c.ForceReplaceCalled = true
+	c.ForceReplaceInfo = info
+	return c.ForceReplaceResult
+}
@@ -97,9 +97,14 @@ func (n *EvalDiff) Eval(ctx EvalContext) (interface{}, error) {
 		return nil, err
 	}
 
+	// A resource requested to be replaced is diffed as if it is created,
+	// like providers do for tainted resources, so that the diff matches
+	// the one made when it is created again during apply.
+	replace := state != nil && state.ID != "" && ctx.ForceReplace(n.Info)
+
 	// The state for the diff must never be nil
 	diffState := state
-	if diffState == nil {
+	if diffState == nil || replace {
 		diffState = new(InstanceState)
 	}
 	diffState.init()
@@ -130,6 +135,18 @@ func (n *EvalDiff) Eval(ctx EvalContext) (interface{}, error) {
 		diff.SetTainted((*n.Diff).GetDestroyTainted())
 	}
 
+	// Preserve the Replace flag, and set it if the resource was requested
+	// to be replaced.
+	if n.Diff != nil {
+		diff.SetReplace((*n.Diff).GetReplace())
+	}
+	if replace {
+		diff.SetReplace(true)
+		for k, attr := range diff.Attributes {
+			attr.Old = state.Attributes[k]
+		}
+	}
+
 	// Require a destroy if there is an ID and it requires new.
 	if diff.RequiresNew() && state != nil && state.ID != "" {
 		diff.SetDestroy(true)
@@ -200,7 +217,7 @@ func (n *EvalDiff) processIgnoreChanges(diff *InstanceDiff) error {
 
 	// If the resource has been tainted then we don't process ignore changes
 	// since we MUST recreate the entire resource.
-	if diff.DestroyTainted {
+	if diff.DestroyTainted || diff.Replace {
 		return nil
 	}
 
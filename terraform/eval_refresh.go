@@ -25,6 +25,16 @@ func (n *EvalRefresh) Eval(ctx EvalContext) (interface{}, error) {
 		return nil, nil
 	}
 
+	// If the resource is skipped, its state passes through untouched
+	if ctx.SkipRefresh(n.Info) {
+		log.Printf("[INFO] refresh: %s: skipped, not refreshing", n.Info.HumanId())
+		if n.Output != nil {
+			*n.Output = state
+		}
+
+		return nil, nil
+	}
+
 	// Call pre-refresh hook
 	err := ctx.Hook(func(h Hook) (HookAction, error) {
 		return h.PreRefresh(n.Info, state)
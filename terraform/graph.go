@@ -264,8 +264,11 @@ func (g *Graph) walk(walker GraphWalker) error {
 			}
 
 			// Modify the return value to show the error
+			stack := debug.Stack()
 			rerr = fmt.Errorf("vertex %q captured panic: %s\n\n%s",
-				dag.VertexName(v), err, debug.Stack())
+				dag.VertexName(v), err, stack)
+			log.Printf("[ERROR] vertex '%s.%s' captured panic: %s\n\n%s",
+				path, dag.VertexName(v), err, stack)
 
 			// Call the panic wrapper
 			panicwrap.Panic(v, err)
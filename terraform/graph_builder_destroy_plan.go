@@ -20,6 +20,10 @@ type DestroyPlanGraphBuilder struct {
 	// Targets are resources to target
 	Targets []string
 
+	// OverridePreventDestroy plans to destroy resources even if they have
+	// lifecycle.prevent_destroy set.
+	OverridePreventDestroy bool
+
 	// Validate will do structural validation of the graph.
 	Validate bool
 }
@@ -37,7 +41,8 @@ func (b *DestroyPlanGraphBuilder) Build(path []string) (*Graph, error) {
 func (b *DestroyPlanGraphBuilder) Steps() []GraphTransformer {
 	concreteResource := func(a *NodeAbstractResource) dag.Vertex {
 		return &NodePlanDestroyableResource{
-			NodeAbstractResource: a,
+			NodeAbstractResource:   a,
+			OverridePreventDestroy: b.OverridePreventDestroy,
 		}
 	}
 
@@ -79,6 +79,9 @@ func (w *ContextGraphWalker) EnterPath(path []string) EvalContext {
 		DiffLock:            &w.Context.diffLock,
 		StateValue:          w.Context.state,
 		StateLock:           &w.Context.stateLock,
+		RefreshSkip:         w.Context.refreshSkip,
+		Replace:             w.Context.forceReplace,
+		SkipProviderConfig:  w.Context.skipProviderConfig,
 		Interpolater: &Interpolater{
 			Operation:          w.Operation,
 			Module:             w.Context.module,
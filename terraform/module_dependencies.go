@@ -0,0 +1,206 @@
+package terraform
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/config/module"
+)
+
+// ModuleDependencies are the providers required by a module, along with
+// the dependencies of the modules it contains. They are found with
+// ModuleTreeDependencies.
+type ModuleDependencies struct {
+	// Name is the name of the module, which is empty for the root module,
+	// and Path the path of the module from the root module.
+	Name string
+	Path []string
+
+	// Providers are the providers required by the module itself, by the
+	// name of the provider.
+	Providers map[string]ProviderDependency
+
+	// Children are the dependencies of the child modules, sorted by name.
+	Children []*ModuleDependencies
+}
+
+// ProviderDependency is a provider required by a module.
+type ProviderDependency struct {
+	// Constraint is the version constraint of the provider given by the
+	// provider blocks of the module, or "" if any version will do.
+	Constraint string
+
+	// Reason is why the module requires the provider.
+	Reason ProviderDependencyReason
+}
+
+// ProviderDependencyReason is why a module requires a provider.
+type ProviderDependencyReason int
+
+const (
+	// ProviderDependencyExplicit is a provider configured with a provider
+	// block in the module.
+	ProviderDependencyExplicit ProviderDependencyReason = iota
+
+	// ProviderDependencyImplicit is a provider used by the resources of
+	// the module without being configured anywhere.
+	ProviderDependencyImplicit
+
+	// ProviderDependencyInherited is a provider used by the resources of
+	// the module that is configured in one of its parent modules.
+	ProviderDependencyInherited
+
+	// ProviderDependencyFromState is a provider only used by resources of
+	// the module that are in the state but no longer in the configuration,
+	// which is needed to destroy them.
+	ProviderDependencyFromState
+)
+
+// ModuleTreeDependencies returns the providers required by every module of
+// the given module tree and state. The state may be nil. Modules that are
+// only in the state are included, since their providers are needed to
+// destroy their resources.
+func ModuleTreeDependencies(root *module.Tree, state *State) *ModuleDependencies {
+	deps := moduleTreeDependencies(root, nil, nil)
+
+	if state != nil {
+		for _, ms := range state.Modules {
+			// Unlike the paths of module states, the paths of the
+			// dependencies don't include the root module.
+			m := deps.child(normalizeModulePath(ms.Path)[1:])
+			for k, rs := range ms.Resources {
+				key, err := ParseResourceStateKey(k)
+				if err != nil {
+					continue
+				}
+
+				name := providerName(resourceProvider(key.Type, rs.Provider))
+				if _, ok := m.Providers[name]; !ok {
+					m.Providers[name] = ProviderDependency{
+						Reason: ProviderDependencyFromState,
+					}
+				}
+			}
+		}
+	}
+
+	return deps
+}
+
+// moduleTreeDependencies returns the dependencies of the module tree t at
+// the given path, where inherited are the providers configured by the
+// parents of the module.
+func moduleTreeDependencies(t *module.Tree, path []string, inherited map[string]struct{}) *ModuleDependencies {
+	deps := &ModuleDependencies{
+		Path:      path,
+		Providers: make(map[string]ProviderDependency),
+	}
+	if len(path) > 0 {
+		deps.Name = path[len(path)-1]
+	}
+	if t == nil {
+		return deps
+	}
+
+	conf := t.Config()
+	if conf == nil {
+		conf = &config.Config{}
+	}
+
+	// The constraints of all the provider blocks of the same provider,
+	// such as those with different aliases, must all be met.
+	constraints := make(map[string][]string)
+	for _, pc := range conf.ProviderConfigs {
+		if pc.Version != "" {
+			constraints[pc.Name] = append(constraints[pc.Name], pc.Version)
+		}
+		deps.Providers[pc.Name] = ProviderDependency{
+			Constraint: strings.Join(constraints[pc.Name], ", "),
+			Reason:     ProviderDependencyExplicit,
+		}
+	}
+
+	for _, r := range conf.Resources {
+		name := providerName(resourceProvider(r.Type, r.Provider))
+		if _, ok := deps.Providers[name]; ok {
+			continue
+		}
+
+		reason := ProviderDependencyImplicit
+		if _, ok := inherited[name]; ok {
+			reason = ProviderDependencyInherited
+		}
+		deps.Providers[name] = ProviderDependency{Reason: reason}
+	}
+
+	// The children inherit the providers configured here as well as those
+	// configured by our own parents.
+	childInherited := make(map[string]struct{})
+	for name := range inherited {
+		childInherited[name] = struct{}{}
+	}
+	for _, pc := range conf.ProviderConfigs {
+		childInherited[pc.Name] = struct{}{}
+	}
+
+	children := t.Children()
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		childPath := make([]string, len(path), len(path)+1)
+		copy(childPath, path)
+		childPath = append(childPath, name)
+
+		deps.Children = append(deps.Children,
+			moduleTreeDependencies(children[name], childPath, childInherited))
+	}
+
+	return deps
+}
+
+// child returns the dependencies of the module at the given path from this
+// module, adding empty dependencies for the modules that aren't there yet.
+func (d *ModuleDependencies) child(path []string) *ModuleDependencies {
+	if len(path) == 0 {
+		return d
+	}
+
+	for _, c := range d.Children {
+		if c.Name == path[0] {
+			return c.child(path[1:])
+		}
+	}
+
+	childPath := make([]string, len(d.Path), len(d.Path)+1)
+	copy(childPath, d.Path)
+	childPath = append(childPath, path[0])
+	c := &ModuleDependencies{
+		Name:      path[0],
+		Path:      childPath,
+		Providers: make(map[string]ProviderDependency),
+	}
+
+	// Keep the children sorted by name
+	idx := sort.Search(len(d.Children), func(i int) bool {
+		return d.Children[i].Name >= c.Name
+	})
+	d.Children = append(d.Children, nil)
+	copy(d.Children[idx+1:], d.Children[idx:])
+	d.Children[idx] = c
+
+	return c.child(path[1:])
+}
+
+// providerName returns the name of the provider of the given provider
+// configuration name, which may include an alias such as "aws.west".
+func providerName(n string) string {
+	if idx := strings.IndexRune(n, '.'); idx >= 0 {
+		return n[:idx]
+	}
+
+	return n
+}
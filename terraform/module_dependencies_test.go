@@ -0,0 +1,88 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModuleTreeDependencies(t *testing.T) {
+	mod := testModule(t, "module-deps")
+	state := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{Type: "aws_instance"},
+					"test_instance.gone": &ResourceState{
+						Type: "test_instance",
+					},
+				},
+			},
+			&ModuleState{
+				Path: []string{"root", "child", "removed"},
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{
+						Type:     "aws_instance",
+						Provider: "aws.west",
+					},
+				},
+			},
+		},
+	}
+
+	expected := &ModuleDependencies{
+		Providers: map[string]ProviderDependency{
+			"aws": ProviderDependency{
+				Constraint: "~> 1.0, >= 1.0.1",
+				Reason:     ProviderDependencyExplicit,
+			},
+			"null": ProviderDependency{Reason: ProviderDependencyImplicit},
+			"test": ProviderDependency{Reason: ProviderDependencyFromState},
+		},
+		Children: []*ModuleDependencies{
+			&ModuleDependencies{
+				Name: "child",
+				Path: []string{"child"},
+				Providers: map[string]ProviderDependency{
+					"aws": ProviderDependency{Reason: ProviderDependencyInherited},
+					"template": ProviderDependency{
+						Constraint: ">= 0.1",
+						Reason:     ProviderDependencyExplicit,
+					},
+				},
+				Children: []*ModuleDependencies{
+					&ModuleDependencies{
+						Name: "grandchild",
+						Path: []string{"child", "grandchild"},
+						Providers: map[string]ProviderDependency{
+							"consul":   ProviderDependency{Reason: ProviderDependencyImplicit},
+							"template": ProviderDependency{Reason: ProviderDependencyInherited},
+						},
+					},
+					&ModuleDependencies{
+						Name: "removed",
+						Path: []string{"child", "removed"},
+						Providers: map[string]ProviderDependency{
+							"aws": ProviderDependency{Reason: ProviderDependencyFromState},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	actual := ModuleTreeDependencies(mod, state)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestModuleTreeDependencies_noState(t *testing.T) {
+	actual := ModuleTreeDependencies(testModule(t, "module-deps"), nil)
+	if _, ok := actual.Providers["test"]; ok {
+		t.Fatalf("bad: %#v", actual.Providers)
+	}
+	if len(actual.Children) != 1 || len(actual.Children[0].Children) != 1 {
+		t.Fatalf("bad: %#v", actual.Children)
+	}
+}
@@ -4,6 +4,9 @@ package terraform
 // it is ready to be applied and is represented by a diff.
 type NodePlanDestroyableResource struct {
 	*NodeAbstractResource
+
+	// OverridePreventDestroy skips checking lifecycle.prevent_destroy.
+	OverridePreventDestroy bool
 }
 
 // GraphNodeDestroyer
@@ -40,9 +43,15 @@ func (n *NodePlanDestroyableResource) EvalTree() EvalNode {
 				State:  &state,
 				Output: &diff,
 			},
-			&EvalCheckPreventDestroy{
-				Resource: n.Config,
-				Diff:     &diff,
+			&EvalIf{
+				If: func(ctx EvalContext) (bool, error) {
+					return !n.OverridePreventDestroy, nil
+				},
+				Then: &EvalCheckPreventDestroy{
+					Resource:   n.Config,
+					ResourceId: stateId,
+					Diff:       &diff,
+				},
 			},
 			&EvalWriteDiff{
 				Name: stateId,
@@ -2,7 +2,10 @@ package terraform
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +21,24 @@ func init() {
 	gob.Register(make(map[string]string))
 }
 
+// PlanMode is the kind of plan that a Context creates.
+type PlanMode byte
+
+const (
+	// PlanModeNormal plans the changes that make the infrastructure match
+	// the configuration.
+	PlanModeNormal PlanMode = iota
+
+	// PlanModeDestroy plans to destroy everything the state manages. This
+	// is the same as ContextOpts.Destroy, which it replaces over time.
+	PlanModeDestroy
+
+	// PlanModeRefreshOnly plans no changes to the infrastructure at all.
+	// The plan only has the refreshed state, and applying it saves that
+	// state to record the changes made outside of Terraform.
+	PlanModeRefreshOnly
+)
+
 // Plan represents a single Terraform execution plan, which contains
 // all the information necessary to make an infrastructure change.
 type Plan struct {
@@ -27,14 +48,40 @@ type Plan struct {
 	Vars    map[string]interface{}
 	Targets []string
 
+	// Destroy is true if this is a plan to destroy everything it manages,
+	// so that applying it later destroys the resources in the same way as
+	// applying right after planning.
+	Destroy bool
+
+	// Mode is the kind of plan this is. Destroy is still set for destroy
+	// plans, so that older versions read them the same.
+	Mode PlanMode
+
+	// TerraformVersion is the version of Terraform that created the plan.
+	// This is empty for plans created before the version was recorded.
+	TerraformVersion string
+
+	// Outputs are the values the root module outputs will have once the
+	// plan is applied, as far as they are known when planning. Values that
+	// aren't known until apply are config.UnknownVariableValue. This is nil
+	// for destroy plans and for plans created before outputs were recorded.
+	Outputs map[string]*OutputState
+
+	// PriorOutputs are the values of the root module outputs before the
+	// plan, and before the state was refreshed if it was. These are what
+	// Outputs are compared with to show the changes to outputs.
+	PriorOutputs map[string]*OutputState
+
 	once sync.Once
 }
 
 // Context returns a Context with the data encapsulated in this plan.
 //
 // The following fields in opts are overridden by the plan: Config,
-// Diff, State, Variables.
+// Destroy, Diff, PlanMode, State, Targets, Variables.
 func (p *Plan) Context(opts *ContextOpts) (*Context, error) {
+	opts.Destroy = p.Destroy
+	opts.PlanMode = p.Mode
 	opts.Diff = p.Diff
 	opts.Module = p.Module
 	opts.State = p.State
@@ -48,6 +95,38 @@ func (p *Plan) Context(opts *ContextOpts) (*Context, error) {
 	return NewContext(opts)
 }
 
+// Id returns an identifier for this plan that is derived from its contents.
+// Identical plans always have the same Id, and any change to the diff,
+// state, variables or targets of the plan results in a different Id. This
+// makes it possible to refer to a plan file by its contents, for example
+// to verify that the plan being applied is the plan that was reviewed.
+//
+// The binary plan format can't be hashed directly since gob doesn't encode
+// maps in a stable order, so the hash is computed over JSON instead. The
+// plan is round-tripped through the plan format first so that a plan and
+// the same plan read back from a plan file have the same Id: gob doesn't
+// distinguish between nil and empty values, for example.
+func (p *Plan) Id() (string, error) {
+	var buf bytes.Buffer
+	if err := WritePlan(p, &buf); err != nil {
+		return "", fmt.Errorf("error computing plan id: %s", err)
+	}
+	plan, err := ReadPlan(&buf)
+	if err != nil {
+		return "", fmt.Errorf("error computing plan id: %s", err)
+	}
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, v := range []interface{}{plan.Diff, plan.State, plan.Vars, plan.Targets} {
+		if err := enc.Encode(v); err != nil {
+			return "", fmt.Errorf("error computing plan id: %s", err)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func (p *Plan) String() string {
 	buf := new(bytes.Buffer)
 	buf.WriteString("DIFF:\n\n")
@@ -53,6 +53,10 @@ func TestReadWritePlan(t *testing.T) {
 		Vars: map[string]interface{}{
 			"foo": "bar",
 		},
+		Outputs: map[string]*OutputState{
+			"foo": &OutputState{Type: "list", Value: []interface{}{"bar"}},
+		},
+		TerraformVersion: "0.1.0",
 	}
 
 	buf := new(bytes.Buffer)
@@ -70,4 +74,99 @@ func TestReadWritePlan(t *testing.T) {
 	if actualStr != expectedStr {
 		t.Fatalf("bad:\n\n%s\n\nexpected:\n\n%s", actualStr, expectedStr)
 	}
+
+	if actual.TerraformVersion != plan.TerraformVersion {
+		t.Fatalf("bad: %q", actual.TerraformVersion)
+	}
+	if !actual.Outputs["foo"].Equal(plan.Outputs["foo"]) {
+		t.Fatalf("bad: %#v", actual.Outputs)
+	}
+}
+
+func TestPlanId(t *testing.T) {
+	newPlan := func(newValue string) *Plan {
+		return &Plan{
+			Diff: &Diff{
+				Modules: []*ModuleDiff{
+					&ModuleDiff{
+						Path: rootModulePath,
+						Resources: map[string]*InstanceDiff{
+							"nodeA": &InstanceDiff{
+								Attributes: map[string]*ResourceAttrDiff{
+									"foo": &ResourceAttrDiff{
+										Old: "foo",
+										New: newValue,
+									},
+									"bar": &ResourceAttrDiff{
+										Old:         "foo",
+										NewComputed: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			State: &State{
+				Lineage: "lineage",
+				Modules: []*ModuleState{
+					&ModuleState{
+						Path:      rootModulePath,
+						Resources: map[string]*ResourceState{},
+						Outputs:   map[string]*OutputState{},
+					},
+				},
+			},
+			Vars: map[string]interface{}{
+				"foo": "bar",
+				"baz": map[string]interface{}{"a": "b", "c": "d"},
+			},
+		}
+	}
+
+	id1, err := newPlan("bar").Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id1 == "" {
+		t.Fatal("id should not be empty")
+	}
+
+	// Identical plans must have the same id, no matter how many times
+	// it is computed.
+	for i := 0; i < 10; i++ {
+		id2, err := newPlan("bar").Id()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if id1 != id2 {
+			t.Fatalf("ids should match: %s != %s", id1, id2)
+		}
+	}
+
+	// The id must survive a round trip through the plan file format
+	buf := new(bytes.Buffer)
+	if err := WritePlan(newPlan("bar"), buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	actual, err := ReadPlan(buf)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	id2, err := actual.Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id1 != id2 {
+		t.Fatalf("ids should match after round trip: %s != %s", id1, id2)
+	}
+
+	// A change in the diff must change the id
+	id3, err := newPlan("baz").Id()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id1 == id3 {
+		t.Fatalf("ids should differ: %s", id1)
+	}
 }
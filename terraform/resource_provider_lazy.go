@@ -0,0 +1,113 @@
+package terraform
+
+import (
+	"sync"
+)
+
+// lazyConfigResourceProvider is a ResourceProvider that defers configuring
+// the provider it wraps until it's called for something that needs the
+// configuration, such as a diff or a refresh. Until then, Configure only
+// records the configuration. If configuring fails, every call that needs
+// it fails with the error from Configure.
+//
+// This lets a plan that doesn't call the provider at all, such as a
+// destroy plan without a refresh, be made without valid provider
+// credentials.
+type lazyConfigResourceProvider struct {
+	ResourceProvider
+
+	lock       sync.Mutex
+	config     *ResourceConfig
+	configured bool
+	err        error
+}
+
+func (p *lazyConfigResourceProvider) Configure(c *ResourceConfig) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.config = c
+	return nil
+}
+
+// configure configures the wrapped provider with the recorded
+// configuration the first time it's called, and returns the result of
+// that every time.
+func (p *lazyConfigResourceProvider) configure() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if !p.configured && p.config != nil {
+		p.configured = true
+		p.err = p.ResourceProvider.Configure(p.config)
+	}
+
+	return p.err
+}
+
+func (p *lazyConfigResourceProvider) Apply(
+	info *InstanceInfo,
+	s *InstanceState,
+	d *InstanceDiff) (*InstanceState, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.Apply(info, s, d)
+}
+
+func (p *lazyConfigResourceProvider) Diff(
+	info *InstanceInfo,
+	s *InstanceState,
+	c *ResourceConfig) (*InstanceDiff, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.Diff(info, s, c)
+}
+
+func (p *lazyConfigResourceProvider) Refresh(
+	info *InstanceInfo, s *InstanceState) (*InstanceState, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.Refresh(info, s)
+}
+
+func (p *lazyConfigResourceProvider) ImportState(
+	info *InstanceInfo, id string) ([]*InstanceState, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.ImportState(info, id)
+}
+
+func (p *lazyConfigResourceProvider) ReadDataDiff(
+	info *InstanceInfo, c *ResourceConfig) (*InstanceDiff, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.ReadDataDiff(info, c)
+}
+
+func (p *lazyConfigResourceProvider) ReadDataApply(
+	info *InstanceInfo, d *InstanceDiff) (*InstanceState, error) {
+	if err := p.configure(); err != nil {
+		return nil, err
+	}
+
+	return p.ResourceProvider.ReadDataApply(info, d)
+}
+
+// Close closes the wrapped provider if it can be closed.
+func (p *lazyConfigResourceProvider) Close() error {
+	if c, ok := p.ResourceProvider.(ResourceProviderCloser); ok {
+		return c.Close()
+	}
+
+	return nil
+}
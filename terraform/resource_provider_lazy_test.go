@@ -0,0 +1,69 @@
+package terraform
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLazyConfigResourceProvider_impl(t *testing.T) {
+	var _ ResourceProvider = new(lazyConfigResourceProvider)
+	var _ ResourceProviderCloser = new(lazyConfigResourceProvider)
+}
+
+func TestLazyConfigResourceProvider(t *testing.T) {
+	p := new(MockResourceProvider)
+	lazy := &lazyConfigResourceProvider{ResourceProvider: p}
+
+	config := testResourceConfig(t, map[string]interface{}{"foo": "bar"})
+	if err := lazy.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.ConfigureCalled {
+		t.Fatal("configure should not be called")
+	}
+
+	// Validating doesn't need the configuration
+	lazy.Validate(config)
+	if p.ConfigureCalled {
+		t.Fatal("configure should not be called")
+	}
+
+	info := &InstanceInfo{Type: "foo"}
+	if _, err := lazy.Diff(info, nil, config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !p.ConfigureCalled || p.ConfigureConfig != config || !p.DiffCalled {
+		t.Fatalf("bad: %#v", p)
+	}
+
+	// It's only configured once
+	p.ConfigureCalled = false
+	if _, err := lazy.Refresh(info, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if p.ConfigureCalled {
+		t.Fatal("configure should not be called again")
+	}
+}
+
+func TestLazyConfigResourceProvider_configureError(t *testing.T) {
+	p := new(MockResourceProvider)
+	p.ConfigureReturnError = fmt.Errorf("no credentials")
+	lazy := &lazyConfigResourceProvider{ResourceProvider: p}
+
+	if err := lazy.Configure(testResourceConfig(t, nil)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Every call that needs the configuration fails with the error
+	info := &InstanceInfo{Type: "foo"}
+	for i := 0; i < 2; i++ {
+		_, err := lazy.Apply(info, nil, nil)
+		if err == nil || err.Error() != "no credentials" {
+			t.Fatalf("%d: bad: %v", i, err)
+		}
+	}
+	if p.ApplyCalled {
+		t.Fatal("apply should not be called")
+	}
+}
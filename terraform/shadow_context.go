@@ -44,6 +44,7 @@ func newShadowContext(c *Context) (*Context, *Context, Shadow) {
 	shadow := &Context{
 		components: componentsShadow,
 		destroy:    c.destroy,
+		planMode:   c.planMode,
 		diff:       c.diff.DeepCopy(),
 		hooks:      nil,
 		module:     c.module,
@@ -51,6 +52,12 @@ func newShadowContext(c *Context) (*Context, *Context, Shadow) {
 		targets:    targetRaw.([]string),
 		variables:  varRaw.(map[string]interface{}),
 
+		// The addresses aren't modified, so they don't need a copy
+		refreshSkip:        c.refreshSkip,
+		forceReplace:       c.forceReplace,
+		overridePrevent:    c.overridePrevent,
+		skipProviderConfig: c.skipProviderConfig,
+
 		// NOTE(mitchellh): This is not going to work for shadows that are
 		// testing that input results in the proper end state. At the time
 		// of writing, input is not used in any state-changing graph
@@ -85,6 +92,11 @@ func newShadowContext(c *Context) (*Context, *Context, Shadow) {
 		uiInput:   c.uiInput,
 		variables: c.variables,
 
+		refreshSkip:        c.refreshSkip,
+		forceReplace:       c.forceReplace,
+		overridePrevent:    c.overridePrevent,
+		skipProviderConfig: c.skipProviderConfig,
+
 		// l - no copy
 		parallelSem:         c.parallelSem,
 		providerInputConfig: c.providerInputConfig,
@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform/config"
+	"github.com/hashicorp/terraform/helper/textfile"
 	"github.com/mitchellh/copystructure"
 	"github.com/satori/go.uuid"
 )
@@ -1122,6 +1123,8 @@ func (m *ModuleState) prune() {
 }
 
 func (m *ModuleState) sort() {
+	sort.Strings(m.Dependencies)
+
 	for _, v := range m.Resources {
 		v.sort()
 	}
@@ -1730,7 +1733,8 @@ func (e *EphemeralState) DeepCopy() *EphemeralState {
 }
 
 type jsonStateVersionIdentifier struct {
-	Version int `json:"version"`
+	Version   int    `json:"version"`
+	TFVersion string `json:"terraform_version"`
 }
 
 // Check if this is a V0 format - the magic bytes at the start of the file
@@ -1767,10 +1771,12 @@ func ReadState(src io.Reader) (*State, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Reading state file failed: %v", err)
 	}
+	jsonBytes = textfile.StripBOM(jsonBytes)
 
 	versionIdentifier := &jsonStateVersionIdentifier{}
 	if err := json.Unmarshal(jsonBytes, versionIdentifier); err != nil {
-		return nil, fmt.Errorf("Decoding state file version failed: %v", err)
+		return nil, fmt.Errorf("Decoding state file version failed: %v%s",
+			err, textfile.Hint(jsonBytes))
 	}
 
 	var result *State
@@ -1816,8 +1822,17 @@ func ReadState(src io.Reader) (*State, error) {
 
 		result = v3State
 	default:
-		return nil, fmt.Errorf("Terraform %s does not support state version %d, please update.",
-			SemVersion.String(), versionIdentifier.Version)
+		// Name the version that wrote the state so that it's clear which
+		// version is needed to use it.
+		writtenBy := ""
+		if v := versionIdentifier.TFVersion; v != "" {
+			writtenBy = fmt.Sprintf(
+				"\n\nThe state was written by Terraform %s. Please use at least\n"+
+					"that version of Terraform with this state.", v)
+		}
+
+		return nil, fmt.Errorf("Terraform %s does not support state version %d, please update.%s",
+			SemVersion.String(), versionIdentifier.Version, writtenBy)
 	}
 
 	// If we reached this place we must have a result set
@@ -1959,7 +1974,9 @@ func WriteState(d *State, dst io.Writer) error {
 		}
 	}
 
-	// Encode the data in a human-friendly way
+	// Encode the data in a human-friendly way. Maps are encoded with their
+	// keys sorted and the lists are sorted above, so writing the same state
+	// always results in the same bytes.
 	data, err := json.MarshalIndent(d, "", "    ")
 	if err != nil {
 		return fmt.Errorf("Failed to encode state: %s", err)
@@ -0,0 +1,52 @@
+package terraform
+
+import (
+	"sort"
+	"strings"
+)
+
+// StateDrift compares the state from before a refresh with the state after
+// it and returns the addresses of the managed resources whose primary
+// instance was changed or removed outside of Terraform. Both lists are
+// sorted.
+//
+// Data sources are read again on every refresh, so their changes aren't
+// drift and are ignored.
+func StateDrift(prior, current *State) (changed, removed []string) {
+	if prior == nil {
+		return nil, nil
+	}
+
+	for _, pm := range prior.Modules {
+		var cm *ModuleState
+		if current != nil {
+			cm = current.ModuleByPath(pm.Path)
+		}
+
+		for k, pr := range pm.Resources {
+			if strings.HasPrefix(k, "data.") || pr.Primary == nil {
+				continue
+			}
+
+			info := &InstanceInfo{Id: k, ModulePath: pm.Path}
+			addr := info.HumanId()
+
+			var cr *ResourceState
+			if cm != nil {
+				cr = cm.Resources[k]
+			}
+			if cr == nil || cr.Primary == nil {
+				removed = append(removed, addr)
+				continue
+			}
+
+			if !pr.Primary.Equal(cr.Primary) {
+				changed = append(changed, addr)
+			}
+		}
+	}
+
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return changed, removed
+}
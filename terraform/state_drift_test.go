@@ -0,0 +1,96 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStateDrift(t *testing.T) {
+	prior := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: rootModulePath,
+				Resources: map[string]*ResourceState{
+					"aws_instance.changed": &ResourceState{
+						Type: "aws_instance",
+						Primary: &InstanceState{
+							ID:         "foo",
+							Attributes: map[string]string{"ami": "bar"},
+						},
+					},
+					"aws_instance.removed": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "foo"},
+					},
+					"aws_instance.same": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "foo"},
+					},
+					"data.aws_ami.foo": &ResourceState{
+						Type:    "aws_ami",
+						Primary: &InstanceState{ID: "foo"},
+					},
+				},
+			},
+			&ModuleState{
+				Path: []string{"root", "child"},
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "foo"},
+					},
+				},
+			},
+		},
+	}
+
+	current := prior.DeepCopy()
+	root := current.RootModule()
+	root.Resources["aws_instance.changed"].Primary.Attributes["ami"] = "baz"
+	root.Resources["data.aws_ami.foo"].Primary.ID = "bar"
+	delete(root.Resources, "aws_instance.removed")
+	current.ModuleByPath([]string{"root", "child"}).Resources["aws_instance.foo"].Primary.ID = "bar"
+
+	changed, removed := StateDrift(prior, current)
+
+	expected := []string{"aws_instance.changed", "module.child.aws_instance.foo"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("bad: %#v", changed)
+	}
+	expected = []string{"aws_instance.removed"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("bad: %#v", removed)
+	}
+}
+
+func TestStateDrift_removedModule(t *testing.T) {
+	prior := &State{
+		Modules: []*ModuleState{
+			&ModuleState{
+				Path: []string{"root", "child"},
+				Resources: map[string]*ResourceState{
+					"aws_instance.foo": &ResourceState{
+						Type:    "aws_instance",
+						Primary: &InstanceState{ID: "foo"},
+					},
+				},
+			},
+		},
+	}
+
+	changed, removed := StateDrift(prior, &State{})
+	if len(changed) != 0 {
+		t.Fatalf("bad: %#v", changed)
+	}
+	expected := []string{"module.child.aws_instance.foo"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("bad: %#v", removed)
+	}
+}
+
+func TestStateDrift_nil(t *testing.T) {
+	changed, removed := StateDrift(nil, nil)
+	if changed != nil || removed != nil {
+		t.Fatalf("bad: %#v %#v", changed, removed)
+	}
+}
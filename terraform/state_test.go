@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -1495,6 +1497,92 @@ func TestReadWriteState(t *testing.T) {
 	}
 }
 
+func TestWriteState_deterministic(t *testing.T) {
+	newState := func(deps []string) *State {
+		return &State{
+			Serial:  3,
+			Lineage: "5d1ad1a1-4027-4665-a908-dbe6adff11d8",
+			Modules: []*ModuleState{
+				&ModuleState{
+					Path:         []string{"root", "child"},
+					Dependencies: deps,
+				},
+				&ModuleState{
+					Path:         rootModulePath,
+					Dependencies: deps,
+					Outputs: map[string]*OutputState{
+						"map": &OutputState{
+							Type: "map",
+							Value: map[string]interface{}{
+								"zed":   "1",
+								"alpha": "2",
+								"mid":   "3",
+							},
+						},
+					},
+					Resources: map[string]*ResourceState{
+						"aws_instance.foo": &ResourceState{
+							Type:         "aws_instance",
+							Dependencies: deps,
+							Primary: &InstanceState{
+								ID: "bar",
+								Attributes: map[string]string{
+									"tags.%":     "3",
+									"tags.zed":   "1",
+									"tags.alpha": "2",
+									"tags.mid":   "3",
+								},
+							},
+						},
+						"aws_instance.bar": &ResourceState{
+							Type: "aws_instance",
+							Primary: &InstanceState{
+								ID: "foo",
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	var expected []byte
+	for i, deps := range [][]string{
+		{"aws_instance.a", "aws_instance.b", "module.c"},
+		{"module.c", "aws_instance.b", "aws_instance.a"},
+		{"aws_instance.b", "module.c", "aws_instance.a"},
+	} {
+		buf := new(bytes.Buffer)
+		if err := WriteState(newState(deps), buf); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("}\n")) {
+			t.Fatalf("%d: missing trailing newline:\n%s", i, buf.Bytes())
+		}
+
+		if expected == nil {
+			expected = buf.Bytes()
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("%d: expected:\n%s\n\ngot:\n%s", i, expected, buf.Bytes())
+		}
+	}
+
+	// Reading the state back and writing it again doesn't change it
+	actual, err := ReadState(bytes.NewReader(expected))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := new(bytes.Buffer)
+	if err := WriteState(actual, buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("expected:\n%s\n\ngot:\n%s", expected, buf.Bytes())
+	}
+}
+
 func TestReadStateNewVersion(t *testing.T) {
 	type out struct {
 		Version int
@@ -1726,3 +1814,66 @@ func TestReadState_prune(t *testing.T) {
 		t.Fatalf("got:\n%#v", actual)
 	}
 }
+
+func TestReadState_futureVersion(t *testing.T) {
+	buf, err := json.Marshal(map[string]interface{}{
+		"version":           StateVersion + 1,
+		"terraform_version": "99.99.99",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	_, err = ReadState(bytes.NewReader(buf))
+	if err == nil {
+		t.Fatal("should error")
+	}
+
+	// Both the version of this binary and the one that wrote the state
+	// are named.
+	for _, v := range []string{SemVersion.String(), "99.99.99"} {
+		if !strings.Contains(err.Error(), v) {
+			t.Fatalf("bad: %s", err)
+		}
+	}
+}
+
+func TestReadState_encoding(t *testing.T) {
+	cases := []struct {
+		File string
+		Hint string
+	}{
+		// A byte order mark is read past
+		{"bom.tfstate", ""},
+
+		{"utf16.tfstate", "UTF-16"},
+		{"crlf-string.tfstate", "carriage return"},
+		{"truncated.tfstate", "cut short"},
+	}
+
+	for _, tc := range cases {
+		f, err := os.Open(filepath.Join(fixtureDir, "state-encoding", tc.File))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		s, err := ReadState(f)
+		f.Close()
+
+		if tc.Hint == "" {
+			if err != nil {
+				t.Fatalf("%s: err: %s", tc.File, err)
+			}
+			if s.Lineage != "b0a4c6e4-6d1f-4e58-a1b4-2b0d5b7a1c01" {
+				t.Fatalf("%s: bad: %#v", tc.File, s)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Fatalf("%s: should error", tc.File)
+		}
+		if !strings.Contains(err.Error(), "Hint: ") || !strings.Contains(err.Error(), tc.Hint) {
+			t.Fatalf("%s: bad: %s", tc.File, err)
+		}
+	}
+}
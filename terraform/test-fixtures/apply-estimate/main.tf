@@ -0,0 +1,20 @@
+resource "aws_instance" "a" {
+    num = "1"
+}
+
+resource "aws_instance" "b" {
+    foo = "${aws_instance.a.num}"
+}
+
+resource "aws_instance" "c" {
+    foo = "${aws_instance.b.foo}"
+}
+
+resource "aws_instance" "d" {
+    count = 2
+    num = "2"
+}
+
+resource "aws_instance" "e" {
+    foo = "${aws_instance.a.num}"
+}
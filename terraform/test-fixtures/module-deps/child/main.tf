@@ -0,0 +1,11 @@
+provider "template" {
+    version = ">= 0.1"
+}
+
+resource "aws_instance" "foo" {
+    provider = "aws.west"
+}
+
+module "grandchild" {
+    source = "./grandchild"
+}
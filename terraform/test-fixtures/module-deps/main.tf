@@ -0,0 +1,16 @@
+provider "aws" {
+    version = "~> 1.0"
+}
+
+provider "aws" {
+    alias = "west"
+    version = ">= 1.0.1"
+}
+
+resource "aws_instance" "foo" {}
+
+resource "null_resource" "foo" {}
+
+module "child" {
+    source = "./child"
+}
@@ -0,0 +1,11 @@
+resource "aws_instance" "foo" {
+    foo = "bar"
+}
+
+output "known" {
+    value = "bar"
+}
+
+output "computed" {
+    value = "${aws_instance.foo.id}"
+}
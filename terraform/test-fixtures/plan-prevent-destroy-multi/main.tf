@@ -0,0 +1,13 @@
+resource "aws_instance" "foo" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "aws_instance" "bar" {
+  lifecycle {
+    prevent_destroy = true
+  }
+}
+
+resource "aws_instance" "baz" {}
@@ -102,6 +102,7 @@ func (n *graphNodeDeposedResource) EvalTree() EvalNode {
 					State:        &state,
 					Index:        n.Index,
 				},
+				&EvalUpdateStateHook{},
 			},
 		},
 	})
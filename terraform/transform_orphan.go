@@ -271,6 +271,7 @@ func (n *graphNodeOrphanResource) managedResourceEvalNodes(info *InstanceInfo) [
 					Dependencies: n.DependentOn(),
 					State:        &state,
 				},
+				&EvalUpdateStateHook{},
 			},
 		},
 	})
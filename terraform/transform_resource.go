@@ -310,6 +310,7 @@ func (n *graphNodeExpandedResource) managedResourceEvalNodes(resource *Resource,
 					Dependencies: n.StateDependencies(),
 					State:        &state,
 				},
+				&EvalUpdateStateHook{},
 			},
 		},
 	})
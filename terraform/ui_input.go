@@ -23,4 +23,8 @@ type InputOpts struct {
 
 	// Default will be the value returned if no data is entered.
 	Default string
+
+	// Sensitive is true if the value being asked for is a secret, such as
+	// a password. Sensitive values must never be saved for later use.
+	Sensitive bool
 }
@@ -64,39 +64,20 @@ func Variables(
 	}
 
 	// Load from env vars
-	for _, v := range os.Environ() {
-		if !strings.HasPrefix(v, VarEnvPrefix) {
-			continue
-		}
-
-		// Strip off the prefix and get the value after the first "="
-		idx := strings.Index(v, "=")
-		k := v[len(VarEnvPrefix):idx]
-		v = v[idx+1:]
-
-		// Override the configuration-default values. Note that *not* finding the variable
-		// in configuration is OK, as we don't want to preclude people from having multiple
-		// sets of TF_VAR_whatever in their environment even if it is a little weird.
-		for _, schema := range m.Config().Variables {
-			if schema.Name != k {
-				continue
-			}
-
-			varType := schema.Type()
-			varVal, err := parseVariableAsHCL(k, v, varType)
-			if err != nil {
+	env, err := VariablesFromEnv(m, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range env {
+		// Maps are merged with their defaults from the configuration
+		if _, ok := result[k].(map[string]interface{}); ok {
+			if err := varSetMap(result, k, v); err != nil {
 				return nil, err
 			}
-
-			switch varType {
-			case config.VariableTypeMap:
-				if err := varSetMap(result, k, varVal); err != nil {
-					return nil, err
-				}
-			default:
-				result[k] = varVal
-			}
+			continue
 		}
+
+		result[k] = v
 	}
 
 	// Load from overrides
@@ -135,6 +116,50 @@ func Variables(
 	return result, nil
 }
 
+// VariablesFromEnv returns the values of the variables of the module that
+// are set in the given environment, such as os.Environ(), with TF_VAR_x
+// variables. The values are parsed as HCL according to the type of the
+// variable. Variables that aren't in the configuration are ignored.
+//
+// Variables uses this to give these values precedence over the defaults
+// in the configuration and below the overrides, such as -var and
+// -var-file. It's exported for programs that build the variables of a
+// context themselves, such as with an environment other than their own.
+func VariablesFromEnv(
+	m *module.Tree, environ []string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	for _, v := range environ {
+		if !strings.HasPrefix(v, VarEnvPrefix) {
+			continue
+		}
+
+		// Strip off the prefix and get the value after the first "="
+		idx := strings.Index(v, "=")
+		if idx < 0 {
+			continue
+		}
+		k := v[len(VarEnvPrefix):idx]
+		v = v[idx+1:]
+
+		// Note that *not* finding the variable in configuration is OK, as we
+		// don't want to preclude people from having multiple sets of
+		// TF_VAR_whatever in their environment even if it is a little weird.
+		for _, schema := range m.Config().Variables {
+			if schema.Name != k {
+				continue
+			}
+
+			varVal, err := parseVariableAsHCL(k, v, schema.Type())
+			if err != nil {
+				return nil, err
+			}
+			result[k] = varVal
+		}
+	}
+
+	return result, nil
+}
+
 // varSetMap sets or merges the map in "v" with the key "k" in the
 // "current" set of variables. This is just a private function to remove
 // duplicate logic in Variables
@@ -164,3 +189,11 @@ func varSetMap(current map[string]interface{}, k string, v interface{}) error {
 	}
 	return nil
 }
+
+// ParseVariableInput parses a value given for a variable of the given type,
+// such as the answer when asking for it, the same way as Context.Input does.
+// This lets a UIInput check an answer and ask again before the context
+// fails on it.
+func ParseVariableInput(name, value string, t config.VariableType) (interface{}, error) {
+	return parseVariableAsHCL(name, value, t)
+}
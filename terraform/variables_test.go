@@ -173,3 +173,34 @@ func TestVariables(t *testing.T) {
 		})
 	}
 }
+
+func TestVariablesFromEnv(t *testing.T) {
+	m := testModule(t, "vars-basic")
+	environ := []string{
+		"TF_VAR_a=bar=baz",
+		`TF_VAR_c={"foo" = "bar"}`,
+		"TF_VAR_unknown=foo",
+		"TF_VARa=foo",
+		"PATH=/bin",
+	}
+
+	actual, err := VariablesFromEnv(m, environ)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]interface{}{
+		"a": "bar=baz",
+		"c": map[string]interface{}{
+			"foo": "bar",
+		},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected: %#v\n\ngot: %#v", expected, actual)
+	}
+
+	// Values that aren't valid for the type of their variable are errors
+	if _, err := VariablesFromEnv(m, []string{"TF_VAR_c=foo"}); err == nil {
+		t.Fatal("should error")
+	}
+}
@@ -2,19 +2,32 @@ package terraform
 
 import (
 	"fmt"
+	"log"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform/config"
 	"github.com/hashicorp/terraform/config/module"
 )
 
-// checkRequiredVersion verifies that any version requirements specified by
+// CheckRequiredVersion verifies that any version requirements specified by
 // the configuration are met.
 //
 // This checks the root module as well as any additional version requirements
-// from child modules.
+// from child modules. Prerelease versions of Terraform aren't checked, since
+// they don't match the constraints meant for releases.
 //
 // This is tested in context_test.go.
+func CheckRequiredVersion(m *module.Tree) error {
+	if SemVersion.Prerelease() != "" {
+		log.Printf(
+			"[WARN] Not checking the required versions of the configuration "+
+				"with prerelease version %s", SemVersion)
+		return nil
+	}
+
+	return checkRequiredVersion(m)
+}
+
 func checkRequiredVersion(m *module.Tree) error {
 	// Check any children
 	for _, c := range m.Children() {
@@ -40,6 +53,12 @@ func checkRequiredVersion(m *module.Tree) error {
 		module = modulePrefixStr(path)
 	}
 
+	// The file that declared the requirement, if it is known
+	file := ""
+	if tf.Path != "" {
+		file = "\n  File: " + tf.Path
+	}
+
 	// Check this version requirement of this module
 	cs, err := version.NewConstraint(tf.RequiredVersion)
 	if err != nil {
@@ -59,10 +78,11 @@ func checkRequiredVersion(m *module.Tree) error {
 				"prior to making any manual changes.\n\n"+
 				"  Module: %s\n"+
 				"  Required version: %s\n"+
-				"  Current version: %s",
+				"  Current version: %s%s",
 			module,
 			tf.RequiredVersion,
-			SemVersion)
+			SemVersion,
+			file)
 	}
 
 	return nil